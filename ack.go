@@ -0,0 +1,136 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ackLogRetention is how long the ack IDs of removed items are remembered,
+// so acknowledging them again can be reported as a duplicate
+const ackLogRetention = 24 * time.Hour
+
+// ackLogTableName returns the name of the table remembering the ack IDs of removed items
+func ackLogTableName(tableName string) string {
+	return fmt.Sprintf("%s_acks", tableName)
+}
+
+// createAckLogTable creates the ack log table for a queue if it doesn't exist
+// Only items deleted on acknowledge are logged, completed items keep their ack ID in the queue table
+// The creation time and attempts of each item are kept for the analytics views
+func createAckLogTable(db execer, tableName string) error {
+	ackLogName := ackLogTableName(tableName)
+
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		ack_id TEXT PRIMARY KEY,
+		acked_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP,
+		attempts INTEGER
+	);
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS created_at TIMESTAMP;
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS attempts INTEGER;
+	`, ackLogName, ackLogName, ackLogName))
+	return err
+}
+
+// Ack marks an item as completed like Acknowledge, but reports why an ack failed
+// Returns ErrDuplicateAck when the item was already acknowledged, which usually means it was
+// delivered twice, and ErrAckNotFound when the ack ID doesn't belong to a processing item
+// Duplicates of items removed on acknowledge are detected for 24 hours
+// With WithAckCoalescing the ack is buffered and always succeeds, see FlushAcks
+func (q *Queue) Ack(ackID string) error {
+	if q.acks != nil && !q.closed.Load() {
+		q.bufferAck(ackID)
+		return nil
+	}
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			return q.ackInTx(tx, ackID)
+		})
+	})
+	if err != nil && !errors.Is(err, ErrDuplicateAck) && !errors.Is(err, ErrAckNotFound) {
+		return fmt.Errorf("failed to acknowledge item: %w", err)
+	}
+
+	return err
+}
+
+// ackInTx acknowledges the processing item with the given ack ID as part of tx
+func (q *Queue) ackInTx(tx *sql.Tx, ackID string) error {
+	now := q.now()
+
+	// Read the item before changing it, DuckDB's RETURNING misses rows already updated by tx
+	var id int64
+	var data []byte
+	var payloadType, offloadKey sql.NullString
+	var createdAt sql.NullTime
+	var attempts int
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT id, %s, payload_type, offload_key, created_at, attempts FROM %s WHERE ack_id = ? AND status = 'processing'", q.dataColumn(), q.tableName),
+		ackID,
+	).Scan(&id, &data, &payloadType, &offloadKey, &createdAt, &attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return q.ackFailure(tx, ackID)
+	}
+	if err != nil {
+		return err
+	}
+	q.count(tx, 0, -1)
+
+	if !q.removeOnComplete {
+		_, err := tx.Exec(
+			fmt.Sprintf("UPDATE %s SET status = 'completed', ack = 1, updated_at = ? WHERE id = ?", q.tableName),
+			now, id,
+		)
+		if err != nil {
+			return err
+		}
+		return q.afterAckInTx(tx, id, data, payloadType.String, offloadKey)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", q.tableName), id); err != nil {
+		return err
+	}
+	q.releasePayload(tx, offloadKey)
+	if err := q.afterAckInTx(tx, id, data, payloadType.String, offloadKey); err != nil {
+		return err
+	}
+
+	// Remember the ack ID of the removed item, the retention maintenance forgets it later
+	_, err = tx.Exec(
+		fmt.Sprintf("INSERT OR REPLACE INTO %s (ack_id, acked_at, created_at, attempts) VALUES (?, ?, ?, ?)", ackLogTableName(q.tableName)),
+		ackID, now, createdAt, attempts,
+	)
+	return err
+}
+
+// ackFailure explains why no processing item matched ackID
+func (q *Queue) ackFailure(tx *sql.Tx, ackID string) error {
+	var completed int
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ack_id = ? AND status = 'completed'", q.tableName),
+		ackID,
+	).Scan(&completed)
+	if err != nil {
+		return err
+	}
+
+	if completed == 0 {
+		err = tx.QueryRow(
+			fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ack_id = ? AND acked_at >= ?", ackLogTableName(q.tableName)),
+			ackID, q.now().Add(-ackLogRetention),
+		).Scan(&completed)
+		if err != nil {
+			return err
+		}
+	}
+
+	if completed > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateAck, ackID)
+	}
+
+	return fmt.Errorf("%w: %s", ErrAckNotFound, ackID)
+}
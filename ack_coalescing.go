@@ -0,0 +1,191 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCoalescedAcks is how many buffered acknowledgements trigger a flush before the interval ends
+const maxCoalescedAcks = 1000
+
+// ackBuffer holds the ack IDs acknowledged since the last flush of a queue created with WithAckCoalescing
+type ackBuffer struct {
+	mu     sync.Mutex
+	ackIDs []string
+	full   chan struct{}
+}
+
+// WithAckCoalescing buffers acknowledgements for up to interval and applies them in a single
+// transaction with one batched UPDATE or DELETE, instead of one write transaction per Ack
+// Ack and Acknowledge then return as soon as the ack ID is buffered, so they can't report
+// ErrDuplicateAck or ErrAckNotFound, and unknown ack IDs are dropped when the buffer is flushed
+// The buffer is flushed every interval, whenever it holds 1000 ack IDs, by FlushAcks and by Close
+// Keep the interval well below the ack timeout, an item whose lease expires first is redelivered
+func WithAckCoalescing(interval time.Duration) Option {
+	return func(q *Queue) {
+		q.ackInterval = interval
+	}
+}
+
+// startAckCoalescing starts flushing the buffered acknowledgements of a queue created with WithAckCoalescing
+func (q *Queue) startAckCoalescing() {
+	if q.ackInterval <= 0 {
+		return
+	}
+
+	q.acks = &ackBuffer{full: make(chan struct{}, 1)}
+	q.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(q.ackInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-q.acks.full:
+			case <-stop:
+				q.FlushAcks()
+				return
+			}
+			q.FlushAcks()
+		}
+	})
+}
+
+// bufferAck adds ackID to the acknowledgements applied by the next flush
+func (q *Queue) bufferAck(ackID string) {
+	q.acks.mu.Lock()
+	q.acks.ackIDs = append(q.acks.ackIDs, ackID)
+	full := len(q.acks.ackIDs) >= maxCoalescedAcks
+	q.acks.mu.Unlock()
+
+	if full {
+		select {
+		case q.acks.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// FlushAcks applies the acknowledgements buffered by WithAckCoalescing right away
+// Ack IDs that no longer belong to a processing item are dropped
+// When the flush fails the ack IDs stay buffered for the next one
+func (q *Queue) FlushAcks() error {
+	if q.acks == nil {
+		return nil
+	}
+
+	q.acks.mu.Lock()
+	ackIDs := q.acks.ackIDs
+	q.acks.ackIDs = nil
+	q.acks.mu.Unlock()
+
+	if len(ackIDs) == 0 {
+		return nil
+	}
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			return q.ackBatchInTx(tx, ackIDs)
+		})
+	})
+	if err != nil {
+		q.acks.mu.Lock()
+		q.acks.ackIDs = append(ackIDs, q.acks.ackIDs...)
+		q.acks.mu.Unlock()
+		return fmt.Errorf("failed to flush acknowledgements: %w", err)
+	}
+
+	return nil
+}
+
+// ackBatchInTx acknowledges the processing items with the given ack IDs as part of tx, see ackInTx
+func (q *Queue) ackBatchInTx(tx *sql.Tx, ackIDs []string) error {
+	args := make([]any, len(ackIDs))
+	for i, ackID := range ackIDs {
+		args[i] = ackID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ackIDs)), ", ")
+
+	// Read the items before changing them, DuckDB's RETURNING misses rows already updated by tx
+	rows, err := tx.Query(
+		fmt.Sprintf("SELECT id, ack_id, %s, payload_type, offload_key, created_at, attempts FROM %s WHERE ack_id IN (%s) AND status = 'processing'", q.dataColumn(), q.tableName, placeholders),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	type ackedItem struct {
+		id          int64
+		ackID       string
+		data        []byte
+		payloadType sql.NullString
+		offloadKey  sql.NullString
+		createdAt   sql.NullTime
+		attempts    int
+	}
+	var items []ackedItem
+	for rows.Next() {
+		var item ackedItem
+		if err := rows.Scan(&item.id, &item.ackID, &item.data, &item.payloadType, &item.offloadKey, &item.createdAt, &item.attempts); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	q.count(tx, 0, -int64(len(items)))
+
+	ids := make([]any, len(items))
+	for i, item := range items {
+		ids[i] = item.id
+	}
+	idPlaceholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	now := q.now()
+	if !q.removeOnComplete {
+		_, err = tx.Exec(
+			fmt.Sprintf("UPDATE %s SET status = 'completed', ack = 1, updated_at = ? WHERE id IN (%s)", q.tableName, idPlaceholders),
+			append([]any{now}, ids...)...,
+		)
+	} else {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", q.tableName, idPlaceholders), ids...)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if q.removeOnComplete {
+			q.releasePayload(tx, item.offloadKey)
+		}
+		if err := q.afterAckInTx(tx, item.id, item.data, item.payloadType.String, item.offloadKey); err != nil {
+			return err
+		}
+	}
+	if !q.removeOnComplete {
+		return nil
+	}
+
+	// Remember the ack IDs of the removed items, the retention maintenance forgets them later
+	values := make([]string, len(items))
+	logArgs := make([]any, 0, 4*len(items))
+	for i, item := range items {
+		values[i] = "(?, ?, ?, ?)"
+		logArgs = append(logArgs, item.ackID, now, item.createdAt, item.attempts)
+	}
+	_, err = tx.Exec(
+		fmt.Sprintf("INSERT OR REPLACE INTO %s (ack_id, acked_at, created_at, attempts) VALUES %s", ackLogTableName(q.tableName), strings.Join(values, ", ")),
+		logArgs...,
+	)
+	return err
+}
@@ -0,0 +1,121 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestAckCoalescing(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_ack_coalescing.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithAckCoalescing(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	processing := func() int {
+		var n int
+		queues.DB().QueryRow("SELECT COUNT(*) FROM test_queue WHERE status = 'processing'").Scan(&n)
+		return n
+	}
+
+	t.Run("Buffered", func(t *testing.T) {
+		var ackIDs []string
+		for _, item := range []string{"a", "b", "c"} {
+			q.Enqueue([]byte(item))
+			_, _, ackID := q.DequeueWithAckId()
+			ackIDs = append(ackIDs, ackID)
+		}
+		for _, ackID := range ackIDs {
+			if !q.Acknowledge(ackID) {
+				t.Errorf("Expected %s to be buffered", ackID)
+			}
+		}
+		if err := q.Ack("unknown"); err != nil {
+			t.Errorf("Expected unknown ack IDs to be buffered too, got %v", err)
+		}
+		if n := processing(); n != 3 {
+			t.Errorf("Expected the acks to wait for the flush, got %d processing items", n)
+		}
+
+		if err := q.FlushAcks(); err != nil {
+			t.Fatalf("FlushAcks failed: %v", err)
+		}
+		if n := processing(); n != 0 {
+			t.Errorf("Expected the flush to acknowledge every item, got %d processing items", n)
+		}
+		if q.ApproxProcessing() != 0 {
+			t.Errorf("Expected no processing items, got %d", q.ApproxProcessing())
+		}
+
+		// The ack log still detects duplicates of removed items
+		q.FlushAcks()
+		var logged int
+		queues.DB().QueryRow("SELECT COUNT(*) FROM test_queue_acks").Scan(&logged)
+		if logged != 3 {
+			t.Errorf("Expected 3 logged ack IDs, got %d", logged)
+		}
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		fast, err := queues.NewQueue("test_interval", WithAckCoalescing(10*time.Millisecond), WithRemoveOnComplete(false))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		fast.Enqueue([]byte("a"))
+		_, _, ackID := fast.DequeueWithAckId()
+		fast.Acknowledge(ackID)
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			var status string
+			queues.DB().QueryRow("SELECT status FROM test_interval").Scan(&status)
+			if status == "completed" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the ack to be flushed within the interval, got status %q", status)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("FlushOnClose", func(t *testing.T) {
+		q.Enqueue([]byte("d"))
+		_, _, ackID := q.DequeueWithAckId()
+		q.Acknowledge(ackID)
+		q.Close()
+		if n := processing(); n != 0 {
+			t.Errorf("Expected Close to flush the buffered ack, got %d processing items", n)
+		}
+
+		// A closed queue acknowledges right away
+		q.Reopen()
+		q.Enqueue([]byte("e"))
+		_, _, ackID = q.DequeueWithAckId()
+		q.Close()
+		if err := q.Ack(ackID); err != nil {
+			t.Errorf("Expected the ack to be applied, got %v", err)
+		}
+		if !errors.Is(q.Ack(ackID), ErrDuplicateAck) {
+			t.Error("Expected a duplicate ack to be reported once the queue is closed")
+		}
+		q.Reopen()
+	})
+
+	t.Run("NegativeInterval", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_negative", WithAckCoalescing(-time.Second)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestAck(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_ack.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	tests := []struct {
+		name             string
+		removeOnComplete bool
+	}{
+		{"RemoveOnComplete", true},
+		{"KeepCompleted", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := queues.NewQueue("test_queue_"+tt.name, WithRemoveOnComplete(tt.removeOnComplete))
+			if err != nil {
+				t.Fatalf("Failed to create queue: %v", err)
+			}
+
+			q.Enqueue([]byte("item"))
+			_, success, ackID := q.DequeueWithAckId()
+			if !success {
+				t.Fatal("DequeueWithAckId failed")
+			}
+
+			if err := q.Ack(ackID); err != nil {
+				t.Fatalf("Ack failed: %v", err)
+			}
+			if err := q.Ack(ackID); !errors.Is(err, ErrDuplicateAck) {
+				t.Errorf("Expected ErrDuplicateAck acking twice, got %v", err)
+			}
+			if err := q.Ack("unknown-ack-id"); !errors.Is(err, ErrAckNotFound) {
+				t.Errorf("Expected ErrAckNotFound for an unknown ack ID, got %v", err)
+			}
+
+			// Acknowledge keeps reporting a repeated ack of a completed item as a success
+			if q.Acknowledge(ackID) != !tt.removeOnComplete {
+				t.Errorf("Unexpected Acknowledge result for a duplicate ack")
+			}
+		})
+	}
+
+	t.Run("NackedAckID", func(t *testing.T) {
+		q, err := queues.NewQueue("test_nack_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue([]byte("item"))
+		_, _, ackID := q.DequeueWithAckId()
+		q.Nack(ackID)
+
+		if err := q.Ack(ackID); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound for a nacked ack ID, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Get returns the message with the given id, regardless of its status
+// Returns the message and a boolean indicating if it was found
+func (q *Queue) Get(id int64) (Message, bool) {
+	row := q.client.QueryRow(
+		fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", q.messageColumns(), q.tableName),
+		id,
+	)
+
+	m, err := scanMessage(row)
+	if err != nil {
+		return Message{}, false
+	}
+	m.Data = q.Redact(m.Data)
+
+	return m, true
+}
+
+// Delete removes the message with the given id, regardless of its status
+// Returns true if a message was deleted, false otherwise
+func (q *Queue) Delete(id int64) bool {
+	if err := q.checkOpen(); err != nil {
+		return err == errDropped
+	}
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			var status string
+			var offloadKey sql.NullString
+			err := tx.QueryRow(
+				fmt.Sprintf("DELETE FROM %s WHERE id = ? RETURNING status, offload_key", q.tableName),
+				id,
+			).Scan(&status, &offloadKey)
+			if err != nil {
+				return err
+			}
+
+			q.releasePayload(tx, offloadKey)
+			q.countStatus(tx, status, -1)
+			return nil
+		})
+	})
+
+	return err == nil
+}
+
+// UpdatePayload replaces the payload of the message with the given id
+// The message keeps its status, position and ack ID
+// Returns true if the message was updated, false otherwise, including when the payload exceeds
+// the size set with WithMaxPayloadSize, or a queue created with WithJSONPayload is given a payload
+// that is not valid JSON
+func (q *Queue) UpdatePayload(id int64, data []byte) bool {
+	if err := q.checkOpen(); err != nil {
+		return err == errDropped
+	}
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			var stored sql.NullString
+			err := tx.QueryRow(fmt.Sprintf("SELECT offload_key FROM %s WHERE id = ?", q.tableName), id).Scan(&stored)
+			if err != nil {
+				return err
+			}
+
+			payload, payloadType, err := q.encode(data)
+			if err != nil {
+				return err
+			}
+			if err := q.checkPayloadSize(payload); err != nil {
+				return err
+			}
+			payload, offloadKey, err := q.offloadPayload(payload)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(
+				fmt.Sprintf("UPDATE %s SET data = ?, payload_type = ?, offload_key = ?, checksum = ?, signature = ?, updated_at = ? WHERE id = ?", q.tableName),
+				payload, payloadType, offloadKey, q.payloadChecksum(payload), q.payloadSignature(payload), q.now(), id,
+			)
+			if err != nil {
+				return err
+			}
+			q.releasePayload(tx, stored)
+
+			return q.writeStructColumns(tx, data, "id = ?", id)
+		})
+	})
+
+	return err == nil
+}
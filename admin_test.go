@@ -0,0 +1,133 @@
+package duckq
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestMessageAdmin(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_admin.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	q.Enqueue([]byte("first"))
+	q.Enqueue([]byte("second"))
+
+	_, success, ackID := q.DequeueWithAckId()
+	if !success {
+		t.Fatal("DequeueWithAckId failed")
+	}
+
+	var processingID int64
+	row := q.client.QueryRow("SELECT id FROM test_queue WHERE ack_id = ?", ackID)
+	if err := row.Scan(&processingID); err != nil {
+		t.Fatalf("Error looking up processing item: %v", err)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		m, found := q.Get(processingID)
+		if !found {
+			t.Fatal("Get failed")
+		}
+		if string(m.Data) != "first" {
+			t.Errorf("Expected 'first', got '%s'", string(m.Data))
+		}
+		if m.Status != "processing" || m.AckID != ackID || m.Attempts != 1 {
+			t.Errorf("Unexpected message state: %+v", m)
+		}
+
+		if _, found := q.Get(-1); found {
+			t.Error("Get with unknown ID should fail")
+		}
+	})
+
+	t.Run("UpdatePayload", func(t *testing.T) {
+		if !q.UpdatePayload(processingID, []byte("fixed")) {
+			t.Fatal("UpdatePayload failed")
+		}
+
+		m, _ := q.Get(processingID)
+		if string(m.Data) != "fixed" {
+			t.Errorf("Expected 'fixed', got '%s'", string(m.Data))
+		}
+		if m.Status != "processing" {
+			t.Errorf("Expected status to be kept, got '%s'", m.Status)
+		}
+
+		if q.UpdatePayload(-1, []byte("nothing")) {
+			t.Error("UpdatePayload with unknown ID should fail")
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if !q.Delete(processingID) {
+			t.Fatal("Delete failed")
+		}
+		if _, found := q.Get(processingID); found {
+			t.Error("Deleted message should not be found")
+		}
+		if q.Acknowledge(ackID) {
+			t.Error("Acknowledge of a deleted message should fail")
+		}
+		if q.Delete(processingID) {
+			t.Error("Deleting twice should fail")
+		}
+
+		if q.Len() != 1 {
+			t.Errorf("Expected queue length 1, got %d", q.Len())
+		}
+		if q.ApproxProcessing() != 0 || q.ApproxLen() != 1 {
+			t.Errorf("Expected the counters to follow the delete, got %d processing and %d pending", q.ApproxProcessing(), q.ApproxLen())
+		}
+	})
+
+	t.Run("OffloadedPayloads", func(t *testing.T) {
+		dir := t.TempDir()
+		offloaded, err := queues.NewQueue("test_admin_offload", WithMaxPayloadSize(64), WithPayloadOffload(NewFileBlobStore(dir), 16))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		blobs := func() int {
+			matches, _ := filepath.Glob(filepath.Join(dir, "test_admin_offload", "*"))
+			return len(matches)
+		}
+		large := bytes.Repeat([]byte("x"), 32)
+
+		id, err := offloaded.EnqueueID(large)
+		if err != nil {
+			t.Fatalf("EnqueueID failed: %v", err)
+		}
+		if offloaded.UpdatePayload(id, bytes.Repeat([]byte("y"), 65)) {
+			t.Error("Expected UpdatePayload to reject a payload over the size limit")
+		}
+		if !offloaded.UpdatePayload(id, bytes.Repeat([]byte("z"), 32)) || blobs() != 1 {
+			t.Errorf("Expected the new payload to replace the offloaded one, got %d blobs", blobs())
+		}
+		if !offloaded.UpdatePayload(id, []byte("small")) || blobs() != 0 {
+			t.Errorf("Expected the replaced payload to be released, got %d blobs", blobs())
+		}
+		if item, _ := offloaded.Dequeue(); string(item.([]byte)) != "small" {
+			t.Errorf("Expected the updated payload, got %v", item)
+		}
+
+		id, _ = offloaded.EnqueueID(large)
+		if !offloaded.Delete(id) || blobs() != 0 {
+			t.Errorf("Expected the deleted item's payload to be released, got %d blobs", blobs())
+		}
+		if offloaded.ApproxLen() != 0 {
+			t.Errorf("Expected the counter to follow the delete, got %d", offloaded.ApproxLen())
+		}
+	})
+}
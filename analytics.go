@@ -0,0 +1,129 @@
+package duckq
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EnableAnalytics creates or refreshes DuckDB views summarizing the queue's throughput:
+//
+//   - <queue>_history: one row per item still in the queue, acknowledged or dead-lettered
+//   - <queue>_enqueues_per_hour: items enqueued per hour
+//   - <queue>_ack_latency_daily: acknowledged items per day with p50, p95 and p99 latency in milliseconds
+//   - <queue>_attempts: how many items needed each number of delivery attempts
+//
+// Acknowledged items are covered while they are retained, for 24 hours in the ack log when
+// removed on acknowledge, or for as long as they are kept with WithRemoveOnComplete(false)
+// The views can be read with Query or any other SQL client
+func (q *Queue) EnableAnalytics() error {
+	history := q.tableName + "_history"
+
+	views := []struct{ name, query string }{
+		{history, fmt.Sprintf(`
+			SELECT created_at, CASE WHEN status = 'completed' THEN updated_at END AS acked_at, attempts, status FROM %s
+			UNION ALL
+			SELECT created_at, acked_at, attempts, 'completed' FROM %s
+			UNION ALL
+			SELECT created_at, NULL, attempts, 'dead_lettered' FROM %s WHERE source_queue = '%s'`,
+			q.tableName, ackLogTableName(q.tableName), q.deadLetterTable(), q.tableName,
+		)},
+		{q.tableName + "_enqueues_per_hour", fmt.Sprintf(`
+			SELECT date_trunc('hour', created_at) AS hour, COUNT(*) AS enqueued
+			FROM %s WHERE created_at IS NOT NULL
+			GROUP BY hour`,
+			history,
+		)},
+		{q.tableName + "_ack_latency_daily", fmt.Sprintf(`
+			SELECT date_trunc('day', acked_at) AS day, COUNT(*) AS acked,
+				quantile_cont(latency_ms, 0.5) AS p50_ms,
+				quantile_cont(latency_ms, 0.95) AS p95_ms,
+				quantile_cont(latency_ms, 0.99) AS p99_ms
+			FROM (SELECT acked_at, date_diff('millisecond', created_at, acked_at) AS latency_ms FROM %s WHERE acked_at IS NOT NULL AND created_at IS NOT NULL)
+			GROUP BY day`,
+			history,
+		)},
+		{q.tableName + "_attempts", fmt.Sprintf(`
+			SELECT COALESCE(attempts, 0) AS attempts, COUNT(*) AS items
+			FROM %s
+			GROUP BY 1`,
+			history,
+		)},
+	}
+
+	for _, view := range views {
+		if _, err := q.client.Exec(fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s", view.name, view.query)); err != nil {
+			return fmt.Errorf("failed to create analytics view %s: %w", view.name, err)
+		}
+	}
+
+	return nil
+}
+
+// HistogramBucket counts the items whose wait time falls within a bucket
+type HistogramBucket struct {
+	// Below is the bucket's exclusive upper bound, zero for the last bucket which has none
+	Below time.Duration
+	Count int64
+}
+
+// WaitTimeHistogram counts how long items waited, in one aggregate query
+// Pending items have waited since they were enqueued, acknowledged items still retained
+// waited from enqueue until their acknowledgement
+// The bounds must be ascending. The result has one bucket per bound, counting waits from the
+// previous bound up to it, and a last bucket for waits at or above the highest bound
+func (q *Queue) WaitTimeHistogram(buckets []time.Duration) ([]HistogramBucket, error) {
+	if !slices.IsSorted(buckets) || len(slices.Compact(slices.Clone(buckets))) != len(buckets) {
+		return nil, fmt.Errorf("histogram buckets must be strictly ascending, got %v", buckets)
+	}
+
+	var counts []string
+	var args []any
+	lower := ""
+	for _, bound := range buckets {
+		condition := "wait_us < ?"
+		if lower != "" {
+			condition = lower + " AND " + condition
+			args = append(args, args[len(args)-1])
+		}
+		counts = append(counts, fmt.Sprintf("COUNT(*) FILTER (WHERE %s)", condition))
+		args = append(args, bound.Microseconds())
+		lower = "wait_us >= ?"
+	}
+	if lower == "" {
+		counts = append(counts, "COUNT(*)")
+	} else {
+		counts = append(counts, "COUNT(*) FILTER (WHERE wait_us >= ?)")
+		args = append(args, buckets[len(buckets)-1].Microseconds())
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM (
+			SELECT date_diff('microsecond', created_at, CASE WHEN status = 'completed' THEN updated_at ELSE CAST(? AS TIMESTAMP) END) AS wait_us
+			FROM %s WHERE status IN ('pending', 'completed')
+			UNION ALL
+			SELECT date_diff('microsecond', created_at, acked_at) FROM %s WHERE created_at IS NOT NULL
+		)`,
+		strings.Join(counts, ", "), q.tableName, ackLogTableName(q.tableName),
+	)
+
+	values := make([]int64, len(counts))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := q.client.QueryRow(query, append(args, q.now())...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to compute wait time histogram: %w", err)
+	}
+
+	histogram := make([]HistogramBucket, len(values))
+	for i, count := range values {
+		histogram[i].Count = count
+		if i < len(buckets) {
+			histogram[i].Below = buckets[i]
+		}
+	}
+
+	return histogram, nil
+}
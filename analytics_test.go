@@ -0,0 +1,133 @@
+package duckq
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestEnableAnalytics(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_analytics.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		q.Enqueue([]byte("job"))
+	}
+
+	// One acknowledged on the first attempt, one after a retry, one dead-lettered, one pending
+	_, _, ackID := q.DequeueWithAckId()
+	q.Acknowledge(ackID)
+	_, _, ackID = q.DequeueWithAckId()
+	q.Nack(ackID)
+	_, _, ackID = q.DequeueWithAckId()
+	q.Acknowledge(ackID)
+	_, _, ackID = q.DequeueWithAckId()
+	q.DeadLetter(ackID, "rejected")
+
+	if err := q.EnableAnalytics(); err != nil {
+		t.Fatalf("EnableAnalytics failed: %v", err)
+	}
+	// Enabling again refreshes the views
+	if err := q.EnableAnalytics(); err != nil {
+		t.Fatalf("EnableAnalytics failed a second time: %v", err)
+	}
+
+	var enqueued int
+	if err := q.client.QueryRow("SELECT SUM(enqueued) FROM test_queue_enqueues_per_hour").Scan(&enqueued); err != nil {
+		t.Fatalf("Failed to read enqueues per hour: %v", err)
+	}
+	if enqueued != 4 {
+		t.Errorf("Expected 4 enqueues, got %d", enqueued)
+	}
+
+	var acked int
+	var p50 float64
+	if err := q.client.QueryRow("SELECT acked, p50_ms FROM test_queue_ack_latency_daily").Scan(&acked, &p50); err != nil {
+		t.Fatalf("Failed to read ack latency: %v", err)
+	}
+	if acked != 2 || p50 < 0 {
+		t.Errorf("Expected 2 acknowledged items with a latency, got %d and %v", acked, p50)
+	}
+
+	rows, err := q.Query(context.Background(), "SELECT attempts, items FROM test_queue_attempts ORDER BY attempts")
+	if err != nil {
+		t.Fatalf("Failed to query attempts: %v", err)
+	}
+	defer rows.Close()
+
+	distribution := make(map[int]int)
+	for rows.Next() {
+		var attempts, items int
+		if err := rows.Scan(&attempts, &items); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		distribution[attempts] = items
+	}
+	if distribution[0] != 1 || distribution[1] != 2 || distribution[2] != 1 {
+		t.Errorf("Unexpected attempts distribution %v", distribution)
+	}
+}
+
+func TestWaitTimeHistogram(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_wait_time_histogram.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q, err := queues.NewQueue("test_queue", WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	// Acknowledged after 2 seconds, then pending for 30 seconds and 5 minutes
+	q.Enqueue([]byte("acked"))
+	clock.Advance(2 * time.Second)
+	_, _, ackID := q.DequeueWithAckId()
+	q.Acknowledge(ackID)
+
+	q.Enqueue([]byte("old"))
+	clock.Advance(5*time.Minute - 30*time.Second)
+	q.Enqueue([]byte("recent"))
+	clock.Advance(30 * time.Second)
+
+	histogram, err := q.WaitTimeHistogram([]time.Duration{time.Second, 10 * time.Second, time.Minute})
+	if err != nil {
+		t.Fatalf("WaitTimeHistogram failed: %v", err)
+	}
+
+	expected := []HistogramBucket{
+		{Below: time.Second, Count: 0},
+		{Below: 10 * time.Second, Count: 1},
+		{Below: time.Minute, Count: 1},
+		{Below: 0, Count: 1},
+	}
+	if len(histogram) != len(expected) {
+		t.Fatalf("Expected %d buckets, got %v", len(expected), histogram)
+	}
+	for i := range expected {
+		if histogram[i] != expected[i] {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, expected[i], histogram[i])
+		}
+	}
+
+	if _, err := q.WaitTimeHistogram([]time.Duration{time.Minute, time.Second}); err == nil {
+		t.Error("Expected descending buckets to be rejected")
+	}
+}
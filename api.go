@@ -0,0 +1,28 @@
+package duckq
+
+// QueueAPI is the set of queue operations that don't depend on DuckDB
+// It is satisfied by *Queue and *DelayedQueue, and by the in-memory queue in the duckqtest
+// package, so consumers can be unit-tested without a database file
+// Operations tied to DuckDB, such as EnqueueInTx and Search, are only available on *Queue
+type QueueAPI interface {
+	Enqueue(item any) bool
+	Dequeue() (any, bool)
+	DequeueWithAckId() (any, bool, string)
+	Acknowledge(ackID string) bool
+	Ack(ackID string) error
+	Nack(ackID string) bool
+	DeadLetter(ackID string, reason string) bool
+	ReplayDeadLetters(n int, resetAttempts bool) (int, error)
+	Get(id int64) (Message, bool)
+	Delete(id int64) bool
+	Len() int
+	Values() []any
+	Messages(filters ...Filter) ([]Message, error)
+	Purge()
+	Close() error
+}
+
+var (
+	_ QueueAPI = (*Queue)(nil)
+	_ QueueAPI = (*DelayedQueue)(nil)
+)
@@ -0,0 +1,44 @@
+//go:build duckdb_arrow
+
+package duckq
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	duckdb "github.com/marcboeker/go-duckdb/v2"
+)
+
+// MessagesArrow returns the messages matching all given filters as Apache Arrow record batches,
+// in dequeue order, with the same columns as Messages
+// The batches are read in full before returning, and the caller must release the reader
+// It needs go-duckdb's Arrow interface, so it is only built with the duckdb_arrow build tag
+func (q *Queue) MessagesArrow(filters ...Filter) (array.RecordReader, error) {
+	where, args := whereFilters(filters...)
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", q.messageColumns(), q.tableName, where, q.dequeueOrder())
+
+	ctx := context.Background()
+	conn, err := q.client.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer conn.Close()
+
+	var reader array.RecordReader
+	err = conn.Raw(func(driverConn any) error {
+		a, err := duckdb.NewArrowFromConn(driverConn.(driver.Conn))
+		if err != nil {
+			return err
+		}
+
+		reader, err = a.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	return reader, nil
+}
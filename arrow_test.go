@@ -0,0 +1,56 @@
+//go:build duckdb_arrow
+
+package duckq
+
+import (
+	"os"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestMessagesArrow(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_messages_arrow.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("first"))
+	q.Enqueue([]byte("second"))
+	q.Enqueue([]byte("third"))
+	q.DequeueWithAckId()
+
+	reader, err := q.MessagesArrow(Filter{Statuses: []string{"pending"}})
+	if err != nil {
+		t.Fatalf("MessagesArrow failed: %v", err)
+	}
+	defer reader.Release()
+
+	if got := reader.Schema().Field(1).Name; got != "data" {
+		t.Errorf("Expected the data column second, got %s", got)
+	}
+
+	var payloads []string
+	for reader.Next() {
+		record := reader.Record()
+		data := record.Column(1).(*array.Binary)
+		for i := 0; i < data.Len(); i++ {
+			payloads = append(payloads, string(data.Value(i)))
+		}
+	}
+	if err := reader.Err(); err != nil {
+		t.Fatalf("Reading record batches failed: %v", err)
+	}
+
+	if len(payloads) != 2 || payloads[0] != "second" || payloads[1] != "third" {
+		t.Errorf("Expected the pending payloads in dequeue order, got %v", payloads)
+	}
+}
@@ -0,0 +1,35 @@
+package duckq
+
+// EnqueueAsync adds an item to the queue in the background like EnqueueID and returns right away,
+// so latency-sensitive callers don't wait for DuckDB to commit
+// The returned channel receives nil once the item is stored, or the error that rejected it, and
+// is closed afterwards, so it can be ignored, waited on, or drained later
+// Items enqueued concurrently may be stored in any order, and Close waits for pending enqueues
+func (q *Queue) EnqueueAsync(item any) <-chan error {
+	return q.goEnqueue(func() error {
+		_, err := q.EnqueueID(item)
+		return err
+	})
+}
+
+// EnqueueAsync adds an item with a specified priority in the background, see Queue.EnqueueAsync
+func (pq *PriorityQueue) EnqueueAsync(item any, priority int) <-chan error {
+	return pq.goEnqueue(func() error {
+		_, err := pq.EnqueueID(item, priority)
+		return err
+	})
+}
+
+// goEnqueue runs enqueue in a goroutine tracked by Close and reports its error on the returned channel
+func (q *Queue) goEnqueue(enqueue func() error) <-chan error {
+	errs := make(chan error, 1)
+
+	q.asyncEnqueues.Add(1)
+	go func() {
+		defer q.asyncEnqueues.Done()
+		errs <- enqueue()
+		close(errs)
+	}()
+
+	return errs
+}
@@ -0,0 +1,73 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestEnqueueAsync(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_enqueue_async.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithMaxPayloadSize(8))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Stored", func(t *testing.T) {
+		var results []<-chan error
+		for _, item := range []string{"a", "b", "c"} {
+			results = append(results, q.EnqueueAsync([]byte(item)))
+		}
+		for _, errs := range results {
+			if err := <-errs; err != nil {
+				t.Errorf("Expected the item to be stored, got %v", err)
+			}
+			if _, open := <-errs; open {
+				t.Error("Expected the channel to be closed after the result")
+			}
+		}
+		if q.Len() != 3 {
+			t.Errorf("Expected 3 items, got %d", q.Len())
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		if err := <-q.EnqueueAsync([]byte("far too large")); !errors.Is(err, ErrPayloadTooLarge) {
+			t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		<-pq.EnqueueAsync([]byte("low"), 5)
+		<-pq.EnqueueAsync([]byte("high"), 1)
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "high" {
+			t.Errorf("Expected the high priority item first, got %v", item)
+		}
+	})
+
+	t.Run("CloseWaits", func(t *testing.T) {
+		q.Purge()
+		for i := 0; i < 10; i++ {
+			q.EnqueueAsync([]byte("x"))
+		}
+		q.Close()
+		defer q.Reopen()
+		if q.Len() != 10 {
+			t.Errorf("Expected Close to wait for pending enqueues, got %d items", q.Len())
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package duckq
+
+import "sync"
+
+// backpressureSignal tells a producer whether a queue's pending depth reached a threshold
+type backpressureSignal struct {
+	threshold int64
+	over      bool
+	ch        chan bool
+}
+
+// backpressure holds the signals handed out by Backpressure
+type backpressure struct {
+	mu      sync.Mutex
+	signals []*backpressureSignal
+}
+
+// Backpressure returns a channel that receives true once the queue holds threshold or more
+// pending items, and false once it drops below threshold again, so producers can shed load
+// before the queue balloons
+// The channel first receives the current state and then only its changes, and never blocks the
+// queue: a change undone before the receiver takes it is withdrawn, so the last value received
+// is always the current state
+// The depth is read from the counter behind ApproxLen, so items enqueued or consumed by other
+// processes are only noticed when the counter is reconciled
+func (q *Queue) Backpressure(threshold int) <-chan bool {
+	signal := &backpressureSignal{threshold: int64(threshold), ch: make(chan bool, 1)}
+
+	q.pressure.mu.Lock()
+	defer q.pressure.mu.Unlock()
+
+	signal.over = q.approxPending.Load() >= signal.threshold
+	signal.ch <- signal.over
+	q.pressure.signals = append(q.pressure.signals, signal)
+
+	return signal.ch
+}
+
+// signalBackpressure notifies the Backpressure channels whose threshold the pending depth crossed
+func (q *Queue) signalBackpressure() {
+	q.pressure.mu.Lock()
+	defer q.pressure.mu.Unlock()
+
+	pending := q.approxPending.Load()
+	for _, signal := range q.pressure.signals {
+		over := pending >= signal.threshold
+		if over == signal.over {
+			continue
+		}
+		signal.over = over
+
+		// A state the receiver didn't take yet is withdrawn, the receiver still knows the current one
+		select {
+		case <-signal.ch:
+		default:
+			signal.ch <- over
+		}
+	}
+}
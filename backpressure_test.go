@@ -0,0 +1,110 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestBackpressure(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_backpressure.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("first"))
+
+	signal := q.Backpressure(3)
+	receive := func() (bool, bool) {
+		select {
+		case over := <-signal:
+			return over, true
+		case <-time.After(100 * time.Millisecond):
+			return false, false
+		}
+	}
+
+	t.Run("InitialState", func(t *testing.T) {
+		if over, ok := receive(); !ok || over {
+			t.Errorf("Expected the initial state to be false, got %v (received %v)", over, ok)
+		}
+	})
+
+	t.Run("Crossing", func(t *testing.T) {
+		q.Enqueue([]byte("second"))
+		if _, ok := receive(); ok {
+			t.Error("Expected no signal below the threshold")
+		}
+
+		q.Enqueue([]byte("third"))
+		q.Enqueue([]byte("fourth"))
+		if over, ok := receive(); !ok || !over {
+			t.Errorf("Expected true once the threshold is reached, got %v (received %v)", over, ok)
+		}
+		if _, ok := receive(); ok {
+			t.Error("Expected a single signal per crossing")
+		}
+	})
+
+	t.Run("Uncrossing", func(t *testing.T) {
+		q.Dequeue()
+		q.Dequeue()
+		if over, ok := receive(); !ok || over {
+			t.Errorf("Expected false below the threshold, got %v (received %v)", over, ok)
+		}
+	})
+
+	t.Run("LatestState", func(t *testing.T) {
+		// A crossing undone before the receiver took it is withdrawn
+		q.Enqueue([]byte("fifth"))
+		q.Purge()
+		if _, ok := receive(); ok {
+			t.Error("Expected the undone crossing to be withdrawn")
+		}
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		q.Enqueue([]byte("sixth"))
+		q.Enqueue([]byte("seventh"))
+		_, _, ackID := q.DequeueWithAckId()
+		q.DeadLetter(ackID, "broken")
+		q.Enqueue([]byte("eighth"))
+		if _, ok := receive(); ok {
+			t.Error("Expected no signal below the threshold")
+		}
+
+		if _, err := q.ReplayDeadLetters(0, false); err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+		if over, ok := receive(); !ok || !over {
+			t.Errorf("Expected true once the replay reaches the threshold, got %v (received %v)", over, ok)
+		}
+
+		q.Purge()
+		receive()
+	})
+
+	t.Run("Reconciled", func(t *testing.T) {
+		// Items added outside the queue are noticed once the counter is reconciled
+		for i := 0; i < 3; i++ {
+			if _, err := queues.DB().Exec("INSERT INTO test_queue (data, status, ack, created_at, updated_at) VALUES ('raw'::BLOB, 'pending', 0, now(), now())"); err != nil {
+				t.Fatalf("Failed to insert items: %v", err)
+			}
+		}
+		if err := queues.RunMaintenance(MaintenanceRetention); err != nil {
+			t.Fatalf("RunMaintenance failed: %v", err)
+		}
+		if over, ok := receive(); !ok || !over {
+			t.Errorf("Expected true after reconciliation, got %v (received %v)", over, ok)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+// Package kafka bridges duckq queues and Kafka topics
+//
+// Pump is a sink that moves messages from a duckq queue into a Kafka topic, and
+// Ingest is a source that moves messages from a topic into a duckq queue. Both map
+// acknowledgments across the boundary so a message is only removed from one side
+// once the other side has durably accepted it, which makes them suitable for
+// gradual migrations between the two systems
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	// ErrEnqueueFailed is returned when the duckq queue rejects a message read from Kafka
+	ErrEnqueueFailed = errors.New("failed to enqueue into duckq queue")
+
+	// ErrUnencodable is returned when a dequeued item cannot be turned into a message value
+	ErrUnencodable = errors.New("item cannot be encoded")
+)
+
+// Enqueuer is the part of a duckq queue used by Ingest, satisfied by *duckq.Queue
+// EnqueueID stores the item before it returns, also on queues created with WithMicroBatching,
+// and returns 0 when a queue closed with CloseDrop drops it
+type Enqueuer interface {
+	EnqueueID(item any) (int64, error)
+}
+
+// Dequeuer is the part of a duckq queue used by Pump, satisfied by *duckq.Queue and *duckq.PriorityQueue
+type Dequeuer interface {
+	DequeueWithAckId() (any, bool, string)
+	Acknowledge(ackID string) bool
+	Nack(ackID string) bool
+}
+
+// Encoder turns dequeued items back into the bytes the queue stored, satisfied by *duckq.Queue and *duckq.PriorityQueue
+// Pump uses it when the queue implements it, so items decoded by a Codec or TypeRegistry are forwarded as stored
+type Encoder interface {
+	Encode(item any) ([]byte, error)
+}
+
+// DeadLetterer is implemented by queues that can set aside items Pump cannot forward,
+// satisfied by *duckq.Queue and *duckq.PriorityQueue
+type DeadLetterer interface {
+	DeadLetter(ackID string, reason string) bool
+}
+
+// MessageWriter writes messages to a topic, satisfied by *kafka.Writer
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// MessageReader reads and commits messages from a topic, satisfied by *kafka.Reader
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Pump moves messages from the queue into Kafka until ctx is done
+// Each message is acknowledged on the queue once the writer has accepted it; when the
+// write fails the message is nacked back into the queue and the error is returned
+// An item that doesn't encode to bytes is dead-lettered when the queue implements DeadLetterer,
+// otherwise it is nacked and an ErrUnencodable error is returned
+// An empty queue is polled again after pollInterval
+func Pump(ctx context.Context, queue Dequeuer, writer MessageWriter, pollInterval time.Duration) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		item, ok, ackID := queue.DequeueWithAckId()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		payload, err := encode(queue, item)
+		if err != nil {
+			if dl, ok := queue.(DeadLetterer); ok && dl.DeadLetter(ackID, err.Error()) {
+				continue
+			}
+			queue.Nack(ackID)
+			return err
+		}
+
+		if err := writer.WriteMessages(ctx, kafka.Message{Value: payload}); err != nil {
+			queue.Nack(ackID)
+			return fmt.Errorf("failed to write message: %w", err)
+		}
+
+		queue.Acknowledge(ackID)
+	}
+}
+
+// encode returns the message value for a dequeued item
+// Without an Encoder only []byte and string items can be forwarded
+func encode(queue Dequeuer, item any) ([]byte, error) {
+	if encoder, ok := queue.(Encoder); ok {
+		payload, err := encoder.Encode(item)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnencodable, err)
+		}
+		return payload, nil
+	}
+
+	switch item := item.(type) {
+	case []byte:
+		return item, nil
+	case string:
+		return []byte(item), nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported item type %T", ErrUnencodable, item)
+}
+
+// Ingest moves messages from Kafka into the queue until ctx is done
+// A message's offset is committed only after the queue stored it, so a message the queue rejects,
+// fails to store or drops because it was closed is redelivered by Kafka after a restart.
+// In that case ErrEnqueueFailed is returned
+// Delivery is at-least-once: a message enqueued just before a failed commit is enqueued again on redelivery
+func Ingest(ctx context.Context, reader MessageReader, queue Enqueuer) error {
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to fetch message: %w", err)
+		}
+
+		id, err := queue.EnqueueID(msg.Value)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrEnqueueFailed, err)
+		}
+		if id == 0 {
+			return ErrEnqueueFailed
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to commit offset %d: %w", msg.Offset, err)
+		}
+	}
+}
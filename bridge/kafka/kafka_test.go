@@ -0,0 +1,259 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goptics/duckq"
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeWriter struct {
+	written []kafka.Message
+	err     error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+type fakeReader struct {
+	msgs      []kafka.Message
+	committed []int64
+	onCommit  func()
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+
+	msg := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return msg, nil
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if r.onCommit != nil {
+		r.onCommit()
+	}
+	for _, msg := range msgs {
+		r.committed = append(r.committed, msg.Offset)
+	}
+	return nil
+}
+
+func TestPump(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_kafka_pump.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := duckq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("WritesAndAcknowledges", func(t *testing.T) {
+		q.Enqueue([]byte("a"))
+		q.Enqueue([]byte("b"))
+
+		writer := &fakeWriter{}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		if err := Pump(ctx, q, writer, 10*time.Millisecond); err != nil {
+			t.Fatalf("Pump failed: %v", err)
+		}
+
+		if len(writer.written) != 2 || string(writer.written[0].Value) != "a" {
+			t.Errorf("Expected 'a' and 'b' to be written, got %v", writer.written)
+		}
+		if q.Len() != 0 {
+			t.Errorf("Expected empty queue, got length %d", q.Len())
+		}
+	})
+
+	t.Run("NacksOnWriteFailure", func(t *testing.T) {
+		q.Enqueue([]byte("c"))
+		writeErr := errors.New("broker unavailable")
+
+		err := Pump(context.Background(), q, &fakeWriter{err: writeErr}, 10*time.Millisecond)
+		if !errors.Is(err, writeErr) {
+			t.Errorf("Expected write error, got %v", err)
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the message to be back in the queue, got length %d", q.Len())
+		}
+		q.Purge()
+	})
+
+	t.Run("ReencodesCodecItems", func(t *testing.T) {
+		cq, err := queues.NewQueue("test_codec_queue", duckq.WithCodec(duckq.JSONCodec{}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		cq.Enqueue(map[string]any{"id": 1})
+		cq.Enqueue("text")
+
+		writer := &fakeWriter{}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		if err := Pump(ctx, cq, writer, 10*time.Millisecond); err != nil {
+			t.Fatalf("Pump failed: %v", err)
+		}
+
+		if len(writer.written) != 2 || string(writer.written[0].Value) != `{"id":1}` || string(writer.written[1].Value) != "text" {
+			t.Errorf("Expected the stored payloads to be written, got %v", writer.written)
+		}
+	})
+
+	t.Run("NeverAcksUnencodableItems", func(t *testing.T) {
+		queue := &fakeQueue{item: 42}
+		err := Pump(context.Background(), queue, &fakeWriter{}, 10*time.Millisecond)
+		if !errors.Is(err, ErrUnencodable) {
+			t.Errorf("Expected ErrUnencodable, got %v", err)
+		}
+		if queue.acked || !queue.nacked {
+			t.Errorf("Expected the item to be nacked, got acked=%v nacked=%v", queue.acked, queue.nacked)
+		}
+
+		writer := &fakeWriter{}
+		dl := &fakeDeadLetterQueue{fakeQueue: fakeQueue{item: 42}}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := Pump(ctx, dl, writer, 10*time.Millisecond); err != nil {
+			t.Fatalf("Pump failed: %v", err)
+		}
+		if dl.reason == "" || dl.acked || len(writer.written) != 0 {
+			t.Errorf("Expected the item to be dead-lettered without writing, got reason %q", dl.reason)
+		}
+	})
+}
+
+// fakeQueue hands out a single item that only a real queue could encode
+type fakeQueue struct {
+	item   any
+	taken  bool
+	acked  bool
+	nacked bool
+}
+
+func (q *fakeQueue) DequeueWithAckId() (any, bool, string) {
+	if q.taken {
+		return nil, false, ""
+	}
+
+	q.taken = true
+	return q.item, true, "ack"
+}
+
+func (q *fakeQueue) Acknowledge(ackID string) bool {
+	q.acked = true
+	return true
+}
+
+func (q *fakeQueue) Nack(ackID string) bool {
+	q.nacked = true
+	return true
+}
+
+// fakeDeadLetterQueue is a fakeQueue that records dead-lettered items
+type fakeDeadLetterQueue struct {
+	fakeQueue
+	reason string
+}
+
+func (q *fakeDeadLetterQueue) DeadLetter(ackID string, reason string) bool {
+	q.reason = reason
+	return true
+}
+
+func TestIngest(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_kafka_ingest.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := duckq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	reader := &fakeReader{msgs: []kafka.Message{{Value: []byte("a"), Offset: 7}, {Value: []byte("b"), Offset: 8}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := Ingest(ctx, reader, q); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Expected queue length 2, got %d", q.Len())
+	}
+	if len(reader.committed) != 2 || reader.committed[1] != 8 {
+		t.Errorf("Expected offsets 7 and 8 to be committed, got %v", reader.committed)
+	}
+
+	// Offsets are left uncommitted when the queue rejects a message
+	q.Close()
+	rejected := &fakeReader{msgs: []kafka.Message{{Value: []byte("c"), Offset: 9}}}
+	if err := Ingest(context.Background(), rejected, q); !errors.Is(err, ErrEnqueueFailed) {
+		t.Errorf("Expected ErrEnqueueFailed, got %v", err)
+	}
+	if len(rejected.committed) != 0 {
+		t.Errorf("Expected no committed offsets, got %v", rejected.committed)
+	}
+
+	// Messages dropped by a queue closed with CloseDrop are left uncommitted too
+	dropping, err := queues.NewQueue("test_dropping_queue", duckq.WithCloseBehavior(duckq.CloseDrop))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	dropping.Close()
+	dropped := &fakeReader{msgs: []kafka.Message{{Value: []byte("c"), Offset: 9}}}
+	if err := Ingest(context.Background(), dropped, dropping); !errors.Is(err, ErrEnqueueFailed) {
+		t.Errorf("Expected ErrEnqueueFailed, got %v", err)
+	}
+	if len(dropped.committed) != 0 {
+		t.Errorf("Expected no committed offsets, got %v", dropped.committed)
+	}
+
+	// Messages are stored before their offsets are committed on micro-batching queues too
+	batched, err := queues.NewQueue("test_batched_queue", duckq.WithMicroBatching(100, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	reader = &fakeReader{msgs: []kafka.Message{{Value: []byte("d"), Offset: 10}}}
+	reader.onCommit = func() {
+		var rows int
+		if err := queues.DB().QueryRow("SELECT COUNT(*) FROM test_batched_queue").Scan(&rows); err != nil || rows != 1 {
+			t.Errorf("Expected the message to be stored before committing, got %d rows (%v)", rows, err)
+		}
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := Ingest(ctx, reader, batched); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if len(reader.committed) != 1 {
+		t.Errorf("Expected offset 10 to be committed, got %v", reader.committed)
+	}
+}
@@ -0,0 +1,101 @@
+// Package nats bridges duckq queues and NATS JetStream
+//
+// A Mirror enqueues into a local duckq queue and publishes the same payload to a
+// JetStream subject, while Ingest drains a JetStream consumer into a duckq queue.
+// Together they let DuckDB-local durability feed or drain a wider messaging fabric
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ErrEnqueueFailed is returned when the local duckq queue rejects a payload
+var ErrEnqueueFailed = errors.New("failed to enqueue into duckq queue")
+
+// Enqueuer is the part of a duckq queue used by the bridge, satisfied by *duckq.Queue
+// EnqueueID stores the item before it returns, also on queues created with WithMicroBatching,
+// and returns 0 when a queue closed with CloseDrop drops it
+type Enqueuer interface {
+	EnqueueID(item any) (int64, error)
+}
+
+// enqueue stores the payload in the queue, failing with ErrEnqueueFailed when the queue rejects or drops it
+func enqueue(queue Enqueuer, payload []byte) error {
+	id, err := queue.EnqueueID(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEnqueueFailed, err)
+	}
+	if id == 0 {
+		return ErrEnqueueFailed
+	}
+
+	return nil
+}
+
+// Publisher publishes payloads to JetStream, satisfied by jetstream.JetStream
+type Publisher interface {
+	Publish(ctx context.Context, subject string, payload []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// Subscriber delivers JetStream messages to a handler, satisfied by jetstream.Consumer
+type Subscriber interface {
+	Consume(handler jetstream.MessageHandler, opts ...jetstream.PullConsumeOpt) (jetstream.ConsumeContext, error)
+}
+
+// Mirror enqueues payloads into a duckq queue and mirrors them to a JetStream subject
+type Mirror struct {
+	queue     Enqueuer
+	publisher Publisher
+	subject   string
+}
+
+// NewMirror creates a Mirror that enqueues into queue and publishes to subject
+func NewMirror(queue Enqueuer, publisher Publisher, subject string) *Mirror {
+	return &Mirror{
+		queue:     queue,
+		publisher: publisher,
+		subject:   subject,
+	}
+}
+
+// Enqueue adds the payload to the local queue and then publishes it to the subject
+// The local queue is written first so the payload is durable even when publishing fails;
+// in that case the returned error wraps the publish error and the payload stays enqueued
+// A payload the queue rejects or drops is not published and ErrEnqueueFailed is returned
+func (m *Mirror) Enqueue(ctx context.Context, payload []byte) error {
+	if err := enqueue(m.queue, payload); err != nil {
+		return err
+	}
+
+	if _, err := m.publisher.Publish(ctx, m.subject, payload); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", m.subject, err)
+	}
+
+	return nil
+}
+
+// Ingest consumes messages from the subscriber into the queue until ctx is done
+// A message is acknowledged on JetStream once the queue stored it, and negatively acknowledged
+// for redelivery when the queue rejects it or drops it because it was closed
+func Ingest(ctx context.Context, subscriber Subscriber, queue Enqueuer, opts ...jetstream.PullConsumeOpt) error {
+	consumeCtx, err := subscriber.Consume(func(msg jetstream.Msg) {
+		if enqueue(queue, msg.Data()) == nil {
+			msg.Ack()
+			return
+		}
+
+		msg.Nak()
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	<-ctx.Done()
+	consumeCtx.Stop()
+
+	return nil
+}
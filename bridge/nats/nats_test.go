@@ -0,0 +1,202 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goptics/duckq"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type fakePublisher struct {
+	subjects []string
+	payloads [][]byte
+	err      error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, subject string, payload []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	p.subjects = append(p.subjects, subject)
+	p.payloads = append(p.payloads, payload)
+	return &jetstream.PubAck{}, nil
+}
+
+type fakeMsg struct {
+	jetstream.Msg
+	data  []byte
+	acked bool
+	naked bool
+}
+
+func (m *fakeMsg) Data() []byte { return m.data }
+func (m *fakeMsg) Ack() error   { m.acked = true; return nil }
+func (m *fakeMsg) Nak() error   { m.naked = true; return nil }
+
+type fakeConsumeContext struct {
+	jetstream.ConsumeContext
+	stopped bool
+}
+
+func (c *fakeConsumeContext) Stop() { c.stopped = true }
+
+type fakeSubscriber struct {
+	msgs    []*fakeMsg
+	context *fakeConsumeContext
+}
+
+func (s *fakeSubscriber) Consume(handler jetstream.MessageHandler, opts ...jetstream.PullConsumeOpt) (jetstream.ConsumeContext, error) {
+	for _, msg := range s.msgs {
+		handler(msg)
+	}
+
+	s.context = &fakeConsumeContext{}
+	return s.context, nil
+}
+
+func TestMirror(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_nats_mirror.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := duckq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("PublishesAfterEnqueue", func(t *testing.T) {
+		publisher := &fakePublisher{}
+		mirror := NewMirror(q, publisher, "orders.created")
+
+		if err := mirror.Enqueue(context.Background(), []byte("order-1")); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		if q.Len() != 1 {
+			t.Errorf("Expected queue length 1, got %d", q.Len())
+		}
+		if len(publisher.payloads) != 1 || string(publisher.payloads[0]) != "order-1" {
+			t.Errorf("Expected 'order-1' to be published, got %v", publisher.payloads)
+		}
+		if publisher.subjects[0] != "orders.created" {
+			t.Errorf("Expected subject 'orders.created', got '%s'", publisher.subjects[0])
+		}
+	})
+
+	t.Run("DroppedNotPublished", func(t *testing.T) {
+		dropping, err := queues.NewQueue("test_dropping_queue", duckq.WithCloseBehavior(duckq.CloseDrop))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		dropping.Close()
+		publisher := &fakePublisher{}
+		mirror := NewMirror(dropping, publisher, "orders.created")
+
+		if err := mirror.Enqueue(context.Background(), []byte("order-0")); !errors.Is(err, ErrEnqueueFailed) {
+			t.Errorf("Expected ErrEnqueueFailed, got %v", err)
+		}
+		if len(publisher.payloads) != 0 {
+			t.Errorf("Expected the dropped payload not to be published, got %v", publisher.payloads)
+		}
+	})
+
+	t.Run("KeepsLocalCopyWhenPublishFails", func(t *testing.T) {
+		q.Purge()
+		publisherErr := errors.New("no responders")
+		mirror := NewMirror(q, &fakePublisher{err: publisherErr}, "orders.created")
+
+		err := mirror.Enqueue(context.Background(), []byte("order-2"))
+		if !errors.Is(err, publisherErr) {
+			t.Errorf("Expected publish error, got %v", err)
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the payload to stay enqueued, got length %d", q.Len())
+		}
+	})
+}
+
+func TestIngest(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_nats_ingest.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := duckq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	subscriber := &fakeSubscriber{msgs: []*fakeMsg{{data: []byte("a")}, {data: []byte("b")}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Ingest(ctx, subscriber, q); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Expected queue length 2, got %d", q.Len())
+	}
+	for _, msg := range subscriber.msgs {
+		if !msg.acked {
+			t.Errorf("Expected message '%s' to be acknowledged", string(msg.data))
+		}
+	}
+	if !subscriber.context.stopped {
+		t.Error("Expected consuming to stop once the context is done")
+	}
+
+	// Messages rejected by a closed queue are handed back for redelivery
+	q.Close()
+	rejected := &fakeSubscriber{msgs: []*fakeMsg{{data: []byte("c")}}}
+	if err := Ingest(ctx, rejected, q); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if !rejected.msgs[0].naked {
+		t.Error("Expected rejected message to be negatively acknowledged")
+	}
+
+	// Messages dropped by a queue closed with CloseDrop are handed back too
+	dropping, err := queues.NewQueue("test_dropping_queue", duckq.WithCloseBehavior(duckq.CloseDrop))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	dropping.Close()
+	dropped := &fakeSubscriber{msgs: []*fakeMsg{{data: []byte("c")}}}
+	if err := Ingest(ctx, dropped, dropping); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if dropped.msgs[0].acked || !dropped.msgs[0].naked {
+		t.Error("Expected dropped message to be negatively acknowledged")
+	}
+
+	// Messages are stored before they are acknowledged on micro-batching queues too
+	batched, err := queues.NewQueue("test_batched_queue", duckq.WithMicroBatching(100, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	subscriber = &fakeSubscriber{msgs: []*fakeMsg{{data: []byte("d")}}}
+	if err := Ingest(ctx, subscriber, batched); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	var rows int
+	if err := queues.DB().QueryRow("SELECT COUNT(*) FROM test_batched_queue").Scan(&rows); err != nil || rows != 1 {
+		t.Errorf("Expected the acknowledged message to be stored, got %d rows (%v)", rows, err)
+	}
+	if !subscriber.msgs[0].acked {
+		t.Error("Expected the stored message to be acknowledged")
+	}
+}
@@ -0,0 +1,252 @@
+// Package webhook delivers duckq messages to an HTTP endpoint
+//
+// A Deliverer turns a duckq queue into a durable webhook outbox: every message is
+// POSTed to the configured URL, retried with exponential backoff on transient
+// failures, optionally signed with an HMAC header, and dead-lettered when it fails permanently
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body when a secret is configured
+const SignatureHeader = "X-Duckq-Signature"
+
+// Dequeuer is the part of a duckq queue used by the Deliverer, satisfied by *duckq.Queue and *duckq.PriorityQueue
+type Dequeuer interface {
+	DequeueWithAckId() (any, bool, string)
+	Acknowledge(ackID string) bool
+	Nack(ackID string) bool
+	DeadLetter(ackID string, reason string) bool
+}
+
+// Encoder turns dequeued items back into the bytes the queue stored, satisfied by *duckq.Queue and *duckq.PriorityQueue
+// The Deliverer uses it when the queue implements it, so items decoded by a Codec or TypeRegistry are POSTed as stored
+type Encoder interface {
+	Encode(item any) ([]byte, error)
+}
+
+// LeaseExtender is implemented by queues whose leases can outlive their ack timeout,
+// satisfied by *duckq.Queue and *duckq.PriorityQueue
+// The Deliverer extends the lease before every backoff so no other consumer picks the message up meanwhile
+type LeaseExtender interface {
+	ExtendLease(ackID string, d time.Duration) error
+}
+
+// Option is a function type that can be used to configure a Deliverer
+type Option func(*Deliverer)
+
+// WithHTTPClient sets the client used to POST messages, defaults to a client with a 10 second timeout
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Deliverer) {
+		d.client = client
+	}
+}
+
+// WithSecret signs every request body with HMAC-SHA256 using the given secret
+func WithSecret(secret []byte) Option {
+	return func(d *Deliverer) {
+		d.secret = secret
+	}
+}
+
+// WithMaxRetries sets how many times a transient failure is retried before the message is dead-lettered
+func WithMaxRetries(retries int) Option {
+	return func(d *Deliverer) {
+		d.maxRetries = retries
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubling on every following retry up to maxBackoff
+func WithBackoff(initial, maxBackoff time.Duration) Option {
+	return func(d *Deliverer) {
+		d.backoff = initial
+		d.maxBackoff = maxBackoff
+	}
+}
+
+// WithPollInterval sets how long the Deliverer waits before polling an empty queue again
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Deliverer) {
+		d.pollInterval = interval
+	}
+}
+
+// Deliverer POSTs queue messages to a webhook URL
+type Deliverer struct {
+	queue        Dequeuer
+	url          string
+	client       *http.Client
+	secret       []byte
+	maxRetries   int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+}
+
+// NewDeliverer creates a Deliverer that sends the messages of queue to url
+func NewDeliverer(queue Dequeuer, url string, opts ...Option) *Deliverer {
+	d := &Deliverer{
+		queue:        queue,
+		url:          url,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		maxRetries:   3,
+		backoff:      time.Second,
+		maxBackoff:   time.Minute,
+		pollInterval: time.Second,
+	}
+
+	// Apply any provided options
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Run delivers messages until ctx is done
+// A message is acknowledged after a 2xx response and dead-lettered after a
+// non-retryable response or once its retries are exhausted, as is an item that
+// doesn't encode to bytes. A message in flight when ctx is done is nacked back into the queue
+func (d *Deliverer) Run(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		item, ok, ackID := d.queue.DequeueWithAckId()
+		if !ok {
+			if !sleep(ctx, d.pollInterval) {
+				return nil
+			}
+			continue
+		}
+
+		payload, err := d.encode(item)
+		if err != nil {
+			d.queue.DeadLetter(ackID, err.Error())
+			continue
+		}
+
+		d.deliver(ctx, payload, ackID)
+	}
+}
+
+// deliver sends a single message, retrying transient failures, and settles it on the queue
+func (d *Deliverer) deliver(ctx context.Context, payload []byte, ackID string) {
+	backoff := d.backoff
+
+	for attempt := 0; ; attempt++ {
+		retryable, err := d.post(ctx, payload)
+		if err == nil {
+			d.queue.Acknowledge(ackID)
+			return
+		}
+
+		if ctx.Err() != nil {
+			d.queue.Nack(ackID)
+			return
+		}
+
+		if !retryable {
+			d.queue.DeadLetter(ackID, err.Error())
+			return
+		}
+
+		if attempt >= d.maxRetries {
+			d.queue.DeadLetter(ackID, fmt.Sprintf("retries exhausted: %v", err))
+			return
+		}
+
+		// Keep the lease past the backoff and the next request, or give up on a lease that was lost
+		if extender, ok := d.queue.(LeaseExtender); ok {
+			if err := extender.ExtendLease(ackID, backoff+d.client.Timeout); err != nil {
+				return
+			}
+		}
+
+		if !sleep(ctx, backoff) {
+			d.queue.Nack(ackID)
+			return
+		}
+
+		backoff = min(backoff*2, d.maxBackoff)
+	}
+}
+
+// encode returns the request body for a dequeued item
+// Without an Encoder only []byte and string items can be delivered
+func (d *Deliverer) encode(item any) ([]byte, error) {
+	if encoder, ok := d.queue.(Encoder); ok {
+		return encoder.Encode(item)
+	}
+
+	switch item := item.(type) {
+	case []byte:
+		return item, nil
+	case string:
+		return []byte(item), nil
+	}
+
+	return nil, fmt.Errorf("unsupported item type %T", item)
+}
+
+// post sends the payload and reports whether a failure is worth retrying
+func (d *Deliverer) post(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	if len(d.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(d.secret, payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post message: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+
+	// Client errors are permanent, except for timeouts and rate limiting
+	retryable := resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+
+	return retryable, err
+}
+
+// Sign returns the value of the SignatureHeader for the given payload, "sha256=" followed by the hex encoded HMAC
+// Receivers can recompute it with their copy of the secret and compare using hmac.Equal
+func Sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sleep waits for the given duration and reports false if ctx was done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
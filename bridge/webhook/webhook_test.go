@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goptics/duckq"
+)
+
+// runUntilEmpty runs the deliverer until the queue has no pending items left
+func runUntilEmpty(t *testing.T, d *Deliverer, q *duckq.Queue) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for q.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Give the in-flight delivery a moment to settle before stopping
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestDeliverer(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_webhook.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := duckq.New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	secret := []byte("secret")
+	fast := []Option{WithBackoff(time.Millisecond, 5*time.Millisecond), WithPollInterval(5 * time.Millisecond)}
+
+	t.Run("DeliversSignedPayload", func(t *testing.T) {
+		var received atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get(SignatureHeader) != Sign(secret, body) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			received.Store(string(body))
+		}))
+		defer server.Close()
+
+		q.Enqueue([]byte("hello"))
+		runUntilEmpty(t, NewDeliverer(q, server.URL, append(fast, WithSecret(secret))...), q)
+
+		if received.Load() != "hello" {
+			t.Errorf("Expected 'hello' to be delivered, got %v", received.Load())
+		}
+		if replayed, _ := q.ReplayDeadLetters(0, false); replayed != 0 {
+			t.Errorf("Expected no dead letters, got %d", replayed)
+		}
+	})
+
+	t.Run("RetriesTransientFailures", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		q.Enqueue([]byte("flaky"))
+		runUntilEmpty(t, NewDeliverer(q, server.URL, fast...), q)
+
+		if calls.Load() != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls.Load())
+		}
+		if replayed, _ := q.ReplayDeadLetters(0, false); replayed != 0 {
+			t.Errorf("Expected no dead letters, got %d", replayed)
+		}
+	})
+
+	t.Run("DeadLettersPermanentFailures", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		q.Enqueue([]byte("rejected"))
+		runUntilEmpty(t, NewDeliverer(q, server.URL, fast...), q)
+
+		if calls.Load() != 1 {
+			t.Errorf("Expected 1 call without retries, got %d", calls.Load())
+		}
+		if replayed, _ := q.ReplayDeadLetters(0, false); replayed != 1 {
+			t.Errorf("Expected 1 dead letter, got %d", replayed)
+		}
+		q.Purge()
+	})
+
+	t.Run("DeadLettersAfterRetries", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		q.Enqueue([]byte("down"))
+		runUntilEmpty(t, NewDeliverer(q, server.URL, append(fast, WithMaxRetries(2))...), q)
+
+		if calls.Load() != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls.Load())
+		}
+		if replayed, _ := q.ReplayDeadLetters(0, false); replayed != 1 {
+			t.Errorf("Expected 1 dead letter, got %d", replayed)
+		}
+	})
+	t.Run("KeepsLeaseDuringBackoff", func(t *testing.T) {
+		lq, err := queues.NewQueue("test_lease_queue", duckq.WithAckTimeout(30*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		lq.Enqueue([]byte("slow"))
+		d := NewDeliverer(lq, server.URL, WithBackoff(150*time.Millisecond, time.Second), WithPollInterval(5*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- d.Run(ctx)
+		}()
+
+		for calls.Load() == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(80 * time.Millisecond)
+
+		if leases, _ := lq.ExpiredLeases(); len(leases) != 0 {
+			t.Errorf("Expected the lease to be extended across the backoff, got %d expired", len(leases))
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for calls.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if lq.Len() != 0 || calls.Load() != 2 {
+			t.Errorf("Expected one successful retry, got %d calls and length %d", calls.Load(), lq.Len())
+		}
+	})
+
+	t.Run("ReencodesCodecItems", func(t *testing.T) {
+		cq, err := queues.NewQueue("test_codec_queue", duckq.WithCodec(duckq.JSONCodec{}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		var received atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received.Store(string(body))
+		}))
+		defer server.Close()
+
+		cq.Enqueue(map[string]any{"id": 1})
+		runUntilEmpty(t, NewDeliverer(cq, server.URL, fast...), cq)
+
+		if received.Load() != `{"id":1}` {
+			t.Errorf("Expected the stored JSON to be delivered, got %v", received.Load())
+		}
+	})
+
+	t.Run("DeadLettersUnencodableItems", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+		defer server.Close()
+
+		queue := &fakeQueue{item: 42}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if err := NewDeliverer(queue, server.URL, fast...).Run(ctx); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if queue.reason == "" || queue.acked || calls.Load() != 0 {
+			t.Errorf("Expected the item to be dead-lettered without a request, got reason %q and %d calls", queue.reason, calls.Load())
+		}
+	})
+}
+
+// fakeQueue hands out a single item that only a real queue could encode
+type fakeQueue struct {
+	item   any
+	taken  bool
+	acked  bool
+	reason string
+}
+
+func (q *fakeQueue) DequeueWithAckId() (any, bool, string) {
+	if q.taken {
+		return nil, false, ""
+	}
+
+	q.taken = true
+	return q.item, true, "ack"
+}
+
+func (q *fakeQueue) Acknowledge(ackID string) bool {
+	q.acked = true
+	return true
+}
+
+func (q *fakeQueue) Nack(ackID string) bool {
+	return true
+}
+
+func (q *fakeQueue) DeadLetter(ackID string, reason string) bool {
+	q.reason = reason
+	return true
+}
@@ -0,0 +1,67 @@
+package duckq
+
+import "fmt"
+
+// NewQueues creates or opens a regular queue for each key with the same options, creating all
+// missing queue and dead-letter tables in a single transaction, so multi-tenant setups that
+// provision hundreds of queues at startup take the catalog lock once instead of once per table
+// The options are validated for every key before anything is created, and no table is created
+// when one of them fails; duplicate keys are rejected with ErrInvalidOption
+// The queues are returned in the order of keys
+func (q *queues) NewQueues(keys []string, opts ...Option) ([]*Queue, error) {
+	seen := make(map[string]bool, len(keys))
+	settings := make([]*Queue, len(keys))
+	for i, key := range keys {
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate queue key %s", ErrInvalidOption, key)
+		}
+		seen[key] = true
+
+		keyOpts, err := q.withStoredConfig(key, opts)
+		if err != nil {
+			return nil, err
+		}
+		settings[i] = defaultQueue(q.client, key)
+		if err := settings[i].apply(q.withManager(keyOpts)); err != nil {
+			return nil, err
+		}
+	}
+
+	err := DefaultRetryPolicy.do(func() error {
+		tx, err := q.client.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		for _, queue := range settings {
+			if err := createQueueTable(tx, queue.tableName, queue.payloadColumnType()); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to create queue %s: %w", queue.tableName, err)
+			}
+			if err := createDeadLetterTable(tx, queue.deadLetterTable(), queue.payloadColumnType(), queue.tableName); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to create queue %s: %w", queue.tableName, err)
+			}
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create queues: %w", err)
+	}
+
+	// The tables exist now, so opening the queues only checks them and starts their background work
+	created := make([]*Queue, 0, len(keys))
+	for _, key := range keys {
+		queue, err := q.NewQueue(key, opts...)
+		if err != nil {
+			for _, queue := range created {
+				queue.Close()
+			}
+			return nil, err
+		}
+		created = append(created, queue)
+	}
+
+	return created, nil
+}
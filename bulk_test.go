@@ -0,0 +1,80 @@
+package duckq
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestNewQueues(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_bulk.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Create", func(t *testing.T) {
+		keys := make([]string, 50)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("test_tenant_%d", i)
+		}
+
+		created, err := queues.NewQueues(keys, WithMaxAttempts(3))
+		if err != nil {
+			t.Fatalf("NewQueues failed: %v", err)
+		}
+		if len(created) != len(keys) {
+			t.Fatalf("Expected %d queues, got %d", len(keys), len(created))
+		}
+		for i, q := range created {
+			if q.tableName != keys[i] || q.maxAttempts != 3 {
+				t.Errorf("Expected queue %s with the options, got %s with max attempts %d", keys[i], q.tableName, q.maxAttempts)
+			}
+		}
+
+		created[7].Enqueue("job")
+		if item, success := created[7].Dequeue(); !success || string(item.([]byte)) != "job" {
+			t.Errorf("Expected the created queue to work, got %v", item)
+		}
+	})
+
+	t.Run("ExistingQueues", func(t *testing.T) {
+		existing, err := queues.NewQueue("test_tenant_existing")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		existing.Enqueue("kept")
+
+		created, err := queues.NewQueues([]string{"test_tenant_existing", "test_tenant_new"})
+		if err != nil {
+			t.Fatalf("NewQueues failed: %v", err)
+		}
+		if created[0].Len() != 1 {
+			t.Errorf("Expected the existing queue to keep its items, got %d", created[0].Len())
+		}
+	})
+
+	t.Run("NothingCreatedOnError", func(t *testing.T) {
+		if _, err := queues.NewQueues([]string{"test_tenant_dup", "test_tenant_dup"}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for duplicate keys, got %v", err)
+		}
+		if _, err := queues.NewQueues([]string{"test_tenant_invalid"}, WithMaxAttempts(-1)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+
+		for _, name := range []string{"test_tenant_dup", "test_tenant_invalid"} {
+			exists, err := tableExists(queues.DB(), name)
+			if err != nil {
+				t.Fatalf("Failed to check table: %v", err)
+			}
+			if exists {
+				t.Errorf("Expected %s not to be created", name)
+			}
+		}
+	})
+}
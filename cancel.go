@@ -0,0 +1,88 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// EnqueueWithToken adds an item carrying a cancellation token chosen by the producer
+// CancelByToken withdraws the pending items with the token before they are delivered,
+// e.g. the jobs of a request the user aborted; several items can share a token
+// Returns true if the operation was successful
+func (q *Queue) EnqueueWithToken(item any, token string) bool {
+	if err := q.checkOpen(); err != nil {
+		return err == errDropped
+	}
+
+	err := q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			id, err := q.enqueueInTx(tx, item, sql.NullTime{})
+			if err != nil || id == 0 {
+				return err
+			}
+
+			_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET cancel_token = ? WHERE id = ?", q.tableName), token, id)
+			return err
+		})
+	})
+
+	return err == nil
+}
+
+// CancelByToken deletes the pending items enqueued with EnqueueWithToken and the given token
+// Items already delivered are left to their consumers
+// Returns the number of deleted items
+func (q *Queue) CancelByToken(token string) (int, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var cancelled int
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			rows, err := tx.Query(fmt.Sprintf("SELECT id, offload_key FROM %s WHERE cancel_token = ? AND status = 'pending'", q.tableName), token)
+			if err != nil {
+				return err
+			}
+
+			var ids []any
+			var offloadKeys []sql.NullString
+			for rows.Next() {
+				var id int64
+				var offloadKey sql.NullString
+				if err := rows.Scan(&id, &offloadKey); err != nil {
+					rows.Close()
+					return err
+				}
+				ids = append(ids, id)
+				offloadKeys = append(offloadKeys, offloadKey)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil || len(ids) == 0 {
+				cancelled = 0
+				return err
+			}
+
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", q.tableName, placeholders), ids...); err != nil {
+				return err
+			}
+			for _, offloadKey := range offloadKeys {
+				q.releasePayload(tx, offloadKey)
+			}
+			q.count(tx, -int64(len(ids)), 0)
+
+			cancelled = len(ids)
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel items: %w", err)
+	}
+
+	return cancelled, nil
+}
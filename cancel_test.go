@@ -0,0 +1,63 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestCancelByToken(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_cancel_by_token.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.EnqueueWithToken([]byte("resize 1"), "request-1")
+	q.EnqueueWithToken([]byte("resize 2"), "request-1")
+	q.EnqueueWithToken([]byte("resize 3"), "request-2")
+	q.Enqueue([]byte("untagged"))
+
+	t.Run("PendingOnly", func(t *testing.T) {
+		// The first item is delivered before the request is cancelled
+		item, success, ackID := q.DequeueWithAckId()
+		if !success || string(item.([]byte)) != "resize 1" {
+			t.Fatalf("Expected the first item, got %v", item)
+		}
+
+		cancelled, err := q.CancelByToken("request-1")
+		if err != nil {
+			t.Fatalf("CancelByToken failed: %v", err)
+		}
+		if cancelled != 1 {
+			t.Errorf("Expected 1 cancelled item, got %d", cancelled)
+		}
+		if err := q.Ack(ackID); err != nil {
+			t.Errorf("Expected the delivered item to stay acknowledgeable, got %v", err)
+		}
+	})
+
+	t.Run("OtherItemsKept", func(t *testing.T) {
+		if q.Len() != 2 || q.ApproxLen() != 2 {
+			t.Fatalf("Expected 2 pending items, got %d (approx %d)", q.Len(), q.ApproxLen())
+		}
+		item, _ := q.Dequeue()
+		if string(item.([]byte)) != "resize 3" {
+			t.Errorf("Expected the other request's item, got %v", item)
+		}
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		if cancelled, err := q.CancelByToken("request-3"); err != nil || cancelled != 0 {
+			t.Errorf("Expected nothing cancelled, got %d, %v", cancelled, err)
+		}
+	})
+}
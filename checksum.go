@@ -0,0 +1,81 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+)
+
+// binaryPayload stores string payloads as their raw bytes
+// Strings bound to the BLOB column would otherwise be cast by DuckDB, which
+// interprets escapes like \x41 and rejects invalid UTF-8
+func binaryPayload(item any) any {
+	if s, ok := item.(string); ok {
+		return []byte(s)
+	}
+
+	return item
+}
+
+// payloadChecksum returns the checksum stored alongside a payload
+// It is NULL unless WithChecksum is enabled and the payload is binary, or JSON text
+func (q *Queue) payloadChecksum(data any) sql.NullInt64 {
+	var b []byte
+	switch data := data.(type) {
+	case []byte:
+		b = data
+	case string:
+		b = []byte(data)
+	}
+	if !q.checksum || b == nil {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: int64(crc32.ChecksumIEEE(b)), Valid: true}
+}
+
+// verifyChecksum returns ErrChecksumMismatch when data doesn't match a stored checksum
+// Payloads stored without a checksum are never reported
+func verifyChecksum(data []byte, checksum sql.NullInt64) error {
+	if !checksum.Valid || int64(crc32.ChecksumIEEE(data)) == checksum.Int64 {
+		return nil
+	}
+
+	return ErrChecksumMismatch
+}
+
+// VerifyChecksums returns the IDs of messages, of any status, whose payload no longer
+// matches the checksum stored when it was enqueued, or on a queue created with WithHMAC its signature
+func (q *Queue) VerifyChecksums() ([]int64, error) {
+	where := " WHERE checksum IS NOT NULL"
+	if q.hmacKey != nil {
+		where = ""
+	}
+
+	rows, err := q.client.Query(fmt.Sprintf("SELECT id, %s, checksum, signature FROM %s%s ORDER BY id ASC", q.dataColumn(), q.tableName, where))
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify checksums: %w", err)
+	}
+	defer rows.Close()
+
+	var corrupted []int64
+	for rows.Next() {
+		var id int64
+		var data []byte
+		var checksum sql.NullInt64
+		var signature []byte
+		if err := rows.Scan(&id, &data, &checksum, &signature); err != nil {
+			return nil, fmt.Errorf("failed to verify checksums: %w", err)
+		}
+
+		if verifyChecksum(data, checksum) != nil || q.verifySignature(data, signature) != nil {
+			corrupted = append(corrupted, id)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to verify checksums: %w", err)
+	}
+
+	return corrupted, nil
+}
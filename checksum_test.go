@@ -0,0 +1,156 @@
+package duckq
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestBinaryPayloads(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_binary_payloads.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	payloads := map[string][]byte{
+		"NULs":        {0x00, 'a', 0x00, 0x00, 'b', 0x00},
+		"InvalidUTF8": {0xff, 0xfe, 0xc3, 0x28, 0xa0, 0xa1},
+		"Escapes":     []byte(`\x41\\x00'"`),
+		"Large":       bytes.Repeat([]byte{0x00, 0xff, 0x80, '\\', 'x'}, 300_000),
+	}
+
+	q, err := queues.NewQueue("test_queue", WithChecksum(true))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	pq, err := queues.NewPriorityQueue("test_priority_queue", WithChecksum(true))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			// Strings are stored as their raw bytes too
+			if !q.Enqueue(payload) || !q.Enqueue(string(payload)) {
+				t.Fatal("Enqueue failed")
+			}
+
+			values := q.Values()
+			if len(values) != 2 {
+				t.Fatalf("Expected 2 values, got %d", len(values))
+			}
+			for _, value := range values {
+				if !bytes.Equal(value.([]byte), payload) {
+					t.Errorf("Values returned a payload of %d bytes, want %d intact bytes", len(value.([]byte)), len(payload))
+				}
+			}
+
+			item, success := q.Dequeue()
+			if !success || !bytes.Equal(item.([]byte), payload) {
+				t.Error("Dequeue did not return the payload intact")
+			}
+			item, success, ackID := q.DequeueWithAckId()
+			if !success || !bytes.Equal(item.([]byte), payload) {
+				t.Error("DequeueWithAckId did not return the payload intact")
+			}
+			q.Acknowledge(ackID)
+
+			pq.Enqueue(payload, 1)
+			item, success = pq.Dequeue()
+			if !success || !bytes.Equal(item.([]byte), payload) {
+				t.Error("PriorityQueue.Dequeue did not return the payload intact")
+			}
+		})
+	}
+
+	t.Run("NoCorruption", func(t *testing.T) {
+		corrupted, err := q.VerifyChecksums()
+		if err != nil {
+			t.Fatalf("VerifyChecksums failed: %v", err)
+		}
+		if len(corrupted) != 0 {
+			t.Errorf("Expected no corrupted messages, got %v", corrupted)
+		}
+	})
+}
+
+func TestChecksum(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_checksum.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	var corruption []int64
+	q, err := queues.NewQueue("test_queue", WithChecksum(true), WithCorruptionHandler(func(id int64, err error) {
+		if errors.Is(err, ErrCorruptPayload) {
+			corruption = append(corruption, id)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte("first"))
+	q.Enqueue([]byte("second"))
+
+	var id int64
+	if err := q.client.QueryRow("SELECT id FROM test_queue ORDER BY id ASC LIMIT 1").Scan(&id); err != nil {
+		t.Fatalf("Error reading message id: %v", err)
+	}
+
+	t.Run("UpdatePayloadKeepsChecksumValid", func(t *testing.T) {
+		if !q.UpdatePayload(id, []byte("first, fixed")) {
+			t.Fatal("UpdatePayload failed")
+		}
+		corrupted, err := q.VerifyChecksums()
+		if err != nil {
+			t.Fatalf("VerifyChecksums failed: %v", err)
+		}
+		if len(corrupted) != 0 {
+			t.Errorf("Expected no corrupted messages, got %v", corrupted)
+		}
+	})
+
+	t.Run("DetectsCorruption", func(t *testing.T) {
+		// Change the payload behind the queue's back
+		if _, err := q.client.Exec("UPDATE test_queue SET data = ? WHERE id = ?", []byte("tampered"), id); err != nil {
+			t.Fatalf("Failed to corrupt payload: %v", err)
+		}
+
+		corrupted, err := q.VerifyChecksums()
+		if err != nil {
+			t.Fatalf("VerifyChecksums failed: %v", err)
+		}
+		if len(corrupted) != 1 || corrupted[0] != id {
+			t.Errorf("Expected message %d to be reported, got %v", id, corrupted)
+		}
+	})
+
+	t.Run("DequeueSkipsCorruption", func(t *testing.T) {
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "second" {
+			t.Errorf("Expected 'second', got '%v'", item)
+		}
+
+		var reason string
+		row := q.client.QueryRow("SELECT reason FROM test_queue_dead_letters WHERE id = ?", id)
+		if err := row.Scan(&reason); err != nil {
+			t.Fatalf("Expected the corrupted message to be dead-lettered: %v", err)
+		}
+		if reason != ErrChecksumMismatch.Error() {
+			t.Errorf("Unexpected dead-letter reason '%s'", reason)
+		}
+		if len(corruption) != 1 || corruption[0] != id {
+			t.Errorf("Expected the corruption handler to report message %d, got %v", id, corruption)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package duckq
+
+import "time"
+
+// Clock tells a queue the current time
+// Queues created with WithClock use it for timestamps, visibility, leases and retention,
+// so tests can move time forward without sleeping
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper is implemented by Clocks that also control how long a queue waits
+// Queues whose clock implements it wait with Sleep instead of time.Sleep, e.g. when an enqueue is
+// throttled by RateLimitThrottle, so a fake clock can advance its time instead of blocking
+type Sleeper interface {
+	Sleep(d time.Duration)
+}
+
+// systemClock is the Clock used by queues created without WithClock
+type systemClock struct{}
+
+// Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time of the queue's clock in UTC
+func (q *Queue) now() time.Time {
+	return q.clock.Now().UTC()
+}
+
+// sleep waits for d on the queue's clock, see Sleeper
+func (q *Queue) sleep(d time.Duration) {
+	if sleeper, ok := q.clock.(Sleeper); ok {
+		sleeper.Sleep(d)
+		return
+	}
+
+	time.Sleep(d)
+}
@@ -0,0 +1,114 @@
+package duckq
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// CloneQueue creates the queue dst with the same type as src and copies src's messages into it
+// Only messages matching every given filter are copied. Copied messages keep their
+// status, attempts, timestamps and every other column, including struct columns, which dst
+// gets too, but get new IDs from dst's sequence
+// The copy is retried with the RetryPolicy of src when it was opened through the manager
+// Returns ErrQueueNotFound when src doesn't exist and ErrQueueExists when dst already does
+// Returns the number of messages copied
+func (q *queues) CloneQueue(src, dst string, filters ...Filter) (int, error) {
+	var copied int64
+	err := q.retryPolicyOf(src).do(func() error {
+		return inTrackedTx(context.Background(), q.client, func(t *trackedTx) error {
+			var err error
+			copied, err = cloneTable(t.tx, src, dst, filters)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(copied), nil
+}
+
+// cloneTable creates the table dst like src and copies the rows of src matching filters into it as part of tx
+// The rows are copied by the columns both tables have, after adding the columns only src has to dst
+func cloneTable(tx *sql.Tx, src, dst string, filters []Filter) (int64, error) {
+	exists, err := tableExists(tx, src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up queue %s: %w", src, err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrQueueNotFound, src)
+	}
+
+	exists, err = tableExists(tx, dst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up queue %s: %w", dst, err)
+	}
+	if exists {
+		return 0, fmt.Errorf("%w: %s", ErrQueueExists, dst)
+	}
+
+	hasPriority, err := tableHasColumn(tx, src, "priority")
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue %s: %w", src, err)
+	}
+
+	dataType, err := tableColumnType(tx, src, "data")
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue %s: %w", src, err)
+	}
+
+	if hasPriority {
+		err = createPriorityTable(tx, dst, dataType)
+	} else {
+		err = createQueueTable(tx, dst, dataType)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create queue %s: %w", dst, err)
+	}
+
+	srcColumns, err := tableColumns(tx, src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue %s: %w", src, err)
+	}
+	dstColumns, err := tableColumns(tx, dst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue %s: %w", dst, err)
+	}
+	existing := make(map[string]bool, len(dstColumns))
+	for _, column := range dstColumns {
+		existing[column.name] = true
+	}
+
+	var columns []string
+	for _, column := range srcColumns {
+		if column.name == "id" {
+			continue
+		}
+		// Struct columns and others added to src after it was created
+		if !existing[column.name] {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", dst, column.name, column.dataType)); err != nil {
+				return 0, fmt.Errorf("failed to add column %s to %s: %w", column.name, dst, err)
+			}
+		}
+		columns = append(columns, column.name)
+	}
+
+	where, args := whereFilters(filters...)
+	list := strings.Join(columns, ", ")
+	result, err := tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s%s ORDER BY id ASC", dst, list, list, src, where),
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy messages: %w", err)
+	}
+
+	copied, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy messages: %w", err)
+	}
+
+	return copied, nil
+}
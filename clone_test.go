@@ -0,0 +1,133 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestCloneQueue(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_clone.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("production")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte("first"))
+	q.Enqueue([]byte("second"))
+	q.Enqueue([]byte("third"))
+	if _, success, _ := q.DequeueWithAckId(); !success {
+		t.Fatal("DequeueWithAckId failed")
+	}
+
+	t.Run("CloneEverything", func(t *testing.T) {
+		copied, err := queues.CloneQueue("production", "staging")
+		if err != nil {
+			t.Fatalf("CloneQueue failed: %v", err)
+		}
+		if copied != 3 {
+			t.Errorf("Expected 3 copied messages, got %d", copied)
+		}
+
+		staging, err := queues.NewQueue("staging")
+		if err != nil {
+			t.Fatalf("Failed to open cloned queue: %v", err)
+		}
+
+		// Opening the clone requeues the copied processing item ahead of the rest
+		values := staging.Values()
+		if len(values) != 3 {
+			t.Fatalf("Expected 3 pending values, got %d", len(values))
+		}
+		if string(values[0].([]byte)) != "first" {
+			t.Errorf("Expected 'first', got '%s'", string(values[0].([]byte)))
+		}
+
+		// The source queue is untouched
+		if q.Len() != 2 {
+			t.Errorf("Expected source queue length 2, got %d", q.Len())
+		}
+	})
+
+	t.Run("CloneFiltered", func(t *testing.T) {
+		copied, err := queues.CloneQueue("production", "staging_pending", Filter{Statuses: []string{"pending"}})
+		if err != nil {
+			t.Fatalf("CloneQueue failed: %v", err)
+		}
+		if copied != 2 {
+			t.Errorf("Expected 2 copied messages, got %d", copied)
+		}
+	})
+
+	t.Run("ClonePriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("production_priority")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		pq.Enqueue([]byte("low"), 10)
+		pq.Enqueue([]byte("high"), 1)
+
+		if _, err := queues.CloneQueue("production_priority", "staging_priority"); err != nil {
+			t.Fatalf("CloneQueue failed: %v", err)
+		}
+
+		staging, err := queues.NewPriorityQueue("staging_priority")
+		if err != nil {
+			t.Fatalf("Failed to open cloned priority queue: %v", err)
+		}
+		item, success := staging.Dequeue()
+		if !success || string(item.([]byte)) != "high" {
+			t.Errorf("Expected 'high' to keep its priority, got '%v'", item)
+		}
+	})
+
+	t.Run("CloneStructColumns", func(t *testing.T) {
+		schema, err := NewStructSchema(orderTask{})
+		if err != nil {
+			t.Fatalf("NewStructSchema failed: %v", err)
+		}
+		orders, err := queues.NewQueue("production_orders", WithStructPayload(schema))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		orders.Enqueue(orderTask{CustomerID: 42, Total: 19.5})
+
+		if _, err := queues.CloneQueue("production_orders", "staging_orders"); err != nil {
+			t.Fatalf("CloneQueue failed: %v", err)
+		}
+
+		var customerID int64
+		var payloadType sql.NullString
+		err = queues.DB().QueryRow("SELECT customer_id, payload_type FROM staging_orders").Scan(&customerID, &payloadType)
+		if err != nil || customerID != 42 || !payloadType.Valid {
+			t.Errorf("Expected the struct column and payload type to be copied, got %d %v (%v)", customerID, payloadType, err)
+		}
+
+		staging, err := queues.NewQueue("staging_orders", WithStructPayload(schema))
+		if err != nil {
+			t.Fatalf("Failed to open cloned queue: %v", err)
+		}
+		if item, _ := staging.Dequeue(); item.(orderTask).CustomerID != 42 {
+			t.Errorf("Expected the struct back, got %#v", item)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := queues.CloneQueue("missing", "other"); !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected ErrQueueNotFound cloning a missing queue, got %v", err)
+		}
+		if _, err := queues.CloneQueue("production", "staging"); !errors.Is(err, ErrQueueExists) {
+			t.Errorf("Expected ErrQueueExists cloning into an existing queue, got %v", err)
+		}
+	})
+}
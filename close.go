@@ -0,0 +1,114 @@
+package duckq
+
+import (
+	"errors"
+	"time"
+)
+
+// CloseBehavior controls what queue operations do after the queue is closed
+type CloseBehavior int
+
+const (
+	// CloseError fails operations with ErrQueueClosed (the default)
+	CloseError CloseBehavior = iota
+	// CloseBlock waits up to the close block timeout for Reopen before failing with ErrQueueClosed
+	CloseBlock
+	// CloseDrop silently discards writes and reports reads as finding an empty queue
+	CloseDrop
+)
+
+// defaultCloseBlockTimeout is how long CloseBlock waits for Reopen unless WithCloseBlockTimeout is given
+const defaultCloseBlockTimeout = 5 * time.Second
+
+// errDropped is returned by checkOpen when a closed queue drops the operation
+var errDropped = errors.New("operation dropped by closed queue")
+
+// checkOpen returns nil when the queue is open, or becomes open while CloseBlock waits
+// A closed queue otherwise returns ErrQueueClosed, or errDropped with CloseDrop
+func (q *Queue) checkOpen() error {
+	if !q.closed.Load() {
+		return nil
+	}
+
+	switch q.closeBehavior {
+	case CloseBlock:
+		if q.waitReopen(q.closeBlockTimeout) {
+			return nil
+		}
+	case CloseDrop:
+		return errDropped
+	}
+
+	return ErrQueueClosed
+}
+
+// waitReopen waits up to timeout for the queue to be reopened
+// Returns true if the queue is open
+func (q *Queue) waitReopen(timeout time.Duration) bool {
+	q.closeMu.Lock()
+	reopened := q.reopened
+	q.closeMu.Unlock()
+
+	if reopened == nil {
+		return !q.closed.Load()
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-reopened:
+		return true
+	case <-timer.C:
+		return !q.closed.Load()
+	}
+}
+
+// Reopen makes a closed queue accept operations again and restarts its background goroutines
+// Operations blocked by CloseBlock resume
+func (q *Queue) Reopen() {
+	q.lifecycleMu.Lock()
+	defer q.lifecycleMu.Unlock()
+
+	if !q.closed.Load() {
+		return
+	}
+
+	q.closeMu.Lock()
+	q.closed.Store(false)
+	if q.reopened != nil {
+		close(q.reopened)
+		q.reopened = nil
+	}
+	q.closeMu.Unlock()
+
+	for _, task := range q.tasks {
+		q.startTask(task)
+	}
+}
+
+// goBackground runs task in a goroutine for as long as the queue is open
+// The stop channel is closed by Close, and the task is started again by Reopen
+func (q *Queue) goBackground(task func(stop <-chan struct{})) {
+	q.lifecycleMu.Lock()
+	defer q.lifecycleMu.Unlock()
+
+	q.tasks = append(q.tasks, task)
+	if !q.closed.Load() {
+		q.startTask(task)
+	}
+}
+
+// startTask starts a background task, the caller must hold lifecycleMu
+func (q *Queue) startTask(task func(stop <-chan struct{})) {
+	if q.stop == nil {
+		q.stop = make(chan struct{})
+	}
+
+	stop := q.stop
+	q.background.Add(1)
+	go func() {
+		defer q.background.Done()
+		task(stop)
+	}()
+}
@@ -0,0 +1,171 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestCloseBehavior(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_close_behavior.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Error", func(t *testing.T) {
+		q, err := queues.NewQueue("test_error_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Close()
+
+		if q.Enqueue([]byte("item")) {
+			t.Error("Enqueue on a closed queue should fail")
+		}
+		if _, err := q.ReplayDeadLetters(0, false); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("Expected ErrQueueClosed, got %v", err)
+		}
+		if _, _, err := q.DequeueEvent(); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("Expected ErrQueueClosed, got %v", err)
+		}
+
+		q.Reopen()
+		if !q.Enqueue([]byte("item")) {
+			t.Error("Enqueue after Reopen failed")
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		q, err := queues.NewQueue("test_drop_queue", WithCloseBehavior(CloseDrop))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("kept"))
+		q.Close()
+
+		if !q.Enqueue([]byte("dropped")) {
+			t.Error("Enqueue on a dropping queue should report success")
+		}
+		if _, success := q.Dequeue(); success {
+			t.Error("Dequeue on a dropping queue should find nothing")
+		}
+		if _, _, err := q.DequeueEvent(); !errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected ErrEmptyQueue, got %v", err)
+		}
+
+		q.Reopen()
+		if q.Len() != 1 {
+			t.Errorf("Expected only the item enqueued before Close, got length %d", q.Len())
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_block_queue", WithCloseBehavior(CloseBlock))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		pq.Close()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			pq.Reopen()
+		}()
+
+		start := time.Now()
+		if !pq.Enqueue([]byte("item"), 1) {
+			t.Error("Enqueue should succeed once the queue is reopened")
+		}
+		if time.Since(start) < 50*time.Millisecond {
+			t.Error("Enqueue should wait for Reopen")
+		}
+	})
+
+	t.Run("BlockTimeout", func(t *testing.T) {
+		q, err := queues.NewQueue("test_block_timeout_queue", WithCloseBehavior(CloseBlock), WithCloseBlockTimeout(20*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Close()
+
+		tx, err := queues.DB().Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+		if err := q.EnqueueInTx(tx, []byte("item")); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("Expected ErrQueueClosed after the block timeout, got %v", err)
+		}
+	})
+}
+
+func TestQueueLifecycle(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_queue_lifecycle.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	other, err := queues.NewPriorityQueue("test_other_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	var running atomic.Int32
+	q.goBackground(func(stop <-chan struct{}) {
+		running.Add(1)
+		defer running.Add(-1)
+		<-stop
+	})
+
+	t.Run("CloseStopsBackgroundGoroutines", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		if running.Load() != 1 {
+			t.Fatalf("Expected 1 background goroutine, got %d", running.Load())
+		}
+
+		q.Close()
+		if running.Load() != 0 {
+			t.Errorf("Expected background goroutines to stop on Close, got %d running", running.Load())
+		}
+
+		// The shared database stays open for the other queues
+		if !other.Enqueue([]byte("item"), 1) {
+			t.Error("Enqueue on another queue failed after Close")
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		reopened, err := queues.Reopen("test_queue")
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		if reopened != q {
+			t.Error("Expected Reopen to return the closed queue")
+		}
+		if !q.Enqueue([]byte("item")) {
+			t.Error("Enqueue after Reopen failed")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		if running.Load() != 1 {
+			t.Errorf("Expected the background goroutine to restart, got %d running", running.Load())
+		}
+
+		if _, err := queues.Reopen("missing"); !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected ErrQueueNotFound, got %v", err)
+		}
+	})
+}
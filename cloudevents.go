@@ -0,0 +1,133 @@
+package duckq
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// EnqueueEvent adds a CloudEvent to the queue
+// The event attributes are stored in the ce_* columns so they can be queried directly,
+// and the event data is stored as the item payload
+func (q *Queue) EnqueueEvent(e event.Event) error {
+	if err := e.Validate(); err != nil {
+		return fmt.Errorf("invalid cloud event: %w", err)
+	}
+
+	extensions := make(map[string]string, len(e.Extensions()))
+	for name, value := range e.Extensions() {
+		formatted, err := types.Format(value)
+		if err != nil {
+			return fmt.Errorf("invalid cloud event extension %s: %w", name, err)
+		}
+		extensions[name] = formatted
+	}
+
+	encodedExtensions, err := json.Marshal(extensions)
+	if err != nil {
+		return fmt.Errorf("failed to encode cloud event extensions: %w", err)
+	}
+
+	var eventTime sql.NullTime
+	if !e.Time().IsZero() {
+		eventTime = sql.NullTime{Time: e.Time().UTC(), Valid: true}
+	}
+
+	data := e.Data()
+	if data == nil {
+		data = []byte{}
+	}
+	columns := []columnValue{
+		{"ce_id", e.ID()},
+		{"ce_source", e.Source()},
+		{"ce_type", e.Type()},
+		{"ce_subject", e.Subject()},
+		{"ce_time", eventTime},
+		{"ce_spec_version", e.SpecVersion()},
+		{"ce_data_content_type", e.DataContentType()},
+		{"ce_data_schema", e.DataSchema()},
+		{"ce_extensions", string(encodedExtensions)},
+	}
+
+	err = q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			_, err := q.enqueueColumnsInTx(tx, data, sql.NullTime{}, columns)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue cloud event: %w", err)
+	}
+
+	return nil
+}
+
+// DequeueEvent removes and returns the next CloudEvent from the queue with an acknowledgment ID
+// Items that were not enqueued with EnqueueEvent are skipped
+// Returns ErrEmptyQueue when no event is pending
+func (q *Queue) DequeueEvent() (event.Event, string, error) {
+	row, err := q.dequeueWhere(true, "ce_id IS NOT NULL")
+	if err != nil {
+		return event.Event{}, "", err
+	}
+
+	e, err := q.loadEvent(row.id, row.data)
+	if err != nil {
+		// Hand the item back rather than leaving it leased without a consumer
+		q.Nack(row.ackID)
+		return event.Event{}, "", fmt.Errorf("failed to load cloud event: %w", err)
+	}
+
+	return e, row.ackID, nil
+}
+
+// loadEvent rebuilds the CloudEvent stored in the row with the given id
+func (q *Queue) loadEvent(id int64, data []byte) (event.Event, error) {
+	var ceID, source, eventType, specVersion string
+	var subject, contentType, dataSchema, encodedExtensions sql.NullString
+	var eventTime sql.NullTime
+
+	err := q.client.QueryRow(
+		fmt.Sprintf("SELECT ce_id, ce_source, ce_type, ce_subject, ce_time, ce_spec_version, ce_data_content_type, ce_data_schema, ce_extensions FROM %s WHERE id = ?", q.tableName),
+		id,
+	).Scan(&ceID, &source, &eventType, &subject, &eventTime, &specVersion, &contentType, &dataSchema, &encodedExtensions)
+	if err != nil {
+		return event.Event{}, err
+	}
+
+	e := event.New(specVersion)
+	e.SetID(ceID)
+	e.SetSource(source)
+	e.SetType(eventType)
+	if subject.String != "" {
+		e.SetSubject(subject.String)
+	}
+	if eventTime.Valid {
+		e.SetTime(eventTime.Time)
+	}
+	if contentType.String != "" {
+		e.SetDataContentType(contentType.String)
+	}
+	if dataSchema.String != "" {
+		e.SetDataSchema(dataSchema.String)
+	}
+
+	if encodedExtensions.Valid {
+		var extensions map[string]string
+		if err := json.Unmarshal([]byte(encodedExtensions.String), &extensions); err != nil {
+			return event.Event{}, err
+		}
+		for name, value := range extensions {
+			e.SetExtension(name, value)
+		}
+	}
+
+	if len(data) > 0 {
+		e.DataEncoded = data
+	}
+
+	return e, nil
+}
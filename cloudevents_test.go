@@ -0,0 +1,129 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestCloudEvents(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_cloudevents.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	eventTime := time.Date(2025, 5, 8, 12, 0, 0, 0, time.UTC)
+
+	e := event.New()
+	e.SetID("evt-1")
+	e.SetSource("/orders")
+	e.SetType("com.example.order.created")
+	e.SetSubject("order-42")
+	e.SetTime(eventTime)
+	e.SetExtension("tenant", "acme")
+	if err := e.SetData(event.ApplicationJSON, map[string]int{"order_id": 42}); err != nil {
+		t.Fatalf("Failed to set event data: %v", err)
+	}
+
+	t.Run("EnqueueEvent", func(t *testing.T) {
+		// A plain item ahead of the event is skipped by DequeueEvent
+		q.Enqueue([]byte("plain item"))
+
+		if err := q.EnqueueEvent(e); err != nil {
+			t.Fatalf("EnqueueEvent failed: %v", err)
+		}
+
+		var eventType string
+		row := q.client.QueryRow("SELECT ce_type FROM test_queue WHERE ce_id = 'evt-1'")
+		if err := row.Scan(&eventType); err != nil {
+			t.Fatalf("Error reading event columns: %v", err)
+		}
+		if eventType != "com.example.order.created" {
+			t.Errorf("Expected type column to be stored, got '%s'", eventType)
+		}
+		if q.ApproxLen() != 2 {
+			t.Errorf("Expected the event to be counted, got approximate length %d", q.ApproxLen())
+		}
+
+		if err := q.EnqueueEvent(event.New()); err == nil {
+			t.Error("EnqueueEvent with an invalid event should fail")
+		}
+	})
+
+	t.Run("DequeueEvent", func(t *testing.T) {
+		got, ackID, err := q.DequeueEvent()
+		if err != nil {
+			t.Fatalf("DequeueEvent failed: %v", err)
+		}
+
+		if got.ID() != "evt-1" || got.Source() != "/orders" || got.Type() != "com.example.order.created" {
+			t.Errorf("Unexpected event attributes: %s", got.String())
+		}
+		if got.Subject() != "order-42" || !got.Time().Equal(eventTime) {
+			t.Errorf("Unexpected subject or time: %s", got.String())
+		}
+		if got.DataContentType() != event.ApplicationJSON {
+			t.Errorf("Expected content type '%s', got '%s'", event.ApplicationJSON, got.DataContentType())
+		}
+		if got.Extensions()["tenant"] != "acme" {
+			t.Errorf("Expected tenant extension 'acme', got %v", got.Extensions()["tenant"])
+		}
+		if string(got.Data()) != `{"order_id":42}` {
+			t.Errorf("Unexpected event data: %s", string(got.Data()))
+		}
+
+		if !q.Acknowledge(ackID) {
+			t.Error("Acknowledge failed")
+		}
+
+		// Only the plain item is left, so there are no more events
+		if _, _, err := q.DequeueEvent(); !errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected ErrEmptyQueue without pending events, got %v", err)
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the plain item to stay in the queue, got length %d", q.Len())
+		}
+	})
+	t.Run("OffloadedData", func(t *testing.T) {
+		dir := t.TempDir()
+		oq, err := queues.NewQueue("test_offload_queue", WithPayloadOffload(NewFileBlobStore(dir), 16))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		large := event.New()
+		large.SetID("evt-2")
+		large.SetSource("/orders")
+		large.SetType("com.example.order.created")
+		large.SetData("text/plain", strings.Repeat("x", 1024))
+		if err := oq.EnqueueEvent(large); err != nil {
+			t.Fatalf("EnqueueEvent failed: %v", err)
+		}
+
+		if matches, _ := filepath.Glob(filepath.Join(dir, "test_offload_queue", "*")); len(matches) != 1 {
+			t.Errorf("Expected the event data to be offloaded, got %d blobs", len(matches))
+		}
+
+		got, ackID, err := oq.DequeueEvent()
+		if err != nil {
+			t.Fatalf("DequeueEvent failed: %v", err)
+		}
+		if len(got.Data()) != 1024 {
+			t.Errorf("Expected the offloaded data to be resolved, got %d bytes", len(got.Data()))
+		}
+		oq.Acknowledge(ackID)
+	})
+}
@@ -0,0 +1,32 @@
+package duckq
+
+import "encoding/json"
+
+// codecPayloadType is recorded in the payload_type column of items encoded with a queue's Codec
+const codecPayloadType = "duckq/codec"
+
+// Codec encodes items that are neither []byte nor string for storage, and decodes them on Dequeue and Values
+// Queues created with WithCodec use it for every such item whose type is not in the TypeRegistry
+type Codec interface {
+	Marshal(item any) ([]byte, error)
+	Unmarshal(data []byte) (any, error)
+}
+
+// JSONCodec stores items as JSON and decodes them into the generic values of encoding/json,
+// such as map[string]any for objects
+type JSONCodec struct{}
+
+// Marshal returns the JSON encoding of item
+func (JSONCodec) Marshal(item any) ([]byte, error) {
+	return json.Marshal(item)
+}
+
+// Unmarshal decodes JSON data into a generic value
+func (JSONCodec) Unmarshal(data []byte) (any, error) {
+	var item any
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
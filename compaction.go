@@ -0,0 +1,92 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// EnqueueKeyed adds an item for key, superseding the pending item already enqueued with the same
+// key, so the queue holds at most one pending item per key and only the latest one is delivered
+// It suits "refresh entity X" work where only the newest request matters
+// The superseding item takes over the superseded one's place in the queue, so a key refreshed
+// faster than the queue drains is still delivered, and an item already being processed for the
+// key is left alone, so the key can be delivered again while it is processed
+// On priority queues, items for new keys get priority 0
+// Returns whether a pending item was superseded
+func (q *Queue) EnqueueKeyed(key string, item any) (bool, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return false, nil
+		}
+		return false, err
+	}
+
+	data, payloadType, err := q.encode(item)
+	if err != nil {
+		return false, err
+	}
+	if err := q.checkPayloadSize(data); err != nil {
+		return false, err
+	}
+
+	// The lookup and insert must not interleave with another call for the same key
+	mu := q.dequeueLock()
+	mu.Lock()
+	defer mu.Unlock()
+
+	var superseded bool
+	err = q.retryEnqueue(1, func() error {
+		superseded = false
+		return q.inTx(func(tx *sql.Tx) error {
+			var stored sql.NullString
+			err := tx.QueryRow(
+				fmt.Sprintf("SELECT offload_key FROM %s WHERE message_key = ? AND status = 'pending' LIMIT 1", q.tableName),
+				key,
+			).Scan(&stored)
+			if err == nil {
+				superseded = true
+				return q.supersedeInTx(tx, key, stored, item, data, payloadType)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			id, err := q.enqueueInTx(tx, item, sql.NullTime{})
+			if err != nil || id == 0 {
+				return err
+			}
+
+			_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET message_key = ? WHERE id = ?", q.tableName), key, id)
+			return err
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue keyed item: %w", err)
+	}
+
+	return superseded, nil
+}
+
+// supersedeInTx replaces the payload of the pending item enqueued with key as part of tx,
+// releasing the offloaded payload with the offload key stored, which it replaces
+func (q *Queue) supersedeInTx(tx *sql.Tx, key string, stored sql.NullString, item, data any, payloadType *string) error {
+	data, offloadKey, err := q.offloadPayload(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("UPDATE %s SET data = ?, payload_type = ?, offload_key = ?, checksum = ?, signature = ?, updated_at = ? WHERE message_key = ? AND status = 'pending'", q.tableName),
+		data, payloadType, offloadKey, q.payloadChecksum(data), q.payloadSignature(data), q.now(), key,
+	)
+	if err != nil {
+		return err
+	}
+	q.releasePayload(tx, stored)
+
+	if err := q.writeStructColumns(tx, item, "message_key = ? AND status = 'pending'", key); err != nil {
+		return err
+	}
+	return q.mirrorInTx(tx, "message_key = ? AND status = 'pending'", key)
+}
@@ -0,0 +1,59 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestEnqueueKeyed(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_enqueue_keyed.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("LatestWins", func(t *testing.T) {
+		if superseded, err := q.EnqueueKeyed("user:1", []byte("v1")); err != nil || superseded {
+			t.Fatalf("Expected a new item, got %v (%v)", superseded, err)
+		}
+		q.Enqueue([]byte("unkeyed"))
+		q.EnqueueKeyed("user:2", []byte("other"))
+		if superseded, err := q.EnqueueKeyed("user:1", []byte("v2")); err != nil || !superseded {
+			t.Fatalf("Expected the pending item to be superseded, got %v (%v)", superseded, err)
+		}
+
+		if q.Len() != 3 {
+			t.Fatalf("Expected 3 pending items, got %d", q.Len())
+		}
+		for _, want := range []string{"v2", "unkeyed", "other"} {
+			item, success := q.Dequeue()
+			if !success || string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+	})
+
+	t.Run("ProcessingNotSuperseded", func(t *testing.T) {
+		q.EnqueueKeyed("user:3", []byte("first"))
+		_, _, ackID := q.DequeueWithAckId()
+
+		if superseded, _ := q.EnqueueKeyed("user:3", []byte("second")); superseded {
+			t.Error("Expected the processing item to be left alone")
+		}
+		q.Acknowledge(ackID)
+
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "second" {
+			t.Errorf("Expected the new item to be delivered, got %v", item)
+		}
+	})
+}
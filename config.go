@@ -0,0 +1,186 @@
+package duckq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QueueConfig describes a regular queue, so its configuration can be loaded from YAML, JSON
+// or the environment and validated before the queue is created
+// Zero values keep the defaults of the corresponding options
+type QueueConfig struct {
+	// Name is the queue key, which is also the name of its table
+	Name string `yaml:"name" json:"name" env:"NAME"`
+	// RemoveOnComplete deletes acknowledged items when true, see WithRemoveOnComplete
+	RemoveOnComplete *bool `yaml:"remove_on_complete" json:"remove_on_complete" env:"REMOVE_ON_COMPLETE"`
+	// AckTimeout sets how long a lease lasts, see WithAckTimeout
+	AckTimeout time.Duration `yaml:"ack_timeout" json:"ack_timeout" env:"ACK_TIMEOUT"`
+	// ConsumerID is recorded on every lease, see WithConsumerID
+	ConsumerID string `yaml:"consumer_id" json:"consumer_id" env:"CONSUMER_ID"`
+	// MaxPayloadSize rejects larger payloads, see WithMaxPayloadSize
+	MaxPayloadSize int `yaml:"max_payload_size" json:"max_payload_size" env:"MAX_PAYLOAD_SIZE"`
+	// MaxAttempts dead-letters items delivered this many times, see WithMaxAttempts
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts" env:"MAX_ATTEMPTS"`
+	// DeadLetter names the dead-letter table, see WithDeadLetter
+	DeadLetter string `yaml:"dead_letter" json:"dead_letter" env:"DEAD_LETTER"`
+	// Checksum stores payload checksums, see WithChecksum
+	Checksum bool `yaml:"checksum" json:"checksum" env:"CHECKSUM"`
+	// JSONPayload stores payloads in a JSON column, see WithJSONPayload
+	JSONPayload bool `yaml:"json_payload" json:"json_payload" env:"JSON_PAYLOAD"`
+	// Tenant assigns the queue to a tenant, see WithTenant
+	Tenant string `yaml:"tenant" json:"tenant" env:"TENANT"`
+	// CloseBehavior is "error", "block" or "drop", see WithCloseBehavior
+	CloseBehavior string `yaml:"close_behavior" json:"close_behavior" env:"CLOSE_BEHAVIOR"`
+	// CloseBlockTimeout limits how long "block" waits for Reopen, see WithCloseBlockTimeout
+	CloseBlockTimeout time.Duration `yaml:"close_block_timeout" json:"close_block_timeout" env:"CLOSE_BLOCK_TIMEOUT"`
+	// Retry overrides DefaultRetryPolicy, see WithRetryPolicy
+	Retry *RetryPolicy `yaml:"retry" json:"retry"`
+	// ProcessingTimeout sets how long a lease lasts before ProcessingAction applies, see WithProcessingTimeout
+	ProcessingTimeout time.Duration `yaml:"processing_timeout" json:"processing_timeout" env:"PROCESSING_TIMEOUT"`
+	// ProcessingAction is "requeue", "expire" or "dead_letter"
+	ProcessingAction string `yaml:"processing_action" json:"processing_action" env:"PROCESSING_ACTION"`
+	// PendingTTL sets how long an item may stay pending before PendingAction applies, see WithPendingTTL
+	PendingTTL time.Duration `yaml:"pending_ttl" json:"pending_ttl" env:"PENDING_TTL"`
+	// PendingAction is "expire" or "dead_letter"
+	PendingAction string `yaml:"pending_action" json:"pending_action" env:"PENDING_ACTION"`
+	// Retention deletes completed items after this long, see WithRetention
+	Retention time.Duration `yaml:"retention" json:"retention" env:"RETENTION"`
+}
+
+// closeBehaviors maps the CloseBehavior names accepted by QueueConfig
+var closeBehaviors = map[string]CloseBehavior{
+	"":      CloseError,
+	"error": CloseError,
+	"block": CloseBlock,
+	"drop":  CloseDrop,
+}
+
+// timeoutActions maps the timeout action names accepted by QueueConfig
+var timeoutActions = map[string]TimeoutAction{
+	"":            TimeoutRequeue,
+	"requeue":     TimeoutRequeue,
+	"expire":      TimeoutExpire,
+	"dead_letter": TimeoutDeadLetter,
+}
+
+// configName returns the non-empty name QueueConfig uses for value in names
+func configName[T comparable](names map[string]T, value T) string {
+	for name, v := range names {
+		if name != "" && v == value {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Validate reports every problem with the configuration at once, each wrapping ErrInvalidOption
+func (c QueueConfig) Validate() error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("%w: name is empty", ErrInvalidOption))
+	}
+	if _, ok := closeBehaviors[c.CloseBehavior]; !ok {
+		errs = append(errs, fmt.Errorf("%w: unknown close behavior %q", ErrInvalidOption, c.CloseBehavior))
+	}
+	for _, action := range []string{c.ProcessingAction, c.PendingAction} {
+		if _, ok := timeoutActions[action]; !ok {
+			errs = append(errs, fmt.Errorf("%w: unknown timeout action %q", ErrInvalidOption, action))
+		}
+	}
+
+	// Check the options the same way creating the queue would, without touching a database
+	if err := defaultQueue(nil, c.Name).apply(c.Options()); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Options returns the options that configure a queue as described by the configuration
+// They can also be passed to NewPriorityQueue or NewDelayedQueue along with their own options
+func (c QueueConfig) Options() []Option {
+	var opts []Option
+	if c.RemoveOnComplete != nil {
+		opts = append(opts, WithRemoveOnComplete(*c.RemoveOnComplete))
+	}
+	if c.AckTimeout != 0 {
+		opts = append(opts, WithAckTimeout(c.AckTimeout))
+	}
+	if c.ConsumerID != "" {
+		opts = append(opts, WithConsumerID(c.ConsumerID))
+	}
+	if c.MaxPayloadSize != 0 {
+		opts = append(opts, WithMaxPayloadSize(c.MaxPayloadSize))
+	}
+	if c.MaxAttempts != 0 {
+		opts = append(opts, WithMaxAttempts(c.MaxAttempts))
+	}
+	if c.DeadLetter != "" {
+		opts = append(opts, WithDeadLetter(c.DeadLetter))
+	}
+	if c.Checksum {
+		opts = append(opts, WithChecksum(true))
+	}
+	if c.JSONPayload {
+		opts = append(opts, WithJSONPayload())
+	}
+	if c.Tenant != "" {
+		opts = append(opts, WithTenant(c.Tenant))
+	}
+	if behavior, ok := closeBehaviors[c.CloseBehavior]; ok && c.CloseBehavior != "" {
+		opts = append(opts, WithCloseBehavior(behavior))
+	}
+	if c.CloseBlockTimeout != 0 {
+		opts = append(opts, WithCloseBlockTimeout(c.CloseBlockTimeout))
+	}
+	if c.Retry != nil {
+		opts = append(opts, WithRetryPolicy(*c.Retry))
+	}
+	if c.ProcessingTimeout != 0 {
+		opts = append(opts, WithProcessingTimeout(c.ProcessingTimeout, timeoutActions[c.ProcessingAction]))
+	}
+	if c.PendingTTL != 0 {
+		opts = append(opts, WithPendingTTL(c.PendingTTL, timeoutActions[c.PendingAction]))
+	}
+	if c.Retention != 0 {
+		opts = append(opts, WithRetention(c.Retention))
+	}
+
+	return opts
+}
+
+// config returns the configuration of the queue's settings that QueueConfig can describe
+// Function-valued settings such as codecs, hooks and clocks are left out, and so is the
+// consumer ID, which belongs to the process
+func (q *Queue) config() QueueConfig {
+	removeOnComplete := q.removeOnComplete
+	retry := q.retryPolicy
+	cfg := QueueConfig{
+		Name:              q.tableName,
+		RemoveOnComplete:  &removeOnComplete,
+		MaxPayloadSize:    q.maxPayloadSize,
+		MaxAttempts:       q.maxAttempts,
+		DeadLetter:        q.deadLetterName,
+		Checksum:          q.checksum,
+		JSONPayload:       q.jsonPayload,
+		Tenant:            q.tenant,
+		CloseBehavior:     configName(closeBehaviors, q.closeBehavior),
+		CloseBlockTimeout: q.closeBlockTimeout,
+		Retry:             &retry,
+		Retention:         q.retention,
+	}
+	if q.processingTimeout {
+		cfg.ProcessingTimeout = q.ackTimeout
+		cfg.ProcessingAction = configName(timeoutActions, q.processingAction)
+	} else {
+		cfg.AckTimeout = q.ackTimeout
+	}
+	if q.pendingTTL != 0 {
+		cfg.PendingTTL = q.pendingTTL
+		cfg.PendingAction = configName(timeoutActions, q.pendingAction)
+	}
+
+	return cfg
+}
@@ -0,0 +1,136 @@
+package duckq
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestQueueConfig(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_queue_config.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Validate", func(t *testing.T) {
+		cfg := QueueConfig{CloseBehavior: "maybe", ProcessingAction: "retry", MaxAttempts: -1, AckTimeout: -1}
+
+		err := cfg.Validate()
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("Expected ErrInvalidOption, got %v", err)
+		}
+		for _, problem := range []string{"name", "close behavior", "timeout action", "max attempts", "ack timeout"} {
+			if !strings.Contains(err.Error(), problem) {
+				t.Errorf("Expected the %s problem to be reported, got %v", problem, err)
+			}
+		}
+
+		if _, err := queues.NewQueueFromConfig(cfg); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected NewQueueFromConfig to reject the config, got %v", err)
+		}
+	})
+
+	t.Run("NewQueueFromConfig", func(t *testing.T) {
+		var cfg QueueConfig
+		raw := `{"name": "test_config_queue", "remove_on_complete": false, "max_attempts": 3, "close_behavior": "drop"}`
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			t.Fatalf("Failed to decode config: %v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Expected a valid config, got %v", err)
+		}
+
+		q, err := queues.NewQueueFromConfig(cfg)
+		if err != nil {
+			t.Fatalf("NewQueueFromConfig failed: %v", err)
+		}
+		if q.removeOnComplete || q.maxAttempts != 3 || q.closeBehavior != CloseDrop {
+			t.Errorf("Queue not configured from %s", raw)
+		}
+	})
+}
+
+func TestStoredConfigs(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_stored_configs.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath, WithStoredConfigs())
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue",
+		WithRemoveOnComplete(false),
+		WithDeadLetter("test_queue_failed"),
+		WithProcessingTimeout(time.Minute, TimeoutDeadLetter),
+		WithPendingTTL(time.Hour, TimeoutExpire),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Close()
+
+	t.Run("StoredConfig", func(t *testing.T) {
+		cfg, err := queues.StoredConfig("test_queue")
+		if err != nil {
+			t.Fatalf("StoredConfig failed: %v", err)
+		}
+		if *cfg.RemoveOnComplete || cfg.DeadLetter != "test_queue_failed" || cfg.ProcessingTimeout != time.Minute ||
+			cfg.ProcessingAction != "dead_letter" || cfg.PendingTTL != time.Hour || cfg.PendingAction != "expire" {
+			t.Errorf("Unexpected stored configuration %+v", cfg)
+		}
+
+		if _, err := queues.StoredConfig("test_unknown"); !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected ErrQueueNotFound, got %v", err)
+		}
+	})
+
+	t.Run("AppliedOnOpen", func(t *testing.T) {
+		// Another process opening the queue without options gets the same configuration
+		other := New(dbPath, WithStoredConfigs())
+		defer other.Close()
+		reopened, err := other.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+		if reopened.removeOnComplete || reopened.deadLetterName != "test_queue_failed" || !reopened.processingTimeout ||
+			reopened.ackTimeout != time.Minute || reopened.pendingAction != TimeoutExpire {
+			t.Errorf("Stored configuration not applied: %+v", reopened.config())
+		}
+		reopened.Close()
+	})
+
+	t.Run("OptionsOverride", func(t *testing.T) {
+		reopened, err := queues.NewQueue("test_queue", WithRemoveOnComplete(true))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+		if !reopened.removeOnComplete || reopened.deadLetterName != "test_queue_failed" {
+			t.Errorf("Expected the option to override only its own setting: %+v", reopened.config())
+		}
+
+		cfg, _ := queues.StoredConfig("test_queue")
+		if !*cfg.RemoveOnComplete {
+			t.Error("Expected the stored configuration to be updated")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		plain := New("")
+		defer plain.Close()
+		if _, err := plain.NewQueue("test_plain", WithMaxAttempts(2)); err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		if _, err := plain.StoredConfig("test_plain"); !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected nothing to be stored, got %v", err)
+		}
+	})
+}
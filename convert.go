@@ -0,0 +1,45 @@
+package duckq
+
+import "fmt"
+
+// ConvertToPriority turns the existing regular queue with the given name into a priority queue in
+// place, adding the priority column and index to its table, and returns a handle to it, so a
+// queue doesn't have to be drained and recreated to start using priorities
+// Existing items keep their order and get priority 0, or the priority set by WithDefaultPriority
+// A regular Queue opened through the manager under that name is closed, since it would ignore
+// priorities; regular queues opened on the table elsewhere must be reopened as priority queues
+// Converting a priority queue just opens it
+// Returns ErrQueueNotFound if no queue table has the given name
+func (q *queues) ConvertToPriority(name string, opts ...Option) (*PriorityQueue, error) {
+	exists, err := tableExists(q.client, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect queue %s: %w", name, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrQueueNotFound, name)
+	}
+	hadPriority, err := tableHasColumn(q.client, name, "priority")
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect queue %s: %w", name, err)
+	}
+
+	q.mu.Lock()
+	regular, ok := q.opened[name]
+	q.mu.Unlock()
+	if ok && !regular.hasPriority {
+		regular.Close()
+	}
+
+	// Creating the priority queue adds the missing column and index, see upgradeTable
+	pq, err := q.NewPriorityQueue(name, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert queue %s: %w", name, err)
+	}
+	if !hadPriority && pq.defaultPriority != nil {
+		if _, err := q.client.Exec(fmt.Sprintf("UPDATE %s SET priority = ?", name), *pq.defaultPriority); err != nil {
+			return nil, fmt.Errorf("failed to convert queue %s: %w", name, err)
+		}
+	}
+
+	return pq, nil
+}
@@ -0,0 +1,83 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestConvertToPriority(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_convert.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("KeepsItems", func(t *testing.T) {
+		regular, err := queues.NewQueue("test_convert")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		regular.Enqueue("old")
+
+		pq, err := queues.ConvertToPriority("test_convert")
+		if err != nil {
+			t.Fatalf("ConvertToPriority failed: %v", err)
+		}
+		if !regular.closed.Load() {
+			t.Error("Expected the regular handle to be closed")
+		}
+
+		pq.Enqueue("later", 5)
+		pq.Enqueue("urgent", 0)
+		for _, want := range []string{"old", "urgent", "later"} {
+			item, success := pq.Dequeue()
+			if !success || string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+	})
+
+	t.Run("DefaultPriority", func(t *testing.T) {
+		regular, err := queues.NewQueue("test_convert_default")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		regular.Enqueue("old")
+
+		pq, err := queues.ConvertToPriority("test_convert_default", WithDefaultPriority(3))
+		if err != nil {
+			t.Fatalf("ConvertToPriority failed: %v", err)
+		}
+		pq.Enqueue("later", 5)
+		pq.Enqueue("sooner", 1)
+		for _, want := range []string{"sooner", "old", "later"} {
+			item, success := pq.Dequeue()
+			if !success || string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+	})
+
+	t.Run("AlreadyPriority", func(t *testing.T) {
+		pq, err := queues.ConvertToPriority("test_convert")
+		if err != nil {
+			t.Fatalf("ConvertToPriority failed: %v", err)
+		}
+		pq.Enqueue("again", 2)
+		item, success := pq.Dequeue()
+		if !success || string(item.([]byte)) != "again" {
+			t.Errorf("Expected again, got %v", item)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, err := queues.ConvertToPriority("test_convert_missing"); !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected ErrQueueNotFound, got %v", err)
+		}
+	})
+}
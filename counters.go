@@ -0,0 +1,141 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// countDelta accumulates the changes a transaction makes to the queue's pending and processing items,
+// the tenant usage its enqueues reserved and the work to do once it commits
+type countDelta struct {
+	pending       int64
+	processing    int64
+	tenantPending int64
+	tenantBytes   int64
+	onCommit      []func()
+}
+
+// ApproxLen returns the number of pending items from an in-process counter instead of a COUNT(*)
+// The counter follows every operation of this process as it commits, including dead-lettering,
+// replays, quarantine and Delete; it is re-counted from the table when the queue is opened, after
+// ApplyTimeouts and by the retention maintenance, which corrects the changes made by other processes
+// and by statements the application runs on the table in its own transactions
+// Use Len when the exact number matters
+func (q *Queue) ApproxLen() int {
+	return int(max(q.approxPending.Load(), 0))
+}
+
+// ApproxProcessing returns the number of processing items from an in-process counter, see ApproxLen
+func (q *Queue) ApproxProcessing() int {
+	return int(max(q.approxProcessing.Load(), 0))
+}
+
+// trackedTx is a transaction started by inTrackedTx and the queues whose count changes it accumulates
+type trackedTx struct {
+	tx     *sql.Tx
+	queues []*Queue
+	deltas []*countDelta
+}
+
+// track starts accumulating the count changes and commit hooks of the transaction on q
+func (t *trackedTx) track(q *Queue) {
+	if slices.Contains(t.queues, q) {
+		return
+	}
+
+	delta := &countDelta{}
+	q.txCounts.Store(t.tx, delta)
+	t.queues = append(t.queues, q)
+	t.deltas = append(t.deltas, delta)
+}
+
+// discard gives back the tenant usage reserved by the rolled back transaction
+func (t *trackedTx) discard() {
+	for i, q := range t.queues {
+		if meter := q.tenantMeter(); meter != nil && (t.deltas[i].tenantPending != 0 || t.deltas[i].tenantBytes != 0) {
+			meter.add(-t.deltas[i].tenantPending, -t.deltas[i].tenantBytes)
+		}
+	}
+}
+
+// release stops accumulating the changes of the transaction on its queues
+func (t *trackedTx) release() {
+	for _, q := range t.queues {
+		q.txCounts.Delete(t.tx)
+	}
+}
+
+// apply adds the changes of the committed transaction to the counters of its queues
+func (t *trackedTx) apply() {
+	for i, q := range t.queues {
+		q.applyCounts(t.deltas[i])
+	}
+}
+
+// count records a change to the pending and processing items made as part of tx
+// Changes in transactions not started by inTx or inTrackedTx are left to the reconciliation
+func (q *Queue) count(tx *sql.Tx, pending, processing int64) {
+	if v, ok := q.txCounts.Load(tx); ok {
+		delta := v.(*countDelta)
+		delta.pending += pending
+		delta.processing += processing
+	}
+}
+
+// countStatus records that n items with the given status were added to the table, or removed when n is negative
+func (q *Queue) countStatus(tx *sql.Tx, status string, n int64) {
+	switch status {
+	case "pending":
+		q.count(tx, n, 0)
+	case "processing":
+		q.count(tx, 0, n)
+	}
+}
+
+// afterCommit runs fn once tx commits, and never if it rolls back
+// Like count, it only sees transactions started by inTx or inTrackedTx
+func (q *Queue) afterCommit(tx *sql.Tx, fn func()) {
+	if v, ok := q.txCounts.Load(tx); ok {
+		delta := v.(*countDelta)
+		delta.onCommit = append(delta.onCommit, fn)
+	}
+}
+
+// applyCounts adds the changes of a committed transaction to the counters and runs its commit hooks
+func (q *Queue) applyCounts(delta *countDelta) {
+	for _, fn := range delta.onCommit {
+		fn()
+	}
+	if delta.pending != 0 {
+		q.approxPending.Add(delta.pending)
+		q.signalBackpressure()
+	}
+	if delta.pending > 0 {
+		q.signalWakeup()
+	}
+	if delta.processing != 0 {
+		q.approxProcessing.Add(delta.processing)
+	}
+	// The enqueues that reserved tenant usage are already part of it
+	if meter := q.tenantMeter(); meter != nil && delta.pending != delta.tenantPending {
+		meter.add(delta.pending-delta.tenantPending, 0)
+	}
+}
+
+// reconcileCounts resets the counters to the pending and processing items in the table
+func (q *Queue) reconcileCounts() error {
+	var pending, processing int64
+	err := q.client.QueryRow(fmt.Sprintf(
+		"SELECT COUNT(*) FILTER (WHERE status = 'pending'), COUNT(*) FILTER (WHERE status = 'processing') FROM %s", q.tableName,
+	)).Scan(&pending, &processing)
+	if err != nil {
+		return fmt.Errorf("failed to count items: %w", err)
+	}
+
+	q.approxPending.Store(pending)
+	q.approxProcessing.Store(processing)
+	q.signalBackpressure()
+	q.invalidateTenantUsage()
+	return nil
+}
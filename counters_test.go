@@ -0,0 +1,119 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestApproxLen(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_approx_len.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithMaxAttempts(2))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	expect := func(t *testing.T, pending, processing int) {
+		t.Helper()
+		if q.ApproxLen() != pending || q.ApproxProcessing() != processing {
+			t.Errorf("Expected %d pending and %d processing, got %d and %d", pending, processing, q.ApproxLen(), q.ApproxProcessing())
+		}
+		if q.ApproxLen() != q.Len() {
+			t.Errorf("Expected ApproxLen to match Len %d", q.Len())
+		}
+	}
+
+	t.Run("Operations", func(t *testing.T) {
+		for _, item := range []string{"a", "b", "c"} {
+			q.Enqueue([]byte(item))
+		}
+		expect(t, 3, 0)
+
+		q.Dequeue()
+		_, _, first := q.DequeueWithAckId()
+		_, _, second := q.DequeueWithAckId()
+		expect(t, 0, 2)
+
+		q.Acknowledge(first)
+		q.Nack(second)
+		expect(t, 1, 0)
+
+		// The second nack uses up the attempts and dead-letters the item
+		_, _, second = q.DequeueWithAckId()
+		q.Nack(second)
+		expect(t, 0, 0)
+	})
+
+	t.Run("MovedItems", func(t *testing.T) {
+		q.Enqueue([]byte("dead"))
+		_, _, ackID := q.DequeueWithAckId()
+		q.DeadLetter(ackID, "broken")
+		expect(t, 0, 0)
+
+		// The item dead-lettered by the max attempts is replayed too
+		if _, err := q.ReplayDeadLetters(0, true); err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+		expect(t, 2, 0)
+
+		id, _ := q.EnqueueID([]byte("deleted"))
+		q.Delete(id)
+		expect(t, 2, 0)
+		q.Dequeue()
+		q.Dequeue()
+		expect(t, 0, 0)
+
+		quarantined, err := queues.NewQueue("test_quarantine_queue", WithQuarantine(QuarantinePolicy{MaxFailures: 1, Window: time.Hour}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		id, _ = quarantined.EnqueueID([]byte("crash"))
+		_, _, ackID = quarantined.DequeueWithAckId()
+		if ok, err := quarantined.RecordFailure(ackID, HandlerFailure{Err: errors.New("boom"), Panicked: true}); !ok || err != nil {
+			t.Fatalf("Expected the item to be quarantined, got %v (%v)", ok, err)
+		}
+		if quarantined.ApproxProcessing() != 0 {
+			t.Errorf("Expected the quarantined item to leave processing, got %d", quarantined.ApproxProcessing())
+		}
+		if err := quarantined.ReleaseQuarantined(id); err != nil {
+			t.Fatalf("ReleaseQuarantined failed: %v", err)
+		}
+		if quarantined.ApproxLen() != 1 || quarantined.Len() != 1 {
+			t.Errorf("Expected the released item to be pending, got %d (Len %d)", quarantined.ApproxLen(), quarantined.Len())
+		}
+	})
+
+	t.Run("RollbackNotCounted", func(t *testing.T) {
+		tx, _ := queues.DB().Begin()
+		q.EnqueueInTx(tx, []byte("rolled back"))
+		tx.Rollback()
+		expect(t, 0, 0)
+	})
+
+	t.Run("Reconciled", func(t *testing.T) {
+		if _, err := queues.DB().Exec("INSERT INTO test_queue (data, status) VALUES ('external'::BLOB, 'pending')"); err != nil {
+			t.Fatalf("Failed to insert item: %v", err)
+		}
+		if q.ApproxLen() != 0 {
+			t.Errorf("Expected the external insert to go unnoticed, got %d", q.ApproxLen())
+		}
+
+		if err := queues.RunMaintenance(MaintenanceRetention); err != nil {
+			t.Fatalf("RunMaintenance failed: %v", err)
+		}
+		expect(t, 1, 0)
+
+		q.Purge()
+		expect(t, 0, 0)
+	})
+}
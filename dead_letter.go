@@ -0,0 +1,233 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// deadLetterTableName returns the default name of the dead-letter table that belongs to a queue table
+func deadLetterTableName(tableName string) string {
+	return fmt.Sprintf("%s_dead_letters", tableName)
+}
+
+// deadLetterTable returns the name of the queue's dead-letter table, set with WithDeadLetter
+func (q *Queue) deadLetterTable() string {
+	if q.deadLetterName != "" {
+		return q.deadLetterName
+	}
+
+	return deadLetterTableName(q.tableName)
+}
+
+// deadLetterColumnsSQL defines the columns of dead-letter tables after the key, id, source_queue and data columns
+const deadLetterColumnsSQL = `attempts INTEGER NOT NULL DEFAULT 0,
+		priority INTEGER NOT NULL DEFAULT 0,
+		reason TEXT,
+		checksum BIGINT,
+		created_at TIMESTAMP,
+		dead_lettered_at TIMESTAMP,
+		signature BLOB,
+		` + typedColumnsSQL
+
+// createDeadLetterTable creates a dead-letter table if it doesn't exist
+// Rows are keyed by dead_letter_id and record the queue they came from in source_queue, so queues
+// configured with the same WithDeadLetter table don't collide; they keep the item's original id
+// so it can be correlated after a replay
+// Tables created by older versions get the columns added since then, and tables keyed by id are
+// rebuilt with their rows attributed to sourceQueue
+func createDeadLetterTable(db execQuerier, dlqName, dataType, sourceQueue string) error {
+	if err := upgradeTable(db, dlqName, deadLetterColumnsSQL); err != nil {
+		return err
+	}
+
+	legacy, err := tableExists(db, dlqName)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if legacy, err = tableHasColumn(db, dlqName, "dead_letter_id"); err != nil {
+			return err
+		}
+		legacy = !legacy
+	}
+	if legacy {
+		_, err := db.Exec(fmt.Sprintf(`
+		DROP INDEX IF EXISTS %s_dead_lettered_at_idx;
+		ALTER TABLE %s RENAME TO %s_legacy;
+		`, dlqName, dlqName, dlqName))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`
+	CREATE SEQUENCE IF NOT EXISTS %s_id_seq START 1;
+	CREATE TABLE IF NOT EXISTS %s (
+		dead_letter_id BIGINT PRIMARY KEY DEFAULT nextval('%s_id_seq'),
+		id BIGINT NOT NULL,
+		source_queue TEXT NOT NULL,
+		data %s NOT NULL,
+		%s
+	);
+	CREATE INDEX IF NOT EXISTS %s_dead_lettered_at_idx ON %s (dead_lettered_at);
+	CREATE INDEX IF NOT EXISTS %s_source_queue_idx ON %s (source_queue);
+	`, dlqName, dlqName, dlqName, dataType, deadLetterColumnsSQL, dlqName, dlqName, dlqName, dlqName))
+	if err != nil || !legacy {
+		return err
+	}
+
+	columns := columnNames(deadLetterColumnsSQL)
+	_, err = db.Exec(
+		fmt.Sprintf(
+			"INSERT INTO %s (id, source_queue, data, %s) SELECT id, CAST(? AS TEXT), data, %s FROM %s_legacy ORDER BY id",
+			dlqName, columns, columns, dlqName,
+		),
+		sourceQueue,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("DROP TABLE %s_legacy", dlqName))
+	return err
+}
+
+// DeadLetter moves a processing item into the queue's dead-letter table
+// The reason is stored alongside the item so operators can inspect why it failed
+// Returns true if the item was found and moved, false otherwise
+func (q *Queue) DeadLetter(ackID string, reason string) bool {
+	return q.deadLetterWhere(reason, "ack_id = ? AND status = 'processing'", ackID) == nil
+}
+
+// deadLetterWhere moves the items matching condition into the dead-letter table
+// Returns ErrAckNotFound when no item matches
+func (q *Queue) deadLetterWhere(reason string, condition string, args ...any) error {
+	return q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			moved, err := q.deadLetterInTx(tx, reason, condition, args...)
+			if err == nil && moved == 0 {
+				return ErrAckNotFound
+			}
+			return err
+		})
+	})
+}
+
+// deadLetterInTx moves the items matching condition into the dead-letter table as part of tx
+// Returns the number of items moved
+func (q *Queue) deadLetterInTx(tx *sql.Tx, reason string, condition string, args ...any) (int64, error) {
+	priorityColumn := "0"
+	if q.hasPriority {
+		priorityColumn = "priority"
+	}
+
+	var pending, processing int64
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT COUNT(*) FILTER (WHERE status = 'pending'), COUNT(*) FILTER (WHERE status = 'processing') FROM %s WHERE %s", q.tableName, condition),
+		args...,
+	).Scan(&pending, &processing)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(
+		fmt.Sprintf(
+			"INSERT INTO %s (id, source_queue, data, attempts, priority, reason, checksum, signature, created_at, dead_lettered_at, %s) SELECT id, CAST(? AS TEXT), data, attempts, %s, CAST(? AS TEXT), checksum, signature, created_at, CAST(? AS TIMESTAMP), %s FROM %s WHERE %s",
+			q.deadLetterTable(), typedColumns, priorityColumn, typedColumns, q.tableName, condition,
+		),
+		append([]any{q.tableName, reason, q.now()}, args...)...,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := result.RowsAffected()
+	if err != nil || moved == 0 {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", q.tableName, condition), args...); err != nil {
+		return 0, err
+	}
+	q.count(tx, -pending, -processing)
+
+	return moved, nil
+}
+
+// maxAttemptsReason is recorded on items dead-lettered by WithMaxAttempts
+const maxAttemptsReason = "max attempts exceeded"
+
+// deadLetterExhausted moves the items matching condition that used up the attempts allowed by
+// WithMaxAttempts into the dead-letter table as part of tx, instead of letting them be requeued
+func (q *Queue) deadLetterExhausted(tx *sql.Tx, condition string, args ...any) (int64, error) {
+	if q.maxAttempts == 0 {
+		return 0, nil
+	}
+
+	return q.deadLetterInTx(tx, maxAttemptsReason, condition+" AND attempts >= ?", append(args, q.maxAttempts)...)
+}
+
+// ReplayDeadLetters moves up to n items from the dead-letter table back into the queue as pending
+// Only the items dead-lettered by this queue are replayed when the table is shared with other queues
+// Items are replayed oldest dead-lettered first and join the back of the queue
+// A non-positive n replays every dead-lettered item
+// When resetAttempts is true the attempt counter of each replayed item starts over at 0
+// Returns the number of items that were replayed
+func (q *Queue) ReplayDeadLetters(n int, resetAttempts bool) (int, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	dlqName := q.deadLetterTable()
+
+	// Select the batch once so the insert and the delete operate on the same rows
+	selectIDs := fmt.Sprintf("SELECT dead_letter_id FROM %s WHERE source_queue = ? ORDER BY dead_lettered_at ASC, dead_letter_id ASC", dlqName)
+	if n > 0 {
+		selectIDs += fmt.Sprintf(" LIMIT %d", n)
+	}
+
+	columns := "id, data, status, attempts, checksum, signature, created_at, updated_at, " + typedColumns
+	values := "id, data, 'pending', CASE WHEN CAST(? AS BOOLEAN) THEN 0 ELSE attempts END, checksum, signature, CAST(? AS TIMESTAMP), CAST(? AS TIMESTAMP), " + typedColumns
+	if q.hasPriority {
+		columns += ", priority"
+		values += ", priority"
+	}
+
+	var replayed int64
+	err := q.retryReinsert(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			now := q.now()
+			result, err := tx.Exec(
+				fmt.Sprintf(
+					"INSERT INTO %s (%s) SELECT %s FROM %s WHERE dead_letter_id IN (%s)",
+					q.tableName, columns, values, dlqName, selectIDs,
+				),
+				resetAttempts, now, now, q.tableName,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to replay dead letters: %w", err)
+			}
+
+			replayed, err = result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to replay dead letters: %w", err)
+			}
+
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE dead_letter_id IN (%s)", dlqName, selectIDs), q.tableName)
+			if err != nil {
+				return fmt.Errorf("failed to remove replayed dead letters: %w", err)
+			}
+			q.count(tx, replayed, 0)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(replayed), nil
+}
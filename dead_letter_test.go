@@ -0,0 +1,206 @@
+package duckq
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestDeadLetterReplay(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_dead_letter.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	// Move three items into the dead-letter table
+	for i := 0; i < 3; i++ {
+		q.Enqueue([]byte(fmt.Sprintf("item-%d", i)))
+		_, success, ackID := q.DequeueWithAckId()
+		if !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+
+		if !q.DeadLetter(ackID, "handler failed") {
+			t.Fatal("DeadLetter failed")
+		}
+	}
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		if q.Len() != 0 {
+			t.Errorf("Expected queue length 0, got %d", q.Len())
+		}
+
+		var count int
+		row := q.client.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", q.deadLetterTable()))
+		if err := row.Scan(&count); err != nil {
+			t.Errorf("Error checking dead letters: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("Expected 3 dead letters, got %d", count)
+		}
+
+		if q.DeadLetter("invalid-ack-id", "unknown") {
+			t.Error("DeadLetter with invalid ID should fail")
+		}
+	})
+
+	t.Run("ReplayWithLimit", func(t *testing.T) {
+		replayed, err := q.ReplayDeadLetters(2, false)
+		if err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+		if replayed != 2 {
+			t.Errorf("Expected 2 replayed items, got %d", replayed)
+		}
+		if q.Len() != 2 {
+			t.Errorf("Expected queue length 2, got %d", q.Len())
+		}
+
+		// The oldest dead letter should come back first with its attempts preserved
+		item, success, _ := q.DequeueWithAckId()
+		if !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+		if string(item.([]byte)) != "item-0" {
+			t.Errorf("Expected 'item-0', got '%s'", string(item.([]byte)))
+		}
+
+		var attempts int
+		row := q.client.QueryRow(fmt.Sprintf("SELECT attempts FROM %s WHERE status = 'processing'", q.tableName))
+		if err := row.Scan(&attempts); err != nil {
+			t.Errorf("Error checking attempts: %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("Expected 2 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("ReplayAllResettingAttempts", func(t *testing.T) {
+		replayed, err := q.ReplayDeadLetters(0, true)
+		if err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+		if replayed != 1 {
+			t.Errorf("Expected 1 replayed item, got %d", replayed)
+		}
+
+		var attempts int
+		row := q.client.QueryRow(fmt.Sprintf("SELECT attempts FROM %s WHERE data = ?", q.tableName), []byte("item-2"))
+		if err := row.Scan(&attempts); err != nil {
+			t.Errorf("Error checking attempts: %v", err)
+		}
+		if attempts != 0 {
+			t.Errorf("Expected 0 attempts, got %d", attempts)
+		}
+
+		replayed, err = q.ReplayDeadLetters(0, true)
+		if err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+		if replayed != 0 {
+			t.Errorf("Expected 0 replayed items from an empty dead-letter table, got %d", replayed)
+		}
+	})
+
+	t.Run("PriorityQueueKeepsPriority", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority_queue")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue([]byte("urgent"), 1)
+		_, success, ackID := pq.DequeueWithAckId()
+		if !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+		if !pq.DeadLetter(ackID, "handler failed") {
+			t.Fatal("DeadLetter failed")
+		}
+
+		pq.Enqueue([]byte("bulk"), 5)
+		if _, err := pq.ReplayDeadLetters(0, false); err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+
+		item, success := pq.Dequeue()
+		if !success {
+			t.Fatal("Dequeue failed")
+		}
+		if string(item.([]byte)) != "urgent" {
+			t.Errorf("Expected 'urgent', got '%s'", string(item.([]byte)))
+		}
+	})
+
+	t.Run("SharedTable", func(t *testing.T) {
+		first, err := queues.NewQueue("test_shared_first", WithDeadLetter("test_shared_dead_letters"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		second, err := queues.NewQueue("test_shared_second", WithDeadLetter("test_shared_dead_letters"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		// Both items have id 1 in their own queue
+		for _, queue := range []*Queue{first, second} {
+			queue.Enqueue([]byte(queue.tableName))
+			_, _, ackID := queue.DequeueWithAckId()
+			if !queue.DeadLetter(ackID, "handler failed") {
+				t.Fatalf("DeadLetter on %s failed", queue.tableName)
+			}
+		}
+
+		replayed, err := second.ReplayDeadLetters(0, false)
+		if err != nil || replayed != 1 {
+			t.Fatalf("Expected 1 replayed item, got %d, %v", replayed, err)
+		}
+		item, _ := second.Dequeue()
+		if string(item.([]byte)) != "test_shared_second" {
+			t.Errorf("Expected the second queue's item, got '%s'", string(item.([]byte)))
+		}
+		if first.Len() != 0 {
+			t.Errorf("Expected the first queue's item to stay dead-lettered, got length %d", first.Len())
+		}
+	})
+	t.Run("ReplayWhileReading", func(t *testing.T) {
+		// Transactions started before a dead-lettering delete make DuckDB report the replayed ID as a duplicate for a while
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				tx, err := queues.DB().Begin()
+				if err != nil {
+					continue
+				}
+				tx.QueryRow("SELECT COUNT(*) FROM test_queue").Scan(new(int))
+				time.Sleep(time.Millisecond)
+				tx.Commit()
+			}
+		}()
+
+		for i := 0; i < 20; i++ {
+			q.Enqueue([]byte("item"))
+			_, _, ackID := q.DequeueWithAckId()
+			q.DeadLetter(ackID, "handler failed")
+			if _, err := q.ReplayDeadLetters(0, false); err != nil {
+				t.Fatalf("ReplayDeadLetters failed: %v", err)
+			}
+			q.Dequeue()
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EnqueueWithDeadline adds an item that must be acknowledged by deadline
+// Unlike WithPendingTTL, which counts from when an item becomes visible, the deadline is an
+// absolute time that covers the item's whole life, so it applies while the item is pending or
+// processing, across redeliveries. Past its deadline the item is no longer delivered and is
+// dead-lettered with the reason "deadline exceeded" by ApplyTimeouts and the TTL maintenance task
+// Returns true if the operation was successful
+func (q *Queue) EnqueueWithDeadline(item any, deadline time.Time) bool {
+	if err := q.checkOpen(); err != nil {
+		return err == errDropped
+	}
+
+	err := q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			id, err := q.enqueueInTx(tx, item, sql.NullTime{})
+			if err != nil || id == 0 {
+				return err
+			}
+
+			_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET deadline = ? WHERE id = ?", q.tableName), deadline.UTC(), id)
+			return err
+		})
+	})
+	if err != nil {
+		return false
+	}
+
+	q.trackDeadlines()
+	return true
+}
+
+// trackDeadlines makes the maintenance scheduler sweep the queue's deadlines
+func (q *Queue) trackDeadlines() {
+	if !q.deadlines.Swap(true) && q.wakeMaintenance != nil {
+		q.wakeMaintenance()
+	}
+}
+
+// detectDeadlines tracks the deadlines of items enqueued with EnqueueWithDeadline before the queue was opened
+func (q *Queue) detectDeadlines() {
+	var found bool
+	err := q.client.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE deadline IS NOT NULL)", q.tableName)).Scan(&found)
+	if err == nil && found {
+		q.trackDeadlines()
+	}
+}
@@ -0,0 +1,91 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestEnqueueWithDeadline(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_deadline.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath, WithMaintenanceInterval(MaintenanceTTL, 0))
+	defer queues.Close()
+
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q, err := queues.NewQueue("test_queue", WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	deadLetterReasons := func() []string {
+		rows, err := queues.DB().Query("SELECT reason FROM test_queue_dead_letters ORDER BY id")
+		if err != nil {
+			t.Fatalf("Failed to read dead letters: %v", err)
+		}
+		defer rows.Close()
+
+		var reasons []string
+		for rows.Next() {
+			var reason string
+			rows.Scan(&reason)
+			reasons = append(reasons, reason)
+		}
+		return reasons
+	}
+
+	t.Run("Pending", func(t *testing.T) {
+		if !q.EnqueueWithDeadline([]byte("late"), clock.Now().Add(time.Minute)) {
+			t.Fatal("EnqueueWithDeadline failed")
+		}
+		q.Enqueue([]byte("no deadline"))
+
+		clock.Advance(2 * time.Minute)
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "no deadline" {
+			t.Errorf("Expected the item past its deadline to be skipped, got %v", item)
+		}
+
+		report, err := q.ApplyTimeouts()
+		if err != nil {
+			t.Fatalf("ApplyTimeouts failed: %v", err)
+		}
+		if report.DeadLettered != 1 {
+			t.Errorf("Expected 1 dead-lettered item, got %+v", report)
+		}
+		if reasons := deadLetterReasons(); len(reasons) != 1 || reasons[0] != deadlineReason {
+			t.Errorf("Expected a deadline exceeded dead letter, got %v", reasons)
+		}
+	})
+
+	t.Run("Processing", func(t *testing.T) {
+		q.EnqueueWithDeadline([]byte("slow"), clock.Now().Add(time.Minute))
+		if _, success, _ := q.DequeueWithAckId(); !success {
+			t.Fatal("Expected the item before its deadline to be delivered")
+		}
+
+		clock.Advance(2 * time.Minute)
+		if _, err := q.ApplyTimeouts(); err != nil {
+			t.Fatalf("ApplyTimeouts failed: %v", err)
+		}
+		if reasons := deadLetterReasons(); len(reasons) != 2 {
+			t.Errorf("Expected the unacknowledged item to be dead-lettered, got %v", reasons)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		q.EnqueueWithDeadline([]byte("kept"), clock.Now().Add(time.Hour))
+		reopened, err := queues.NewQueue("test_queue", WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+		if !reopened.deadlines.Load() {
+			t.Error("Expected the reopened queue to track the stored deadline")
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DelayedQueue extends Queue with items that only become visible at a scheduled time
+// Dequeue returns due items in the order they became due
+type DelayedQueue struct {
+	*Queue
+}
+
+// newDelayedQueue creates a new DuckDB-based delayed queue
+func newDelayedQueue(db *sql.DB, tableName string, opts ...Option) (*DelayedQueue, error) {
+	q, err := newQueue(db, tableName, append([]Option{withDelayed()}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DelayedQueue{Queue: q}, nil
+}
+
+// withDelayed orders dequeues by the time items became visible
+func withDelayed() Option {
+	return func(q *Queue) {
+		q.delayed = true
+	}
+}
+
+// EnqueueAt adds an item that becomes visible to dequeues at the given time
+// Returns true if the operation was successful
+func (dq *DelayedQueue) EnqueueAt(item any, at time.Time) bool {
+	_, err := dq.EnqueueAtID(item, at)
+	return err == nil
+}
+
+// EnqueueAtID adds an item that becomes visible at the given time and returns its generated ID,
+// which CancelScheduled and Reschedule accept
+// A queue closed with CloseDrop drops the item and returns 0
+func (dq *DelayedQueue) EnqueueAtID(item any, at time.Time) (int64, error) {
+	if err := dq.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var id int64
+	visibleAt := sql.NullTime{Time: at.UTC(), Valid: true}
+	err := dq.retryEnqueue(1, func() error {
+		return dq.inTx(func(tx *sql.Tx) error {
+			var err error
+			id, err = dq.enqueueInTx(tx, item, visibleAt)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// EnqueueAfter adds an item that becomes visible to dequeues after delay
+// Returns true if the operation was successful
+func (dq *DelayedQueue) EnqueueAfter(item any, delay time.Duration) bool {
+	return dq.EnqueueAt(item, dq.now().Add(delay))
+}
+
+// Due returns the pending items that are visible now, in the order they would be dequeued
+func (dq *DelayedQueue) Due() ([]Message, error) {
+	messages, err := dq.queryMessages(
+		fmt.Sprintf(
+			"SELECT %s FROM %s WHERE status = 'pending' AND (visible_at IS NULL OR visible_at <= ?) ORDER BY %s",
+			dq.messageColumns(), dq.tableName, dq.dequeueOrder(),
+		),
+		dq.now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// Upcoming returns the pending items that become visible within window from now, soonest first
+// Items that are already due are not included
+func (dq *DelayedQueue) Upcoming(window time.Duration) ([]Message, error) {
+	now := dq.now()
+	messages, err := dq.queryMessages(
+		fmt.Sprintf(
+			"SELECT %s FROM %s WHERE status = 'pending' AND visible_at > ? AND visible_at <= ? ORDER BY visible_at ASC, id ASC",
+			dq.messageColumns(), dq.tableName,
+		),
+		now, now.Add(window),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upcoming messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// CancelScheduled removes a pending item before it is dequeued
+// Returns true if the item was removed, false if it was not found or already dequeued
+func (dq *DelayedQueue) CancelScheduled(id int64) bool {
+	err := dq.retry(func() error {
+		return dq.inTx(func(tx *sql.Tx) error {
+			var offloadKey sql.NullString
+			err := tx.QueryRow(
+				fmt.Sprintf("DELETE FROM %s WHERE id = ? AND status = 'pending' RETURNING offload_key", dq.tableName),
+				id,
+			).Scan(&offloadKey)
+			if err != nil {
+				return err
+			}
+
+			dq.releasePayload(tx, offloadKey)
+			dq.count(tx, -1, 0)
+			return nil
+		})
+	})
+	return err == nil
+}
+
+// Reschedule moves a pending item to become visible at a new time, earlier or later
+// Returns true if the item was rescheduled, false if it was not found or already dequeued
+func (dq *DelayedQueue) Reschedule(id int64, at time.Time) bool {
+	err := dq.retry(func() error {
+		return dq.inTx(func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				fmt.Sprintf("UPDATE %s SET visible_at = ?, updated_at = ? WHERE id = ? AND status = 'pending'", dq.tableName),
+				at.UTC(), dq.now(), id,
+			)
+			if err != nil {
+				return err
+			}
+			if err := requireRows(result); err != nil {
+				return err
+			}
+
+			// An item moved forward may be due now
+			dq.afterCommit(tx, dq.signalWakeup)
+			return nil
+		})
+	})
+	return err == nil
+}
+
+// scheduleKeysTableName returns the name of the table holding the pending item of every key used with ScheduleUnique
+func scheduleKeysTableName(tableName string) string {
+	return fmt.Sprintf("%s_schedule_keys", tableName)
+}
+
+// initScheduleKeys creates the schedule keys table of a delayed queue as part of tx,
+// filling it from the pending items of tables that were created before it
+func (q *Queue) initScheduleKeys(tx *sql.Tx) error {
+	exists, err := tableExists(tx, scheduleKeysTableName(q.tableName))
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+	CREATE TABLE %s (
+		schedule_key TEXT PRIMARY KEY,
+		id BIGINT NOT NULL
+	);
+	`, scheduleKeysTableName(q.tableName)))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(
+		"INSERT INTO %s (schedule_key, id) SELECT schedule_key, MAX(id) FROM %s WHERE schedule_key IS NOT NULL AND status = 'pending' GROUP BY schedule_key",
+		scheduleKeysTableName(q.tableName), q.tableName,
+	))
+	return err
+}
+
+// ScheduleUnique adds an item that becomes visible at the given time, or replaces the payload and
+// time of the pending item already scheduled with key, so repeated calls leave exactly one pending item
+// Once the pending item is dequeued the key can be scheduled again
+// The key is claimed in the queue's schedule keys table, so concurrent calls from other
+// handles and processes conflict in the database and are retried rather than both inserting
+// Returns ErrDuplicate when other calls claimed the key on every attempt of the queue's RetryPolicy
+func (dq *DelayedQueue) ScheduleUnique(key string, at time.Time, item any) error {
+	visibleAt := sql.NullTime{Time: at.UTC(), Valid: true}
+	schedule := func() error {
+		return dq.inTx(func(tx *sql.Tx) error {
+			var id int64
+			var stored sql.NullString
+			err := tx.QueryRow(
+				fmt.Sprintf("SELECT t.id, t.offload_key FROM %s AS k JOIN %s AS t ON t.id = k.id WHERE k.schedule_key = ? AND t.status = 'pending'", scheduleKeysTableName(dq.tableName), dq.tableName),
+				key,
+			).Scan(&id, &stored)
+			if errors.Is(err, sql.ErrNoRows) {
+				return dq.scheduleInTx(tx, key, item, visibleAt)
+			}
+			if err != nil {
+				return err
+			}
+
+			return dq.rescheduleInTx(tx, id, stored, item, visibleAt)
+		})
+	}
+
+	err := dq.retryEnqueue(1, schedule)
+	for attempt := 1; attempt < dq.retryPolicy.MaxAttempts && isDuplicateKey(err); attempt++ {
+		// Another call claimed the key first, so a fresh attempt replaces its item instead
+		time.Sleep(dq.retryPolicy.backoff(attempt))
+		err = dq.retry(schedule)
+	}
+	if isDuplicateKey(err) {
+		return fmt.Errorf("failed to schedule item: %w: %s", ErrDuplicate, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to schedule item: %w", err)
+	}
+
+	return nil
+}
+
+// scheduleInTx inserts an item for a key that has no pending item and claims the key for it as part of tx
+// A concurrent claim of the same key makes one of the transactions fail with a conflict or a duplicate key
+func (dq *DelayedQueue) scheduleInTx(tx *sql.Tx, key string, item any, visibleAt sql.NullTime) error {
+	id, err := dq.enqueueColumnsInTx(tx, item, visibleAt, []columnValue{{"schedule_key", key}})
+	if err != nil || id == 0 {
+		return err
+	}
+
+	// Updating the row of a key whose item was dequeued, or inserting a new one, both conflict with a concurrent claim
+	result, err := tx.Exec(fmt.Sprintf("UPDATE %s SET id = ? WHERE schedule_key = ?", scheduleKeysTableName(dq.tableName)), id, key)
+	if err != nil {
+		return err
+	}
+	if requireRows(result) == nil {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (schedule_key, id) VALUES (?, ?)", scheduleKeysTableName(dq.tableName)), key, id)
+	return err
+}
+
+// rescheduleInTx replaces the payload and visibility time of the pending item with id as part of tx,
+// releasing the offloaded payload with the offload key stored, which it replaces
+func (dq *DelayedQueue) rescheduleInTx(tx *sql.Tx, id int64, stored sql.NullString, item any, visibleAt sql.NullTime) error {
+	if err := dq.checkOpen(); err != nil {
+		if err == errDropped {
+			return nil
+		}
+		return err
+	}
+
+	data, payloadType, err := dq.encode(item)
+	if err != nil {
+		return err
+	}
+	if err := dq.checkPayloadSize(data); err != nil {
+		return err
+	}
+	data, offloadKey, err := dq.offloadPayload(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("UPDATE %s SET data = ?, payload_type = ?, offload_key = ?, checksum = ?, signature = ?, visible_at = ?, updated_at = ? WHERE id = ?", dq.tableName),
+		data, payloadType, offloadKey, dq.payloadChecksum(data), dq.payloadSignature(data), visibleAt, dq.now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	dq.releasePayload(tx, stored)
+
+	if err := dq.writeStructColumns(tx, item, "id = ?", id); err != nil {
+		return err
+	}
+	if err := dq.mirrorInTx(tx, "id = ?", id); err != nil {
+		return err
+	}
+
+	dq.afterCommit(tx, dq.signalWakeup)
+	return nil
+}
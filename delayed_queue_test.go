@@ -0,0 +1,236 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestDelayedQueue(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_delayed_queue.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	dq, err := queues.NewDelayedQueue("test_delayed_queue")
+	if err != nil {
+		t.Fatalf("Failed to create delayed queue: %v", err)
+	}
+
+	t.Run("DequeueOnlyDueItems", func(t *testing.T) {
+		if !dq.EnqueueAfter([]byte("later"), time.Hour) {
+			t.Fatal("EnqueueAfter failed")
+		}
+		if !dq.EnqueueAt([]byte("overdue"), time.Now().Add(-time.Minute)) {
+			t.Fatal("EnqueueAt failed")
+		}
+		if !dq.Enqueue([]byte("now")) {
+			t.Fatal("Enqueue failed")
+		}
+
+		for _, expected := range []string{"overdue", "now"} {
+			item, success := dq.Dequeue()
+			if !success {
+				t.Fatalf("Expected to dequeue '%s'", expected)
+			}
+			if string(item.([]byte)) != expected {
+				t.Errorf("Expected '%s', got '%s'", expected, item)
+			}
+		}
+
+		if _, success := dq.Dequeue(); success {
+			t.Error("Dequeue should not return an item before it is due")
+		}
+		if dq.Len() != 1 {
+			t.Errorf("Expected the scheduled item to stay pending, got length %d", dq.Len())
+		}
+	})
+
+	t.Run("DueAndUpcoming", func(t *testing.T) {
+		dq.EnqueueAfter([]byte("soon"), 10*time.Minute)
+		dq.EnqueueAt([]byte("ready"), time.Now().Add(-time.Second))
+
+		due, err := dq.Due()
+		if err != nil {
+			t.Fatalf("Due failed: %v", err)
+		}
+		if len(due) != 1 || string(due[0].Data) != "ready" {
+			t.Errorf("Expected only 'ready' to be due, got %v", due)
+		}
+
+		upcoming, err := dq.Upcoming(30 * time.Minute)
+		if err != nil {
+			t.Fatalf("Upcoming failed: %v", err)
+		}
+		if len(upcoming) != 1 || string(upcoming[0].Data) != "soon" {
+			t.Fatalf("Expected only 'soon' within the window, got %v", upcoming)
+		}
+		if upcoming[0].VisibleAt.Before(time.Now().Add(9 * time.Minute)) {
+			t.Errorf("Unexpected visible time %v", upcoming[0].VisibleAt)
+		}
+
+		upcoming, err = dq.Upcoming(2 * time.Hour)
+		if err != nil {
+			t.Fatalf("Upcoming failed: %v", err)
+		}
+		if len(upcoming) != 2 || string(upcoming[1].Data) != "later" {
+			t.Fatalf("Expected 'soon' then 'later', got %v", upcoming)
+		}
+
+		// Rescheduling reorders the preview by the new visible time
+		dq.Reschedule(upcoming[1].ID, time.Now().Add(5*time.Minute))
+		upcoming, err = dq.Upcoming(2 * time.Hour)
+		if err != nil {
+			t.Fatalf("Upcoming failed: %v", err)
+		}
+		if len(upcoming) != 2 || string(upcoming[0].Data) != "later" {
+			t.Errorf("Expected 'later' first after rescheduling, got %v", upcoming)
+		}
+	})
+
+	t.Run("CancelAndReschedule", func(t *testing.T) {
+		queue, err := queues.NewDelayedQueue("test_reschedule_queue")
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+
+		cancelled, err := queue.EnqueueAtID([]byte("cancelled"), time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("EnqueueAtID failed: %v", err)
+		}
+		moved, err := queue.EnqueueAtID([]byte("moved"), time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("EnqueueAtID failed: %v", err)
+		}
+
+		if !queue.CancelScheduled(cancelled) {
+			t.Error("CancelScheduled failed")
+		}
+		if queue.CancelScheduled(cancelled) {
+			t.Error("CancelScheduled should fail for a removed item")
+		}
+
+		if !queue.Reschedule(moved, time.Now().Add(-time.Second)) {
+			t.Fatal("Reschedule failed")
+		}
+		item, success := queue.Dequeue()
+		if !success || string(item.([]byte)) != "moved" {
+			t.Fatalf("Expected the rescheduled item to be due, got '%v'", item)
+		}
+
+		if queue.Reschedule(moved, time.Now().Add(time.Hour)) {
+			t.Error("Reschedule should fail for a dequeued item")
+		}
+		if queue.Len() != 0 {
+			t.Errorf("Expected an empty queue, got length %d", queue.Len())
+		}
+	})
+
+	t.Run("ScheduleUnique", func(t *testing.T) {
+		queue, err := queues.NewDelayedQueue("test_unique_queue")
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := queue.ScheduleUnique("report", time.Now().Add(time.Hour), []byte("first")); err != nil {
+					t.Errorf("ScheduleUnique failed: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if queue.Len() != 1 {
+			t.Fatalf("Expected exactly one pending item, got %d", queue.Len())
+		}
+
+		if err := queue.ScheduleUnique("report", time.Now().Add(-time.Second), []byte("second")); err != nil {
+			t.Fatalf("ScheduleUnique failed: %v", err)
+		}
+		item, success := queue.Dequeue()
+		if !success || string(item.([]byte)) != "second" {
+			t.Fatalf("Expected the replaced item to be due, got '%v'", item)
+		}
+
+		// A dequeued item no longer holds its key
+		if err := queue.ScheduleUnique("report", time.Now().Add(time.Hour), []byte("third")); err != nil {
+			t.Fatalf("ScheduleUnique failed: %v", err)
+		}
+		if queue.Len() != 1 {
+			t.Errorf("Expected the key to be scheduled again, got length %d", queue.Len())
+		}
+	})
+
+	t.Run("ScheduleUniqueAcrossHandles", func(t *testing.T) {
+		other := New(dbPath)
+		defer other.Close()
+
+		first, err := queues.NewDelayedQueue("test_unique_handles_queue")
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+		second, err := other.NewDelayedQueue("test_unique_handles_queue")
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for _, queue := range []*DelayedQueue{first, second, first, second} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := queue.ScheduleUnique("nightly", time.Now().Add(time.Hour), []byte("job")); err != nil {
+					t.Errorf("ScheduleUnique failed: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if first.Len() != 1 {
+			t.Errorf("Expected exactly one pending item, got %d", first.Len())
+		}
+	})
+
+	t.Run("ScheduleUniqueWithoutRetries", func(t *testing.T) {
+		other := New(dbPath)
+		defer other.Close()
+
+		first, err := queues.NewDelayedQueue("test_unique_no_retry_queue", WithRetryPolicy(NoRetry))
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+		second, err := other.NewDelayedQueue("test_unique_no_retry_queue", WithRetryPolicy(NoRetry))
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+
+		// Losing a race for the key fails right away instead of retrying until it wins
+		var wg sync.WaitGroup
+		for _, queue := range []*DelayedQueue{first, second, first, second} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := queue.ScheduleUnique("hourly", time.Now().Add(time.Hour), []byte("job"))
+				if err != nil && !errors.Is(err, ErrDuplicate) && !isTransient(err) {
+					t.Errorf("Expected ErrDuplicate or a conflict, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if first.Len() != 1 {
+			t.Errorf("Expected exactly one pending item, got %d", first.Len())
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// dependenciesTableName returns the name of the table holding the acknowledgements a queue's items wait for
+func dependenciesTableName(tableName string) string {
+	return fmt.Sprintf("%s_dependencies", tableName)
+}
+
+// createDependenciesTable creates the dependency table of a queue if it doesn't exist
+// Each row holds back message_id until the item depends_on is acknowledged
+func createDependenciesTable(db execer, tableName string) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		message_id BIGINT NOT NULL,
+		depends_on BIGINT NOT NULL
+	);
+	`, dependenciesTableName(tableName)))
+	return err
+}
+
+// EnqueueAfterAck adds an item that only becomes visible once every item with one of the
+// given IDs, see EnqueueID, is acknowledged, so simple dependency chains need no orchestrator
+// IDs that are not in the queue or already completed are considered acknowledged
+// An item whose dependency is dead-lettered or deleted stays pending but is never delivered
+// Returns the item's ID, 0 when a queue closed with CloseDrop dropped it
+func (q *Queue) EnqueueAfterAck(item any, dependsOnIDs ...int64) (int64, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var id int64
+	err := q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) (err error) {
+			id, err = q.enqueueInTx(tx, item, sql.NullTime{})
+			if err != nil || id == 0 || len(dependsOnIDs) == 0 {
+				return err
+			}
+
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(dependsOnIDs)), ", ")
+			args := []any{id}
+			for _, dependsOn := range dependsOnIDs {
+				args = append(args, dependsOn)
+			}
+			_, err = tx.Exec(
+				fmt.Sprintf("INSERT INTO %s (message_id, depends_on) SELECT DISTINCT CAST(? AS BIGINT), id FROM %s WHERE id IN (%s) AND status <> 'completed'", dependenciesTableName(q.tableName), q.tableName, placeholders),
+				args...,
+			)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue item: %w", err)
+	}
+
+	return id, nil
+}
+
+// Dependencies returns the IDs of the unacknowledged items the item with the given ID waits for
+func (q *Queue) Dependencies(id int64) ([]int64, error) {
+	rows, err := q.client.Query(fmt.Sprintf("SELECT depends_on FROM %s WHERE message_id = ? ORDER BY depends_on", dependenciesTableName(q.tableName)), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var dependsOn int64
+		if err := rows.Scan(&dependsOn); err != nil {
+			return nil, fmt.Errorf("failed to list dependencies: %w", err)
+		}
+		ids = append(ids, dependsOn)
+	}
+
+	return ids, rows.Err()
+}
+
+// resolveDependencies releases the items waiting for the acknowledged item with the given ID as part of tx
+func (q *Queue) resolveDependencies(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE depends_on = ?", dependenciesTableName(q.tableName)), id)
+	return err
+}
+
+// dependencyFreeSQL matches the items of the queue that don't wait for any acknowledgement
+func (q *Queue) dependencyFreeSQL() string {
+	return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s d WHERE d.message_id = %s.id)", dependenciesTableName(q.tableName), q.tableName)
+}
@@ -0,0 +1,84 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestEnqueueAfterAck(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_dependencies.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Chain", func(t *testing.T) {
+		extract, _ := q.EnqueueID([]byte("extract"))
+		load, err := q.EnqueueAfterAck([]byte("load"), extract)
+		if err != nil {
+			t.Fatalf("EnqueueAfterAck failed: %v", err)
+		}
+		if deps, _ := q.Dependencies(load); len(deps) != 1 || deps[0] != extract {
+			t.Errorf("Expected load to wait for %d, got %v", extract, deps)
+		}
+
+		item, _, ackID := q.DequeueWithAckId()
+		if string(item.([]byte)) != "extract" {
+			t.Fatalf("Expected extract first, got %v", item)
+		}
+		if _, success := q.Dequeue(); success {
+			t.Fatal("Expected load to wait for extract's acknowledgement")
+		}
+
+		q.Acknowledge(ackID)
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "load" {
+			t.Errorf("Expected load after extract was acknowledged, got %v", item)
+		}
+	})
+
+	t.Run("FanIn", func(t *testing.T) {
+		a, _ := q.EnqueueID([]byte("a"))
+		b, _ := q.EnqueueID([]byte("b"))
+		q.EnqueueAfterAck([]byte("merge"), a, b, 999)
+
+		_, _, ackA := q.DequeueWithAckId()
+		q.Acknowledge(ackA)
+		_, _, ackB := q.DequeueWithAckId()
+		if _, success := q.Dequeue(); success {
+			t.Fatal("Expected merge to wait for b")
+		}
+
+		q.Acknowledge(ackB)
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "merge" {
+			t.Errorf("Expected merge once a and b were acknowledged, got %v", item)
+		}
+	})
+
+	t.Run("NackKeepsWaiting", func(t *testing.T) {
+		first, _ := q.EnqueueID([]byte("first"))
+		q.EnqueueAfterAck([]byte("second"), first)
+
+		_, _, ackID := q.DequeueWithAckId()
+		q.Nack(ackID)
+		item, _, ackID := q.DequeueWithAckId()
+		if string(item.([]byte)) != "first" {
+			t.Fatalf("Expected the nacked item to be delivered again, got %v", item)
+		}
+		q.Acknowledge(ackID)
+
+		if item, _ := q.Dequeue(); string(item.([]byte)) != "second" {
+			t.Errorf("Expected second, got %v", item)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package duckq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DequeueFilter restricts the pending items DequeueMatching considers with a SQL condition,
+// which may reference attached databases and Parquet, CSV or JSON files read by DuckDB
+type DequeueFilter struct {
+	condition func(q *Queue) string
+	args      []any
+}
+
+// Where matches items for which condition holds
+// condition is a SQL boolean expression over the queue table's columns, with ? placeholders for args
+func Where(condition string, args ...any) DequeueFilter {
+	return DequeueFilter{
+		condition: func(*Queue) string { return condition },
+		args:      args,
+	}
+}
+
+// InDataset matches items whose value of the SQL expression expr appears in column of dataset
+// dataset is a file path or glob DuckDB can read, e.g. "allowlist.parquet" or "exports/*.csv",
+// or a table or view, including one in an attached database, given as a bare or dotted name
+func InDataset(expr, dataset, column string) DequeueFilter {
+	return DequeueFilter{
+		condition: func(*Queue) string {
+			return fmt.Sprintf("%s IN (SELECT %s FROM %s)", expr, quoteIdentifier(column), datasetSQL(dataset))
+		},
+	}
+}
+
+// PayloadInDataset matches items whose JSON payload has a value at jsonPath that appears in
+// column of dataset, see InDataset. Values are compared as text, and payloads that are not
+// valid JSON never match
+func PayloadInDataset(jsonPath, dataset, column string) DequeueFilter {
+	return DequeueFilter{
+		condition: func(q *Queue) string {
+			return fmt.Sprintf("json_extract_string(%s, ?) IN (SELECT CAST(%s AS VARCHAR) FROM %s)", q.jsonPayloadSQL(), quoteIdentifier(column), datasetSQL(dataset))
+		},
+		args: []any{jsonPath},
+	}
+}
+
+// datasetSQL returns the FROM clause source for a dataset
+// Paths are quoted as string literals, which DuckDB reads with the reader matching their extension
+func datasetSQL(dataset string) string {
+	if strings.ContainsAny(dataset, `/\*`) || isFilePath(dataset) {
+		return "'" + strings.ReplaceAll(dataset, "'", "''") + "'"
+	}
+
+	return dataset
+}
+
+// isFilePath reports whether a dotted name ends in a file extension DuckDB reads
+func isFilePath(name string) bool {
+	lower := strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst"))
+	for _, ext := range []string{".parquet", ".csv", ".tsv", ".json", ".jsonl", ".ndjson"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quoteIdentifier quotes a column name for use in SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// DequeueMatching removes and returns the next pending item matching every filter, in dequeue
+// order, with an acknowledgment ID
+// Returns ErrEmptyQueue when no pending item matches, and the error from DuckDB when
+// a filter cannot be evaluated, e.g. because a dataset file is missing
+func (q *Queue) DequeueMatching(filters ...DequeueFilter) (any, string, error) {
+	conditions := make([]string, 0, len(filters))
+	var args []any
+	for _, filter := range filters {
+		conditions = append(conditions, "("+filter.condition(q)+")")
+		args = append(args, filter.args...)
+	}
+
+	row, err := q.dequeueWhere(true, strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return q.decode(row.data, row.payloadType), row.ackID, nil
+}
@@ -0,0 +1,97 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestDequeueMatching(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_dequeue_matching.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	dir := t.TempDir()
+	allowlist := filepath.Join(dir, "allowlist.parquet")
+	if _, err := queues.DB().Exec("COPY (SELECT * FROM (VALUES (42), (99)) AS t(customer_id)) TO '" + allowlist + "' (FORMAT PARQUET)"); err != nil {
+		t.Fatalf("Failed to write allowlist: %v", err)
+	}
+	blocklist := filepath.Join(dir, "blocklist.csv")
+	if err := os.WriteFile(blocklist, []byte("customer_id\n7\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte(`{"customer_id": 7}`))
+	q.Enqueue([]byte(`{"customer_id": 42}`))
+	q.Enqueue([]byte("not json"))
+
+	t.Run("PayloadInParquet", func(t *testing.T) {
+		item, ackID, err := q.DequeueMatching(PayloadInDataset("$.customer_id", allowlist, "customer_id"))
+		if err != nil {
+			t.Fatalf("DequeueMatching failed: %v", err)
+		}
+		if string(item.([]byte)) != `{"customer_id": 42}` || ackID == "" {
+			t.Errorf("Expected the allowlisted customer with an ack ID, got %s", item)
+		}
+
+		if _, _, err := q.DequeueMatching(PayloadInDataset("$.customer_id", allowlist, "customer_id")); !errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected ErrEmptyQueue, got %v", err)
+		}
+	})
+
+	t.Run("StructColumnInCSV", func(t *testing.T) {
+		schema, err := NewStructSchema(orderTask{})
+		if err != nil {
+			t.Fatalf("NewStructSchema failed: %v", err)
+		}
+		orders, err := queues.NewQueue("test_orders", WithStructPayload(schema))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		orders.Enqueue(orderTask{CustomerID: 7, Total: 5})
+		orders.Enqueue(orderTask{CustomerID: 42, Total: 50})
+		orders.Enqueue(orderTask{CustomerID: 7, Total: 50})
+
+		item, _, err := orders.DequeueMatching(Where("total > ?", 10), InDataset("customer_id", blocklist, "customer_id"))
+		if err != nil {
+			t.Fatalf("DequeueMatching failed: %v", err)
+		}
+		if order := item.(orderTask); order.CustomerID != 7 || order.Total != 50 {
+			t.Errorf("Expected the large order of the listed customer, got %#v", order)
+		}
+	})
+
+	t.Run("MissingDataset", func(t *testing.T) {
+		_, _, err := q.DequeueMatching(PayloadInDataset("$.customer_id", filepath.Join(dir, "missing.parquet"), "customer_id"))
+		if err == nil || errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected an error for a missing dataset, got %v", err)
+		}
+		if q.Len() != 2 {
+			t.Errorf("Expected the remaining items to stay pending, got length %d", q.Len())
+		}
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		if _, err := queues.DB().Exec("CREATE TABLE vip AS SELECT 'not json' AS payload"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		item, _, err := q.DequeueMatching(InDataset("CAST(data AS VARCHAR)", "vip", "payload"))
+		if err != nil {
+			t.Fatalf("DequeueMatching failed: %v", err)
+		}
+		if string(item.([]byte)) != "not json" {
+			t.Errorf("Unexpected item: %s", item)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package duckqtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/goptics/duckq"
+)
+
+// pollInterval is how often RequireEventuallyEmpty checks the queue
+const pollInterval = 10 * time.Millisecond
+
+// RequireEventuallyEmpty fails the test unless q has no pending items within timeout
+// Use it to wait for consumers running in other goroutines to drain the queue
+func RequireEventuallyEmpty(t testing.TB, q duckq.QueueAPI, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		n := q.Len()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue still has %d pending items after %v", n, timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// RequireLen fails the test unless q has exactly want pending items
+func RequireLen(t testing.TB, q duckq.QueueAPI, want int) {
+	t.Helper()
+
+	if n := q.Len(); n != want {
+		t.Fatalf("expected %d pending items, got %d", want, n)
+	}
+}
+
+// RequireValues fails the test unless the pending items of q are want, in dequeue order
+// Strings on either side are compared as []byte, the way queues return them
+func RequireValues(t testing.TB, q duckq.QueueAPI, want ...any) {
+	t.Helper()
+
+	got := q.Values()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d pending items, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(normalize(got[i]), normalize(want[i])) {
+			t.Fatalf("pending item %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+// RequireDequeue fails the test unless q has a pending item, and returns it with its ack ID
+func RequireDequeue(t testing.TB, q duckq.QueueAPI) (any, string) {
+	t.Helper()
+
+	item, success, ackID := q.DequeueWithAckId()
+	if !success {
+		t.Fatal("expected a pending item, the queue is empty")
+	}
+
+	return item, ackID
+}
+
+// RequireStatus fails the test unless the message with the given id exists and has status
+func RequireStatus(t testing.TB, q duckq.QueueAPI, id int64, status string) duckq.Message {
+	t.Helper()
+
+	m, ok := q.Get(id)
+	if !ok {
+		t.Fatalf("message %d not found", id)
+	}
+	if m.Status != status {
+		t.Fatalf("message %d: expected status %q, got %q", id, status, m.Status)
+	}
+
+	return m
+}
+
+// normalize turns strings into []byte so they compare equal to dequeued payloads
+func normalize(item any) any {
+	if s, ok := item.(string); ok {
+		return []byte(s)
+	}
+
+	return item
+}
@@ -0,0 +1,65 @@
+package duckqtest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goptics/duckq"
+)
+
+func TestHelpers(t *testing.T) {
+	t.Run("Assertions", func(t *testing.T) {
+		clock := NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+		q := NewQueue(WithClock(clock))
+
+		q.Enqueue("a")
+		clock.Advance(time.Hour)
+		q.Enqueue([]byte("b"))
+
+		RequireLen(t, q, 2)
+		RequireValues(t, q, "a", []byte("b"))
+
+		item, ackID := RequireDequeue(t, q)
+		if string(item.([]byte)) != "a" {
+			t.Errorf("Expected 'a', got '%s'", item)
+		}
+		m := RequireStatus(t, q, 1, "processing")
+		if m.AckID != ackID {
+			t.Errorf("Expected ack ID %s, got %s", ackID, m.AckID)
+		}
+		if !m.CreatedAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected the clock's time, got %v", m.CreatedAt)
+		}
+		if b := RequireStatus(t, q, 2, "pending"); !b.CreatedAt.Equal(clock.Now()) {
+			t.Errorf("Expected the advanced time, got %v", b.CreatedAt)
+		}
+	})
+
+	t.Run("RequireEventuallyEmpty", func(t *testing.T) {
+		// Create a temporary database file
+		dbPath := "test_duckqtest_helpers.db"
+
+		// Cleanup after test
+		defer os.Remove(dbPath)
+		queues := duckq.New(dbPath)
+		defer queues.Close()
+
+		q, err := queues.NewQueue("test_drain_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			q.Enqueue([]byte("job"))
+		}
+
+		go func() {
+			for i := 0; i < 5; i++ {
+				time.Sleep(5 * time.Millisecond)
+				q.Dequeue()
+			}
+		}()
+
+		RequireEventuallyEmpty(t, q, time.Second)
+	})
+}
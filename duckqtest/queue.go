@@ -0,0 +1,350 @@
+// Package duckqtest provides an in-memory duckq.QueueAPI implementation and helpers
+// for unit-testing code that consumes duckq queues without a DuckDB file
+package duckqtest
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goptics/duckq"
+)
+
+// Queue is an in-memory duckq.QueueAPI that behaves like a regular duckq queue created with
+// the default options: items are dequeued in FIFO order, acknowledged items are removed,
+// and string payloads come back as []byte
+// It is safe for concurrent use but keeps nothing once the process exits
+type Queue struct {
+	mu          sync.Mutex
+	entries     []*entry
+	deadLetters []*entry
+	acked       map[string]bool
+	nextID      int64
+	nextAckID   int64
+	closed      bool
+	clock       duckq.Clock
+}
+
+// entry is an item held by the queue along with its message metadata
+type entry struct {
+	message duckq.Message
+	item    any
+	reason  string
+}
+
+var _ duckq.QueueAPI = (*Queue)(nil)
+
+// QueueOption is a function type that can be used to configure an in-memory Queue
+type QueueOption func(*Queue)
+
+// WithClock sets the clock the queue timestamps messages with
+func WithClock(clock duckq.Clock) QueueOption {
+	return func(q *Queue) {
+		q.clock = clock
+	}
+}
+
+// NewQueue creates an empty in-memory queue
+func NewQueue(opts ...QueueOption) *Queue {
+	q := &Queue{acked: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// now returns the current time of the queue's clock in UTC
+func (q *Queue) now() time.Time {
+	if q.clock == nil {
+		return time.Now().UTC()
+	}
+
+	return q.clock.Now().UTC()
+}
+
+// Enqueue adds an item to the queue
+// Returns false once the queue is closed
+func (q *Queue) Enqueue(item any) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	q.nextID++
+	now := q.now()
+	e := &entry{
+		message: duckq.Message{ID: q.nextID, Status: "pending", CreatedAt: now, UpdatedAt: now},
+		item:    item,
+	}
+	if s, ok := item.(string); ok {
+		e.item = []byte(s)
+	}
+	if data, ok := e.item.([]byte); ok {
+		e.message.Data = data
+	}
+
+	q.entries = append(q.entries, e)
+	return true
+}
+
+// Dequeue removes and returns the next pending item
+func (q *Queue) Dequeue() (any, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.nextPending()
+	if i < 0 {
+		return nil, false
+	}
+
+	e := q.entries[i]
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	return e.item, true
+}
+
+// DequeueWithAckId leases the next pending item until it is acknowledged, nacked or dead-lettered
+func (q *Queue) DequeueWithAckId() (any, bool, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.nextPending()
+	if i < 0 {
+		return nil, false, ""
+	}
+
+	q.nextAckID++
+	e := q.entries[i]
+	e.message.Status = "processing"
+	e.message.AckID = fmt.Sprintf("ack-%d", q.nextAckID)
+	e.message.Attempts++
+	e.message.UpdatedAt = q.now()
+
+	return e.item, true, e.message.AckID
+}
+
+// nextPending returns the index of the first pending entry, or -1, the caller must hold mu
+func (q *Queue) nextPending() int {
+	if q.closed {
+		return -1
+	}
+
+	for i, e := range q.entries {
+		if e.message.Status == "pending" {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// processing returns the index of the processing entry leased with ackID, or -1, the caller must hold mu
+func (q *Queue) processing(ackID string) int {
+	for i, e := range q.entries {
+		if e.message.Status == "processing" && e.message.AckID == ackID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Acknowledge removes a processing item
+func (q *Queue) Acknowledge(ackID string) bool {
+	return q.Ack(ackID) == nil
+}
+
+// Ack removes a processing item, returning duckq.ErrDuplicateAck when it was already
+// acknowledged and duckq.ErrAckNotFound when the ack ID is unknown
+func (q *Queue) Ack(ackID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.processing(ackID)
+	if i < 0 {
+		if q.acked[ackID] {
+			return fmt.Errorf("%w: %s", duckq.ErrDuplicateAck, ackID)
+		}
+		return fmt.Errorf("%w: %s", duckq.ErrAckNotFound, ackID)
+	}
+
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	q.acked[ackID] = true
+	return nil
+}
+
+// Nack returns a processing item to its position in the queue
+func (q *Queue) Nack(ackID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.processing(ackID)
+	if i < 0 {
+		return false
+	}
+
+	e := q.entries[i]
+	e.message.Status = "pending"
+	e.message.AckID = ""
+	e.message.UpdatedAt = q.now()
+	return true
+}
+
+// DeadLetter moves a processing item to the dead letters
+func (q *Queue) DeadLetter(ackID string, reason string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.processing(ackID)
+	if i < 0 {
+		return false
+	}
+
+	e := q.entries[i]
+	e.reason = reason
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	q.deadLetters = append(q.deadLetters, e)
+	return true
+}
+
+// ReplayDeadLetters moves up to n dead letters, oldest first, to the back of the queue
+// A non-positive n replays every dead letter
+func (q *Queue) ReplayDeadLetters(n int, resetAttempts bool) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, duckq.ErrQueueClosed
+	}
+
+	if n <= 0 || n > len(q.deadLetters) {
+		n = len(q.deadLetters)
+	}
+
+	now := q.now()
+	for _, e := range q.deadLetters[:n] {
+		e.message.Status = "pending"
+		e.message.AckID = ""
+		e.message.CreatedAt = now
+		e.message.UpdatedAt = now
+		e.reason = ""
+		if resetAttempts {
+			e.message.Attempts = 0
+		}
+		q.entries = append(q.entries, e)
+	}
+	q.deadLetters = q.deadLetters[n:]
+
+	return n, nil
+}
+
+// Get returns the message with the given id, regardless of its status
+func (q *Queue) Get(id int64) (duckq.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, e := range q.entries {
+		if e.message.ID == id {
+			return e.message, true
+		}
+	}
+
+	return duckq.Message{}, false
+}
+
+// Delete removes the message with the given id, regardless of its status
+func (q *Queue) Delete(id int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, e := range q.entries {
+		if e.message.ID == id {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Len returns the number of pending items
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var count int
+	for _, e := range q.entries {
+		if e.message.Status == "pending" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Values returns all pending items in the order they would be dequeued
+func (q *Queue) Values() []any {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var items []any
+	for _, e := range q.entries {
+		if e.message.Status == "pending" {
+			items = append(items, e.item)
+		}
+	}
+
+	return items
+}
+
+// Messages returns the messages matching all given filters, in dequeue order
+func (q *Queue) Messages(filters ...duckq.Filter) ([]duckq.Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var messages []duckq.Message
+	for _, e := range q.entries {
+		if matches(e.message, filters) {
+			messages = append(messages, e.message)
+		}
+	}
+
+	return messages, nil
+}
+
+// matches reports whether m satisfies every filter
+func matches(m duckq.Message, filters []duckq.Filter) bool {
+	for _, f := range filters {
+		if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, m.Status) {
+			return false
+		}
+		if !f.CreatedAfter.IsZero() && !m.CreatedAt.After(f.CreatedAfter) {
+			return false
+		}
+		if !f.CreatedBefore.IsZero() && !m.CreatedAt.Before(f.CreatedBefore) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Purge removes all items from the queue
+func (q *Queue) Purge() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.entries = nil
+}
+
+// Close makes the queue reject enqueues and report itself as empty
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	return nil
+}
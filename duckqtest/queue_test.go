@@ -0,0 +1,92 @@
+package duckqtest
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/goptics/duckq"
+)
+
+func TestQueue(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_duckqtest.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := duckq.New(dbPath)
+	defer queues.Close()
+
+	durable, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	// The in-memory queue must behave like the DuckDB queue it stands in for
+	implementations := map[string]duckq.QueueAPI{
+		"InMemory": NewQueue(),
+		"DuckDB":   durable,
+	}
+
+	for name, q := range implementations {
+		t.Run(name, func(t *testing.T) {
+			q.Enqueue("first")
+			q.Enqueue([]byte("second"))
+			q.Enqueue([]byte("third"))
+
+			if q.Len() != 3 {
+				t.Fatalf("Expected length 3, got %d", q.Len())
+			}
+
+			item, success, ackID := q.DequeueWithAckId()
+			if !success || string(item.([]byte)) != "first" {
+				t.Fatalf("Expected 'first' as []byte, got %#v", item)
+			}
+			if !q.Nack(ackID) {
+				t.Fatal("Nack failed")
+			}
+
+			item, _, ackID = q.DequeueWithAckId()
+			if string(item.([]byte)) != "first" {
+				t.Errorf("Expected the nacked item to keep its position, got '%s'", item)
+			}
+			if err := q.Ack(ackID); err != nil {
+				t.Fatalf("Ack failed: %v", err)
+			}
+			if err := q.Ack(ackID); !errors.Is(err, duckq.ErrDuplicateAck) {
+				t.Errorf("Expected ErrDuplicateAck, got %v", err)
+			}
+			if err := q.Ack("unknown"); !errors.Is(err, duckq.ErrAckNotFound) {
+				t.Errorf("Expected ErrAckNotFound, got %v", err)
+			}
+
+			_, _, ackID = q.DequeueWithAckId()
+			if !q.DeadLetter(ackID, "rejected") {
+				t.Fatal("DeadLetter failed")
+			}
+			if replayed, err := q.ReplayDeadLetters(0, true); err != nil || replayed != 1 {
+				t.Fatalf("Expected 1 replayed item, got %d, %v", replayed, err)
+			}
+
+			pending, err := q.Messages(duckq.Filter{Statuses: []string{"pending"}})
+			if err != nil || len(pending) != 2 || pending[1].ID != 2 || pending[1].Attempts != 0 {
+				t.Errorf("Expected the replayed message last with its attempts reset, got %+v, %v", pending, err)
+			}
+
+			values := q.Values()
+			if len(values) != 2 || string(values[0].([]byte)) != "third" || string(values[1].([]byte)) != "second" {
+				t.Errorf("Expected the replayed item at the back, got %q", values)
+			}
+
+			q.Purge()
+			if _, success := q.Dequeue(); success {
+				t.Error("Dequeue should fail on a purged queue")
+			}
+
+			q.Close()
+			if q.Enqueue([]byte("late")) {
+				t.Error("Enqueue should fail on a closed queue")
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package duckq
+
+import "errors"
+
+var (
+	// ErrQueueClosed is returned by operations on a queue after Close
+	ErrQueueClosed = errors.New("queue is closed")
+	// ErrQueueNotFound is returned when an operation refers to a queue table that doesn't exist
+	ErrQueueNotFound = errors.New("queue not found")
+	// ErrTemplateNotFound is returned when a queue is created from a template that wasn't defined
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrQueueExists is returned when an operation would create a queue table that already exists
+	ErrQueueExists = errors.New("queue already exists")
+	// ErrEmptyQueue is returned by dequeue operations when no pending item is available
+	ErrEmptyQueue = errors.New("queue is empty")
+	// ErrAckNotFound is returned when an ack ID doesn't belong to any leased item
+	ErrAckNotFound = errors.New("ack ID not found")
+	// ErrDuplicateAck is returned when an item is acknowledged more than once
+	ErrDuplicateAck = errors.New("item already acknowledged")
+	// ErrDuplicate is returned when concurrent calls kept claiming the same unique key until the retries ran out
+	ErrDuplicate = errors.New("duplicate key")
+	// ErrPayloadTooLarge is returned when a payload exceeds the size set with WithMaxPayloadSize
+	ErrPayloadTooLarge = errors.New("payload too large")
+	// ErrInvalidPriority is returned when a priority is outside the levels a queue accepts
+	ErrInvalidPriority = errors.New("invalid priority")
+	// ErrCorruptPayload is matched by every error reporting a payload that failed verification
+	ErrCorruptPayload = errors.New("corrupt payload")
+	// ErrChecksumMismatch is returned when a stored payload no longer matches its checksum, it matches ErrCorruptPayload
+	ErrChecksumMismatch error = corruptPayloadError("payload checksum mismatch")
+	// ErrSignatureMismatch is returned when a stored payload doesn't match the signature made with WithHMAC,
+	// it matches ErrCorruptPayload
+	ErrSignatureMismatch error = corruptPayloadError("payload signature mismatch")
+	// ErrInvalidPayload is returned when a stored payload cannot be decoded into the requested type
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ErrInvalidOption is returned when a queue is created with an option it cannot honor
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrShutdownTimeout is returned by Run when in-flight handlers don't finish within the shutdown timeout
+	ErrShutdownTimeout = errors.New("shutdown timed out")
+	// ErrHandlerPanic is returned by handlers wrapped with Recover when they panic
+	ErrHandlerPanic = errors.New("handler panicked")
+	// ErrQuotaExceeded is returned when an operation would take a tenant over its quota
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+	// ErrRateLimited is returned when an enqueue exceeds the rate set with WithEnqueueRateLimit
+	ErrRateLimited = errors.New("enqueue rate limit exceeded")
+	// ErrBatchTooLarge is returned when a batch holds more items than WithEnqueueRateLimit allows at once,
+	// so it can never be enqueued under RateLimitReject
+	ErrBatchTooLarge = errors.New("batch exceeds the enqueue rate limit")
+	// ErrDatabaseBusy is returned by Open when another process holds the lock on the database file
+	ErrDatabaseBusy = errors.New("database is locked by another process")
+	// ErrReadOnlyQuery is returned by Query for SQL that is not a single SELECT statement over the queue's tables
+	ErrReadOnlyQuery = errors.New("query is not read-only")
+)
+
+// corruptPayloadError is a payload verification failure, matching ErrCorruptPayload with errors.Is
+type corruptPayloadError string
+
+// Error returns the failure
+func (e corruptPayloadError) Error() string {
+	return string(e)
+}
+
+// Is reports whether target is ErrCorruptPayload
+func (e corruptPayloadError) Is(target error) bool {
+	return target == ErrCorruptPayload
+}
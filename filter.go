@@ -0,0 +1,48 @@
+package duckq
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter narrows the messages selected by listing and copying operations
+// Zero-valued fields don't filter anything
+type Filter struct {
+	// Statuses restricts messages to the given statuses, e.g. "pending" or "processing"
+	Statuses []string
+	// CreatedAfter restricts messages to those created after the given time
+	CreatedAfter time.Time
+	// CreatedBefore restricts messages to those created before the given time
+	CreatedBefore time.Time
+}
+
+// whereFilters builds a WHERE clause matching every given filter
+// Returns an empty clause when nothing is filtered
+func whereFilters(filters ...Filter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	for _, f := range filters {
+		if len(f.Statuses) > 0 {
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(f.Statuses)), ", ")
+			conditions = append(conditions, "status IN ("+placeholders+")")
+			for _, status := range f.Statuses {
+				args = append(args, status)
+			}
+		}
+		if !f.CreatedAfter.IsZero() {
+			conditions = append(conditions, "created_at > ?")
+			args = append(args, f.CreatedAfter.UTC())
+		}
+		if !f.CreatedBefore.IsZero() {
+			conditions = append(conditions, "created_at < ?")
+			args = append(args, f.CreatedBefore.UTC())
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,87 @@
+package duckq
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// processedKeyRetention is how long the keys recorded by Idempotent are remembered
+const processedKeyRetention = 7 * 24 * time.Hour
+
+// processedTableName returns the name of the table recording the idempotency keys a queue's consumers processed
+func processedTableName(tableName string) string {
+	return fmt.Sprintf("%s_processed", tableName)
+}
+
+// createProcessedTable creates the processed-keys table of a queue if it doesn't exist
+func createProcessedTable(db execer, tableName string) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		dedup_key TEXT PRIMARY KEY,
+		processed_at TIMESTAMP NOT NULL
+	);
+	`, processedTableName(tableName)))
+	return err
+}
+
+// Idempotent skips items whose idempotency key was already processed, so a redelivered item, or
+// the same request enqueued twice, is acknowledged without running the handler again
+// key derives the idempotency key of an item; an empty key always runs the handler
+// The key is recorded once the handler succeeds, and remembered for 7 days by the retention
+// maintenance, which gives effectively-once processing as long as a key isn't delivered to two
+// consumers at the same time
+func (q *Queue) Idempotent(key func(item any) string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, item any) error {
+			k := key(item)
+			if k == "" {
+				return next(ctx, item)
+			}
+
+			processed, err := q.Processed(k)
+			if err != nil {
+				return err
+			}
+			if processed {
+				return nil
+			}
+
+			if err := next(ctx, item); err != nil {
+				return err
+			}
+
+			return q.MarkProcessed(k)
+		}
+	}
+}
+
+// Processed reports whether the idempotency key was recorded by MarkProcessed or Idempotent
+func (q *Queue) Processed(key string) (bool, error) {
+	var processed bool
+	err := q.client.QueryRow(
+		fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE dedup_key = ? AND processed_at >= ?)", processedTableName(q.tableName)),
+		key, q.now().Add(-processedKeyRetention),
+	).Scan(&processed)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up idempotency key: %w", err)
+	}
+
+	return processed, nil
+}
+
+// MarkProcessed records that the work identified by the idempotency key was done
+func (q *Queue) MarkProcessed(key string) error {
+	err := q.retry(func() error {
+		_, err := q.client.Exec(
+			fmt.Sprintf("INSERT OR REPLACE INTO %s (dedup_key, processed_at) VALUES (?, ?)", processedTableName(q.tableName)),
+			key, q.now(),
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+
+	return nil
+}
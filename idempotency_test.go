@@ -0,0 +1,75 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestIdempotent(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_idempotent.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	var handled []string
+	fail := false
+	handler := q.Idempotent(func(item any) string {
+		return string(item.([]byte))
+	})(func(ctx context.Context, item any) error {
+		if fail {
+			return errors.New("failed")
+		}
+		handled = append(handled, string(item.([]byte)))
+		return nil
+	})
+
+	t.Run("SkipsDuplicates", func(t *testing.T) {
+		for _, key := range []string{"order-1", "order-2", "order-1"} {
+			if err := handler(context.Background(), []byte(key)); err != nil {
+				t.Fatalf("Handler failed: %v", err)
+			}
+		}
+		if len(handled) != 2 {
+			t.Errorf("Expected the duplicate to be skipped, got %v", handled)
+		}
+		if processed, _ := q.Processed("order-1"); !processed {
+			t.Error("Expected order-1 to be recorded")
+		}
+	})
+
+	t.Run("FailureNotRecorded", func(t *testing.T) {
+		fail = true
+		if err := handler(context.Background(), []byte("order-3")); err == nil {
+			t.Fatal("Expected the handler error")
+		}
+		if processed, _ := q.Processed("order-3"); processed {
+			t.Error("Expected the failed key not to be recorded")
+		}
+
+		fail = false
+		handler(context.Background(), []byte("order-3"))
+		if len(handled) != 3 {
+			t.Errorf("Expected the retried key to be handled, got %v", handled)
+		}
+	})
+
+	t.Run("EmptyKey", func(t *testing.T) {
+		handler(context.Background(), []byte(""))
+		handler(context.Background(), []byte(""))
+		if len(handled) != 5 {
+			t.Errorf("Expected items without a key to always be handled, got %v", handled)
+		}
+	})
+}
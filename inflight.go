@@ -0,0 +1,49 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WithMaxInFlight limits how many items the queue's consumer ID can hold leased at once
+// Once the consumer holds n unexpired leases, DequeueWithAckId, DequeueWithID and Run find the
+// queue empty until it acknowledges or nacks some of them, so a single worker can't lease more
+// items than it can handle and let their leases expire together
+// The leases are counted in the table, so processes sharing a consumer ID share the limit
+func WithMaxInFlight(n int) Option {
+	return func(q *Queue) {
+		q.maxInFlight = n
+	}
+}
+
+// WithMaxProcessing limits how many items of the queue can be leased at once across every
+// consumer, so a downstream system with a strict concurrency limit isn't overloaded however
+// many workers there are
+// Once n items are processing, DequeueWithAckId, DequeueWithID and Run find the queue empty until
+// some are acknowledged, nacked or requeued; unlike WithMaxInFlight, expired leases still count
+// until WithProcessingTimeout or RequeueNoAckRows requeues them
+func WithMaxProcessing(n int) Option {
+	return func(q *Queue) {
+		q.maxProcessing = n
+	}
+}
+
+// inFlightFull reports whether the queue's consumer holds as many leases as WithMaxInFlight
+// allows, or the queue has as many processing items as WithMaxProcessing allows
+func (q *Queue) inFlightFull(tx *sql.Tx, now time.Time) (bool, error) {
+	if q.maxInFlight == 0 && q.maxProcessing == 0 {
+		return false, nil
+	}
+
+	var leased, processing int
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT COUNT(*) FILTER (WHERE consumer_id = ? AND (lease_expires_at IS NULL OR lease_expires_at > ?)), COUNT(*) FROM %s WHERE status = 'processing'", q.tableName),
+		q.consumerID, now,
+	).Scan(&leased, &processing)
+	if err != nil {
+		return false, fmt.Errorf("failed to count leases: %w", err)
+	}
+
+	return (q.maxInFlight > 0 && leased >= q.maxInFlight) || (q.maxProcessing > 0 && processing >= q.maxProcessing), nil
+}
@@ -0,0 +1,118 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestMaxInFlight(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_max_in_flight.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	worker, err := queues.NewQueue("test_queue", WithConsumerID("worker-1"), WithMaxInFlight(2), WithAckTimeout(time.Minute), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	other, err := queues.NewQueue("test_queue", WithConsumerID("worker-2"))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		worker.Enqueue([]byte("job"))
+	}
+
+	var ackIDs []string
+	t.Run("Capped", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, success, ackID := worker.DequeueWithAckId()
+			if !success {
+				t.Fatalf("Expected lease %d to be granted", i+1)
+			}
+			ackIDs = append(ackIDs, ackID)
+		}
+		if _, success, _ := worker.DequeueWithAckId(); success {
+			t.Error("Expected the third lease to be refused")
+		}
+		if _, success := worker.Dequeue(); !success {
+			t.Error("Expected Dequeue without a lease to be allowed")
+		}
+	})
+
+	t.Run("PerConsumer", func(t *testing.T) {
+		if _, success, _ := other.DequeueWithAckId(); !success {
+			t.Error("Expected another consumer to lease items")
+		}
+	})
+
+	t.Run("Released", func(t *testing.T) {
+		worker.Ack(ackIDs[0])
+		if _, success, _ := worker.DequeueWithAckId(); !success {
+			t.Error("Expected a lease once one was acknowledged")
+		}
+
+		// Expired leases no longer count
+		clock.Advance(2 * time.Minute)
+		if _, success, _ := worker.DequeueWithAckId(); !success {
+			t.Error("Expected a lease once the others expired")
+		}
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_invalid", WithMaxInFlight(-1)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
+
+func TestMaxProcessing(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_max_processing.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	first, err := queues.NewQueue("test_queue", WithConsumerID("worker-1"), WithMaxProcessing(2))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	second, err := queues.NewQueue("test_queue", WithConsumerID("worker-2"), WithMaxProcessing(2))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		first.Enqueue([]byte("job"))
+	}
+
+	t.Run("AcrossConsumers", func(t *testing.T) {
+		_, _, ackID := first.DequeueWithAckId()
+		if _, success, _ := second.DequeueWithAckId(); !success {
+			t.Fatal("Expected the second lease to be granted")
+		}
+		if _, success, _ := second.DequeueWithAckId(); success {
+			t.Error("Expected the third lease to be refused")
+		}
+
+		first.Nack(ackID)
+		if _, success, _ := second.DequeueWithAckId(); !success {
+			t.Error("Expected a lease once one was nacked")
+		}
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_invalid", WithMaxProcessing(-1)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
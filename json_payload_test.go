@@ -0,0 +1,141 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestJSONPayload(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_json_payload.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	registry := NewTypeRegistry()
+	registry.Register(emailTask{})
+
+	q, err := queues.NewQueue("test_queue", WithJSONPayload(), WithTypeRegistry(registry), WithChecksum(true))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("StoresJSONColumn", func(t *testing.T) {
+		columnType, err := tableColumnType(queues.DB(), "test_queue", "data")
+		if err != nil {
+			t.Fatalf("Failed to inspect column: %v", err)
+		}
+		if columnType != "JSON" {
+			t.Errorf("Expected a JSON data column, got %s", columnType)
+		}
+	})
+
+	t.Run("EnqueueDequeue", func(t *testing.T) {
+		if !q.Enqueue([]byte(`{"customer_id": 42}`)) {
+			t.Fatal("Enqueue of a JSON []byte failed")
+		}
+		if !q.Enqueue(map[string]int{"customer_id": 7}) {
+			t.Fatal("Enqueue of a map failed")
+		}
+		if !q.Enqueue(emailTask{To: "a@example.com"}) {
+			t.Fatal("Enqueue of a registered type failed")
+		}
+		if q.Enqueue([]byte("not json")) {
+			t.Error("Enqueue of invalid JSON should fail")
+		}
+		if _, err := q.EnqueueID("not json"); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Expected ErrInvalidPayload, got %v", err)
+		}
+
+		item, _ := q.Dequeue()
+		if string(item.([]byte)) != `{"customer_id": 42}` {
+			t.Errorf("Unexpected first item: %v", item)
+		}
+		item, _ = q.Dequeue()
+		if string(item.([]byte)) != `{"customer_id":7}` {
+			t.Errorf("Unexpected second item: %v", item)
+		}
+		item, _ = q.Dequeue()
+		if task, ok := item.(emailTask); !ok || task.To != "a@example.com" {
+			t.Errorf("Expected the registered type back, got %#v", item)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		q.Purge()
+		q.Enqueue(map[string]int{"customer_id": 42, "order": 1})
+		q.Enqueue(map[string]int{"customer_id": 7, "order": 2})
+
+		messages, err := q.Search("$.customer_id", 42, Page{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(messages) != 1 || string(messages[0].Data) != `{"customer_id":42,"order":1}` {
+			t.Errorf("Unexpected search result: %v", messages)
+		}
+
+		rows, err := q.Query(t.Context(), "SELECT SUM(CAST(data->>'order' AS INTEGER)) FROM "+q.TableName())
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		defer rows.Close()
+		var total int
+		if !rows.Next() || rows.Scan(&total) != nil || total != 3 {
+			t.Errorf("Expected the JSON operators to sum orders to 3, got %d", total)
+		}
+	})
+
+	t.Run("UpdatePayload", func(t *testing.T) {
+		id, err := q.EnqueueID(map[string]int{"order": 3})
+		if err != nil {
+			t.Fatalf("EnqueueID failed: %v", err)
+		}
+		if q.UpdatePayload(id, []byte("not json")) {
+			t.Error("UpdatePayload with invalid JSON should fail")
+		}
+		if !q.UpdatePayload(id, []byte(`{"order": 4}`)) {
+			t.Error("UpdatePayload failed")
+		}
+
+		corrupted, err := q.VerifyChecksums()
+		if err != nil {
+			t.Fatalf("VerifyChecksums failed: %v", err)
+		}
+		if len(corrupted) != 0 {
+			t.Errorf("Expected no corrupted payloads, got %v", corrupted)
+		}
+	})
+
+	t.Run("Clone", func(t *testing.T) {
+		if _, err := queues.CloneQueue("test_queue", "test_clone"); err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		columnType, err := tableColumnType(queues.DB(), "test_clone", "data")
+		if err != nil {
+			t.Fatalf("Failed to inspect column: %v", err)
+		}
+		if columnType != "JSON" {
+			t.Errorf("Expected the clone to keep the JSON data column, got %s", columnType)
+		}
+	})
+
+	t.Run("ModeMismatch", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_queue"); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption opening a JSON queue as BLOB, got %v", err)
+		}
+		if _, err := queues.NewPriorityQueue("test_blob_queue"); err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		if _, err := queues.NewPriorityQueue("test_blob_queue", WithJSONPayload()); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption opening a BLOB queue as JSON, got %v", err)
+		}
+		if _, err := queues.NewQueue("test_codec_queue", WithJSONPayload(), WithCodec(JSONCodec{})); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption combining WithCodec and WithJSONPayload, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultConsumerID identifies the current process as a consumer
+func defaultConsumerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// ExpiredLeases returns the processing messages whose ack deadline has passed
+// Each message carries its ack ID and the consumer that holds the lease, so operators can
+// decide whether to requeue or dead-letter it. Messages are ordered by how long ago they expired
+// Leases only expire on queues created with WithAckTimeout
+func (q *Queue) ExpiredLeases() ([]Message, error) {
+	messages, err := q.queryMessages(
+		fmt.Sprintf(
+			"SELECT %s FROM %s WHERE status = 'processing' AND lease_expires_at < ? ORDER BY lease_expires_at ASC, id ASC",
+			q.messageColumns(), q.tableName,
+		),
+		q.now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired leases: %w", err)
+	}
+
+	return messages, nil
+}
+
+// ExtendLease keeps the processing item with ackID leased for at least d from now,
+// for consumers that hold an item longer than WithAckTimeout allows, e.g. between retries
+// Leases are never shortened, and leases without a deadline are left without one
+// Returns ErrAckNotFound if no processing item has ackID
+func (q *Queue) ExtendLease(ackID string, d time.Duration) error {
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			now := q.now()
+			result, err := tx.Exec(
+				fmt.Sprintf("UPDATE %s SET lease_expires_at = CASE WHEN lease_expires_at IS NULL THEN NULL ELSE GREATEST(lease_expires_at, ?) END, updated_at = ? WHERE ack_id = ? AND status = 'processing'", q.tableName),
+				now.Add(d), now, ackID,
+			)
+			if err != nil {
+				return err
+			}
+
+			return requireRows(result)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extend lease: %w", err)
+	}
+
+	return nil
+}
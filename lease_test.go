@@ -0,0 +1,140 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestExpiredLeases(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_expired_leases.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("ListsExpiredLeases", func(t *testing.T) {
+		q, err := queues.NewQueue("test_queue", WithAckTimeout(50*time.Millisecond), WithConsumerID("worker-1"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue([]byte("slow job"))
+		_, success, ackID := q.DequeueWithAckId()
+		if !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+
+		leases, err := q.ExpiredLeases()
+		if err != nil {
+			t.Fatalf("ExpiredLeases failed: %v", err)
+		}
+		if len(leases) != 0 {
+			t.Errorf("Expected no expired leases before the timeout, got %d", len(leases))
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		leases, err = q.ExpiredLeases()
+		if err != nil {
+			t.Fatalf("ExpiredLeases failed: %v", err)
+		}
+		if len(leases) != 1 {
+			t.Fatalf("Expected 1 expired lease, got %d", len(leases))
+		}
+		if leases[0].AckID != ackID {
+			t.Errorf("Expected ack ID %s, got %s", ackID, leases[0].AckID)
+		}
+		if leases[0].ConsumerID != "worker-1" {
+			t.Errorf("Expected consumer 'worker-1', got '%s'", leases[0].ConsumerID)
+		}
+		if leases[0].LeaseExpiresAt.IsZero() {
+			t.Error("Expected a lease deadline")
+		}
+
+		// Acknowledged items are no longer leased
+		if !q.Acknowledge(ackID) {
+			t.Error("Acknowledge failed")
+		}
+		leases, _ = q.ExpiredLeases()
+		if len(leases) != 0 {
+			t.Errorf("Expected no expired leases after acknowledge, got %d", len(leases))
+		}
+	})
+
+	t.Run("NoTimeoutNeverExpires", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority_queue")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue([]byte("job"), 1)
+		if _, success, _ := pq.DequeueWithAckId(); !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+
+		leases, err := pq.ExpiredLeases()
+		if err != nil {
+			t.Fatalf("ExpiredLeases failed: %v", err)
+		}
+		if len(leases) != 0 {
+			t.Errorf("Expected no expired leases without an ack timeout, got %d", len(leases))
+		}
+	})
+}
+
+func TestExtendLease(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_extend_lease.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithAckTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("KeepsLeaseAlive", func(t *testing.T) {
+		q.Enqueue([]byte("slow job"))
+		_, success, ackID := q.DequeueWithAckId()
+		if !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+
+		if err := q.ExtendLease(ackID, time.Second); err != nil {
+			t.Fatalf("ExtendLease failed: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		if leases, _ := q.ExpiredLeases(); len(leases) != 0 {
+			t.Errorf("Expected the extended lease not to expire, got %d expired", len(leases))
+		}
+
+		// A shorter extension doesn't cut the lease short
+		if err := q.ExtendLease(ackID, time.Millisecond); err != nil {
+			t.Fatalf("ExtendLease failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if leases, _ := q.ExpiredLeases(); len(leases) != 0 {
+			t.Errorf("Expected the lease to be kept, got %d expired", len(leases))
+		}
+
+		if !q.Acknowledge(ackID) {
+			t.Error("Acknowledge failed")
+		}
+	})
+
+	t.Run("UnknownAckID", func(t *testing.T) {
+		if err := q.ExtendLease("unknown", time.Second); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package duckq
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// dequeueLockKey identifies a queue table within a database handle
+type dequeueLockKey struct {
+	db        *sql.DB
+	tableName string
+}
+
+// dequeueLocks holds one mutex per queue table, shared by every Queue opened on it
+var dequeueLocks sync.Map
+
+// dequeueLock returns the mutex that serializes dequeues from the queue's table
+// Concurrent dequeues all race for the same head row, so running them one at a time
+// avoids the write-write conflicts DuckDB would otherwise abort all but one of them with
+func (q *Queue) dequeueLock() *sync.Mutex {
+	mu, _ := dequeueLocks.LoadOrStore(dequeueLockKey{db: q.client, tableName: q.tableName}, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
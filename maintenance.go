@@ -0,0 +1,377 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// MaintenanceTask names one of the jobs the maintenance scheduler of a Queues runs
+type MaintenanceTask string
+
+const (
+	// MaintenanceLeaseExpiry applies WithProcessingTimeout to processing items whose lease expired
+	MaintenanceLeaseExpiry MaintenanceTask = "lease_expiry"
+	// MaintenanceTTL applies WithPendingTTL to items that stayed pending for too long
+	MaintenanceTTL MaintenanceTask = "ttl"
+	// MaintenanceRetention forgets ack IDs and crashes past their retention, and deletes completed
+	// items older than WithRetention
+	MaintenanceRetention MaintenanceTask = "retention"
+	// MaintenanceCheckpoint checkpoints the database, writing the WAL into the database file
+	MaintenanceCheckpoint MaintenanceTask = "checkpoint"
+)
+
+// maintenanceTasks lists every task in the order the scheduler runs tasks that are due together
+var maintenanceTasks = []MaintenanceTask{MaintenanceLeaseExpiry, MaintenanceTTL, MaintenanceRetention, MaintenanceCheckpoint}
+
+// defaultMaintenanceIntervals are the intervals of tasks that run at a fixed rate unless
+// WithMaintenanceInterval overrides them. Lease expiry and TTL adapt to the queues' timeouts,
+// and checkpoints only run when enabled
+var defaultMaintenanceIntervals = map[MaintenanceTask]time.Duration{
+	MaintenanceRetention: time.Minute,
+}
+
+// defaultMaintenanceJitter is the fraction intervals are randomly shortened or lengthened by,
+// so processes sharing a database don't run their maintenance in lockstep
+const defaultMaintenanceJitter = 0.1
+
+// Bounds of the interval lease expiry and TTL adapt to, a fraction of the shortest timeout
+const (
+	minTimeoutSweepInterval = 10 * time.Millisecond
+	maxTimeoutSweepInterval = time.Minute
+)
+
+// deadlineSweepInterval is the longest the TTL task waits while an open queue has items with a deadline
+const deadlineSweepInterval = time.Second
+
+// MaintenanceStats describes the runs of a single maintenance task
+type MaintenanceStats struct {
+	// Runs counts every run, including failed ones
+	Runs int64
+	// Failures counts the runs that returned an error
+	Failures int64
+	// Affected counts the items the task acted on across every run
+	Affected int64
+	// LastRun is when the last run started
+	LastRun time.Time
+	// LastDuration is how long the last run took
+	LastDuration time.Duration
+	// LastError is the error of the last run, nil if it succeeded
+	LastError error
+}
+
+// WithMaintenanceInterval sets how often the maintenance scheduler runs task
+// A non-positive interval disables the task, which can still be run with RunMaintenance
+// By default lease expiry and TTL run at a quarter of the shortest timeout of the open queues,
+// between 10ms and a minute, retention runs every minute and checkpoints don't run
+func WithMaintenanceInterval(task MaintenanceTask, interval time.Duration) QueuesOption {
+	return func(q *queues) {
+		if q.maintenanceIntervals == nil {
+			q.maintenanceIntervals = make(map[MaintenanceTask]time.Duration)
+		}
+		q.maintenanceIntervals[task] = interval
+	}
+}
+
+// WithMaintenanceJitter sets the fraction every maintenance interval is randomly shortened or
+// lengthened by, clamped between 0 and 1. Defaults to 0.1
+func WithMaintenanceJitter(fraction float64) QueuesOption {
+	return func(q *queues) {
+		q.maintenanceJitter = min(max(fraction, 0), 1)
+	}
+}
+
+// WithMaintenanceObserver calls observe after every maintenance run with its duration, the
+// number of items it acted on and its error, e.g. to feed metrics
+func WithMaintenanceObserver(observe func(task MaintenanceTask, duration time.Duration, affected int64, err error)) QueuesOption {
+	return func(q *queues) {
+		q.maintenanceObserver = observe
+	}
+}
+
+// StartMaintenance starts the goroutine running the maintenance tasks of every open queue
+// New starts it, so it only needs to be called after StopMaintenance
+func (q *queues) StartMaintenance() {
+	q.maintenanceLifecycle.Lock()
+	defer q.maintenanceLifecycle.Unlock()
+
+	if q.maintenanceStop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	q.maintenanceStop = stop
+	q.maintenanceDone.Add(1)
+	go func() {
+		defer q.maintenanceDone.Done()
+		q.maintain(stop)
+	}()
+}
+
+// StopMaintenance stops the maintenance goroutine and waits for a running task to finish
+// Timeouts, TTLs and retention are no longer applied until StartMaintenance or RunMaintenance
+func (q *queues) StopMaintenance() {
+	q.maintenanceLifecycle.Lock()
+	defer q.maintenanceLifecycle.Unlock()
+
+	if q.maintenanceStop == nil {
+		return
+	}
+
+	close(q.maintenanceStop)
+	q.maintenanceStop = nil
+	q.maintenanceDone.Wait()
+}
+
+// MaintenanceStats returns the stats of every maintenance task that ran at least once
+func (q *queues) MaintenanceStats() map[MaintenanceTask]MaintenanceStats {
+	q.maintenanceMu.Lock()
+	defer q.maintenanceMu.Unlock()
+
+	stats := make(map[MaintenanceTask]MaintenanceStats, len(q.maintenanceStats))
+	for task, s := range q.maintenanceStats {
+		stats[task] = s
+	}
+
+	return stats
+}
+
+// RunMaintenance runs task on every open queue right away, whether or not the scheduler is running
+// Returns ErrInvalidOption for an unknown task
+func (q *queues) RunMaintenance(task MaintenanceTask) error {
+	var apply func(*Queue) (int64, error)
+	switch task {
+	case MaintenanceLeaseExpiry:
+		apply = func(queue *Queue) (int64, error) { return queue.applyTimeouts(true, false) }
+	case MaintenanceTTL:
+		apply = func(queue *Queue) (int64, error) { return queue.applyTimeouts(false, true) }
+	case MaintenanceRetention:
+		apply = (*Queue).applyRetention
+	case MaintenanceCheckpoint:
+	default:
+		return fmt.Errorf("%w: unknown maintenance task %q", ErrInvalidOption, task)
+	}
+
+	start := time.Now()
+	var affected int64
+	var err error
+	if apply == nil {
+		_, err = q.client.Exec("CHECKPOINT")
+	} else {
+		for _, queue := range q.openQueues() {
+			n, queueErr := apply(queue)
+			affected += n
+			if queueErr != nil && err == nil {
+				err = fmt.Errorf("%s: %w", queue.tableName, queueErr)
+			}
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to run %s maintenance: %w", task, err)
+	}
+	duration := time.Since(start)
+
+	q.maintenanceMu.Lock()
+	if q.maintenanceStats == nil {
+		q.maintenanceStats = make(map[MaintenanceTask]MaintenanceStats)
+	}
+	stats := q.maintenanceStats[task]
+	stats.Runs++
+	stats.Affected += affected
+	stats.LastRun = start
+	stats.LastDuration = duration
+	stats.LastError = err
+	if err != nil {
+		stats.Failures++
+	}
+	q.maintenanceStats[task] = stats
+	q.maintenanceMu.Unlock()
+
+	if q.maintenanceObserver != nil {
+		q.maintenanceObserver(task, duration, affected, err)
+	}
+
+	return err
+}
+
+// openQueues returns the queues opened through the manager that are not closed
+func (q *queues) openQueues() []*Queue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	open := make([]*Queue, 0, len(q.opened))
+	for _, queue := range q.opened {
+		if !queue.closed.Load() {
+			open = append(open, queue)
+		}
+	}
+
+	return open
+}
+
+// anyDeadlines reports whether an open queue has items with a deadline
+func (q *queues) anyDeadlines() bool {
+	for _, queue := range q.openQueues() {
+		if queue.deadlines.Load() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withMaintenanceWake lets queues created by the manager make its scheduler recompute its intervals
+func withMaintenanceWake(wake func()) Option {
+	return func(q *Queue) {
+		q.wakeMaintenance = wake
+	}
+}
+
+// maintain runs every enabled task whenever it is due, until stop is closed
+func (q *queues) maintain(stop <-chan struct{}) {
+	next := make(map[MaintenanceTask]time.Time, len(maintenanceTasks))
+	schedule := func(now time.Time) {
+		for _, task := range maintenanceTasks {
+			interval, ok := q.maintenanceInterval(task)
+			if !ok {
+				delete(next, task)
+				continue
+			}
+			due := now.Add(q.jittered(interval))
+			if at, scheduled := next[task]; !scheduled || due.Before(at) {
+				next[task] = due
+			}
+		}
+	}
+	schedule(time.Now())
+
+	timer := time.NewTimer(maxTimeoutSweepInterval)
+	defer timer.Stop()
+
+	for {
+		// Wake up for the earliest task, or at least once a minute to pick up new queues
+		wait := maxTimeoutSweepInterval
+		for _, at := range next {
+			wait = min(wait, time.Until(at))
+		}
+		timer.Reset(max(wait, 0))
+
+		select {
+		case <-stop:
+			return
+		case <-q.maintenanceWake:
+			schedule(time.Now())
+			continue
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, task := range maintenanceTasks {
+			if at, ok := next[task]; ok && !now.Before(at) {
+				q.RunMaintenance(task)
+				delete(next, task)
+			}
+		}
+		schedule(time.Now())
+	}
+}
+
+// wakeMaintenance makes the scheduler recompute its intervals, e.g. after a queue was opened
+func (q *queues) wakeMaintenance() {
+	select {
+	case q.maintenanceWake <- struct{}{}:
+	default:
+	}
+}
+
+// maintenanceInterval returns how often task runs, false if it is disabled
+func (q *queues) maintenanceInterval(task MaintenanceTask) (time.Duration, bool) {
+	if interval, ok := q.maintenanceIntervals[task]; ok {
+		return interval, interval > 0
+	}
+	if interval, ok := defaultMaintenanceIntervals[task]; ok {
+		return interval, true
+	}
+
+	var shortest time.Duration
+	for _, queue := range q.openQueues() {
+		var timeout time.Duration
+		switch {
+		case task == MaintenanceLeaseExpiry && queue.processingTimeout:
+			timeout = queue.ackTimeout
+		case task == MaintenanceTTL:
+			timeout = queue.pendingTTL
+		}
+		if timeout > 0 && (shortest == 0 || timeout < shortest) {
+			shortest = timeout
+		}
+	}
+	interval := min(max(shortest/4, minTimeoutSweepInterval), maxTimeoutSweepInterval)
+	if task == MaintenanceTTL && q.anyDeadlines() && (shortest == 0 || interval > deadlineSweepInterval) {
+		return deadlineSweepInterval, true
+	}
+	if shortest == 0 {
+		return 0, false
+	}
+
+	return interval, true
+}
+
+// jittered randomly shortens or lengthens interval by up to the maintenance jitter
+func (q *queues) jittered(interval time.Duration) time.Duration {
+	return time.Duration(float64(interval) * (1 + q.maintenanceJitter*(2*rand.Float64()-1)))
+}
+
+// applyRetention forgets the ack IDs, idempotency keys and handler crashes past their retention,
+// the dependencies of items no longer in the queue, and deletes completed items older than WithRetention
+// Returns the number of rows deleted
+func (q *Queue) applyRetention() (int64, error) {
+	var deleted int64
+	err := q.retry(func() error {
+		deleted = 0
+		return q.inTx(func(tx *sql.Tx) error {
+			now := q.now()
+
+			type retentionDelete struct {
+				query string
+				args  []any
+			}
+			deletes := []retentionDelete{
+				{fmt.Sprintf("DELETE FROM %s WHERE acked_at < ?", ackLogTableName(q.tableName)), []any{now.Add(-ackLogRetention)}},
+				{fmt.Sprintf("DELETE FROM %s WHERE processed_at < ?", processedTableName(q.tableName)), []any{now.Add(-processedKeyRetention)}},
+				{fmt.Sprintf("DELETE FROM %s WHERE message_id NOT IN (SELECT id FROM %s)", dependenciesTableName(q.tableName), q.tableName), nil},
+			}
+			if q.quarantine != nil {
+				deletes = append(deletes, retentionDelete{fmt.Sprintf("DELETE FROM %s WHERE failed_at < ?", failuresTableName(q.tableName)), []any{now.Add(-q.quarantine.Window)}})
+			}
+			if q.retention > 0 {
+				deletes = append(deletes, retentionDelete{fmt.Sprintf("DELETE FROM %s WHERE status = 'completed' AND updated_at < ?", q.tableName), []any{now.Add(-q.retention)}})
+			}
+
+			for _, d := range deletes {
+				result, err := tx.Exec(d.query, d.args...)
+				if err != nil {
+					return err
+				}
+				n, err := result.RowsAffected()
+				if err != nil {
+					return err
+				}
+				deleted += n
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply retention: %w", err)
+	}
+
+	// Correct the counters for changes this process didn't see
+	if err := q.reconcileCounts(); err != nil {
+		return deleted, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,116 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestMaintenance(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_maintenance.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	var mu sync.Mutex
+	observed := make(map[MaintenanceTask]int64)
+	queues := New(dbPath,
+		WithMaintenanceInterval(MaintenanceRetention, 0),
+		WithMaintenanceJitter(0),
+		WithMaintenanceObserver(func(task MaintenanceTask, _ time.Duration, affected int64, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				observed[task] += affected
+			}
+		}),
+	)
+	defer queues.Close()
+
+	t.Run("Retention", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+		kept, err := queues.NewQueue("test_retention_kept", WithRemoveOnComplete(false), WithRetention(time.Hour), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		removed, err := queues.NewQueue("test_retention_removed", WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		kept.Enqueue([]byte("completed"))
+		_, _, keptAck := kept.DequeueWithAckId()
+		kept.Acknowledge(keptAck)
+		removed.Enqueue([]byte("removed"))
+		_, _, removedAck := removed.DequeueWithAckId()
+		removed.Acknowledge(removedAck)
+
+		if err := queues.RunMaintenance(MaintenanceRetention); err != nil {
+			t.Fatalf("RunMaintenance failed: %v", err)
+		}
+		if messages, _ := kept.Messages(Filter{}); len(messages) != 1 {
+			t.Fatalf("Expected the recent completed item to be kept, got %d", len(messages))
+		}
+
+		clock.Advance(25 * time.Hour)
+		if err := queues.RunMaintenance(MaintenanceRetention); err != nil {
+			t.Fatalf("RunMaintenance failed: %v", err)
+		}
+		if messages, _ := kept.Messages(Filter{}); len(messages) != 0 {
+			t.Errorf("Expected the old completed item to be deleted, got %d", len(messages))
+		}
+		if err := removed.Ack(removedAck); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected the forgotten ack ID to be unknown, got %v", err)
+		}
+
+		stats := queues.MaintenanceStats()[MaintenanceRetention]
+		if stats.Runs != 2 || stats.Failures != 0 || stats.Affected != 2 || stats.LastError != nil {
+			t.Errorf("Unexpected retention stats: %+v", stats)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if observed[MaintenanceRetention] != 2 {
+			t.Errorf("Expected the observer to see 2 deleted rows, got %d", observed[MaintenanceRetention])
+		}
+	})
+
+	t.Run("StartStop", func(t *testing.T) {
+		q, err := queues.NewQueue("test_maintenance_ttl", WithPendingTTL(40*time.Millisecond, TimeoutDeadLetter))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		queues.StopMaintenance()
+		q.Enqueue([]byte("item"))
+		time.Sleep(100 * time.Millisecond)
+		if q.Len() != 1 {
+			t.Fatal("Expected the stopped scheduler to leave the stale item")
+		}
+
+		queues.StartMaintenance()
+		time.Sleep(100 * time.Millisecond)
+		if q.Len() != 0 {
+			t.Error("Expected the restarted scheduler to dead-letter the stale item")
+		}
+		if stats := queues.MaintenanceStats()[MaintenanceTTL]; stats.Runs == 0 || stats.Affected != 1 {
+			t.Errorf("Unexpected TTL stats: %+v", stats)
+		}
+	})
+
+	t.Run("Checkpoint", func(t *testing.T) {
+		if err := queues.RunMaintenance(MaintenanceCheckpoint); err != nil {
+			t.Errorf("RunMaintenance failed: %v", err)
+		}
+	})
+
+	t.Run("UnknownTask", func(t *testing.T) {
+		if err := queues.RunMaintenance("vacuum"); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Message is a structured view of a single row in a queue table
+type Message struct {
+	ID             int64
+	Data           []byte
+	Status         string
+	AckID          string
+	Attempts       int
+	Priority       int
+	ConsumerID     string
+	LeaseExpiresAt time.Time
+	VisibleAt      time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Page limits the number of messages returned by listing operations
+// A zero Limit returns every remaining message after Offset
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// sql returns the LIMIT/OFFSET clause for the page
+func (p Page) sql() string {
+	clause := ""
+	if p.Limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", p.Limit)
+	}
+	if p.Offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", p.Offset)
+	}
+	return clause
+}
+
+// messageColumns returns the column list matching scanMessage for the queue's table
+func (q *Queue) messageColumns() string {
+	priorityColumn := "0"
+	if q.hasPriority {
+		priorityColumn = "priority"
+	}
+
+	return "id, " + q.dataColumn() + ", status, ack_id, attempts, " + priorityColumn + ", consumer_id, lease_expires_at, visible_at, created_at, updated_at"
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage scans a row selected with messageColumns into a Message
+func scanMessage(row scanner) (Message, error) {
+	var m Message
+	var ackID, consumerID sql.NullString
+	var leaseExpiresAt, visibleAt, updatedAt sql.NullTime
+
+	err := row.Scan(&m.ID, &m.Data, &m.Status, &ackID, &m.Attempts, &m.Priority, &consumerID, &leaseExpiresAt, &visibleAt, &m.CreatedAt, &updatedAt)
+	if err != nil {
+		return Message{}, err
+	}
+
+	m.AckID = ackID.String
+	m.ConsumerID = consumerID.String
+	m.LeaseExpiresAt = leaseExpiresAt.Time
+	m.VisibleAt = visibleAt.Time
+	m.UpdatedAt = updatedAt.Time
+
+	return m, nil
+}
+
+// queryMessages runs a query selecting messageColumns and scans every resulting row, redacting their payloads
+func (q *Queue) queryMessages(query string, args ...any) ([]Message, error) {
+	rows, err := q.client.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := scanMessages(rows)
+	return q.redactMessages(messages), err
+}
+
+// scanMessages scans and closes rows selected with messageColumns
+func scanMessages(rows *sql.Rows) ([]Message, error) {
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// Messages returns the messages matching all given filters as structured rows, in dequeue order
+// Unlike Values it includes every status unless filtered, along with IDs, attempts and timestamps
+func (q *Queue) Messages(filters ...Filter) ([]Message, error) {
+	where, args := whereFilters(filters...)
+
+	messages, err := q.queryMessages(
+		fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", q.messageColumns(), q.tableName, where, q.dequeueOrder()),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	return messages, nil
+}
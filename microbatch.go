@@ -0,0 +1,243 @@
+package duckq
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// microBatchPayloadType is recorded in the payload_type column of rows holding a micro-batch
+const microBatchPayloadType = "duckq/micro-batch"
+
+// textPayloadType is recorded in the payload_type column of messages enqueued as a string in a
+// micro-batch, so they come back as a string once the batch is split
+const textPayloadType = "duckq/text"
+
+// Kinds of message framed in a micro-batch, stored as the first byte of each frame
+const (
+	binaryFrame byte = iota
+	textFrame
+)
+
+// errInvalidMicroBatch dead-letters rows whose micro-batch cannot be split
+var errInvalidMicroBatch error = corruptPayloadError("invalid micro-batch")
+
+// microBatch is the framed payload of a row holding several messages, see WithMicroBatching
+// Each message is stored as its kind, its uvarint length and its bytes
+type microBatch []byte
+
+// batchedMessage is a message waiting in, or split from, a micro-batch
+type batchedMessage struct {
+	data []byte
+	text bool
+}
+
+// item returns data, the message's possibly redacted payload, with the type the message was enqueued with
+func (m batchedMessage) item(data []byte) any {
+	if m.text {
+		return string(data)
+	}
+
+	return data
+}
+
+// microBatcher collects the messages enqueued since the last flush of a queue created with WithMicroBatching
+type microBatcher struct {
+	maxItems int
+	maxDelay time.Duration
+
+	mu       sync.Mutex
+	messages []batchedMessage
+	size     int64
+	full     chan struct{}
+}
+
+// WithMicroBatching coalesces up to maxItems []byte or string messages passed to Enqueue within
+// maxDelay into a single stored row, cutting the per-row overhead of telemetry-style workloads
+// Enqueue returns as soon as the message is buffered, after the rate limit and tenant quota
+// accepted it; the buffer is stored every maxDelay, whenever it holds maxItems messages, by
+// FlushMicroBatch and by Close, and messages buffered when the process crashes are lost
+// Code that acknowledges a message to another system once Enqueue returns must call FlushMicroBatch
+// first, or enqueue it with EnqueueID, which stores it right away like the kafka and nats bridges do
+// The first dequeue of a stored batch, on any queue opened on the table and through any dequeue
+// method, splits it into one row per message in the same transaction, so every message gets its
+// own lease and comes back with the type it was enqueued with
+func WithMicroBatching(maxItems int, maxDelay time.Duration) Option {
+	return func(q *Queue) {
+		q.batcher = &microBatcher{maxItems: maxItems, maxDelay: maxDelay}
+	}
+}
+
+// startMicroBatching starts storing the buffered messages of a queue created with WithMicroBatching
+func (q *Queue) startMicroBatching() {
+	if q.batcher == nil {
+		return
+	}
+
+	q.batcher.full = make(chan struct{}, 1)
+	q.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(q.batcher.maxDelay)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-q.batcher.full:
+			case <-stop:
+				return
+			}
+			q.FlushMicroBatch()
+		}
+	})
+}
+
+// batchMessage buffers a raw message for the next micro-batch
+// The message passes the same size, rate and tenant quota checks as one stored on its own,
+// counting the messages already buffered towards the quota
+// Returns false for items that are stored on their own
+func (q *Queue) batchMessage(item any) (bool, error) {
+	var message batchedMessage
+	switch item := item.(type) {
+	case []byte:
+		message = batchedMessage{data: item}
+	case string:
+		message = batchedMessage{data: []byte(item), text: true}
+	default:
+		return false, nil
+	}
+	if err := q.checkPayloadSize(message.data); err != nil {
+		return true, err
+	}
+	if err := q.checkEnqueueRate(1); err != nil {
+		return true, err
+	}
+
+	q.batcher.mu.Lock()
+	defer q.batcher.mu.Unlock()
+
+	size := q.batcher.size + int64(len(message.data))
+	if err := q.checkTenantQuotaFor(nil, int64(len(q.batcher.messages))+1, size); err != nil {
+		return true, err
+	}
+	q.batcher.messages = append(q.batcher.messages, message)
+	q.batcher.size = size
+
+	if len(q.batcher.messages) >= q.batcher.maxItems {
+		select {
+		case q.batcher.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return true, nil
+}
+
+// FlushMicroBatch stores the messages buffered by WithMicroBatching right away
+// When storing fails the messages stay buffered for the next flush
+func (q *Queue) FlushMicroBatch() error {
+	if q.batcher == nil {
+		return nil
+	}
+
+	q.batcher.mu.Lock()
+	messages, size := q.batcher.messages, q.batcher.size
+	q.batcher.messages, q.batcher.size = nil, 0
+	q.batcher.mu.Unlock()
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var batch []byte
+	for _, message := range messages {
+		kind := binaryFrame
+		if message.text {
+			kind = textFrame
+		}
+		batch = append(batch, kind)
+		batch = binary.AppendUvarint(batch, uint64(len(message.data)))
+		batch = append(batch, message.data...)
+	}
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			_, err := q.enqueueInTx(tx, microBatch(batch), sql.NullTime{})
+			return err
+		})
+	})
+	if err != nil {
+		q.batcher.mu.Lock()
+		q.batcher.messages = append(messages, q.batcher.messages...)
+		q.batcher.size += size
+		q.batcher.mu.Unlock()
+		return fmt.Errorf("failed to store micro-batch: %w", err)
+	}
+
+	return nil
+}
+
+// splitMicroBatch returns the messages framed in a micro-batch
+func splitMicroBatch(batch []byte) ([]batchedMessage, error) {
+	var messages []batchedMessage
+	for len(batch) > 0 {
+		kind := batch[0]
+		size, n := binary.Uvarint(batch[1:])
+		if kind > textFrame || n <= 0 || uint64(len(batch)-1-n) < size {
+			return nil, errInvalidMicroBatch
+		}
+		start := 1 + n
+		messages = append(messages, batchedMessage{data: batch[start : start+int(size)], text: kind == textFrame})
+		batch = batch[start+int(size):]
+	}
+	if len(messages) == 0 {
+		return nil, errInvalidMicroBatch
+	}
+
+	return messages, nil
+}
+
+// splitMicroBatchInTx replaces the pending micro-batch row with the given id by one pending row
+// per message as part of tx, keeping the batch's position in the queue
+// offloadKey is the row's offload_key column, whose payload is released once the row is gone
+func (q *Queue) splitMicroBatchInTx(tx *sql.Tx, id int64, batch []byte, offloadKey sql.NullString) error {
+	messages, err := splitMicroBatch(batch)
+	if err != nil {
+		return err
+	}
+
+	now := q.now()
+	values := make([]string, len(messages))
+	args := make([]any, 0, 5*len(messages)+2)
+	args = append(args, now)
+	for i, message := range messages {
+		var payloadType sql.NullString
+		if message.text {
+			payloadType = sql.NullString{String: textPayloadType, Valid: true}
+		}
+		values[i] = "(?::INTEGER, ?::BLOB, ?::TEXT, ?::BIGINT, ?::BLOB)"
+		args = append(args, i, message.data, payloadType, q.payloadChecksum(message.data), q.payloadSignature(message.data))
+	}
+	args = append(args, id)
+
+	// The messages take the batch's timestamps, and ids in frame order, so they are dequeued in order
+	_, err = tx.Exec(fmt.Sprintf(`
+	INSERT INTO %s (data, status, ack, created_at, updated_at, payload_type, checksum, signature, visible_at)
+	SELECT m.data, 'pending', 0, b.created_at, ?::TIMESTAMP, m.payload_type, m.checksum, m.signature, b.visible_at
+	FROM %s AS b, (VALUES %s) AS m(frame, data, payload_type, checksum, signature)
+	WHERE b.id = ?
+	ORDER BY m.frame
+	`, q.tableName, q.tableName, strings.Join(values, ", ")), args...)
+	if err != nil {
+		return fmt.Errorf("failed to split micro-batch: %w", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", q.tableName), id); err != nil {
+		return fmt.Errorf("failed to split micro-batch: %w", err)
+	}
+	q.releasePayload(tx, offloadKey)
+	q.count(tx, int64(len(messages))-1, 0)
+
+	return nil
+}
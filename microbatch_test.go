@@ -0,0 +1,177 @@
+package duckq
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestMicroBatching(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_micro_batching.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithMicroBatching(3, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	rows := func() int {
+		var n int
+		queues.DB().QueryRow("SELECT COUNT(*) FROM test_queue").Scan(&n)
+		return n
+	}
+
+	t.Run("Coalesced", func(t *testing.T) {
+		for i := range 5 {
+			if !q.Enqueue(fmt.Sprintf("m%d", i)) {
+				t.Fatalf("Failed to enqueue message %d", i)
+			}
+		}
+		if err := q.FlushMicroBatch(); err != nil {
+			t.Fatalf("FlushMicroBatch failed: %v", err)
+		}
+		if n := rows(); n > 2 {
+			t.Errorf("Expected the messages to share at most 2 rows, got %d", n)
+		}
+
+		if values := q.Values(); len(values) != 5 || values[0] != "m0" {
+			t.Errorf("Expected Values to list the batched messages, got %v", values)
+		}
+
+		for i := range 5 {
+			item, success := q.Dequeue()
+			if !success || item != fmt.Sprintf("m%d", i) {
+				t.Errorf("Expected message m%d with its string type, got %v", i, item)
+			}
+		}
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected the queue to be empty")
+		}
+	})
+
+	t.Run("SplitOnDequeue", func(t *testing.T) {
+		q.Enqueue([]byte("a"))
+		q.Enqueue([]byte("b"))
+		q.FlushMicroBatch()
+
+		item, _, first := q.DequeueWithAckId()
+		if string(item.([]byte)) != "a" {
+			t.Errorf("Expected a []byte message, got %v", item)
+		}
+		if rows() != 2 {
+			t.Errorf("Expected the batch to be split into a row per message, got %d rows", rows())
+		}
+		_, _, second := q.DequeueWithAckId()
+		if first == second {
+			t.Fatal("Expected every message to get its own ack ID")
+		}
+
+		if err := q.Ack(first); err != nil {
+			t.Fatalf("Ack failed: %v", err)
+		}
+		if !errors.Is(q.Ack(first), ErrDuplicateAck) {
+			t.Error("Expected acknowledging a message twice to be reported")
+		}
+		if !q.Nack(second) {
+			t.Fatal("Expected the nack to succeed")
+		}
+
+		// Only the nacked message comes back
+		item, success, ackID := q.DequeueWithAckId()
+		if !success || string(item.([]byte)) != "b" {
+			t.Errorf("Expected only b again, got %v", item)
+		}
+		q.Acknowledge(ackID)
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected the queue to be empty")
+		}
+	})
+
+	t.Run("NothingHeldInMemory", func(t *testing.T) {
+		q.Enqueue("a")
+		q.Enqueue("b")
+		q.FlushMicroBatch()
+
+		if item, success, _ := q.DequeueWithID(); !success || item != "a" {
+			t.Fatalf("Expected DequeueWithID to unbatch the first message, got %v", item)
+		}
+
+		// A handle opened after this process crashed recovers the leased message and keeps the rest
+		other, err := queues.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		for _, want := range []string{"a", "b"} {
+			item, success := other.Dequeue()
+			if !success || item != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+		q.Purge()
+	})
+
+	t.Run("LargeBatchKeepsOrder", func(t *testing.T) {
+		large, err := queues.NewQueue("test_large", WithMicroBatching(1000, time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		defer large.Close()
+
+		for i := range 300 {
+			large.Enqueue([]byte(fmt.Sprint(i)))
+		}
+		large.FlushMicroBatch()
+		for i := range 300 {
+			item, success := large.Dequeue()
+			if !success || string(item.([]byte)) != fmt.Sprint(i) {
+				t.Fatalf("Expected message %d, got %v", i, item)
+			}
+		}
+	})
+
+	t.Run("RateLimitPerMessage", func(t *testing.T) {
+		limited, err := queues.NewQueue("test_limited", WithMicroBatching(10, time.Hour), WithEnqueueRateLimit(2, time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		defer limited.Close()
+
+		if !limited.Enqueue("a") || !limited.Enqueue("b") {
+			t.Fatal("Expected the messages within the rate to be buffered")
+		}
+		if limited.Enqueue("c") {
+			t.Error("Expected the message over the rate to be rejected")
+		}
+	})
+
+	t.Run("FlushedOnClose", func(t *testing.T) {
+		q.Enqueue([]byte("buffered"))
+		q.Close()
+		if rows() != 1 {
+			t.Errorf("Expected Close to store the buffered message, got %d rows", rows())
+		}
+		q.Reopen()
+		q.Purge()
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_invalid", WithMicroBatching(0, time.Second)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+		if _, err := queues.NewPriorityQueue("test_invalid", WithMicroBatching(10, time.Second)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+		if _, err := queues.NewQueue("test_invalid", WithMicroBatching(10, time.Second), WithJSONPayload()); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
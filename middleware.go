@@ -0,0 +1,105 @@
+package duckq
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps a Handler with behavior such as logging, metrics or panic recovery
+type Middleware func(Handler) Handler
+
+// Chain wraps handler with middleware, the first middleware being the outermost
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// WithMiddleware wraps the handler passed to Run, the first middleware being the outermost
+func WithMiddleware(middleware ...Middleware) RunOption {
+	return func(r *runner) {
+		r.middleware = append(r.middleware, middleware...)
+	}
+}
+
+// Recover turns a panicking handler into one that returns a *PanicError wrapping ErrHandlerPanic,
+// so the item is nacked instead of the worker crashing
+// The error carries the stack trace, which WithQuarantine stores with quarantined messages
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, item any) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+
+			return next(ctx, item)
+		}
+	}
+}
+
+// Logging logs every failed item at error level and every handled item at debug level
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, item any) error {
+			start := time.Now()
+			err := next(ctx, item)
+
+			if err != nil {
+				logger.ErrorContext(ctx, "duckq handler failed", "duration", time.Since(start), "error", err)
+			} else {
+				logger.DebugContext(ctx, "duckq handler succeeded", "duration", time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// LoggingWithPayloads logs like Logging, adding the payload of every failed item passed through
+// redact, e.g. Queue.Redact, so personal data stays out of the logs. A nil redact logs them as is
+// Items that are neither []byte nor string are logged without a payload
+func LoggingWithPayloads(logger *slog.Logger, redact Redactor) Middleware {
+	if redact == nil {
+		redact = func(payload []byte) []byte { return payload }
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, item any) error {
+			start := time.Now()
+			err := next(ctx, item)
+
+			if err == nil {
+				logger.DebugContext(ctx, "duckq handler succeeded", "duration", time.Since(start))
+				return nil
+			}
+
+			attrs := []any{"duration", time.Since(start), "error", err}
+			switch payload := item.(type) {
+			case []byte:
+				attrs = append(attrs, "payload", string(redact(payload)))
+			case string:
+				attrs = append(attrs, "payload", string(redact([]byte(payload))))
+			}
+			logger.ErrorContext(ctx, "duckq handler failed", attrs...)
+			return err
+		}
+	}
+}
+
+// Metrics calls observe with the duration and result of every handled item,
+// e.g. to feed a latency histogram and an error counter
+func Metrics(observe func(duration time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, item any) error {
+			start := time.Now()
+			err := next(ctx, item)
+			observe(time.Since(start), err)
+			return err
+		}
+	}
+}
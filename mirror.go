@@ -0,0 +1,153 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// mirrorsTable records the audit mirrors of every queue, so queues opened later keep copying into them
+const mirrorsTable = "duckq_mirrors"
+
+// Mirror maintains dst as an append-only copy of every message entering the queue src, for
+// audit consumers that must see each message even after the hot queue deleted it on ack
+// Each enqueue copies the message into dst in the same transaction, so a message is in the
+// mirror exactly when it was stored; superseded keyed and scheduled items are copied again
+// Mirror rows hold message_id, data, payload_type, priority, created_at and mirrored_at, and are
+// never deleted by the library
+// src must have been opened through the manager, otherwise ErrQueueNotFound is returned; the
+// mirror is recorded in the database, so opening src again later keeps it, while copies of src
+// already open in other processes start mirroring once they are reopened
+// Returns ErrQueueExists when dst is a table that isn't a mirror
+func (q *queues) Mirror(src, dst string) error {
+	q.mu.Lock()
+	queue, ok := q.opened[src]
+	q.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrQueueNotFound, src)
+	}
+
+	if exists, err := tableExists(q.client, dst); err != nil {
+		return fmt.Errorf("failed to create mirror: %w", err)
+	} else if exists {
+		isMirror, err := tableHasColumn(q.client, dst, "mirrored_at")
+		if err != nil {
+			return fmt.Errorf("failed to create mirror: %w", err)
+		}
+		if !isMirror {
+			return fmt.Errorf("%w: %s", ErrQueueExists, dst)
+		}
+	}
+
+	err := DefaultRetryPolicy.do(func() error {
+		tx, err := q.client.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			queue_name TEXT NOT NULL,
+			mirror_name TEXT NOT NULL,
+			PRIMARY KEY (queue_name, mirror_name)
+		);
+		CREATE TABLE IF NOT EXISTS %s (
+			message_id BIGINT NOT NULL,
+			data %s NOT NULL,
+			payload_type TEXT,
+			priority INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP,
+			mirrored_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS %s_message_id_idx ON %s (message_id);
+		`, mirrorsTable, dst, queue.payloadColumnType(), dst, dst))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (queue_name, mirror_name) VALUES (?, ?) ON CONFLICT DO NOTHING", mirrorsTable), src, dst)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create mirror: %w", err)
+	}
+
+	queue.addMirror(dst)
+	return nil
+}
+
+// addMirror makes the queue copy the messages it stores into dst from now on
+func (q *Queue) addMirror(dst string) {
+	q.mirrorMu.Lock()
+	defer q.mirrorMu.Unlock()
+
+	current := q.mirrors.Load()
+	if current != nil && slices.Contains(*current, dst) {
+		return
+	}
+
+	var mirrors []string
+	if current != nil {
+		mirrors = slices.Clone(*current)
+	}
+	mirrors = append(mirrors, dst)
+	q.mirrors.Store(&mirrors)
+}
+
+// loadMirrors picks up the mirrors recorded for the queue by Mirror
+func (q *Queue) loadMirrors() error {
+	exists, err := tableExists(q.client, mirrorsTable)
+	if err != nil || !exists {
+		return err
+	}
+
+	rows, err := q.client.Query(fmt.Sprintf("SELECT mirror_name FROM %s WHERE queue_name = ? ORDER BY mirror_name", mirrorsTable), q.tableName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dst string
+		if err := rows.Scan(&dst); err != nil {
+			return err
+		}
+		q.addMirror(dst)
+	}
+
+	return rows.Err()
+}
+
+// mirrorInTx copies the items matching condition into every mirror of the queue as part of tx
+func (q *Queue) mirrorInTx(tx *sql.Tx, condition string, args ...any) error {
+	mirrors := q.mirrors.Load()
+	if mirrors == nil {
+		return nil
+	}
+
+	priorityColumn := "0"
+	if q.hasPriority {
+		priorityColumn = "priority"
+	}
+
+	now := q.now()
+	for _, dst := range *mirrors {
+		_, err := tx.Exec(
+			fmt.Sprintf(
+				"INSERT INTO %s (message_id, data, payload_type, priority, created_at, mirrored_at) SELECT id, data, payload_type, %s, created_at, CAST(? AS TIMESTAMP) FROM %s WHERE %s",
+				dst, priorityColumn, q.tableName, condition,
+			),
+			append([]any{now}, args...)...,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to mirror into %s: %w", dst, err)
+		}
+	}
+
+	return nil
+}
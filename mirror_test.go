@@ -0,0 +1,118 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestMirror(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_mirror.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("before"))
+
+	if err := queues.Mirror("test_queue", "test_audit"); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	mirrored := func() []string {
+		rows, err := queues.DB().Query("SELECT data FROM test_audit ORDER BY mirrored_at, message_id")
+		if err != nil {
+			t.Fatalf("Failed to read mirror: %v", err)
+		}
+		defer rows.Close()
+
+		var items []string
+		for rows.Next() {
+			var data []byte
+			rows.Scan(&data)
+			items = append(items, string(data))
+		}
+		return items
+	}
+
+	t.Run("CopiesEnqueues", func(t *testing.T) {
+		q.Enqueue([]byte("a"))
+		q.Enqueue([]byte("b"))
+
+		// Acknowledged items are deleted from the queue but stay in the mirror
+		for range 3 {
+			_, _, ackID := q.DequeueWithAckId()
+			q.Acknowledge(ackID)
+		}
+		if got := mirrored(); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("Expected the items enqueued after Mirror, got %v", got)
+		}
+	})
+
+	t.Run("SameTransaction", func(t *testing.T) {
+		tx, _ := queues.DB().Begin()
+		q.EnqueueInTx(tx, []byte("rolled back"))
+		tx.Rollback()
+		if got := mirrored(); len(got) != 2 {
+			t.Errorf("Expected a rolled back enqueue to leave the mirror alone, got %v", got)
+		}
+	})
+
+	t.Run("Keyed", func(t *testing.T) {
+		q.EnqueueKeyed("user-1", []byte("v1"))
+		q.EnqueueKeyed("user-1", []byte("v2"))
+
+		var id, copies int
+		queues.DB().QueryRow("SELECT id FROM test_queue WHERE message_key = 'user-1'").Scan(&id)
+		queues.DB().QueryRow("SELECT COUNT(*) FROM test_audit WHERE message_id = ?", id).Scan(&copies)
+		if copies != 2 {
+			t.Errorf("Expected both versions of the keyed item, got %d", copies)
+		}
+		q.Purge()
+	})
+
+	t.Run("Reopened", func(t *testing.T) {
+		reopened, err := queues.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+		reopened.Enqueue([]byte("after reopen"))
+		if got := mirrored(); got[len(got)-1] != "after reopen" {
+			t.Errorf("Expected the reopened queue to keep mirroring, got %v", got)
+		}
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		if err := queues.Mirror("test_priority", "test_priority_audit"); err != nil {
+			t.Fatalf("Mirror failed: %v", err)
+		}
+		pq.Enqueue([]byte("urgent"), 3)
+
+		var priority int
+		if err := queues.DB().QueryRow("SELECT priority FROM test_priority_audit").Scan(&priority); err != nil || priority != 3 {
+			t.Errorf("Expected the mirrored priority 3, got %d (%v)", priority, err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if err := queues.Mirror("test_missing", "test_audit"); !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected ErrQueueNotFound, got %v", err)
+		}
+		if err := queues.Mirror("test_queue", "test_priority"); !errors.Is(err, ErrQueueExists) {
+			t.Errorf("Expected ErrQueueExists, got %v", err)
+		}
+	})
+
+}
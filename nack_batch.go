@@ -0,0 +1,77 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NackBatch returns the processing items with the given ack IDs to the queue in a single
+// transaction, e.g. when a downstream outage fails a whole batch, and keeps them invisible for
+// delay so they aren't retried before the outage has a chance to end
+// Items that used up WithMaxAttempts are dead-lettered instead, like with Nack, and ack IDs that
+// no longer belong to a processing item are skipped
+// Returns the number of items requeued or dead-lettered
+func (q *Queue) NackBatch(ackIDs []string, delay time.Duration) (int, error) {
+	rows := make([]string, 0, len(ackIDs))
+	seen := make(map[string]bool, len(ackIDs))
+	for _, ackID := range ackIDs {
+		if !seen[ackID] {
+			seen[ackID] = true
+			rows = append(rows, ackID)
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	var settled int64
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) (err error) {
+			settled, err = q.nackBatchInTx(tx, rows, delay)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to nack items: %w", err)
+	}
+
+	return int(settled), nil
+}
+
+// nackBatchInTx returns the processing items with the given ack IDs to pending as part of tx, see nackInTx
+func (q *Queue) nackBatchInTx(tx *sql.Tx, ackIDs []string, delay time.Duration) (int64, error) {
+	args := make([]any, len(ackIDs))
+	for i, ackID := range ackIDs {
+		args[i] = ackID
+	}
+	condition := fmt.Sprintf("ack_id IN (%s) AND status = 'processing'", strings.TrimSuffix(strings.Repeat("?, ", len(ackIDs)), ", "))
+
+	exhausted, err := q.deadLetterExhausted(tx, condition, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	now := q.now()
+	set := ""
+	setArgs := []any{now}
+	if delay > 0 {
+		set = ", visible_at = ?"
+		setArgs = append(setArgs, now.Add(delay))
+	}
+	result, err := tx.Exec(
+		fmt.Sprintf("UPDATE %s SET status = 'pending', ack_id = NULL, consumer_id = NULL, lease_expires_at = NULL, updated_at = ?%s%s WHERE %s", q.tableName, set, q.retryPrioritySQL(), condition),
+		append(setArgs, args...)...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	q.count(tx, requeued, -requeued)
+
+	return exhausted + requeued, q.countRequeues(tx, RequeueNack, requeued)
+}
@@ -0,0 +1,70 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestNackBatch(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_nack_batch.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	q, err := queues.NewQueue("test_queue", WithClock(clock), WithMaxAttempts(2))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	for _, item := range []string{"a", "b", "c"} {
+		q.Enqueue([]byte(item))
+	}
+
+	var ackIDs []string
+	for i := 0; i < 3; i++ {
+		_, _, ackID := q.DequeueWithAckId()
+		ackIDs = append(ackIDs, ackID)
+	}
+
+	t.Run("Delayed", func(t *testing.T) {
+		nacked, err := q.NackBatch(append(ackIDs, "unknown", ackIDs[0]), time.Minute)
+		if err != nil {
+			t.Fatalf("NackBatch failed: %v", err)
+		}
+		if nacked != 3 {
+			t.Errorf("Expected 3 nacked items, got %d", nacked)
+		}
+		if q.Len() != 3 || q.ApproxProcessing() != 0 {
+			t.Errorf("Expected every item to be pending again, got %d pending", q.Len())
+		}
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected the items to stay invisible during the delay")
+		}
+
+		clock.Advance(time.Minute)
+		if item, success := q.Dequeue(); !success || string(item.([]byte)) != "a" {
+			t.Errorf("Expected the items in their order after the delay, got %v", item)
+		}
+		stats, _ := q.RequeueStats()
+		if stats.Nack != 3 {
+			t.Errorf("Expected 3 counted nacks, got %+v", stats)
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		_, _, ackID := q.DequeueWithAckId()
+		nacked, err := q.NackBatch([]string{ackID}, 0)
+		if err != nil || nacked != 1 {
+			t.Fatalf("Expected the item to be settled, got %d, %v", nacked, err)
+		}
+		if stats, _ := q.Stats(); stats.DeadLettered != 1 {
+			t.Errorf("Expected the exhausted item to be dead-lettered, got %+v", stats)
+		}
+	})
+}
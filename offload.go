@@ -0,0 +1,171 @@
+package duckq
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucsky/cuid"
+)
+
+// offloadPrefix marks a data column holding a reference to an offloaded payload instead of the payload
+const offloadPrefix = "\x00duckq-offload:"
+
+// BlobStore keeps the payloads offloaded by WithPayloadOffload outside the database, e.g. on a
+// filesystem or in an S3 bucket
+// Get must return an error matching fs.ErrNotExist for keys it doesn't have
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FileBlobStore is a BlobStore keeping each payload in a file below a directory
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore returns a BlobStore writing payloads below dir, which is created when needed
+func NewFileBlobStore(dir string) *FileBlobStore {
+	return &FileBlobStore{dir: dir}
+}
+
+// path returns the file for key, rejecting keys that would leave the store's directory
+func (s *FileBlobStore) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(s.dir, name), nil
+}
+
+// Put writes data to the file for key, replacing it atomically
+func (s *FileBlobStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Get reads the file for key
+func (s *FileBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// Delete removes the file for key, a missing file is not an error
+func (s *FileBlobStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// payloadOffload is the claim-check configured with WithPayloadOffload
+type payloadOffload struct {
+	store     BlobStore
+	threshold int
+}
+
+// WithPayloadOffload writes payloads larger than threshold bytes to store and keeps only a
+// reference in the queue row, so large payloads don't bloat the database (the claim-check pattern)
+// Dequeues resolve the reference transparently and verify the payload against the SHA-256
+// recorded in it; a payload missing from the store or changed in it is dead-lettered like a
+// corrupted one, while other store errors leave the item pending
+// Payloads are offloaded by Enqueue, EnqueueID, EnqueueInTx, batches and delayed enqueues,
+// and deleted from the store once an acknowledgement removing their item commits
+// Values, Messages and Get show the reference, and items removed in other ways leave their
+// payload behind, so give the store its own expiry, such as an S3 lifecycle rule
+func WithPayloadOffload(store BlobStore, threshold int) Option {
+	return func(q *Queue) {
+		q.offload = &payloadOffload{store: store, threshold: threshold}
+	}
+}
+
+// offloadPayload writes data to the blob store when it exceeds the offload threshold and
+// returns the reference to store in its place, with the key to store in the row's offload_key column
+// Only rows with an offload_key are resolved, so a payload that merely looks like a reference stays as it is
+func (q *Queue) offloadPayload(data any) (any, sql.NullString, error) {
+	payload, ok := data.([]byte)
+	if q.offload == nil || !ok || len(payload) <= q.offload.threshold {
+		return data, sql.NullString{}, nil
+	}
+
+	key := q.tableName + "/" + cuid.New()
+	if err := q.offload.store.Put(context.Background(), key, payload); err != nil {
+		return nil, sql.NullString{}, fmt.Errorf("failed to offload payload: %w", err)
+	}
+
+	sum := sha256.Sum256(payload)
+	return []byte(offloadPrefix + key + ":" + hex.EncodeToString(sum[:])), sql.NullString{String: key, Valid: true}, nil
+}
+
+// resolvePayload replaces a reference made by offloadPayload with the payload it points to
+// offloadKey is the row's offload_key column, data is only resolved when it references that key
+// Payloads missing from the store or not matching their digest are reported as corrupt
+func (q *Queue) resolvePayload(data []byte, offloadKey sql.NullString) ([]byte, error) {
+	if q.offload == nil || !offloadKey.Valid {
+		return data, nil
+	}
+
+	key, digest, ok := strings.Cut(strings.TrimPrefix(string(data), offloadPrefix), ":")
+	if !ok || !bytes.HasPrefix(data, []byte(offloadPrefix)) || key != offloadKey.String {
+		return nil, corruptPayloadError(fmt.Sprintf("offloaded payload %s has an invalid reference", offloadKey.String))
+	}
+
+	payload, err := q.offload.store.Get(context.Background(), key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, corruptPayloadError(fmt.Sprintf("offloaded payload %s is missing", key))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load offloaded payload %s: %w", key, err)
+	}
+
+	sum := sha256.Sum256(payload)
+	if hex.EncodeToString(sum[:]) != digest {
+		return nil, corruptPayloadError(fmt.Sprintf("offloaded payload %s does not match its digest", key))
+	}
+
+	return payload, nil
+}
+
+// releasePayload deletes the offloaded payload with the row's offload_key once tx commits
+// Only keys the queue generated itself are deleted, e.g. not those of rows cloned from another queue
+func (q *Queue) releasePayload(tx *sql.Tx, offloadKey sql.NullString) {
+	if q.offload == nil || !offloadKey.Valid || !strings.HasPrefix(offloadKey.String, q.tableName+"/") {
+		return
+	}
+
+	q.afterCommit(tx, func() {
+		q.offload.store.Delete(context.Background(), offloadKey.String)
+	})
+}
@@ -0,0 +1,156 @@
+package duckq
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestPayloadOffload(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_payload_offload.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	dir := t.TempDir()
+	q, err := queues.NewQueue("test_queue", WithPayloadOffload(NewFileBlobStore(dir), 16))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	blobs := func() int {
+		matches, _ := filepath.Glob(filepath.Join(dir, "test_queue", "*"))
+		return len(matches)
+	}
+	large := bytes.Repeat([]byte("x"), 1024)
+
+	t.Run("Offloaded", func(t *testing.T) {
+		q.Enqueue(large)
+		q.Enqueue([]byte("small"))
+
+		var stored int
+		queues.DB().QueryRow("SELECT MAX(octet_length(data)) FROM test_queue").Scan(&stored)
+		if stored >= len(large) {
+			t.Errorf("Expected the row to hold a reference, got %d bytes", stored)
+		}
+		if blobs() != 1 {
+			t.Errorf("Expected 1 offloaded payload, got %d", blobs())
+		}
+
+		item, success, ackID := q.DequeueWithAckId()
+		if !success || !bytes.Equal(item.([]byte), large) {
+			t.Fatalf("Expected the offloaded payload to be resolved, got %d bytes", len(item.([]byte)))
+		}
+		q.Acknowledge(ackID)
+		if blobs() != 0 {
+			t.Errorf("Expected the acknowledged payload to be deleted, got %d", blobs())
+		}
+
+		item, _ = q.Dequeue()
+		if string(item.([]byte)) != "small" {
+			t.Errorf("Expected the small payload inline, got %v", item)
+		}
+	})
+
+	t.Run("Dequeue", func(t *testing.T) {
+		q.Enqueue(large)
+		item, _ := q.Dequeue()
+		if !bytes.Equal(item.([]byte), large) {
+			t.Error("Expected the offloaded payload to be resolved")
+		}
+		if blobs() != 0 {
+			t.Errorf("Expected the dequeued payload to be deleted, got %d", blobs())
+		}
+	})
+
+	t.Run("Keyed", func(t *testing.T) {
+		q.EnqueueKeyed("entity:1", large)
+		if superseded, err := q.EnqueueKeyed("entity:1", []byte("small")); err != nil || !superseded {
+			t.Fatalf("Expected the offloaded item to be superseded, got %v (%v)", superseded, err)
+		}
+		if blobs() != 0 {
+			t.Errorf("Expected the superseded payload to be deleted, got %d", blobs())
+		}
+		q.EnqueueKeyed("entity:1", bytes.ToUpper(large))
+
+		item, success := q.Dequeue()
+		if !success || !bytes.Equal(item.([]byte), bytes.ToUpper(large)) {
+			t.Fatalf("Expected the superseding payload to be offloaded and resolved, got %v", success)
+		}
+		if blobs() != 0 {
+			t.Errorf("Expected the dequeued payload to be deleted, got %d", blobs())
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		q.Enqueue(large)
+		q.Enqueue([]byte("next"))
+		os.RemoveAll(filepath.Join(dir, "test_queue"))
+
+		item, _ := q.Dequeue()
+		if string(item.([]byte)) != "next" {
+			t.Errorf("Expected the item with a missing payload to be skipped, got %v", item)
+		}
+		var reason string
+		queues.DB().QueryRow("SELECT reason FROM test_queue_dead_letters").Scan(&reason)
+		if reason == "" {
+			t.Error("Expected the item with a missing payload to be dead-lettered")
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		q.Enqueue(large)
+		matches, _ := filepath.Glob(filepath.Join(dir, "test_queue", "*"))
+		os.WriteFile(matches[0], []byte("tampered"), 0o644)
+
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected the tampered payload to be refused")
+		}
+	})
+
+	t.Run("ForgedReference", func(t *testing.T) {
+		// A producer's payload that looks like a reference must not reach files outside the store
+		victim := filepath.Join(filepath.Dir(dir), "victim.txt")
+		os.WriteFile(victim, []byte("secret"), 0o644)
+		defer os.Remove(victim)
+		sum := sha256.Sum256([]byte("secret"))
+		forged := offloadPrefix + "../victim.txt:" + hex.EncodeToString(sum[:])
+
+		q.Enqueue([]byte(forged))
+		item, success, ackID := q.DequeueWithAckId()
+		if !success || string(item.([]byte)) != forged {
+			t.Fatalf("Expected the forged reference to be delivered as is, got %v", item)
+		}
+		q.Acknowledge(ackID)
+		if _, err := os.Stat(victim); err != nil {
+			t.Errorf("Expected the file outside the store to be kept, got %v", err)
+		}
+
+		store := NewFileBlobStore(dir)
+		if _, err := store.Get(context.Background(), "../victim.txt"); err == nil {
+			t.Error("Expected the store to reject a key outside its directory")
+		}
+		if err := store.Delete(context.Background(), "../victim.txt"); err == nil {
+			t.Error("Expected the store to reject a key outside its directory")
+		}
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_invalid", WithPayloadOffload(nil, 16)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+		if _, err := queues.NewQueue("test_invalid", WithPayloadOffload(NewFileBlobStore(dir), 16), WithJSONPayload()); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultOpenBackoff paces the attempts of WithOpenTimeout when the open retry policy has no backoff
+var defaultOpenBackoff = RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+
+// WithOpenRetry retries opening a database whose file is locked by another process with the
+// attempts and backoff of policy, instead of failing with ErrDatabaseBusy on the first attempt
+func WithOpenRetry(policy RetryPolicy) QueuesOption {
+	return func(q *queues) {
+		q.openRetry = policy
+	}
+}
+
+// WithOpenTimeout keeps retrying to open a database whose file is locked by another process
+// until timeout has passed, e.g. while the previous instance of a service shuts down
+// The delay between attempts follows WithOpenRetry, or grows from 10ms to 500ms without it
+func WithOpenTimeout(timeout time.Duration) QueuesOption {
+	return func(q *queues) {
+		q.openTimeout = timeout
+	}
+}
+
+// open opens the database, retrying while another process holds its lock
+func (q *queues) open(dbPath string) (*sql.DB, error) {
+	backoff := q.openRetry
+	if backoff.InitialBackoff <= 0 {
+		backoff = defaultOpenBackoff
+	}
+
+	dsn := q.dsn(dbPath)
+	deadline := time.Now().Add(q.openTimeout)
+	for attempt := 1; ; attempt++ {
+		db, err := sql.Open("duckdb", dsn)
+		if err == nil {
+			return db, nil
+		}
+		if !isLockConflict(err) {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		delay := backoff.backoff(attempt)
+		if attempt >= q.openRetry.MaxAttempts && !time.Now().Add(delay).Before(deadline) {
+			return nil, fmt.Errorf("%w: %v", ErrDatabaseBusy, err)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// isLockConflict reports whether err is DuckDB failing to lock a database file held by another process
+// The driver reports it as a plain connection error, so the message is all there is to go by
+func isLockConflict(err error) bool {
+	return strings.Contains(err.Error(), "Could not set lock on file") || strings.Contains(err.Error(), "Conflicting lock")
+}
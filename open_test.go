@@ -0,0 +1,83 @@
+package duckq
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+// lockHolderEnv makes the test binary hold the lock on the named database until its stdin closes
+const lockHolderEnv = "DUCKQ_TEST_LOCK_HOLDER"
+
+func TestOpenBusy(t *testing.T) {
+	if dbPath := os.Getenv(lockHolderEnv); dbPath != "" {
+		queues := New(dbPath)
+		queues.NewQueue("test_queue")
+		os.Stdout.WriteString("locked\n")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		queues.Close()
+		return
+	}
+
+	// Create a temporary database file
+	dbPath := "test_open_busy.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	// DuckDB only refuses a locked file to other processes
+	holder := exec.Command(os.Args[0], "-test.run=^TestOpenBusy$")
+	holder.Env = append(os.Environ(), lockHolderEnv+"="+dbPath)
+	release, err := holder.StdinPipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	out, err := holder.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	if err := holder.Start(); err != nil {
+		t.Fatalf("Failed to start lock holder: %v", err)
+	}
+	defer holder.Wait()
+	if line, _ := bufio.NewReader(out).ReadString('\n'); line != "locked\n" {
+		release.Close()
+		t.Fatalf("Expected the lock holder to lock the database, got %q", line)
+	}
+
+	t.Run("Busy", func(t *testing.T) {
+		if _, err := Open(dbPath); !errors.Is(err, ErrDatabaseBusy) {
+			t.Errorf("Expected ErrDatabaseBusy, got %v", err)
+		}
+	})
+
+	t.Run("RetryExhausted", func(t *testing.T) {
+		start := time.Now()
+		_, err := Open(dbPath, WithOpenRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+		if !errors.Is(err, ErrDatabaseBusy) {
+			t.Errorf("Expected ErrDatabaseBusy, got %v", err)
+		}
+		if time.Since(start) < 10*time.Millisecond {
+			t.Error("Expected Open to back off between attempts")
+		}
+	})
+
+	t.Run("WaitForLock", func(t *testing.T) {
+		time.AfterFunc(100*time.Millisecond, func() { release.Close() })
+
+		queues, err := Open(dbPath, WithOpenTimeout(10*time.Second))
+		if err != nil {
+			t.Fatalf("Expected the database to open once the lock was released, got %v", err)
+		}
+		defer queues.Close()
+
+		if _, err := queues.NewQueue("test_queue"); err != nil {
+			t.Errorf("Failed to open queue: %v", err)
+		}
+	})
+}
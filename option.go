@@ -1,6 +1,13 @@
 package duckq
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 // Option is a function type that can be used to configure a Queue
+// Options are validated when the queue is created, which fails with every invalid option at once
 type Option func(*Queue)
 
 // WithRemoveOnComplete sets whether acknowledged items should be deleted
@@ -10,3 +17,452 @@ func WithRemoveOnComplete(remove bool) Option {
 		q.removeOnComplete = remove
 	}
 }
+
+// WithAckTimeout sets how long an item dequeued with an ack ID may stay in processing
+// before its lease is considered expired. A zero duration (the default) never expires leases
+func WithAckTimeout(timeout time.Duration) Option {
+	return func(q *Queue) {
+		q.ackTimeout = timeout
+	}
+}
+
+// WithConsumerID sets the consumer identifier recorded on every lease taken by the queue
+// Defaults to the host name and process ID of the current process
+func WithConsumerID(id string) Option {
+	return func(q *Queue) {
+		q.consumerID = id
+	}
+}
+
+// WithMaxPayloadSize rejects []byte and string payloads larger than size bytes with ErrPayloadTooLarge
+// A zero size (the default) accepts payloads of any size
+func WithMaxPayloadSize(size int) Option {
+	return func(q *Queue) {
+		q.maxPayloadSize = size
+	}
+}
+
+// WithRetryPolicy sets how operations are retried after transient DuckDB errors
+// such as write-write conflicts between concurrent consumers. Use NoRetry to disable retries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(q *Queue) {
+		q.retryPolicy = policy
+	}
+}
+
+// WithChecksum stores a CRC-32 checksum of every binary payload on enqueue
+// Dequeue moves items whose payload no longer matches the checksum to the dead-letter table,
+// and VerifyChecksums lists them
+func WithChecksum(enabled bool) Option {
+	return func(q *Queue) {
+		q.checksum = enabled
+	}
+}
+
+// WithHMAC signs every payload with HMAC-SHA256 and key on enqueue, and verifies the signature
+// on dequeue, moving items whose payload was altered outside the queue, or that carry no signature,
+// to the dead-letter table. Use it when the database file crosses a trust boundary
+// The signature covers the payload only, so rows can still be deleted or swapped
+func WithHMAC(key []byte) Option {
+	return func(q *Queue) {
+		q.hmacKey = append([]byte{}, key...)
+	}
+}
+
+// WithRedactor passes payloads through redact wherever they are shown rather than consumed:
+// Values, Get, Messages and the other message listings, recovery reports and quarantined messages
+// Dequeues and the table keep the full payload. Redacted payloads are decoded like stored ones,
+// so a redactor should keep the payload's format, e.g. mask fields of a JSON document
+func WithRedactor(redact Redactor) Option {
+	return func(q *Queue) {
+		q.redactor = redact
+	}
+}
+
+// WithCloseBehavior sets what operations do after Close: fail with ErrQueueClosed (CloseError),
+// wait for Reopen (CloseBlock) or silently do nothing (CloseDrop)
+func WithCloseBehavior(behavior CloseBehavior) Option {
+	return func(q *Queue) {
+		q.closeBehavior = behavior
+	}
+}
+
+// WithCloseBlockTimeout sets how long CloseBlock waits for Reopen before failing with ErrQueueClosed
+// Defaults to 5 seconds
+func WithCloseBlockTimeout(timeout time.Duration) Option {
+	return func(q *Queue) {
+		q.closeBlockTimeout = timeout
+	}
+}
+
+// WithRecoveryHandler calls handler when opening the queue requeues items that a crashed
+// consumer left in processing, so operators know work was left behind
+func WithRecoveryHandler(handler func(RecoveryReport)) Option {
+	return func(q *Queue) {
+		q.recoveryHandler = handler
+	}
+}
+
+// WithCorruptionHandler calls handler with the ID of every item a dequeue moved to the dead-letter
+// table because its payload failed its checksum or signature, and the error, which matches
+// ErrCorruptPayload, so corruption is noticed as soon as it is read
+func WithCorruptionHandler(handler func(id int64, err error)) Option {
+	return func(q *Queue) {
+		q.corruptionHandler = handler
+	}
+}
+
+// WithPriorityLevels names the priority levels a priority queue accepts, highest first
+// The first level has priority 0, the next 1 and so on. Enqueue rejects priorities that are not
+// one of the levels, and EnqueueLevel enqueues by name
+func WithPriorityLevels(levels ...string) Option {
+	return func(q *Queue) {
+		q.priorityLevels = levels
+	}
+}
+
+// WithPriorityRange makes a priority queue reject priorities outside min to max inclusive
+// with ErrInvalidPriority, on enqueue as well as SetPriority
+func WithPriorityRange(min, max int) Option {
+	return func(q *Queue) {
+		q.priorityRange = &[2]int{min, max}
+	}
+}
+
+// WithRetryPriorityBoost lowers the priority number of nacked and requeued items by delta,
+// so retried work moves ahead of fresh enqueues at the same priority. Only priority queues are affected
+func WithRetryPriorityBoost(delta int) Option {
+	return func(q *Queue) {
+		q.priorityBoost = delta
+	}
+}
+
+// WithDefaultPriority sets the priority of items a priority queue gets without one, through
+// the Enqueue methods of its embedded Queue, e.g. from shared code that takes a *Queue
+// Without it such items get priority 0
+func WithDefaultPriority(priority int) Option {
+	return func(q *Queue) {
+		q.defaultPriority = &priority
+	}
+}
+
+// WithMaxAttempts dead-letters items that were delivered n times instead of requeuing them
+// when they are nacked or recovered after a crash. A zero n (the default) requeues items forever
+func WithMaxAttempts(n int) Option {
+	return func(q *Queue) {
+		q.maxAttempts = n
+	}
+}
+
+// WithDeadLetter sets the name of the table dead-lettered items are moved to
+// Defaults to the queue name followed by _dead_letters
+// Several queues can share a table, each replaying only the items it dead-lettered
+func WithDeadLetter(name string) Option {
+	return func(q *Queue) {
+		q.deadLetterName = name
+	}
+}
+
+// WithCodec encodes items that are neither []byte, string nor registered in the TypeRegistry
+// with codec, and decodes them back on Dequeue and Values
+func WithCodec(codec Codec) Option {
+	return func(q *Queue) {
+		q.codec = codec
+	}
+}
+
+// WithJSONPayload stores payloads in a DuckDB JSON column instead of a BLOB column,
+// so they can be filtered and aggregated with DuckDB's JSON functions
+// []byte and string items must hold valid JSON, other items are marshaled with encoding/json
+// The column type is fixed when the table is created, opening an existing queue
+// with a different payload mode fails with ErrInvalidOption
+func WithJSONPayload() Option {
+	return func(q *Queue) {
+		q.jsonPayload = true
+	}
+}
+
+// WithStructPayload writes the fields of items of the schema's struct type to native columns
+// of the queue table, so queue contents can be filtered, aggregated and joined with plain SQL
+// The item itself is still stored as JSON in the data column and decoded back on Dequeue and Values
+func WithStructPayload(schema *StructSchema) Option {
+	return func(q *Queue) {
+		q.structSchema = schema
+	}
+}
+
+// WithTenant assigns the queue to the tenant with the given ID, so the tenant's quota set with
+// WithTenantQuota or WithDefaultTenantQuota is enforced when the queue is created and on Enqueue
+func WithTenant(tenantID string) Option {
+	return func(q *Queue) {
+		q.tenant = tenantID
+	}
+}
+
+// WithProcessingTimeout leases dequeued items for timeout like WithAckTimeout, and takes action
+// on items still processing once their lease expired: requeue them for another delivery,
+// mark them expired or dead-letter them
+// Requeued items keep their created_at and attempts, so they go back ahead of the items of their
+// priority that arrived later
+// Timed-out items are handled in the background while the queue is open, see ApplyTimeouts
+func WithProcessingTimeout(timeout time.Duration, action TimeoutAction) Option {
+	return func(q *Queue) {
+		q.ackTimeout = timeout
+		q.processingTimeout = true
+		q.processingAction = action
+	}
+}
+
+// WithPendingTTL takes action on items that stayed pending for longer than ttl after becoming
+// visible, because nobody consumed them in time: mark them expired or dead-letter them
+// A stale item is not requeued, so TimeoutRequeue is rejected
+// Stale items are handled in the background while the queue is open, see ApplyTimeouts
+func WithPendingTTL(ttl time.Duration, action TimeoutAction) Option {
+	return func(q *Queue) {
+		q.pendingTTL = ttl
+		q.pendingAction = action
+	}
+}
+
+// WithEnqueueRateLimit limits producers to n enqueues per period, allowing bursts of up to n,
+// so one runaway producer can't monopolize the shared DuckDB writer
+// Enqueues over the limit fail with ErrRateLimited, unless WithRateLimitBehavior is RateLimitThrottle,
+// and batches of more than n items fail with ErrBatchTooLarge, since they would never fit
+// Every enqueue takes a token per item once, before its transaction starts; items forwarded by
+// WithOnAckEnqueue are enqueued by consumers and don't take tokens
+// The limit applies to this Queue value, not to other processes using the same table
+func WithEnqueueRateLimit(n int, per time.Duration) Option {
+	return func(q *Queue) {
+		q.enqueueLimiter = &rateLimiter{limit: n, per: per}
+	}
+}
+
+// WithRateLimitBehavior sets whether enqueues over the WithEnqueueRateLimit rate are rejected
+// with ErrRateLimited (RateLimitReject) or wait until the rate allows them (RateLimitThrottle)
+func WithRateLimitBehavior(behavior RateLimitBehavior) Option {
+	return func(q *Queue) {
+		q.rateLimitBehavior = behavior
+	}
+}
+
+// WithRetention deletes completed items once they were acknowledged longer than retention ago,
+// for queues that keep them with WithRemoveOnComplete(false)
+// They are deleted by the retention maintenance of the Queues that opened the queue
+func WithRetention(retention time.Duration) Option {
+	return func(q *Queue) {
+		q.retention = retention
+	}
+}
+
+// WithQuarantine moves messages that keep crashing their handler into the queue's quarantine
+// table, with the error and stack of the last crash, instead of retrying them forever
+// Crashes are reported by RecordFailure, which Run calls for every failed item, see QuarantinePolicy
+func WithQuarantine(policy QuarantinePolicy) Option {
+	return func(q *Queue) {
+		q.quarantine = &policy
+	}
+}
+
+// WithClock sets the clock the queue reads the current time from
+// A clock implementing Sleeper also serves the waits of throttled enqueues
+func WithClock(clock Clock) Option {
+	return func(q *Queue) {
+		q.clock = clock
+	}
+}
+
+// apply configures the queue with opts and validates the result
+func (q *Queue) apply(opts []Option) error {
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q.validate()
+}
+
+// validate reports every option the queue cannot honor, each wrapping ErrInvalidOption
+func (q *Queue) validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidOption, fmt.Sprintf(format, args...)))
+	}
+
+	if q.maxInFlight < 0 {
+		invalid("max in-flight %d is negative", q.maxInFlight)
+	}
+	if q.maxProcessing < 0 {
+		invalid("max processing %d is negative", q.maxProcessing)
+	}
+	if q.ackTimeout < 0 {
+		invalid("ack timeout %v is negative", q.ackTimeout)
+	}
+	if q.wakeup != nil && q.wakeup.interval <= 0 {
+		invalid("wakeup signal interval %v is not positive", q.wakeup.interval)
+	}
+	if q.ackInterval < 0 {
+		invalid("ack coalescing interval %v is negative", q.ackInterval)
+	}
+	if q.consumerID == "" {
+		invalid("consumer ID is empty")
+	}
+	if q.maxPayloadSize < 0 {
+		invalid("max payload size %d is negative", q.maxPayloadSize)
+	}
+	if q.maxAttempts < 0 {
+		invalid("max attempts %d is negative", q.maxAttempts)
+	}
+	if q.retryPolicy.InitialBackoff < 0 || q.retryPolicy.MaxBackoff < 0 {
+		invalid("retry backoff is negative")
+	}
+	if q.closeBehavior < CloseError || q.closeBehavior > CloseDrop {
+		invalid("unknown close behavior %d", q.closeBehavior)
+	}
+	if q.closeBlockTimeout < 0 {
+		invalid("close block timeout %v is negative", q.closeBlockTimeout)
+	}
+	if q.clock == nil {
+		invalid("clock is nil")
+	}
+	if q.processingTimeout && q.ackTimeout <= 0 {
+		invalid("processing timeout %v is not positive", q.ackTimeout)
+	}
+	if q.processingAction < TimeoutRequeue || q.processingAction > TimeoutDeadLetter {
+		invalid("unknown processing timeout action %d", q.processingAction)
+	}
+	if q.pendingTTL < 0 {
+		invalid("pending TTL %v is negative", q.pendingTTL)
+	}
+	if q.pendingAction == TimeoutRequeue && q.pendingTTL > 0 {
+		invalid("WithPendingTTL cannot requeue items that are already pending")
+	}
+	if q.pendingAction < TimeoutRequeue || q.pendingAction > TimeoutDeadLetter {
+		invalid("unknown pending TTL action %d", q.pendingAction)
+	}
+	if q.enqueueLimiter != nil && (q.enqueueLimiter.limit <= 0 || q.enqueueLimiter.per <= 0) {
+		invalid("enqueue rate limit %d per %v is not positive", q.enqueueLimiter.limit, q.enqueueLimiter.per)
+	}
+	if q.rateLimitBehavior < RateLimitReject || q.rateLimitBehavior > RateLimitThrottle {
+		invalid("unknown rate limit behavior %d", q.rateLimitBehavior)
+	}
+	for _, stage := range q.ackStages {
+		if stage.next == nil || stage.next.client != q.client {
+			invalid("WithOnAckEnqueue needs a next queue opened on the same database")
+		}
+	}
+	for _, window := range q.deliveryWindows {
+		if !window.valid() {
+			invalid("delivery window %q to %q is not a range of 15:04 times of day", window.Start, window.End)
+		}
+	}
+	if q.batcher != nil && (q.batcher.maxItems < 1 || q.batcher.maxDelay <= 0) {
+		invalid("micro-batches of %d items within %v are not positive", q.batcher.maxItems, q.batcher.maxDelay)
+	}
+	if q.batcher != nil && q.jsonPayload {
+		invalid("WithMicroBatching cannot be combined with WithJSONPayload")
+	}
+	if q.batcher != nil && q.quarantine != nil {
+		invalid("WithMicroBatching cannot be combined with WithQuarantine")
+	}
+	if q.offload != nil && (q.offload.store == nil || q.offload.threshold < 0) {
+		invalid("WithPayloadOffload needs a blob store and a non-negative threshold")
+	}
+	if q.offload != nil && q.jsonPayload {
+		invalid("WithPayloadOffload cannot be combined with WithJSONPayload")
+	}
+	if q.hmacKey != nil && len(q.hmacKey) == 0 {
+		invalid("HMAC key is empty")
+	}
+	if q.retention < 0 {
+		invalid("retention %v is negative", q.retention)
+	}
+	if q.quarantine != nil && (q.quarantine.MaxFailures < 1 || q.quarantine.Window <= 0) {
+		invalid("quarantine after %d failures within %v is not positive", q.quarantine.MaxFailures, q.quarantine.Window)
+	}
+	if q.quarantine != nil && q.quarantine.FastFail < 0 {
+		invalid("quarantine fast-fail threshold %v is negative", q.quarantine.FastFail)
+	}
+	if q.jsonPayload && q.codec != nil {
+		invalid("WithCodec cannot be combined with WithJSONPayload")
+	}
+	if q.structSchema != nil {
+		builtin := builtinColumns()
+		for _, column := range q.structSchema.columns {
+			if builtin[column.Name] {
+				invalid("struct column %s collides with a queue column", column.Name)
+			}
+		}
+	}
+	if q.deadLetterName != "" && (q.deadLetterName == q.tableName || q.deadLetterName == ackLogTableName(q.tableName)) {
+		invalid("dead-letter table %q collides with the queue's own tables", q.deadLetterName)
+	}
+
+	if !q.hasPriority {
+		if len(q.priorityLevels) > 0 {
+			invalid("WithPriorityLevels only applies to priority queues")
+		}
+		if q.priorityRange != nil {
+			invalid("WithPriorityRange only applies to priority queues")
+		}
+		if q.priorityBoost != 0 {
+			invalid("WithRetryPriorityBoost only applies to priority queues")
+		}
+		if q.defaultPriority != nil {
+			invalid("WithDefaultPriority only applies to priority queues")
+		}
+		return errors.Join(errs...)
+	}
+	if q.batcher != nil {
+		invalid("WithMicroBatching only applies to regular queues")
+	}
+
+	seen := make(map[string]bool, len(q.priorityLevels))
+	for _, level := range q.priorityLevels {
+		if level == "" || seen[level] {
+			invalid("priority levels must be unique and non-empty, got %q", q.priorityLevels)
+			break
+		}
+		seen[level] = true
+	}
+	if q.priorityRange != nil && q.priorityRange[0] > q.priorityRange[1] {
+		invalid("priority range %d to %d is empty", q.priorityRange[0], q.priorityRange[1])
+	} else if lowest, highest, ok := q.priorityBounds(); ok && lowest > highest {
+		invalid("priority range %d to %d excludes every priority level", q.priorityRange[0], q.priorityRange[1])
+	}
+	if q.priorityBoost < 0 {
+		invalid("retry priority boost %d is negative", q.priorityBoost)
+	}
+	if q.defaultPriority != nil {
+		if err := q.checkPriority(*q.defaultPriority); err != nil {
+			invalid("default priority: %v", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// QueuesOption is a function type that can be used to configure the Queues returned by New
+type QueuesOption func(*queues)
+
+// WithMotherDuckToken sets the token used to authenticate "md:" connection strings
+// Without it DuckDB falls back to the motherduck_token environment variable
+func WithMotherDuckToken(token string) QueuesOption {
+	return func(q *queues) {
+		q.motherDuckToken = token
+	}
+}
+
+// WithProtoTypeURL records the type URL of every message enqueued with EnqueueProto
+// DequeueProto then refuses payloads whose type URL doesn't match the target message
+func WithProtoTypeURL(enabled bool) Option {
+	return func(q *Queue) {
+		q.protoTypeURL = enabled
+	}
+}
+
+// WithTypeRegistry gob-encodes items whose type is registered in the registry and records
+// their type name, so Dequeue and Values return the original concrete type
+func WithTypeRegistry(registry *TypeRegistry) Option {
+	return func(q *Queue) {
+		q.registry = registry
+	}
+}
@@ -0,0 +1,137 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestOptions(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_options.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := queues.NewQueue("test_invalid_queue",
+			WithAckTimeout(-time.Second),
+			WithMaxAttempts(-1),
+			WithPriorityRange(0, 10),
+		)
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("Expected ErrInvalidOption, got %v", err)
+		}
+		if n := strings.Count(err.Error(), ErrInvalidOption.Error()); n != 3 {
+			t.Errorf("Expected all 3 invalid options to be reported, got %d: %v", n, err)
+		}
+
+		var exists bool
+		queues.DB().QueryRow("SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_name = 'test_invalid_queue'").Scan(&exists)
+		if exists {
+			t.Error("An invalid queue should not create its table")
+		}
+
+		if _, err := queues.NewPriorityQueue("test_invalid_priority_queue", WithPriorityRange(5, 1)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for an empty priority range, got %v", err)
+		}
+		if _, err := queues.NewPriorityQueue("test_invalid_priority_queue", WithPriorityLevels("high", "high")); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for duplicate levels, got %v", err)
+		}
+		if _, err := queues.NewQueue("test_invalid_queue", WithClock(nil)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for a nil clock, got %v", err)
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		q, err := queues.NewQueue("test_max_attempts_queue", WithMaxAttempts(2), WithDeadLetter("test_failed_jobs"))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue([]byte("poison"))
+		for attempt := 1; attempt <= 2; attempt++ {
+			_, success, ackID := q.DequeueWithAckId()
+			if !success {
+				t.Fatalf("Attempt %d: DequeueWithAckId failed", attempt)
+			}
+			if !q.Nack(ackID) {
+				t.Fatalf("Attempt %d: Nack failed", attempt)
+			}
+		}
+
+		if q.Len() != 0 {
+			t.Errorf("Expected the exhausted item to leave the queue, got length %d", q.Len())
+		}
+
+		var reason string
+		if err := q.client.QueryRow("SELECT reason FROM test_failed_jobs").Scan(&reason); err != nil {
+			t.Fatalf("Expected the item in the named dead-letter table: %v", err)
+		}
+		if reason != maxAttemptsReason {
+			t.Errorf("Expected reason %q, got %q", maxAttemptsReason, reason)
+		}
+	})
+
+	t.Run("Codec", func(t *testing.T) {
+		q, err := queues.NewQueue("test_codec_queue", WithCodec(JSONCodec{}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue(map[string]any{"to": "ops@example.com"})
+		q.Enqueue([]byte("raw"))
+
+		item, _ := q.Dequeue()
+		if m, ok := item.(map[string]any); !ok || m["to"] != "ops@example.com" {
+			t.Errorf("Expected the decoded map, got %#v", item)
+		}
+		item, _ = q.Dequeue()
+		if string(item.([]byte)) != "raw" {
+			t.Errorf("Expected raw bytes to bypass the codec, got %#v", item)
+		}
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+		dq, err := queues.NewDelayedQueue("test_clock_queue", WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+
+		dq.EnqueueAfter([]byte("tick"), time.Minute)
+		if _, success := dq.Dequeue(); success {
+			t.Fatal("Dequeue should not return an item before the clock reaches it")
+		}
+
+		clock.Advance(time.Minute)
+		if _, success := dq.Dequeue(); !success {
+			t.Error("Dequeue should return the item once the clock reaches it")
+		}
+	})
+}
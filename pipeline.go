@@ -0,0 +1,61 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ackStage enqueues a derived item into the next queue of a pipeline
+type ackStage struct {
+	next      *Queue
+	transform func(item any) (any, error)
+}
+
+// WithOnAckEnqueue makes acknowledging an item enqueue transform(item) into next in the same
+// transaction, so either the acknowledgement and the derived item are both stored or neither is,
+// building multi-stage pipelines without a consumer that could crash between the two
+// A nil transform forwards the item as is, and a transform returning a nil item enqueues nothing
+// When transform returns an error, or next rejects the item, the acknowledgement fails and the
+// item stays leased, so it is retried like any other failure
+// next must be opened on the same database; the option can be repeated to fan out to several queues
+func WithOnAckEnqueue(next *Queue, transform func(item any) (any, error)) Option {
+	return func(q *Queue) {
+		q.ackStages = append(q.ackStages, ackStage{next: next, transform: transform})
+	}
+}
+
+// afterAckInTx releases the dependents of the acknowledged item with the given id and enqueues
+// its derived items into the next queues of the pipeline as part of tx
+// offloadKey is the item's offload_key column, or NULL when data was already resolved
+func (q *Queue) afterAckInTx(tx *sql.Tx, id int64, data []byte, payloadType string, offloadKey sql.NullString) error {
+	if err := q.resolveDependencies(tx, id); err != nil {
+		return err
+	}
+	if len(q.ackStages) == 0 {
+		return nil
+	}
+
+	data, err := q.resolvePayload(data, offloadKey)
+	if err != nil {
+		return err
+	}
+	item := q.decode(data, payloadType)
+	for _, stage := range q.ackStages {
+		derived := item
+		if stage.transform != nil {
+			var err error
+			if derived, err = stage.transform(item); err != nil {
+				return fmt.Errorf("failed to derive item for %s: %w", stage.next.tableName, err)
+			}
+		}
+		if derived == nil {
+			continue
+		}
+
+		if _, err := stage.next.enqueueInTx(tx, derived, sql.NullTime{}); err != nil {
+			return fmt.Errorf("failed to enqueue into %s: %w", stage.next.tableName, err)
+		}
+	}
+
+	return nil
+}
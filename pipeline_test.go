@@ -0,0 +1,97 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestOnAckEnqueue(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_pipeline.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	load, err := queues.NewQueue("test_load")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	audit, err := queues.NewQueue("test_audit")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	errRejected := errors.New("rejected")
+	extract, err := queues.NewQueue("test_extract",
+		WithOnAckEnqueue(load, func(item any) (any, error) {
+			data := string(item.([]byte))
+			switch data {
+			case "reject":
+				return nil, errRejected
+			case "skip":
+				return nil, nil
+			}
+			return []byte(strings.ToUpper(data)), nil
+		}),
+		WithOnAckEnqueue(audit, nil),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Chain", func(t *testing.T) {
+		extract.Enqueue([]byte("row"))
+		_, _, ackID := extract.DequeueWithAckId()
+		if err := extract.Ack(ackID); err != nil {
+			t.Fatalf("Ack failed: %v", err)
+		}
+
+		if item, success := load.Dequeue(); !success || string(item.([]byte)) != "ROW" {
+			t.Errorf("Expected the transformed item in the next stage, got %v", item)
+		}
+		if item, success := audit.Dequeue(); !success || string(item.([]byte)) != "row" {
+			t.Errorf("Expected the item forwarded as is, got %v", item)
+		}
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		extract.Enqueue([]byte("skip"))
+		extract.Dequeue()
+		if load.Len() != 0 || audit.Len() != 1 {
+			t.Errorf("Expected only the audit stage to receive the item, got %d and %d", load.Len(), audit.Len())
+		}
+		audit.Dequeue()
+	})
+
+	t.Run("TransformError", func(t *testing.T) {
+		extract.Enqueue([]byte("reject"))
+		_, _, ackID := extract.DequeueWithAckId()
+		if err := extract.Ack(ackID); !errors.Is(err, errRejected) {
+			t.Fatalf("Expected the transform error, got %v", err)
+		}
+		if audit.Len() != 0 {
+			t.Error("Expected no stage to receive the item of a failed acknowledgement")
+		}
+		if !extract.Nack(ackID) {
+			t.Error("Expected the item to stay leased")
+		}
+	})
+
+	t.Run("OtherDatabase", func(t *testing.T) {
+		otherPath := "test_pipeline_other.db"
+		defer os.Remove(otherPath)
+		other := New(otherPath)
+		defer other.Close()
+
+		next, _ := other.NewQueue("test_next")
+		if _, err := queues.NewQueue("test_source", WithOnAckEnqueue(next, nil)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// Standard priority levels, lower numbers are dequeued first
+const (
+	PriorityHigh = iota
+	PriorityNormal
+	PriorityLow
+)
+
+// DefaultPriorityLevels names the standard priority levels in dequeue order
+var DefaultPriorityLevels = []string{"high", "normal", "low"}
+
+// priorityBounds returns the lowest and highest priority the queue accepts
+// They come from WithPriorityLevels and WithPriorityRange, ok is false when neither is set
+func (q *Queue) priorityBounds() (lowest, highest int, ok bool) {
+	if len(q.priorityLevels) > 0 {
+		lowest, highest, ok = 0, len(q.priorityLevels)-1, true
+	}
+
+	if q.priorityRange != nil {
+		if !ok {
+			lowest, highest, ok = q.priorityRange[0], q.priorityRange[1], true
+		} else {
+			lowest, highest = max(lowest, q.priorityRange[0]), min(highest, q.priorityRange[1])
+		}
+	}
+
+	return lowest, highest, ok
+}
+
+// checkPriority returns ErrInvalidPriority when priority is outside the queue's priority bounds
+// Queues without WithPriorityLevels or WithPriorityRange accept any priority
+func (q *Queue) checkPriority(priority int) error {
+	lowest, highest, ok := q.priorityBounds()
+	if ok && (priority < lowest || priority > highest) {
+		return fmt.Errorf("%w: %d is outside %d to %d", ErrInvalidPriority, priority, lowest, highest)
+	}
+
+	return nil
+}
+
+// retryPrioritySQL returns the SET clause that boosts the priority of a requeued item
+// It is empty unless WithRetryPriorityBoost is set on a priority queue, and never boosts
+// an item past the lowest accepted priority
+func (q *Queue) retryPrioritySQL() string {
+	if !q.hasPriority || q.priorityBoost == 0 {
+		return ""
+	}
+
+	boosted := fmt.Sprintf("priority - %d", q.priorityBoost)
+	if lowest, _, ok := q.priorityBounds(); ok {
+		boosted = fmt.Sprintf("GREATEST(%s, %d)", boosted, lowest)
+	}
+
+	return ", priority = " + boosted
+}
+
+// PriorityOf returns the priority of a named level configured with WithPriorityLevels
+// Without WithPriorityLevels the DefaultPriorityLevels are used
+func (pq *PriorityQueue) PriorityOf(level string) (int, bool) {
+	levels := pq.priorityLevels
+	if len(levels) == 0 {
+		levels = DefaultPriorityLevels
+	}
+
+	priority := slices.Index(levels, level)
+	return priority, priority >= 0
+}
+
+// EnqueueLevel adds an item to the queue with the priority of a named level
+// Returns ErrInvalidPriority for levels the queue doesn't define
+func (pq *PriorityQueue) EnqueueLevel(item any, level string) error {
+	priority, ok := pq.PriorityOf(level)
+	if !ok {
+		return fmt.Errorf("%w: unknown level %q", ErrInvalidPriority, level)
+	}
+
+	if err := pq.checkOpen(); err != nil {
+		if err == errDropped {
+			return nil
+		}
+		return err
+	}
+
+	return pq.retryEnqueue(1, func() error {
+		return pq.inTx(func(tx *sql.Tx) error {
+			_, err := pq.enqueueInTx(tx, item, priority, sql.NullTime{})
+			return err
+		})
+	})
+}
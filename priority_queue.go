@@ -4,8 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
-
-	"github.com/lucsky/cuid"
 )
 
 // PriorityQueue extends Queue with priority-based dequeuing
@@ -13,8 +11,13 @@ type PriorityQueue struct {
 	*Queue
 }
 
+// priorityColumnSQL defines the priority column priority queue tables have after the shared columns
+const priorityColumnSQL = "priority INTEGER NOT NULL DEFAULT 0"
+
 // createPriorityTable creates a table with a priority column for a priority queue
-func createPriorityTable(db *sql.DB, tableName string) error {
+// with the given payload column type
+// Tables created by older versions, or for a regular queue, get the columns added since then
+func createPriorityTable(db execQuerier, tableName, dataType string) error {
 	// First create a sequence for auto-incrementing IDs if it doesn't exist
 	seqName := fmt.Sprintf("%s_id_seq", tableName)
 	createSeqSQL := fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s START 1;", seqName)
@@ -22,50 +25,55 @@ func createPriorityTable(db *sql.DB, tableName string) error {
 	if err != nil {
 		return err
 	}
+	if err := upgradeTable(db, tableName, queueColumnsSQL+",\n\t\t"+priorityColumnSQL); err != nil {
+		return err
+	}
 
 	// Create the table with a priority column included from the start
 	createTableSQL := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS %s (
 		id INTEGER PRIMARY KEY DEFAULT nextval('%s'),
-		data BLOB NOT NULL,
-		status TEXT NOT NULL,
-		ack_id TEXT UNIQUE,
-		ack BOOLEAN DEFAULT 0,
-		created_at TIMESTAMP,
-		updated_at TIMESTAMP,
-		priority INTEGER NOT NULL DEFAULT 0
+		data %s NOT NULL,
+		%s,
+		%s
 	);
 	CREATE INDEX IF NOT EXISTS %s_status_idx ON %s (status, created_at);
 	CREATE INDEX IF NOT EXISTS %s_status_ack_idx ON %s (status, ack);
 	CREATE INDEX IF NOT EXISTS %s_ack_id_idx ON %s (ack_id);
 	CREATE INDEX IF NOT EXISTS %s_priority_idx ON %s (priority ASC, created_at ASC);
-	`, tableName, seqName, tableName, tableName, tableName, tableName, tableName, tableName, tableName, tableName)
+	`, tableName, seqName, dataType, queueColumnsSQL, priorityColumnSQL, tableName, tableName, tableName, tableName, tableName, tableName, tableName, tableName)
 
 	_, err = db.Exec(createTableSQL)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := createAckLogTable(db, tableName); err != nil {
+		return err
+	}
+	if err := createRequeuesTable(db, tableName); err != nil {
+		return err
+	}
+	if err := createDependenciesTable(db, tableName); err != nil {
+		return err
+	}
+
+	return createProcessedTable(db, tableName)
 }
 
 // newPriorityQueue creates a new DuckDB-based priority queue
 func newPriorityQueue(db *sql.DB, tableName string, opts ...Option) (*PriorityQueue, error) {
-	// Create the queue with a priority column included
-	q := &Queue{
-		client:           db,
-		tableName:        tableName,
-		removeOnComplete: true, // Default to removing completed items
+	q, err := prepareQueue(db, tableName, true, opts)
+	if err != nil {
+		return nil, err
 	}
-
-	// Apply any provided options
-	for _, opt := range opts {
-		opt(q)
+	if err := q.inTx(q.createSchema); err != nil {
+		return nil, err
 	}
-
-	// Initialize the table with priority column
-	if err := createPriorityTable(db, tableName); err != nil {
-		return nil, fmt.Errorf("failed to initialize table: %w", err)
+	if err := q.open(); err != nil {
+		return nil, err
 	}
 
-	q.RequeueNoAckRows()
-
 	pq := &PriorityQueue{
 		Queue: q,
 	}
@@ -73,113 +81,275 @@ func newPriorityQueue(db *sql.DB, tableName string, opts ...Option) (*PriorityQu
 	return pq, nil
 }
 
-// No need for initPriorityColumn anymore since we create the table with priority column from the start
-
 // Enqueue adds an item to the queue with a specified priority
 // Lower priority numbers will be dequeued first (0 is highest priority)
 // Returns true if the operation was successful
 func (pq *PriorityQueue) Enqueue(item any, priority int) bool {
-	if pq.closed.Load() {
-		return false
+	if err := pq.checkOpen(); err != nil {
+		return err == errDropped
 	}
 
-	now := time.Now().UTC()
-	tx, err := pq.client.Begin()
-	if err != nil {
-		return false
+	err := pq.retryEnqueue(1, func() error {
+		return pq.inTx(func(tx *sql.Tx) error {
+			_, err := pq.enqueueInTx(tx, item, priority, sql.NullTime{})
+			return err
+		})
+	})
+	return err == nil
+}
+
+// EnqueueInTx adds an item with a specified priority as part of the caller's transaction
+// With RateLimitThrottle, an enqueue over the rate limit waits with tx open
+func (pq *PriorityQueue) EnqueueInTx(tx *sql.Tx, item any, priority int) error {
+	if err := pq.checkEnqueueRate(1); err != nil {
+		return err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+
+	_, err := pq.enqueueInTx(tx, item, priority, sql.NullTime{})
+	return err
+}
+
+// EnqueueID adds an item with a specified priority and returns its generated ID
+// The ID can be passed to SetPriority, Get or Delete later
+// A queue closed with CloseDrop drops the item and returns 0
+func (pq *PriorityQueue) EnqueueID(item any, priority int) (int64, error) {
+	if err := pq.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
 		}
-	}()
+		return 0, err
+	}
 
-	_, err = tx.Exec(
-		fmt.Sprintf("INSERT INTO %s (data, status, created_at, updated_at, priority) VALUES (?, ?, ?, ?, ?)", pq.tableName),
-		item, "pending", now, now, priority,
-	)
+	var id int64
+	err := pq.retryEnqueue(1, func() error {
+		return pq.inTx(func(tx *sql.Tx) error {
+			var err error
+			id, err = pq.enqueueInTx(tx, item, priority, sql.NullTime{})
+			return err
+		})
+	})
 	if err != nil {
-		tx.Rollback()
-		return false
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// EnqueueAfter adds an item with a specified priority that only becomes visible after delay
+// Once visible it is dequeued in priority order like any other item
+// Returns true if the operation was successful
+func (pq *PriorityQueue) EnqueueAfter(item any, priority int, delay time.Duration) bool {
+	if err := pq.checkOpen(); err != nil {
+		return err == errDropped
 	}
 
-	err = tx.Commit()
+	visibleAt := sql.NullTime{Time: pq.now().Add(delay), Valid: true}
+	err := pq.retryEnqueue(1, func() error {
+		return pq.inTx(func(tx *sql.Tx) error {
+			_, err := pq.enqueueInTx(tx, item, priority, visibleAt)
+			return err
+		})
+	})
 	return err == nil
 }
 
-// dequeueInternal overrides the base dequeueInternal method to consider priority
-func (pq *PriorityQueue) dequeueInternal(withAckId bool) (any, bool, string) {
-	if pq.closed.Load() {
-		return nil, false, ""
+// PrioritizedItem is an item and its priority, as enqueued by EnqueueBatch
+type PrioritizedItem struct {
+	Item     any
+	Priority int
+}
+
+// EnqueueBatch adds many items with their own priorities in one transaction
+// Either every item is enqueued or, if any of them fails, none is
+func (pq *PriorityQueue) EnqueueBatch(items []PrioritizedItem) error {
+	if err := pq.checkOpen(); err != nil {
+		if err == errDropped {
+			return nil
+		}
+		return err
 	}
 
-	tx, err := pq.client.Begin()
-	if err != nil {
-		return nil, false, ""
+	return pq.retryEnqueue(len(items), func() error {
+		return pq.inTx(func(tx *sql.Tx) error {
+			for i, item := range items {
+				if _, err := pq.enqueueInTx(tx, item.Item, item.Priority, sql.NullTime{}); err != nil {
+					return fmt.Errorf("item %d: %w", i, err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// enqueueInTx inserts an item with a priority and an optional visibility time as part of tx
+// Returns the ID of the new row
+func (pq *PriorityQueue) enqueueInTx(tx *sql.Tx, item any, priority int, visibleAt sql.NullTime) (int64, error) {
+	if err := pq.checkPriority(priority); err != nil {
+		return 0, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
 
-	// Get the highest priority pending item (lower priority numbers come first)
-	var id int64
-	var data []byte
-	row := tx.QueryRow(fmt.Sprintf(
-		"SELECT id, data FROM %s WHERE status = 'pending' ORDER BY priority ASC, created_at ASC LIMIT 1",
-		pq.tableName,
-	))
-	err = row.Scan(&id, &data)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			tx.Rollback()
-			return nil, false, ""
-		}
-		tx.Rollback()
-		return nil, false, ""
+	return pq.enqueueColumnsInTx(tx, item, visibleAt, []columnValue{{"priority", priority}})
+}
+
+// Dequeue removes and returns the highest priority item from the queue
+// Lower priority numbers come first, items with equal priority are dequeued in FIFO order
+func (pq *PriorityQueue) Dequeue() (any, bool) {
+	item, success, _ := pq.Queue.dequeueInternal(false)
+	return item, success
+}
+
+// DequeueWithAckId removes and returns the highest priority item from the queue with an acknowledgment ID
+func (pq *PriorityQueue) DequeueWithAckId() (any, bool, string) {
+	return pq.Queue.dequeueInternal(true)
+}
+
+// SetPriority changes the priority of a pending item, escalating or demoting it
+// Returns true if a pending item with the given id was updated, false otherwise
+func (pq *PriorityQueue) SetPriority(id int64, priority int) bool {
+	if pq.checkPriority(priority) != nil {
+		return false
 	}
 
-	// Update the status to 'processing' with ack ID or remove directly if no ack ID
-	now := time.Now().UTC()
-	var ackID string
+	if err := pq.checkOpen(); err != nil {
+		return err == errDropped
+	}
 
-	if withAckId {
-		ackID = cuid.New()
+	err := pq.retry(func() error {
+		return pq.inTx(func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				fmt.Sprintf("UPDATE %s SET priority = ?, updated_at = ? WHERE id = ? AND status = 'pending'", pq.tableName),
+				priority, pq.now(), id,
+			)
+			if err != nil {
+				return err
+			}
+			return requireRows(result)
+		})
+	})
+	return err == nil
+}
 
-		_, err = tx.Exec(
-			fmt.Sprintf("UPDATE %s SET status = 'processing', ack_id = ?, updated_at = ? WHERE id = ?", pq.tableName),
-			ackID, now, id,
-		)
+// SetPriorityWhere changes the priority of every pending item matching all given filters
+// Returns the number of items updated, or ErrInvalidPriority for a priority the queue rejects
+func (pq *PriorityQueue) SetPriorityWhere(priority int, filters ...Filter) (int, error) {
+	if err := pq.checkPriority(priority); err != nil {
+		return 0, err
+	}
+
+	where, args := whereFilters(filters...)
+	if where == "" {
+		where = " WHERE status = 'pending'"
 	} else {
-		// remove the row if there is no ack
-		_, err = tx.Exec(
-			fmt.Sprintf("DELETE FROM %s WHERE id = ?", pq.tableName),
-			id,
+		where += " AND status = 'pending'"
+	}
+
+	var updated int64
+	err := pq.retry(func() error {
+		result, err := pq.client.Exec(
+			fmt.Sprintf("UPDATE %s SET priority = ?, updated_at = ?%s", pq.tableName, where),
+			append([]any{priority, pq.now()}, args...)...,
 		)
+		if err != nil {
+			return err
+		}
+
+		updated, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update priorities: %w", err)
 	}
 
+	return int(updated), nil
+}
+
+// DequeueWithin removes and returns the highest priority item whose priority is between
+// minPriority and maxPriority inclusive, with an acknowledgment ID
+// Dedicated workers use it to serve a single priority band
+func (pq *PriorityQueue) DequeueWithin(minPriority, maxPriority int) (any, bool, string) {
+	row, err := pq.dequeueWhere(true, "priority BETWEEN ? AND ?", minPriority, maxPriority)
 	if err != nil {
-		tx.Rollback()
 		return nil, false, ""
 	}
 
-	// Commit transaction
-	err = tx.Commit()
+	return pq.decode(row.data, row.payloadType), true, row.ackID
+}
+
+// LenByPriority returns the number of pending items at each priority
+// Priorities without pending items are left out
+func (pq *PriorityQueue) LenByPriority() map[int]int64 {
+	counts := make(map[int]int64)
+
+	rows, err := pq.client.Query(fmt.Sprintf("SELECT priority, COUNT(*) FROM %s WHERE status = 'pending' GROUP BY priority", pq.tableName))
 	if err != nil {
-		return nil, false, ""
+		return counts
 	}
+	defer rows.Close()
 
-	return data, true, ackID
+	for rows.Next() {
+		var priority int
+		var count int64
+		if err := rows.Scan(&priority, &count); err != nil {
+			continue
+		}
+		counts[priority] = count
+	}
+
+	return counts
 }
 
-// Dequeue overrides the base Dequeue method to use priority-based dequeuing
-func (pq *PriorityQueue) Dequeue() (any, bool) {
-	item, success, _ := pq.dequeueInternal(false)
-	return item, success
+// PriorityStats describes the pending items at one priority of a priority queue
+type PriorityStats struct {
+	Pending int64
+	// OldestPendingAge is how long the oldest visible pending item has waited since it became
+	// visible, a growing age at a low priority means its items are starving
+	OldestPendingAge time.Duration
 }
 
-// DequeueWithAckId overrides the base DequeueWithAckId method to use priority-based dequeuing
-func (pq *PriorityQueue) DequeueWithAckId() (any, bool, string) {
-	return pq.dequeueInternal(true)
+// PriorityQueueStats counts a priority queue's items by status and breaks the pending ones down by priority
+type PriorityQueueStats struct {
+	QueueStats
+	// Priorities holds the priorities with pending items
+	Priorities map[int]PriorityStats
+}
+
+// Stats counts the queue's items by status and by priority in a single transaction, see Queue.Stats
+func (pq *PriorityQueue) Stats() (PriorityQueueStats, error) {
+	stats := PriorityQueueStats{Priorities: make(map[int]PriorityStats)}
+	err := pq.readSnapshot(func(tx *sql.Tx) (err error) {
+		if stats.QueueStats, err = pq.stats(tx); err != nil {
+			return err
+		}
+
+		now := pq.now()
+		rows, err := tx.Query(fmt.Sprintf(`
+			SELECT priority, COUNT(*), MIN(COALESCE(visible_at, created_at)) FILTER (WHERE COALESCE(visible_at, created_at) <= ?)
+			FROM %s WHERE status = 'pending' GROUP BY priority`, pq.tableName),
+			now,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var priority int
+			var level PriorityStats
+			var oldest sql.NullTime
+			if err := rows.Scan(&priority, &level.Pending, &oldest); err != nil {
+				return err
+			}
+			if oldest.Valid {
+				level.OldestPendingAge = max(now.Sub(oldest.Time), 0)
+			}
+			stats.Priorities[priority] = level
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return PriorityQueueStats{}, fmt.Errorf("failed to read stats: %w", err)
+	}
+
+	return stats, nil
 }
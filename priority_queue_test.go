@@ -1,9 +1,11 @@
 package duckq
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb/v2"
 )
@@ -553,3 +555,531 @@ func TestPriorityQueueConcurrentOperations(t *testing.T) {
 		t.Errorf("Expected empty queue, got length %d", pq.Len())
 	}
 }
+
+// Test enqueueing with priority as part of an application transaction
+func TestPriorityQueueEnqueueInTx(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_enqueue_in_tx.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("low"), 10)
+
+	tx, err := queuesInstance.DB().Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := pq.EnqueueInTx(tx, []byte("high"), 1); err != nil {
+		t.Fatalf("EnqueueInTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	item, success := pq.Dequeue()
+	if !success {
+		t.Fatal("Dequeue failed")
+	}
+	if string(item.([]byte)) != "high" {
+		t.Errorf("Expected 'high', got '%s'", string(item.([]byte)))
+	}
+}
+
+func TestPriorityQueueSetPriority(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_set_priority.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("first"), 5)
+	pq.Enqueue([]byte("second"), 5)
+	pq.Enqueue([]byte("urgent"), 5)
+
+	var id int64
+	if err := pq.client.QueryRow("SELECT id FROM test_priority_queue ORDER BY id DESC LIMIT 1").Scan(&id); err != nil {
+		t.Fatalf("Error reading message id: %v", err)
+	}
+
+	t.Run("Escalate", func(t *testing.T) {
+		if !pq.SetPriority(id, 1) {
+			t.Fatal("SetPriority failed")
+		}
+
+		item, success, ackID := pq.DequeueWithAckId()
+		if !success || string(item.([]byte)) != "urgent" {
+			t.Errorf("Expected 'urgent' to be dequeued first, got '%v'", item)
+		}
+
+		// Items that are already processing keep their priority
+		if pq.SetPriority(id, 0) {
+			t.Error("SetPriority on a processing item should fail")
+		}
+		pq.Acknowledge(ackID)
+
+		if pq.SetPriority(9999, 0) {
+			t.Error("SetPriority on an unknown id should fail")
+		}
+	})
+
+	t.Run("BulkDemote", func(t *testing.T) {
+		updated, err := pq.SetPriorityWhere(20, Filter{CreatedBefore: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("SetPriorityWhere failed: %v", err)
+		}
+		if updated != 2 {
+			t.Errorf("Expected 2 updated items, got %d", updated)
+		}
+
+		pq.Enqueue([]byte("fresh"), 10)
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "fresh" {
+			t.Errorf("Expected 'fresh' ahead of the demoted items, got '%s'", string(item.([]byte)))
+		}
+	})
+}
+
+func TestPriorityQueueLevels(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_levels.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	defer queuesInstance.Close()
+
+	t.Run("StandardLevels", func(t *testing.T) {
+		pq, err := queuesInstance.NewPriorityQueue("test_standard_queue")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue([]byte("low"), PriorityLow)
+		if err := pq.EnqueueLevel([]byte("high"), "high"); err != nil {
+			t.Fatalf("EnqueueLevel failed: %v", err)
+		}
+		pq.Enqueue([]byte("normal"), PriorityNormal)
+
+		for _, want := range []string{"high", "normal", "low"} {
+			item, _ := pq.Dequeue()
+			if string(item.([]byte)) != want {
+				t.Errorf("Expected '%s', got '%s'", want, string(item.([]byte)))
+			}
+		}
+
+		// Without levels any integer priority is accepted
+		if !pq.Enqueue([]byte("custom"), 42) {
+			t.Error("Enqueue with a custom priority failed")
+		}
+	})
+
+	t.Run("CustomLevels", func(t *testing.T) {
+		pq, err := queuesInstance.NewPriorityQueue("test_custom_queue", WithPriorityLevels("critical", "default", "bulk"))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		if priority, ok := pq.PriorityOf("bulk"); !ok || priority != 2 {
+			t.Errorf("Expected 'bulk' to have priority 2, got %d", priority)
+		}
+		if err := pq.EnqueueLevel([]byte("item"), "high"); !errors.Is(err, ErrInvalidPriority) {
+			t.Errorf("Expected ErrInvalidPriority for an unknown level, got %v", err)
+		}
+		if pq.Enqueue([]byte("item"), 3) {
+			t.Error("Enqueue with a priority outside the levels should fail")
+		}
+		if err := pq.EnqueueLevel([]byte("item"), "critical"); err != nil {
+			t.Errorf("EnqueueLevel failed: %v", err)
+		}
+		if pq.Len() != 1 {
+			t.Errorf("Expected queue length 1, got %d", pq.Len())
+		}
+	})
+}
+
+func TestPriorityQueueDequeueWithin(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_dequeue_within.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("bulk"), 50)
+	pq.Enqueue([]byte("urgent"), 1)
+	pq.Enqueue([]byte("fast"), 5)
+
+	// The fast lane only serves priorities 3 to 10
+	item, success, ackID := pq.DequeueWithin(3, 10)
+	if !success || string(item.([]byte)) != "fast" {
+		t.Fatalf("Expected 'fast', got '%v'", item)
+	}
+	if !pq.Acknowledge(ackID) {
+		t.Error("Acknowledge failed")
+	}
+
+	if _, success, _ := pq.DequeueWithin(3, 10); success {
+		t.Error("DequeueWithin should fail when the band is empty")
+	}
+
+	item, success, _ = pq.DequeueWithin(0, 100)
+	if !success || string(item.([]byte)) != "urgent" {
+		t.Errorf("Expected 'urgent' from the full band, got '%v'", item)
+	}
+	if pq.Len() != 1 {
+		t.Errorf("Expected the bulk item to stay pending, got length %d", pq.Len())
+	}
+}
+
+func TestPriorityQueueLenByPriority(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_len_by_priority.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	if counts := pq.LenByPriority(); len(counts) != 0 {
+		t.Errorf("Expected no counts for an empty queue, got %v", counts)
+	}
+
+	pq.Enqueue([]byte("urgent"), 1)
+	pq.Enqueue([]byte("bulk 1"), 10)
+	pq.Enqueue([]byte("bulk 2"), 10)
+	pq.Enqueue([]byte("bulk 3"), 10)
+
+	// Processing items are not pending anymore
+	pq.DequeueWithAckId()
+
+	counts := pq.LenByPriority()
+	if len(counts) != 1 || counts[10] != 3 {
+		t.Errorf("Expected 3 pending items at priority 10 only, got %v", counts)
+	}
+}
+
+func TestPriorityQueueValues(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_values.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("low"), 10)
+	pq.Enqueue([]byte("high 1"), 1)
+	pq.Enqueue([]byte("medium"), 5)
+	pq.Enqueue([]byte("high 2"), 1)
+
+	// Values lists items in the order Dequeue returns them
+	values := pq.Values()
+	if len(values) != 4 {
+		t.Fatalf("Expected 4 values, got %d", len(values))
+	}
+	for i, value := range values {
+		item, _ := pq.Dequeue()
+		if string(value.([]byte)) != string(item.([]byte)) {
+			t.Errorf("Value %d is '%s' but Dequeue returned '%s'", i, string(value.([]byte)), string(item.([]byte)))
+		}
+	}
+}
+
+func TestPriorityQueueRetryPriorityBoost(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_retry_priority_boost.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	defer queuesInstance.Close()
+
+	t.Run("Nack", func(t *testing.T) {
+		pq, err := queuesInstance.NewPriorityQueue("test_boost_queue", WithRetryPriorityBoost(2))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue([]byte("retried"), 5)
+		_, _, ackID := pq.DequeueWithAckId()
+
+		// A flood of fresh work at the retried item's original priority
+		pq.Enqueue([]byte("fresh"), 5)
+		pq.Enqueue([]byte("fresh"), 4)
+
+		if !pq.Nack(ackID) {
+			t.Fatal("Nack failed")
+		}
+
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "retried" {
+			t.Errorf("Expected the retried item first, got '%s'", string(item.([]byte)))
+		}
+	})
+
+	t.Run("Requeue", func(t *testing.T) {
+		pq, err := queuesInstance.NewPriorityQueue("test_requeue_boost_queue",
+			WithRetryPriorityBoost(5), WithPriorityLevels(DefaultPriorityLevels...))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue([]byte("crashed"), PriorityLow)
+		pq.DequeueWithAckId()
+		pq.RequeueNoAckRows()
+
+		// The boost never goes past the highest named level
+		counts := pq.LenByPriority()
+		if counts[PriorityHigh] != 1 {
+			t.Errorf("Expected the requeued item at PriorityHigh, got %v", counts)
+		}
+	})
+}
+
+func TestPriorityQueuePriorityRange(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_range.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue", WithPriorityRange(1, 10))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	tx, err := queuesInstance.DB().Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	for _, priority := range []int{0, 11, -5} {
+		if err := pq.EnqueueInTx(tx, []byte("out of range"), priority); !errors.Is(err, ErrInvalidPriority) {
+			t.Errorf("Expected ErrInvalidPriority for priority %d, got %v", priority, err)
+		}
+	}
+	for _, priority := range []int{1, 10} {
+		if err := pq.EnqueueInTx(tx, []byte("in range"), priority); err != nil {
+			t.Errorf("EnqueueInTx with priority %d failed: %v", priority, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if pq.Enqueue([]byte("out of range"), 100) {
+		t.Error("Enqueue with an out of range priority should fail")
+	}
+	if _, err := pq.SetPriorityWhere(0); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority from SetPriorityWhere, got %v", err)
+	}
+	if pq.Len() != 2 {
+		t.Errorf("Expected queue length 2, got %d", pq.Len())
+	}
+}
+
+func TestPriorityQueueEnqueueAfter(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_enqueue_after.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	if !pq.EnqueueAfter([]byte("escalation"), 0, 100*time.Millisecond) {
+		t.Fatal("EnqueueAfter failed")
+	}
+	pq.Enqueue([]byte("routine"), 10)
+
+	// The escalation is more urgent but not visible yet
+	item, success := pq.Dequeue()
+	if !success || string(item.([]byte)) != "routine" {
+		t.Errorf("Expected 'routine' while the escalation is delayed, got '%v'", item)
+	}
+	if _, success := pq.Dequeue(); success {
+		t.Error("Dequeue should not return a delayed item early")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	pq.Enqueue([]byte("later routine"), 10)
+
+	item, success = pq.Dequeue()
+	if !success || string(item.([]byte)) != "escalation" {
+		t.Errorf("Expected 'escalation' once visible, got '%v'", item)
+	}
+}
+
+func TestPriorityQueueEnqueueBatch(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_enqueue_batch.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue", WithPriorityRange(0, 10))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	t.Run("EnqueuesEveryItem", func(t *testing.T) {
+		var plan []PrioritizedItem
+		for i := 0; i < 100; i++ {
+			plan = append(plan, PrioritizedItem{Item: []byte(fmt.Sprintf("step %d", i)), Priority: 10 - i%11})
+		}
+
+		if err := pq.EnqueueBatch(plan); err != nil {
+			t.Fatalf("EnqueueBatch failed: %v", err)
+		}
+		if pq.Len() != 100 {
+			t.Errorf("Expected queue length 100, got %d", pq.Len())
+		}
+
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "step 10" {
+			t.Errorf("Expected the first priority 0 item 'step 10', got '%s'", string(item.([]byte)))
+		}
+		pq.Purge()
+	})
+
+	t.Run("AllOrNothing", func(t *testing.T) {
+		err := pq.EnqueueBatch([]PrioritizedItem{
+			{Item: []byte("valid"), Priority: 1},
+			{Item: []byte("invalid"), Priority: 99},
+		})
+		if !errors.Is(err, ErrInvalidPriority) {
+			t.Errorf("Expected ErrInvalidPriority, got %v", err)
+		}
+		if pq.Len() != 0 {
+			t.Errorf("Expected no items after a failed batch, got %d", pq.Len())
+		}
+	})
+}
+
+func TestPriorityQueueStats(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_priority_stats.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue", WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	pq.Enqueue([]byte("bulk"), 10)
+	clock.Advance(10 * time.Minute)
+	pq.Enqueue([]byte("urgent 1"), 1)
+	pq.Enqueue([]byte("urgent 2"), 1)
+	pq.EnqueueAfter([]byte("later"), 5, time.Hour)
+	pq.DequeueWithAckId()
+	clock.Advance(time.Minute)
+
+	stats, err := pq.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Pending != 3 || stats.Processing != 1 {
+		t.Errorf("Expected 3 pending and 1 processing items, got %+v", stats.QueueStats)
+	}
+
+	want := map[int]PriorityStats{
+		1:  {Pending: 1, OldestPendingAge: time.Minute},
+		5:  {Pending: 1},
+		10: {Pending: 1, OldestPendingAge: 11 * time.Minute},
+	}
+	if len(stats.Priorities) != len(want) {
+		t.Fatalf("Expected stats for %d priorities, got %v", len(want), stats.Priorities)
+	}
+	for priority, level := range want {
+		if stats.Priorities[priority] != level {
+			t.Errorf("Expected %+v at priority %d, got %+v", level, priority, stats.Priorities[priority])
+		}
+	}
+}
+
+func TestPriorityQueueDefaultPriority(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_default_priority.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queuesInstance := New(dbPath)
+	pq, err := queuesInstance.NewPriorityQueue("test_priority_queue", WithDefaultPriority(5))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	defer queuesInstance.Close()
+
+	// Shared code that only knows about *Queue enqueues without a priority
+	enqueue := func(q *Queue, item string) {
+		if !q.Enqueue([]byte(item)) {
+			t.Fatalf("Failed to enqueue %s", item)
+		}
+	}
+	enqueue(pq.Queue, "default")
+	pq.Enqueue([]byte("bulk"), 9)
+	pq.Enqueue([]byte("urgent"), 1)
+
+	if counts := pq.LenByPriority(); counts[5] != 1 || counts[0] != 0 {
+		t.Errorf("Expected the item at the default priority, got %v", counts)
+	}
+	for _, want := range []string{"urgent", "default", "bulk"} {
+		if item, _ := pq.Dequeue(); string(item.([]byte)) != want {
+			t.Errorf("Expected %s, got %v", want, item)
+		}
+	}
+
+	if _, err := queuesInstance.NewPriorityQueue("test_invalid", WithPriorityRange(1, 3), WithDefaultPriority(5)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a default outside the range, got %v", err)
+	}
+	if _, err := queuesInstance.NewQueue("test_invalid", WithDefaultPriority(5)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption on a regular queue, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// JobProgress is the partial progress a consumer saved for an item with Progress
+type JobProgress struct {
+	// Percent is how much of the item was processed, from 0 to 100
+	Percent int
+	// Checkpoint is the consumer's own resume point, e.g. the last chunk it finished
+	Checkpoint []byte
+}
+
+// Progress saves the partial progress of the processing item with ackID, so a consumer that
+// gets it again after a Nack, an expired lease or a crash can resume from checkpoint with
+// LastProgress instead of starting over
+// percent is clamped to 0..100, and saving progress doesn't extend the item's lease
+// Returns ErrAckNotFound if no processing item has ackID
+func (q *Queue) Progress(ackID string, percent int, checkpoint []byte) error {
+	percent = min(max(percent, 0), 100)
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				fmt.Sprintf("UPDATE %s SET progress = ?, checkpoint = ?, updated_at = ? WHERE ack_id = ? AND status = 'processing'", q.tableName),
+				percent, checkpoint, q.now(), ackID,
+			)
+			if err != nil {
+				return err
+			}
+
+			return requireRows(result)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+
+	return nil
+}
+
+// LastProgress returns the progress last saved by Progress for the processing item with ackID,
+// during this delivery or an earlier one, or a zero JobProgress when none was saved
+// Returns ErrAckNotFound if no processing item has ackID
+func (q *Queue) LastProgress(ackID string) (JobProgress, error) {
+	var progress JobProgress
+	err := q.client.QueryRow(
+		fmt.Sprintf("SELECT progress, checkpoint FROM %s WHERE ack_id = ? AND status = 'processing'", q.tableName),
+		ackID,
+	).Scan(&progress.Percent, &progress.Checkpoint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return JobProgress{}, fmt.Errorf("failed to read progress: %w", ErrAckNotFound)
+	}
+	if err != nil {
+		return JobProgress{}, fmt.Errorf("failed to read progress: %w", err)
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,68 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestProgress(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_progress.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("import.csv"))
+
+	t.Run("NoProgress", func(t *testing.T) {
+		_, _, ackID := q.DequeueWithAckId()
+		progress, err := q.LastProgress(ackID)
+		if err != nil {
+			t.Fatalf("LastProgress failed: %v", err)
+		}
+		if progress.Percent != 0 || progress.Checkpoint != nil {
+			t.Errorf("Expected no progress, got %+v", progress)
+		}
+
+		if err := q.Progress(ackID, 40, []byte("chunk 4")); err != nil {
+			t.Fatalf("Progress failed: %v", err)
+		}
+		q.Nack(ackID)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		// The redelivered item keeps the progress of the earlier delivery
+		_, _, ackID := q.DequeueWithAckId()
+		progress, err := q.LastProgress(ackID)
+		if err != nil {
+			t.Fatalf("LastProgress failed: %v", err)
+		}
+		if progress.Percent != 40 || string(progress.Checkpoint) != "chunk 4" {
+			t.Errorf("Expected the saved progress, got %+v", progress)
+		}
+
+		if err := q.Progress(ackID, 150, []byte("chunk 10")); err != nil {
+			t.Fatalf("Progress failed: %v", err)
+		}
+		if progress, _ := q.LastProgress(ackID); progress.Percent != 100 {
+			t.Errorf("Expected the percentage to be clamped, got %d", progress.Percent)
+		}
+		q.Ack(ackID)
+
+		if err := q.Progress(ackID, 100, nil); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound once acknowledged, got %v", err)
+		}
+		if _, err := q.LastProgress(ackID); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound once acknowledged, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// protoTypeURLPrefix is the prefix used by google.protobuf.Any type URLs
+const protoTypeURLPrefix = "type.googleapis.com/"
+
+// protoTypeURL returns the type URL of a protobuf message
+func protoTypeURL(m proto.Message) string {
+	return protoTypeURLPrefix + string(m.ProtoReflect().Descriptor().FullName())
+}
+
+// EnqueueProto marshals a protobuf message and adds it to the queue
+// With WithProtoTypeURL the message's type URL is stored in the payload_type column
+func (q *Queue) EnqueueProto(m proto.Message) error {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal protobuf message: %w", err)
+	}
+
+	var columns []columnValue
+	if q.protoTypeURL {
+		columns = append(columns, columnValue{"payload_type", protoTypeURL(m)})
+	}
+
+	err = q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			_, err := q.enqueueColumnsInTx(tx, data, sql.NullTime{}, columns)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue protobuf message: %w", err)
+	}
+
+	return nil
+}
+
+// DequeueProto removes the next item from the queue with an acknowledgment ID and unmarshals it into m
+// Items that cannot be unmarshalled into m, or whose stored type URL doesn't match m,
+// are moved to the dead-letter table and reported with ErrInvalidPayload
+// Returns the acknowledgment ID, or ErrEmptyQueue when no item is pending
+func (q *Queue) DequeueProto(m proto.Message) (string, error) {
+	row, err := q.dequeueWhere(true, "")
+	if err != nil {
+		return "", err
+	}
+
+	if expected := protoTypeURL(m); q.protoTypeURL && row.payloadType != "" && row.payloadType != expected {
+		reason := fmt.Sprintf("payload type %s does not match %s", row.payloadType, expected)
+		q.DeadLetter(row.ackID, reason)
+		return "", fmt.Errorf("%w: %s", ErrInvalidPayload, reason)
+	}
+
+	if err := proto.Unmarshal(row.data, m); err != nil {
+		q.DeadLetter(row.ackID, fmt.Sprintf("invalid protobuf payload: %v", err))
+		return "", fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	return row.ackID, nil
+}
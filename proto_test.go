@@ -0,0 +1,98 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoPayloads(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_proto.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		q, err := queues.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		if err := q.EnqueueProto(wrapperspb.String("hello")); err != nil {
+			t.Fatalf("EnqueueProto failed: %v", err)
+		}
+		if q.ApproxLen() != 1 {
+			t.Errorf("Expected the message to be counted, got approximate length %d", q.ApproxLen())
+		}
+
+		var got wrapperspb.StringValue
+		ackID, err := q.DequeueProto(&got)
+		if err != nil {
+			t.Fatalf("DequeueProto failed: %v", err)
+		}
+		if got.GetValue() != "hello" {
+			t.Errorf("Expected 'hello', got '%s'", got.GetValue())
+		}
+		if !q.Acknowledge(ackID) {
+			t.Error("Acknowledge failed")
+		}
+
+		if _, err := q.DequeueProto(&got); !errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected ErrEmptyQueue on empty queue, got %v", err)
+		}
+	})
+
+	t.Run("InvalidPayloadIsDeadLettered", func(t *testing.T) {
+		q, err := queues.NewQueue("test_invalid_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue([]byte{0xff, 0xff, 0xff})
+
+		var got wrapperspb.StringValue
+		if _, err := q.DequeueProto(&got); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Expected ErrInvalidPayload, got %v", err)
+		}
+		if replayed, _ := q.ReplayDeadLetters(0, false); replayed != 1 {
+			t.Errorf("Expected 1 dead letter, got %d", replayed)
+		}
+	})
+
+	t.Run("TypeURL", func(t *testing.T) {
+		q, err := queues.NewQueue("test_typed_queue", WithProtoTypeURL(true))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.EnqueueProto(durationpb.New(0))
+		q.EnqueueProto(wrapperspb.String("typed"))
+
+		var payloadType string
+		row := q.client.QueryRow("SELECT payload_type FROM test_typed_queue ORDER BY id DESC LIMIT 1")
+		if err := row.Scan(&payloadType); err != nil {
+			t.Fatalf("Error reading payload type: %v", err)
+		}
+		if payloadType != "type.googleapis.com/google.protobuf.StringValue" {
+			t.Errorf("Unexpected payload type '%s'", payloadType)
+		}
+
+		// The duration would otherwise decode silently into a StringValue
+		var got wrapperspb.StringValue
+		if _, err := q.DequeueProto(&got); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Expected ErrInvalidPayload for a mismatched type URL, got %v", err)
+		}
+
+		_, err = q.DequeueProto(&got)
+		if err != nil || got.GetValue() != "typed" {
+			t.Errorf("Expected 'typed', got '%s'", got.GetValue())
+		}
+	})
+}
@@ -0,0 +1,316 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QuarantinePolicy sets when a message that keeps crashing its handler is quarantined
+// A crash is a handler panic, or an error returned faster than FastFail, which usually means
+// the handler chokes on the message itself rather than on a transient problem
+type QuarantinePolicy struct {
+	// MaxFailures is how many crashes within Window quarantine the message
+	MaxFailures int `yaml:"max_failures" json:"max_failures"`
+	// Window is how far back crashes are counted
+	Window time.Duration `yaml:"window" json:"window"`
+	// FastFail counts errors returned within this duration of the delivery as crashes
+	// Zero counts only panics
+	FastFail time.Duration `yaml:"fast_fail" json:"fast_fail"`
+}
+
+// HandlerFailure describes a single failed delivery, reported with RecordFailure
+type HandlerFailure struct {
+	// Err is the error the handler returned or the panic it raised
+	Err error
+	// Stack is the stack trace captured at the panic, if any
+	Stack string
+	// Panicked reports whether the handler panicked
+	Panicked bool
+	// Duration is how long the handler ran before failing
+	Duration time.Duration
+}
+
+// PanicError is returned by handlers wrapped with Recover when they panic
+// It wraps ErrHandlerPanic and carries the stack trace captured at the panic
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error returns the panic value
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrHandlerPanic, e.Value)
+}
+
+// Unwrap returns ErrHandlerPanic
+func (e *PanicError) Unwrap() error {
+	return ErrHandlerPanic
+}
+
+// failureFromError describes a delivery whose handler returned err after running for duration
+func failureFromError(err error, duration time.Duration) HandlerFailure {
+	failure := HandlerFailure{
+		Err:      err,
+		Panicked: errors.Is(err, ErrHandlerPanic),
+		Duration: duration,
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		failure.Stack = string(panicErr.Stack)
+	}
+
+	return failure
+}
+
+// QuarantinedMessage is a message moved out of the queue because it kept crashing its handler
+type QuarantinedMessage struct {
+	ID            int64
+	Data          []byte
+	Attempts      int
+	Priority      int
+	Failures      int
+	Error         string
+	Stack         string
+	CreatedAt     time.Time
+	QuarantinedAt time.Time
+}
+
+// quarantineTableName returns the name of the table holding a queue's quarantined messages
+func quarantineTableName(tableName string) string {
+	return fmt.Sprintf("%s_quarantine", tableName)
+}
+
+// failuresTableName returns the name of the table recording a queue's recent handler crashes
+func failuresTableName(tableName string) string {
+	return fmt.Sprintf("%s_failures", tableName)
+}
+
+// quarantineColumnsSQL defines the columns of quarantine tables after the id and data columns
+const quarantineColumnsSQL = `attempts INTEGER NOT NULL DEFAULT 0,
+		priority INTEGER NOT NULL DEFAULT 0,
+		failures INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		stack TEXT,
+		checksum BIGINT,
+		signature BLOB,
+		created_at TIMESTAMP,
+		quarantined_at TIMESTAMP,
+		` + typedColumnsSQL
+
+// initQuarantine creates the quarantine and failure tables of a queue created with WithQuarantine
+// Tables created by older versions get the columns added since then
+func (q *Queue) initQuarantine(db execQuerier) error {
+	if q.quarantine == nil {
+		return nil
+	}
+	if err := upgradeTable(db, quarantineTableName(q.tableName), quarantineColumnsSQL); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY,
+		data %s NOT NULL,
+		%s
+	);
+	CREATE TABLE IF NOT EXISTS %s (
+		message_id BIGINT NOT NULL,
+		failed_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS %s_message_id_idx ON %s (message_id);
+	`, quarantineTableName(q.tableName), q.payloadColumnType(), quarantineColumnsSQL, failuresTableName(q.tableName), failuresTableName(q.tableName), failuresTableName(q.tableName)))
+	return err
+}
+
+// isCrash reports whether a failure counts towards the quarantine policy
+func (p QuarantinePolicy) isCrash(failure HandlerFailure) bool {
+	return failure.Panicked || failure.Duration < p.FastFail
+}
+
+// RecordFailure nacks the processing item with the given ack ID after its handler failed
+// On a queue created with WithQuarantine, a crash is recorded against the message first, and the
+// message is moved to the quarantine table with the failure's error and stack instead of being
+// nacked once it crashed MaxFailures times within the policy's window
+// Run calls it for every failed item of a queue that has it
+// Returns whether the item was quarantined, and ErrAckNotFound if the ack ID isn't leased
+func (q *Queue) RecordFailure(ackID string, failure HandlerFailure) (bool, error) {
+	if q.quarantine == nil || !q.quarantine.isCrash(failure) {
+		if !q.Nack(ackID) {
+			return false, ErrAckNotFound
+		}
+		return false, nil
+	}
+
+	var quarantined bool
+	err := q.retry(func() error {
+		quarantined = false
+		return q.inTx(func(tx *sql.Tx) error {
+			var id int64
+			err := tx.QueryRow(fmt.Sprintf("SELECT id FROM %s WHERE ack_id = ? AND status = 'processing'", q.tableName), ackID).Scan(&id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrAckNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			now := q.now()
+			failures := failuresTableName(q.tableName)
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE message_id = ? AND failed_at < ?", failures), id, now.Add(-q.quarantine.Window)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (message_id, failed_at) VALUES (?, ?)", failures), id, now); err != nil {
+				return err
+			}
+
+			var crashes int
+			if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE message_id = ?", failures), id).Scan(&crashes); err != nil {
+				return err
+			}
+			if crashes < q.quarantine.MaxFailures {
+				return q.nackInTx(tx, ackID)
+			}
+
+			quarantined = true
+			return q.quarantineInTx(tx, id, crashes, failure)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrAckNotFound) {
+			return false, err
+		}
+		return false, fmt.Errorf("failed to record failure: %w", err)
+	}
+
+	return quarantined, nil
+}
+
+// quarantineInTx moves the message with the given id into the quarantine table as part of tx
+func (q *Queue) quarantineInTx(tx *sql.Tx, id int64, crashes int, failure HandlerFailure) error {
+	priorityColumn := "0"
+	if q.hasPriority {
+		priorityColumn = "priority"
+	}
+
+	var message string
+	if failure.Err != nil {
+		message = failure.Err.Error()
+	}
+
+	_, err := tx.Exec(
+		fmt.Sprintf(
+			"INSERT INTO %s (id, data, attempts, priority, failures, error, stack, checksum, signature, created_at, quarantined_at, %s) SELECT id, data, attempts, %s, CAST(? AS INTEGER), CAST(? AS TEXT), CAST(? AS TEXT), checksum, signature, created_at, CAST(? AS TIMESTAMP), %s FROM %s WHERE id = ?",
+			quarantineTableName(q.tableName), typedColumns, priorityColumn, typedColumns, q.tableName,
+		),
+		crashes, message, failure.Stack, q.now(), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE message_id = ?", failuresTableName(q.tableName)), id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", q.tableName), id); err != nil {
+		return err
+	}
+	q.count(tx, 0, -1)
+
+	return nil
+}
+
+// Quarantined returns the messages in the quarantine table, most recently quarantined first
+func (q *Queue) Quarantined(page Page) ([]QuarantinedMessage, error) {
+	if q.quarantine == nil {
+		return nil, nil
+	}
+
+	rows, err := q.client.Query(fmt.Sprintf(
+		"SELECT id, %s, attempts, priority, failures, error, stack, created_at, quarantined_at FROM %s ORDER BY quarantined_at DESC, id DESC%s",
+		q.dataColumn(), quarantineTableName(q.tableName), page.sql(),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quarantined messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []QuarantinedMessage
+	for rows.Next() {
+		var m QuarantinedMessage
+		var data any
+		var message, stack sql.NullString
+		var createdAt sql.NullTime
+		if err := rows.Scan(&m.ID, &data, &m.Attempts, &m.Priority, &m.Failures, &message, &stack, &createdAt, &m.QuarantinedAt); err != nil {
+			return nil, fmt.Errorf("failed to list quarantined messages: %w", err)
+		}
+		switch v := data.(type) {
+		case []byte:
+			m.Data = q.Redact(v)
+		case string:
+			m.Data = q.Redact([]byte(v))
+		}
+		m.Error = message.String
+		m.Stack = stack.String
+		m.CreatedAt = createdAt.Time
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// ReleaseQuarantined moves the quarantined message with the given id back into the queue as
+// pending, with its attempt counter reset, e.g. after the handler was fixed
+// Returns ErrAckNotFound when no quarantined message has the id
+func (q *Queue) ReleaseQuarantined(id int64) error {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return nil
+		}
+		return err
+	}
+	if q.quarantine == nil {
+		return ErrAckNotFound
+	}
+
+	columns := "id, data, status, attempts, checksum, signature, created_at, updated_at, " + typedColumns
+	values := "id, data, 'pending', 0, checksum, signature, CAST(? AS TIMESTAMP), CAST(? AS TIMESTAMP), " + typedColumns
+	if q.hasPriority {
+		columns += ", priority"
+		values += ", priority"
+	}
+
+	err := q.retryReinsert(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			now := q.now()
+			result, err := tx.Exec(
+				fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE id = ?", q.tableName, columns, values, quarantineTableName(q.tableName)),
+				now, now, id,
+			)
+			if err != nil {
+				return err
+			}
+			if err := requireRows(result); err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", quarantineTableName(q.tableName)), id); err != nil {
+				return err
+			}
+			q.count(tx, 1, 0)
+
+			return nil
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrAckNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to release quarantined message: %w", err)
+	}
+
+	return nil
+}
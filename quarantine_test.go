@@ -0,0 +1,188 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestQuarantine(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_quarantine.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	policy := QuarantinePolicy{MaxFailures: 3, Window: time.Minute, FastFail: 100 * time.Millisecond}
+
+	t.Run("FastFailures", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+		q, err := queues.NewQueue("test_fast_failures", WithQuarantine(policy), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("poison"))
+
+		fail := func() bool {
+			_, _, ackID := q.DequeueWithAckId()
+			quarantined, err := q.RecordFailure(ackID, HandlerFailure{Err: errors.New("bad input"), Duration: time.Millisecond})
+			if err != nil {
+				t.Fatalf("RecordFailure failed: %v", err)
+			}
+			clock.Advance(time.Second)
+			return quarantined
+		}
+
+		if fail() || fail() {
+			t.Fatal("Expected the first failures to be nacked")
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the item to be pending again, got length %d", q.Len())
+		}
+		if !fail() {
+			t.Fatal("Expected the third failure to quarantine the item")
+		}
+		if q.Len() != 0 {
+			t.Errorf("Expected the queue to be empty, got length %d", q.Len())
+		}
+
+		quarantined, err := q.Quarantined(Page{})
+		if err != nil {
+			t.Fatalf("Quarantined failed: %v", err)
+		}
+		if len(quarantined) != 1 || string(quarantined[0].Data) != "poison" || quarantined[0].Failures != 3 || quarantined[0].Error != "bad input" {
+			t.Fatalf("Unexpected quarantined messages: %+v", quarantined)
+		}
+
+		if err := q.ReleaseQuarantined(quarantined[0].ID); err != nil {
+			t.Fatalf("ReleaseQuarantined failed: %v", err)
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the released item to be pending, got length %d", q.Len())
+		}
+		if err := q.ReleaseQuarantined(quarantined[0].ID); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound, got %v", err)
+		}
+
+		// The released item starts over with no recorded crashes
+		if fail() {
+			t.Error("Expected the released item to be nacked")
+		}
+	})
+
+	t.Run("OrdinaryFailuresAndWindow", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+		q, err := queues.NewQueue("test_slow_failures", WithQuarantine(policy), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("flaky"))
+
+		for i := 0; i < 5; i++ {
+			_, _, ackID := q.DequeueWithAckId()
+			failure := HandlerFailure{Err: errors.New("timeout"), Duration: time.Second}
+			if i%2 == 1 {
+				// Crashes spread out wider than the window never add up
+				failure = HandlerFailure{Err: ErrHandlerPanic, Panicked: true}
+				clock.Advance(time.Minute)
+			}
+			if quarantined, err := q.RecordFailure(ackID, failure); err != nil || quarantined {
+				t.Fatalf("Expected failure %d to be nacked, got %v, %v", i, quarantined, err)
+			}
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the item to be pending, got length %d", q.Len())
+		}
+
+		if _, err := q.RecordFailure("unknown", HandlerFailure{Panicked: true}); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound, got %v", err)
+		}
+	})
+
+	t.Run("RunWithRecover", func(t *testing.T) {
+		q, err := queues.NewQueue("test_run_quarantine", WithQuarantine(QuarantinePolicy{MaxFailures: 2, Window: time.Minute}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("poison"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var calls atomic.Int32
+		handler := func(ctx context.Context, item any) error {
+			if calls.Add(1) == 2 {
+				defer cancel()
+			}
+			panic("boom")
+		}
+
+		if err := Run(ctx, q, handler, WithPollInterval(time.Millisecond), WithSignals(), WithMiddleware(Recover())); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		quarantined, err := q.Quarantined(Page{})
+		if err != nil {
+			t.Fatalf("Quarantined failed: %v", err)
+		}
+		if len(quarantined) != 1 {
+			t.Fatalf("Expected 1 quarantined message, got %d", len(quarantined))
+		}
+		if !strings.Contains(quarantined[0].Error, "boom") || !strings.Contains(quarantined[0].Stack, "goroutine") {
+			t.Errorf("Expected the panic and its stack to be captured, got %+v", quarantined[0])
+		}
+	})
+
+	t.Run("ReleaseWhileReading", func(t *testing.T) {
+		q, err := queues.NewQueue("test_release_reading", WithQuarantine(QuarantinePolicy{MaxFailures: 1, Window: time.Minute}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		// Transactions started before the quarantining delete make DuckDB report the released ID as a duplicate for a while
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				tx, err := queues.DB().Begin()
+				if err != nil {
+					continue
+				}
+				tx.QueryRow("SELECT COUNT(*) FROM test_release_reading").Scan(new(int))
+				time.Sleep(time.Millisecond)
+				tx.Commit()
+			}
+		}()
+
+		for i := 0; i < 20; i++ {
+			id, _ := q.EnqueueID([]byte("crash"))
+			_, _, ackID := q.DequeueWithAckId()
+			if _, err := q.RecordFailure(ackID, HandlerFailure{Err: errors.New("boom"), Panicked: true}); err != nil {
+				t.Fatalf("RecordFailure failed: %v", err)
+			}
+			if err := q.ReleaseQuarantined(id); err != nil {
+				t.Fatalf("ReleaseQuarantined failed: %v", err)
+			}
+			q.Dequeue()
+		}
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		_, err := queues.NewQueue("test_invalid_quarantine", WithQuarantine(QuarantinePolicy{MaxFailures: 0, Window: time.Minute}))
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
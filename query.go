@@ -0,0 +1,225 @@
+package duckq
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"unicode"
+
+	duckdb "github.com/marcboeker/go-duckdb/v2"
+)
+
+// TableName returns the name of the table holding the queue's items, for use in Query
+func (q *Queue) TableName() string {
+	return q.tableName
+}
+
+// deniedQueryFunctions are the functions Query rejects because they change sequences or read settings
+var deniedQueryFunctions = map[string]bool{
+	"nextval":         true,
+	"currval":         true,
+	"setval":          true,
+	"current_setting": true,
+	"getenv":          true,
+}
+
+// allowedTableRefs are the table references Query accepts, the others read files, catalogs or settings
+var allowedTableRefs = map[string]bool{
+	"BASE_TABLE":      true,
+	"SUBQUERY":        true,
+	"JOIN":            true,
+	"EMPTY":           true,
+	"EXPRESSION_LIST": true,
+}
+
+// Query runs a read-only SQL query against the queue's tables, e.g. analytics over TableName
+// The query must be a single SELECT statement that only reads the queue table and the tables and
+// views named after it, like the dead-letter table or the analytics views, and calls no table
+// functions, sequence functions or settings; anything else is rejected with ErrReadOnlyQuery
+// before it runs. The caller must close the returned rows
+func (q *Queue) Query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := q.checkReadOnly(ctx, query); err != nil {
+		return nil, err
+	}
+	if err := q.checkQuerySources(ctx, query); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query queue: %w", err)
+	}
+
+	return rows, nil
+}
+
+// checkReadOnly prepares query without running it and returns ErrReadOnlyQuery
+// unless it is a single SELECT statement
+func (q *Queue) checkReadOnly(ctx context.Context, query string) error {
+	conn, err := q.client.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query queue: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		// DuckDB refuses to prepare more than one statement at a time
+		stmt, err := driverConn.(driver.Conn).Prepare(query)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrReadOnlyQuery, err)
+		}
+		defer stmt.Close()
+
+		duckStmt, ok := stmt.(*duckdb.Stmt)
+		if !ok {
+			return fmt.Errorf("%w: cannot inspect the statement", ErrReadOnlyQuery)
+		}
+
+		stmtType, err := duckStmt.StatementType()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrReadOnlyQuery, err)
+		}
+		if stmtType != duckdb.STATEMENT_TYPE_SELECT {
+			return fmt.Errorf("%w: only SELECT statements are allowed", ErrReadOnlyQuery)
+		}
+
+		return nil
+	})
+}
+
+// checkQuerySources parses query without running it and returns ErrReadOnlyQuery unless it only
+// reads the queue's tables, see Query
+func (q *Queue) checkQuerySources(ctx context.Context, query string) error {
+	var serialized string
+	err := q.client.QueryRowContext(ctx, "SELECT json_serialize_sql(?::VARCHAR)::VARCHAR", query).Scan(&serialized)
+	if err != nil {
+		return fmt.Errorf("failed to query queue: %w", err)
+	}
+
+	var tree struct {
+		Error        bool   `json:"error"`
+		ErrorMessage string `json:"error_message"`
+		Statements   []any  `json:"statements"`
+	}
+	if err := json.Unmarshal([]byte(serialized), &tree); err != nil {
+		return fmt.Errorf("%w: cannot inspect the statement: %w", ErrReadOnlyQuery, err)
+	}
+	if tree.Error {
+		return fmt.Errorf("%w: cannot inspect the statement: %s", ErrReadOnlyQuery, tree.ErrorMessage)
+	}
+
+	return q.checkQueryNode(tree.Statements, nil)
+}
+
+// checkQueryNode checks every table reference and function call in a node of the parsed query
+// ctes holds the names of the common table expressions in scope, which may be referenced like tables
+func (q *Queue) checkQueryNode(node any, ctes map[string]bool) error {
+	switch node := node.(type) {
+	case []any:
+		for _, child := range node {
+			if err := q.checkQueryNode(child, ctes); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case map[string]any:
+		if cteMap, ok := node["cte_map"].(map[string]any); ok {
+			entries, _ := cteMap["map"].([]any)
+			if len(entries) > 0 {
+				var err error
+				if ctes, err = q.checkQueryCTEs(entries, ctes); err != nil {
+					return err
+				}
+			}
+		}
+		if err := q.checkQuerySource(node, ctes); err != nil {
+			return err
+		}
+
+		for key, child := range node {
+			if key == "cte_map" {
+				continue
+			}
+			if err := q.checkQueryNode(child, ctes); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// checkQueryCTEs checks the common table expressions of a WITH clause in order, each seeing itself
+// and the ones before it, and returns the names in scope of the query they belong to
+func (q *Queue) checkQueryCTEs(entries []any, outer map[string]bool) (map[string]bool, error) {
+	scope := maps.Clone(outer)
+	if scope == nil {
+		scope = make(map[string]bool, len(entries))
+	}
+
+	for _, entry := range entries {
+		entry, _ := entry.(map[string]any)
+		name, _ := entry["key"].(string)
+		scope[strings.ToLower(name)] = true
+
+		if err := q.checkQueryNode(entry["value"], scope); err != nil {
+			return nil, err
+		}
+	}
+
+	return scope, nil
+}
+
+// checkQuerySource rejects a table reference or function call of the parsed query that Query doesn't allow
+func (q *Queue) checkQuerySource(node map[string]any, ctes map[string]bool) error {
+	if class, _ := node["class"].(string); class == "FUNCTION" {
+		name, _ := node["function_name"].(string)
+		if deniedQueryFunctions[strings.ToLower(name)] {
+			return fmt.Errorf("%w: function %s is not allowed", ErrReadOnlyQuery, name)
+		}
+		return nil
+	}
+
+	// Table references are the only nodes with a type and an alias but no expression class
+	refType, isRef := node["type"].(string)
+	if _, hasAlias := node["alias"]; !isRef || !hasAlias || node["class"] != nil {
+		return nil
+	}
+	if !allowedTableRefs[refType] {
+		return fmt.Errorf("%w: %s references are not allowed", ErrReadOnlyQuery, strings.ToLower(refType))
+	}
+	if refType != "BASE_TABLE" {
+		return nil
+	}
+
+	catalog, _ := node["catalog_name"].(string)
+	schema, _ := node["schema_name"].(string)
+	name, _ := node["table_name"].(string)
+	if catalog == "" && schema == "" && ctes[strings.ToLower(name)] {
+		return nil
+	}
+	if catalog != "" || (schema != "" && !strings.EqualFold(schema, "main")) || !q.isQueueTable(name) {
+		return fmt.Errorf("%w: table %s is not one of the queue's tables", ErrReadOnlyQuery, name)
+	}
+
+	return nil
+}
+
+// isQueueTable reports whether name is the queue table or a table or view named after it
+// Names that are not plain identifiers are rejected, since DuckDB reads them as files
+func (q *Queue) isQueueTable(name string) bool {
+	if strings.IndexFunc(name, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+		return false
+	}
+
+	name = strings.ToLower(name)
+	table := strings.ToLower(q.tableName)
+	return name == table || strings.HasPrefix(name, table+"_")
+}
@@ -0,0 +1,114 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestQuery(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_query.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("a"))
+	q.Enqueue([]byte("b"))
+	q.DequeueWithAckId()
+
+	t.Run("Select", func(t *testing.T) {
+		rows, err := q.Query(context.Background(),
+			fmt.Sprintf("WITH counts AS (SELECT status, COUNT(*) AS n FROM %s GROUP BY status) SELECT status, n FROM counts WHERE n > ? ORDER BY status", q.TableName()),
+			0,
+		)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		defer rows.Close()
+
+		counts := make(map[string]int)
+		for rows.Next() {
+			var status string
+			var n int
+			if err := rows.Scan(&status, &n); err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			counts[status] = n
+		}
+		if counts["pending"] != 1 || counts["processing"] != 1 {
+			t.Errorf("Unexpected counts %v", counts)
+		}
+	})
+
+	t.Run("RejectsWrites", func(t *testing.T) {
+		for _, query := range []string{
+			"DELETE FROM " + q.TableName(),
+			"UPDATE " + q.TableName() + " SET status = 'completed'",
+			"DELETE FROM " + q.TableName() + "; SELECT 1",
+			"DROP TABLE " + q.TableName(),
+		} {
+			if _, err := q.Query(context.Background(), query); !errors.Is(err, ErrReadOnlyQuery) {
+				t.Errorf("Expected ErrReadOnlyQuery for %q, got %v", query, err)
+			}
+		}
+
+		if q.Len() != 1 {
+			t.Errorf("Expected the rejected queries not to run, got length %d", q.Len())
+		}
+	})
+
+	t.Run("QueueTablesOnly", func(t *testing.T) {
+		if err := q.EnableAnalytics(); err != nil {
+			t.Fatalf("EnableAnalytics failed: %v", err)
+		}
+		for _, query := range []string{
+			"SELECT COUNT(*) FROM test_queue_dead_letters",
+			"SELECT * FROM main.test_queue_attempts",
+			"SELECT * FROM (WITH recent AS (SELECT id FROM test_queue) SELECT * FROM recent) AS r JOIN test_queue USING (id)",
+			"SELECT 1 FROM (VALUES (1)) AS v(x)",
+		} {
+			rows, err := q.Query(context.Background(), query)
+			if err != nil {
+				t.Errorf("Expected %q to be allowed, got %v", query, err)
+				continue
+			}
+			rows.Close()
+		}
+
+		var before int64
+		queues.DB().QueryRow("SELECT currval('test_queue_id_seq')").Scan(&before)
+		for _, query := range []string{
+			"SELECT nextval('test_queue_id_seq')",
+			"SELECT id FROM test_queue WHERE id < (SELECT nextval('test_queue_id_seq'))",
+			"SELECT * FROM read_text('/etc/hostname')",
+			"SELECT * FROM '/etc/hostname'",
+			"SELECT * FROM duckdb_tables()",
+			"SELECT * FROM information_schema.tables",
+			"SELECT * FROM other_table",
+			"SELECT * FROM test_queue, (WITH other_table AS (SELECT 1) SELECT 1) AS s, other_table",
+			"WITH a AS (SELECT * FROM b), b AS (SELECT 1) SELECT * FROM a",
+			"SELECT current_setting('home_directory')",
+		} {
+			if _, err := q.Query(context.Background(), query); !errors.Is(err, ErrReadOnlyQuery) {
+				t.Errorf("Expected ErrReadOnlyQuery for %q, got %v", query, err)
+			}
+		}
+
+		var after int64
+		queues.DB().QueryRow("SELECT currval('test_queue_id_seq')").Scan(&after)
+		if after != before {
+			t.Errorf("Expected the rejected queries not to advance the sequence, got %d after %d", after, before)
+		}
+	})
+}
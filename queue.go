@@ -2,7 +2,11 @@ package duckq
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -12,194 +16,707 @@ import (
 
 // Queue implements the Queue interface using DuckDB as the storage backend
 type Queue struct {
-	client           *sql.DB
-	tableName        string
-	removeOnComplete bool
-	closed           atomic.Bool
+	client            *sql.DB
+	tableName         string
+	removeOnComplete  bool
+	hasPriority       bool
+	delayed           bool
+	ackTimeout        time.Duration
+	consumerID        string
+	protoTypeURL      bool
+	registry          *TypeRegistry
+	maxPayloadSize    int
+	retryPolicy       RetryPolicy
+	checksum          bool
+	closeBehavior     CloseBehavior
+	closeBlockTimeout time.Duration
+	closeMu           sync.Mutex
+	reopened          chan struct{}
+	recoveryHandler   func(RecoveryReport)
+	corruptionHandler func(id int64, err error)
+	priorityLevels    []string
+	priorityBoost     int
+	defaultPriority   *int
+	priorityRange     *[2]int
+	maxAttempts       int
+	deadLetterName    string
+	codec             Codec
+	clock             Clock
+	jsonPayload       bool
+	structSchema      *StructSchema
+	tenant            string
+	enqueueLimiter    *rateLimiter
+	processingTimeout bool
+	processingAction  TimeoutAction
+	pendingTTL        time.Duration
+	pendingAction     TimeoutAction
+	rateLimitBehavior RateLimitBehavior
+	quarantine        *QuarantinePolicy
+	retention         time.Duration
+	hmacKey           []byte
+	redactor          Redactor
+	quotaFor          func(tenantID string) TenantQuota
+	meterFor          func(tenantID string) *tenantMeter
+	wakeMaintenance   func()
+	ackStages         []ackStage
+	ackInterval       time.Duration
+	acks              *ackBuffer
+	lifecycleMu       sync.Mutex
+	stop              chan struct{}
+	tasks             []func(stop <-chan struct{})
+	background        sync.WaitGroup
+	asyncEnqueues     sync.WaitGroup
+	closed            atomic.Bool
+	deadlines         atomic.Bool
+	approxPending     atomic.Int64
+	approxProcessing  atomic.Int64
+	txCounts          sync.Map
+	mirrorMu          sync.Mutex
+	mirrors           atomic.Pointer[[]string]
+	offload           *payloadOffload
+	batcher           *microBatcher
+	deliveryWindows   []DeliveryWindow
+	pressure          backpressure
+	maxInFlight       int
+	maxProcessing     int
+	wakeup            *wakeup
+}
+
+// defaultQueue returns a queue with the settings used when no option overrides them
+func defaultQueue(db *sql.DB, tableName string) *Queue {
+	return &Queue{
+		client:            db,
+		tableName:         tableName,
+		removeOnComplete:  true, // Default to removing completed items
+		consumerID:        defaultConsumerID(),
+		retryPolicy:       DefaultRetryPolicy,
+		closeBlockTimeout: defaultCloseBlockTimeout,
+		clock:             systemClock{},
+	}
 }
 
 // newQueue creates a new DuckDB-based queue
 func newQueue(db *sql.DB, tableName string, opts ...Option) (*Queue, error) {
-	q := &Queue{
-		client:           db,
-		tableName:        tableName,
-		removeOnComplete: true, // Default to removing completed items
+	q, err := prepareQueue(db, tableName, false, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.inTx(q.createSchema); err != nil {
+		return nil, err
+	}
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// prepareQueue returns a queue on tableName with opts applied, rejecting invalid ones before touching the database
+func prepareQueue(db *sql.DB, tableName string, hasPriority bool, opts []Option) (*Queue, error) {
+	q := defaultQueue(db, tableName)
+	q.hasPriority = hasPriority
+	if err := q.apply(opts); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// createSchema registers the queue with its tenant and creates or upgrades its tables as part of tx,
+// every constructor goes through it so regular, priority and delayed queues get the same tables
+func (q *Queue) createSchema(tx *sql.Tx) error {
+	if err := q.registerTenant(tx); err != nil {
+		return err
 	}
 
-	// Apply any provided options
-	for _, opt := range opts {
-		opt(q)
+	createTable := createQueueTable
+	if q.hasPriority {
+		createTable = createPriorityTable
+	}
+	if err := createTable(tx, q.tableName, q.payloadColumnType()); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if err := q.checkPayloadColumn(tx); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if err := q.addStructColumns(tx); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if err := createDeadLetterTable(tx, q.deadLetterTable(), q.payloadColumnType(), q.tableName); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if err := q.initQuarantine(tx); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if err := q.initWakeupSignals(tx); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if q.delayed {
+		if err := q.initScheduleKeys(tx); err != nil {
+			return fmt.Errorf("failed to initialize table: %w", err)
+		}
 	}
 
-	if err := q.initTable(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to initialize table: %w", err)
+	return nil
+}
+
+// open starts using a queue whose schema exists, see createSchema: it loads the queue's shared
+// state, recovers the items left behind by a crash and starts the background work of its options
+func (q *Queue) open() error {
+	if err := q.loadMirrors(); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
+	}
+	if err := q.loadWakeupSignals(); err != nil {
+		return fmt.Errorf("failed to initialize table: %w", err)
 	}
 
-	q.RequeueNoAckRows()
+	q.recover()
+	q.startAckCoalescing()
+	q.startMicroBatching()
+	q.startWakeupWatcher()
 
-	return q, nil
+	return nil
 }
 
-// initTable initializes the queue table if it doesn't exist
-func (q *Queue) initTable() error {
+// Payload column types, BLOB unless the queue is created with WithJSONPayload
+const (
+	blobPayloadColumn = "BLOB"
+	jsonPayloadColumn = "JSON"
+)
+
+// queueColumnsSQL defines the columns shared by regular and priority queue tables, after the id and data columns
+const queueColumnsSQL = `status TEXT NOT NULL,
+		ack_id TEXT UNIQUE,
+		ack BOOLEAN DEFAULT 0,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		consumer_id TEXT,
+		lease_expires_at TIMESTAMP,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		` + typedColumnsSQL + `,
+		checksum BIGINT,
+		visible_at TIMESTAMP,
+		schedule_key TEXT,
+		signature BLOB,
+		message_key TEXT,
+		deadline TIMESTAMP,
+		cancel_token TEXT,
+		progress INTEGER NOT NULL DEFAULT 0,
+		checkpoint BLOB,
+		message_tags VARCHAR[]`
+
+// typedColumnsSQL defines the columns that tell how a payload is decoded, such as its registered type,
+// the attributes of a CloudEvent or the blob store key it was offloaded to, which dead-letter and
+// quarantine tables keep alongside the data
+const typedColumnsSQL = `ce_id TEXT,
+		ce_source TEXT,
+		ce_type TEXT,
+		ce_subject TEXT,
+		ce_time TIMESTAMP,
+		ce_spec_version TEXT,
+		ce_data_content_type TEXT,
+		ce_data_schema TEXT,
+		ce_extensions TEXT,
+		payload_type TEXT,
+		offload_key TEXT`
+
+// typedColumns lists the columns of typedColumnsSQL, to copy them between tables
+const typedColumns = "ce_id, ce_source, ce_type, ce_subject, ce_time, ce_spec_version, ce_data_content_type, ce_data_schema, ce_extensions, payload_type, offload_key"
+
+// payloadColumnType returns the type of the data column the queue stores payloads in
+func (q *Queue) payloadColumnType() string {
+	if q.jsonPayload {
+		return jsonPayloadColumn
+	}
+
+	return blobPayloadColumn
+}
+
+// dataColumn returns the select expression for the data column
+// The driver decodes JSON values into Go maps, so JSON payloads are read back as text
+func (q *Queue) dataColumn() string {
+	if q.jsonPayload {
+		return "CAST(data AS VARCHAR)"
+	}
+
+	return "data"
+}
+
+// checkPayloadColumn returns ErrInvalidOption when an existing queue table stores
+// payloads in a different column type than the queue was opened with
+func (q *Queue) checkPayloadColumn(db querier) error {
+	columnType, err := tableColumnType(db, q.tableName, "data")
+	if err != nil {
+		return err
+	}
+	if columnType != q.payloadColumnType() {
+		return fmt.Errorf("%w: queue %s stores %s payloads, not %s", ErrInvalidOption, q.tableName, columnType, q.payloadColumnType())
+	}
+
+	return nil
+}
+
+// createQueueTable creates a table for a regular queue with the given payload column type
+// Tables created by older versions get the columns added since then
+func createQueueTable(db execQuerier, tableName, dataType string) error {
 	// First create a sequence for auto-incrementing IDs if it doesn't exist
-	seqName := fmt.Sprintf("%s_id_seq", q.tableName)
+	seqName := fmt.Sprintf("%s_id_seq", tableName)
 	createSeqSQL := fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s START 1;", seqName)
-	_, err := q.client.Exec(createSeqSQL)
+	_, err := db.Exec(createSeqSQL)
 	if err != nil {
 		return err
 	}
+	if err := upgradeTable(db, tableName, queueColumnsSQL); err != nil {
+		return err
+	}
 
 	// Then create the table with the sequence as the default value for id
 	createTableSQL := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS %s (
 		id INTEGER PRIMARY KEY DEFAULT nextval('%s'),
-		data BLOB NOT NULL,
-		status TEXT NOT NULL,
-		ack_id TEXT UNIQUE,
-		ack BOOLEAN DEFAULT 0,
-		created_at TIMESTAMP,
-		updated_at TIMESTAMP
+		data %s NOT NULL,
+		%s
 	);
 	CREATE INDEX IF NOT EXISTS %s_status_idx ON %s (status, created_at);
 	CREATE INDEX IF NOT EXISTS %s_status_ack_idx ON %s (status, ack);
 	CREATE INDEX IF NOT EXISTS %s_ack_id_idx ON %s (ack_id);
-	`, q.tableName, seqName, q.tableName, q.tableName, q.tableName, q.tableName, q.tableName, q.tableName)
+	`, tableName, seqName, dataType, queueColumnsSQL, tableName, tableName, tableName, tableName, tableName, tableName)
 
-	_, err = q.client.Exec(createTableSQL)
-	return err
-}
+	_, err = db.Exec(createTableSQL)
+	if err != nil {
+		return err
+	}
 
-func (q *Queue) RequeueNoAckRows() {
-	tx, err := q.client.Begin()
+	if err := createAckLogTable(db, tableName); err != nil {
+		return err
+	}
+	if err := createRequeuesTable(db, tableName); err != nil {
+		return err
+	}
+	if err := createDependenciesTable(db, tableName); err != nil {
+		return err
+	}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	return createProcessedTable(db, tableName)
+}
 
-	_, err = tx.Exec(
-		fmt.Sprintf("UPDATE %s SET status = 'pending', updated_at = ? WHERE  status = 'processing' AND ack = 0", q.tableName),
-		time.Now().UTC(),
-	)
+// RequeueNoAckRows returns every processing item that was never acknowledged to pending
+// It runs when a queue is opened, so items leased by a consumer that crashed are delivered again
+// Items that used up the attempts allowed by WithMaxAttempts are dead-lettered instead and left out of the report
+// Returns a report of the recovered items
+func (q *Queue) RequeueNoAckRows() RecoveryReport {
+	report := RecoveryReport{RecoveredAt: q.now()}
+
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			if _, err := q.deadLetterExhausted(tx, "status = 'processing' AND ack = 0"); err != nil {
+				return err
+			}
+
+			rows, err := tx.Query(fmt.Sprintf(
+				"SELECT %s FROM %s WHERE status = 'processing' AND ack = 0 ORDER BY id ASC",
+				q.messageColumns(), q.tableName,
+			))
+			if err != nil {
+				return err
+			}
+
+			report.Messages, err = scanMessages(rows)
+			if err != nil {
+				return err
+			}
+			report.Messages = q.redactMessages(report.Messages)
+
+			result, err := tx.Exec(
+				fmt.Sprintf("UPDATE %s SET status = 'pending', updated_at = ?%s WHERE  status = 'processing' AND ack = 0", q.tableName, q.retryPrioritySQL()),
+				report.RecoveredAt,
+			)
+			if err != nil {
+				return err
+			}
+			recovered, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			return q.countRequeues(tx, RequeueCrashRecovery, recovered)
+		})
+	})
+	if err != nil {
+		return RecoveryReport{RecoveredAt: report.RecoveredAt}
+	}
 
-	tx.Commit()
+	return report
+}
+
+// recover requeues the items left behind by a crash and reports them to the recovery handler
+func (q *Queue) recover() {
+	report := q.RequeueNoAckRows()
+	if q.recoveryHandler != nil && report.Recovered() > 0 {
+		q.recoveryHandler(report)
+	}
+	q.detectDeadlines()
+	q.reconcileCounts()
 }
 
 // Enqueue adds an item to the queue
 // It serializes the item to JSON and stores it in the database
 // Returns true if the operation was successful
 func (q *Queue) Enqueue(item any) bool {
-	if q.closed.Load() {
-		return false
+	if err := q.checkOpen(); err != nil {
+		return err == errDropped
+	}
+	if q.batcher != nil {
+		if batched, err := q.batchMessage(item); batched {
+			return err == nil
+		}
 	}
 
-	now := time.Now().UTC()
-	tx, err := q.client.Begin()
+	err := q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			_, err := q.enqueueInTx(tx, item, sql.NullTime{})
+			return err
+		})
+	})
+	return err == nil
+}
+
+// EnqueueInTx adds an item to the queue as part of the caller's transaction
+// The item only becomes visible to consumers once tx commits, so applications sharing
+// the queues' database (see Queues.DB) can enqueue atomically with their own writes
+// With RateLimitThrottle, an enqueue over the rate limit waits with tx open
+func (q *Queue) EnqueueInTx(tx *sql.Tx, item any) error {
+	if err := q.checkEnqueueRate(1); err != nil {
+		return err
+	}
+
+	_, err := q.enqueueInTx(tx, item, sql.NullTime{})
+	return err
+}
+
+// EnqueueID adds an item to the queue and returns its generated ID
+// The ID can be stored alongside the producer's own records and later passed to Get or Delete
+// A queue closed with CloseDrop drops the item and returns 0
+func (q *Queue) EnqueueID(item any) (int64, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var id int64
+	err := q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			var err error
+			id, err = q.enqueueInTx(tx, item, sql.NullTime{})
+			return err
+		})
+	})
 	if err != nil {
-		return false
+		return 0, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+
+	return id, nil
+}
+
+// enqueueInTx inserts an item with an optional visibility time as part of tx
+// Returns the ID of the new row
+func (q *Queue) enqueueInTx(tx *sql.Tx, item any, visibleAt sql.NullTime) (int64, error) {
+	return q.enqueueColumnsInTx(tx, item, visibleAt, nil)
+}
+
+// columnValue is a column value written alongside an enqueued item
+type columnValue struct {
+	name  string
+	value any
+}
+
+// enqueueColumnsInTx inserts an item like enqueueInTx, also writing the given columns
+// A column the insert writes anyway, such as payload_type or priority, takes the given value instead
+func (q *Queue) enqueueColumnsInTx(tx *sql.Tx, item any, visibleAt sql.NullTime, extra []columnValue) (int64, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return 0, nil
 		}
-	}()
+		return 0, err
+	}
 
-	_, err = tx.Exec(
-		fmt.Sprintf("INSERT INTO %s (data, status, ack, created_at, updated_at) VALUES (?, ?, ?, ?, ?)", q.tableName),
-		item, "pending", 0, now, now,
-	)
+	data, payloadType, err := q.encode(item)
 	if err != nil {
-		return false
+		return 0, err
+	}
+	if err := q.checkPayloadSize(data); err != nil {
+		return 0, err
+	}
+	// The messages of a micro-batch were already checked one by one when they were buffered
+	if _, batched := item.(microBatch); !batched {
+		if err := q.checkTenantQuota(tx, data); err != nil {
+			return 0, err
+		}
+	}
+	data, offloadKey, err := q.offloadPayload(data)
+	if err != nil {
+		return 0, err
 	}
 
-	err = tx.Commit()
-	return err == nil
+	var id int64
+	now := q.now()
+	columns := []string{"data", "status", "ack", "created_at", "updated_at", "payload_type", "offload_key", "checksum", "signature", "visible_at"}
+	args := []any{data, "pending", 0, now, now, payloadType, offloadKey, q.payloadChecksum(data), q.payloadSignature(data), visibleAt}
+	if q.defaultPriority != nil {
+		extra = append([]columnValue{{"priority", *q.defaultPriority}}, extra...)
+	}
+	for _, column := range extra {
+		if i := slices.Index(columns, column.name); i >= 0 {
+			args[i] = column.value
+			continue
+		}
+		columns = append(columns, column.name)
+		args = append(args, column.value)
+	}
+	err = tx.QueryRow(
+		fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id", q.tableName, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")),
+		args...,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue item: %w", err)
+	}
+	if err := q.writeStructColumns(tx, item, "id = ?", id); err != nil {
+		return 0, err
+	}
+	if err := q.mirrorInTx(tx, "id = ?", id); err != nil {
+		return 0, err
+	}
+	q.count(tx, 1, 0)
+
+	return id, nil
+}
+
+// checkPayloadSize returns ErrPayloadTooLarge when a []byte or string payload exceeds the max payload size
+func (q *Queue) checkPayloadSize(data any) error {
+	if q.maxPayloadSize <= 0 {
+		return nil
+	}
+
+	var size int
+	switch v := data.(type) {
+	case []byte:
+		size = len(v)
+	case string:
+		size = len(v)
+	default:
+		return nil
+	}
+
+	if size > q.maxPayloadSize {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrPayloadTooLarge, size, q.maxPayloadSize)
+	}
+
+	return nil
 }
 
 // dequeueInternal is a helper function for both Dequeue and DequeueWithAckId
 // It handles the common operations of finding and retrieving an item from the queue
 // If withAckId is true, it will generate and store an ack ID
 func (q *Queue) dequeueInternal(withAckId bool) (item any, success bool, ackID string) {
-	if q.closed.Load() {
+	row, err := q.dequeueWhere(withAckId, "")
+	if err != nil {
 		return nil, false, ""
 	}
 
-	tx, err := q.client.Begin()
-	if err != nil {
-		return nil, false, ""
+	return q.decode(row.data, row.payloadType), true, row.ackID
+}
+
+// dequeuedRow holds the columns of an item returned by dequeueWhere
+type dequeuedRow struct {
+	id          int64
+	data        []byte
+	ackID       string
+	payloadType string
+}
+
+// dequeueWhere finds the next pending item that also matches condition and dequeues it
+// An empty condition matches every pending item
+// Returns ErrQueueClosed after Close and ErrEmptyQueue when no pending item matches
+// Conflicts with concurrent consumers are retried with the queue's RetryPolicy, and the conflict
+// is returned rather than ErrEmptyQueue once every attempt conflicted
+// Items failing their checksum are moved to the dead-letter table and skipped
+func (q *Queue) dequeueWhere(withAckId bool, condition string, args ...any) (dequeuedRow, error) {
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return dequeuedRow{}, ErrEmptyQueue
+		}
+		return dequeuedRow{}, err
 	}
-	defer func() {
+
+	mu := q.dequeueLock()
+	mu.Lock()
+	defer mu.Unlock()
+
+	var row dequeuedRow
+	dequeue := func() error {
+		var dequeueErr error
+		err := q.inTx(func(tx *sql.Tx) error {
+			row, dequeueErr = q.dequeueVerifiedInTx(tx, withAckId, condition, args...)
+			if errors.Is(dequeueErr, ErrEmptyQueue) {
+				// Commit the corrupted items dead-lettered before the queue ran out
+				return nil
+			}
+			return dequeueErr
+		})
 		if err != nil {
-			tx.Rollback()
+			return err
 		}
-	}()
+		return dequeueErr
+	}
 
-	// Get the oldest pending item
-	var id int64
-	var data []byte
+	// Another writer touching the same row conflicts, and a fresh attempt sees its result
+	if err := q.retry(dequeue); err != nil {
+		return dequeuedRow{}, err
+	}
+
+	return row, nil
+}
 
-	// Only dequeue pending items in FIFO order
+// dequeueVerifiedInTx dequeues the next pending item matching condition as part of tx, moving the
+// items failing their checksum or signature into the dead-letter table on the way
+// The corruption handler is called for each of them once tx commits
+func (q *Queue) dequeueVerifiedInTx(tx *sql.Tx, withAckId bool, condition string, args ...any) (dequeuedRow, error) {
+	for {
+		row, err := q.dequeueInTx(tx, withAckId, condition, args...)
+		if !errors.Is(err, ErrCorruptPayload) {
+			return row, err
+		}
+
+		// Quarantine the corrupted or tampered item so it doesn't block the items behind it
+		if _, err := q.deadLetterInTx(tx, err.Error(), "id = ?", row.id); err != nil {
+			return dequeuedRow{}, fmt.Errorf("failed to dead-letter corrupted item %d: %w", row.id, err)
+		}
+		if q.corruptionHandler != nil {
+			q.afterCommit(tx, func() {
+				q.corruptionHandler(row.id, err)
+			})
+		}
+	}
+}
+
+// dequeueInTx dequeues the next pending item matching condition as part of tx
+func (q *Queue) dequeueInTx(tx *sql.Tx, withAckId bool, condition string, args ...any) (dequeuedRow, error) {
+	filter := condition
+	if filter != "" {
+		filter = " AND " + filter
+	}
+
+	now := q.now()
+	if !q.inDeliveryWindow(now) {
+		return dequeuedRow{}, ErrEmptyQueue
+	}
+	if withAckId {
+		full, err := q.inFlightFull(tx, now)
+		if err != nil {
+			return dequeuedRow{}, err
+		}
+		if full {
+			return dequeuedRow{}, ErrEmptyQueue
+		}
+	}
+
+	// Only dequeue pending items that are visible, in FIFO order or priority order for priority queues
 	row := tx.QueryRow(fmt.Sprintf(
-		"SELECT id, data, ack_id FROM %s WHERE status = 'pending' ORDER BY created_at ASC LIMIT 1",
-		q.tableName,
-	))
+		"SELECT id, %s, ack_id, payload_type, offload_key, checksum, signature FROM %s WHERE status = 'pending' AND (visible_at IS NULL OR visible_at <= ?) AND (deadline IS NULL OR deadline >= ?) AND %s%s ORDER BY %s LIMIT 1",
+		q.dataColumn(), q.tableName, q.dependencyFreeSQL(), filter, q.dequeueOrder(),
+	), append([]any{now, now}, args...)...)
+
+	var id int64
+	var data []byte
 
 	// Use NullString to handle NULL values from database
-	var nullAckID sql.NullString
+	var nullAckID, payloadType, offloadKey sql.NullString
+	var checksum sql.NullInt64
+	var signature []byte
 
 	// Scan the row data
-	err = row.Scan(&id, &data, &nullAckID) // ackID may be NULL for pending items
+	err := row.Scan(&id, &data, &nullAckID, &payloadType, &offloadKey, &checksum, &signature) // ackID may be NULL for pending items
 
 	// Extract the string value if valid
-	if nullAckID.Valid {
-		ackID = nullAckID.String
-	}
+	ackID := nullAckID.String
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			tx.Rollback()
-			return nil, false, ""
+			return dequeuedRow{}, ErrEmptyQueue
 		}
-		tx.Rollback()
-		return nil, false, ""
+		return dequeuedRow{}, fmt.Errorf("failed to select next item: %w", err)
 	}
 
-	// Update the status to 'processing' or delete the item, based on withAckId
-	now := time.Now().UTC()
+	if err := verifyChecksum(data, checksum); err != nil {
+		return dequeuedRow{id: id}, err
+	}
+	if err := q.verifySignature(data, signature); err != nil {
+		return dequeuedRow{id: id}, err
+	}
+	if data, err = q.resolvePayload(data, offloadKey); err != nil {
+		return dequeuedRow{id: id}, err
+	}
+	if payloadType.String == microBatchPayloadType {
+		// Split the batch into its messages, then dequeue the first of them like any other row
+		if err := q.splitMicroBatchInTx(tx, id, data, offloadKey); err != nil {
+			return dequeuedRow{id: id}, err
+		}
+		return q.dequeueInTx(tx, withAckId, condition, args...)
+	}
 
+	// Update the status to 'processing' or delete the item, based on withAckId
 	if withAckId {
 		if ackID == "" {
 			ackID = cuid.New()
 		}
 
+		// Without an ack timeout the lease never expires
+		var leaseExpiresAt sql.NullTime
+		if q.ackTimeout > 0 {
+			leaseExpiresAt = sql.NullTime{Time: now.Add(q.ackTimeout), Valid: true}
+		}
+
 		// Update the item to processing status
 		_, err = tx.Exec(
-			fmt.Sprintf("UPDATE %s SET status = 'processing', ack_id = ?, updated_at = ? WHERE id = ?", q.tableName),
-			ackID, now, id,
+			fmt.Sprintf("UPDATE %s SET status = 'processing', ack_id = ?, attempts = attempts + 1, consumer_id = ?, lease_expires_at = ?, updated_at = ? WHERE id = ?", q.tableName),
+			ackID, q.consumerID, leaseExpiresAt, now, id,
 		)
 	} else {
-		// For regular Dequeue, just delete the item immediately
+		// For regular Dequeue, just delete the item immediately, which acknowledges it
 		_, err = tx.Exec(
 			fmt.Sprintf("DELETE FROM %s WHERE id = ?", q.tableName),
 			id,
 		)
+		if err == nil {
+			q.releasePayload(tx, offloadKey)
+			err = q.afterAckInTx(tx, id, data, payloadType.String, sql.NullString{})
+		}
 	}
 
 	if err != nil {
-		tx.Rollback()
-		return nil, false, ""
+		return dequeuedRow{}, fmt.Errorf("failed to dequeue item: %w", err)
+	}
+	if withAckId {
+		q.count(tx, -1, 1)
+	} else {
+		q.count(tx, -1, 0)
 	}
 
-	// Commit transaction
-	err = tx.Commit()
-	if err != nil {
-		return nil, false, ""
+	return dequeuedRow{id: id, data: data, ackID: ackID, payloadType: payloadType.String}, nil
+}
+
+// dequeueOrder returns the ORDER BY clause used to pick the next pending item
+func (q *Queue) dequeueOrder() string {
+	if q.hasPriority {
+		return "priority ASC, created_at ASC, id ASC"
+	}
+	if q.delayed {
+		return "COALESCE(visible_at, created_at) ASC, id ASC"
 	}
 
-	return data, true, ackID
+	return "created_at ASC, id ASC"
 }
 
 // Dequeue removes and returns the next item from the queue
@@ -215,48 +732,108 @@ func (q *Queue) DequeueWithAckId() (any, bool, string) {
 	return q.dequeueInternal(true)
 }
 
+// DequeueWithID removes and returns the next item like DequeueWithAckId, but identifies it by its ID
+// Systems that persist their own references can store the int64 ID and settle the item with
+// AcknowledgeByID or NackByID instead of keeping the ack ID string
+func (q *Queue) DequeueWithID() (any, bool, int64) {
+	row, err := q.dequeueWhere(true, "")
+	if err != nil {
+		return nil, false, 0
+	}
+
+	return q.decode(row.data, row.payloadType), true, row.id
+}
+
 // Acknowledge marks an item as completed
 // Returns true if the item was successfully acknowledged, false otherwise
+// Use Ack to tell duplicate acknowledgements apart from unknown ack IDs
 func (q *Queue) Acknowledge(ackID string) bool {
-	tx, err := q.client.Begin()
-	if err != nil {
-		return false
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	err := q.Ack(ackID)
 
-	var result sql.Result
+	// Acknowledging a completed item again has always been reported as a success
+	return err == nil || (!q.removeOnComplete && errors.Is(err, ErrDuplicateAck))
+}
 
-	if q.removeOnComplete {
-		// If removeOnComplete is true, delete the acknowledged item
-		result, err = tx.Exec(
-			fmt.Sprintf("DELETE FROM %s WHERE ack_id = ? ", q.tableName),
-			ackID,
-		)
-	} else {
-		// Otherwise, mark it as completed and set ack to 1 (true in SQLite)
-		result, err = tx.Exec(
-			fmt.Sprintf("UPDATE %s SET status = 'completed', ack = 1, updated_at = ? WHERE ack_id = ?", q.tableName),
-			time.Now().UTC(), ackID,
-		)
+// Nack returns a processing item to the queue so it can be dequeued again
+// The item keeps its position and attempt count, and its ack ID is invalidated
+// Items that used up the attempts allowed by WithMaxAttempts are dead-lettered instead
+// Returns true if the item was returned to the queue or dead-lettered, false otherwise
+func (q *Queue) Nack(ackID string) bool {
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			return q.nackInTx(tx, ackID)
+		})
+	})
+	return err == nil
+}
+
+// AcknowledgeByID marks the processing item with the given ID as completed, like Acknowledge
+func (q *Queue) AcknowledgeByID(id int64) bool {
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			ackID, err := q.ackIDOf(tx, id)
+			if err != nil {
+				return err
+			}
+			return q.ackInTx(tx, ackID)
+		})
+	})
+
+	return err == nil || (!q.removeOnComplete && errors.Is(err, ErrDuplicateAck))
+}
+
+// NackByID returns the processing item with the given ID to the queue, like Nack
+func (q *Queue) NackByID(id int64) bool {
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			ackID, err := q.ackIDOf(tx, id)
+			if err != nil {
+				return err
+			}
+			return q.nackInTx(tx, ackID)
+		})
+	})
+	return err == nil
+}
+
+// ackIDOf returns the ack ID of the leased or completed item with the given ID as part of tx
+// Returns ErrAckNotFound when no such item has an ack ID
+func (q *Queue) ackIDOf(tx *sql.Tx, id int64) (string, error) {
+	var ackID sql.NullString
+	err := tx.QueryRow(
+		fmt.Sprintf("SELECT ack_id FROM %s WHERE id = ? AND status IN ('processing', 'completed')", q.tableName),
+		id,
+	).Scan(&ackID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !ackID.Valid {
+		return "", fmt.Errorf("%w: item %d", ErrAckNotFound, id)
 	}
 
+	return ackID.String, err
+}
+
+// nackInTx returns the processing item with the given ack ID to pending as part of tx, see Nack
+func (q *Queue) nackInTx(tx *sql.Tx, ackID string) error {
+	exhausted, err := q.deadLetterExhausted(tx, "ack_id = ? AND status = 'processing'", ackID)
 	if err != nil {
-		tx.Rollback()
-		return false
+		return err
+	}
+	if exhausted > 0 {
+		return nil
 	}
 
-	rowsAffected, err := result.RowsAffected()
-
-	if err != nil || rowsAffected == 0 {
-		tx.Rollback()
-		return false
+	result, err := tx.Exec(
+		fmt.Sprintf("UPDATE %s SET status = 'pending', ack_id = NULL, consumer_id = NULL, lease_expires_at = NULL, updated_at = ?%s WHERE ack_id = ? AND status = 'processing'", q.tableName, q.retryPrioritySQL()),
+		q.now(), ackID,
+	)
+	if err != nil {
+		return err
 	}
+	if err := requireRows(result); err != nil {
+		return err
+	}
+	q.count(tx, 1, -1)
 
-	return tx.Commit() == nil
+	return q.countRequeues(tx, RequeueNack, 1)
 }
 
 // Len returns the number of pending items in the queue
@@ -270,27 +847,46 @@ func (q *Queue) Len() int {
 	return count
 }
 
-// Values returns all pending items in the queue
+// Values returns all pending items in the queue, in the order they would be dequeued
+// Payloads are previews passed through the WithRedactor redactor, Dequeue returns them intact
+// For priority queues that is priority order, then FIFO within a priority
 func (q *Queue) Values() []any {
-	rows, err := q.client.Query(fmt.Sprintf("SELECT data FROM %s WHERE status = 'pending' ORDER BY created_at ASC", q.tableName))
+	items, _ := q.values(q.client)
+	return items
+}
+
+// values reads the pending items from db, which may be a snapshot transaction
+func (q *Queue) values(db rowsQuerier) ([]any, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s, payload_type FROM %s WHERE status = 'pending' ORDER BY %s", q.dataColumn(), q.tableName, q.dequeueOrder()))
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
 
 	var items []any
 	for rows.Next() {
 		var data []byte
-		if err := rows.Scan(&data); err != nil {
+		var payloadType sql.NullString
+		if err := rows.Scan(&data, &payloadType); err != nil {
 			continue
 		}
 
+		// Micro-batches that weren't dequeued yet list their messages
+		if payloadType.String == microBatchPayloadType {
+			if messages, err := splitMicroBatch(data); err == nil {
+				for _, message := range messages {
+					items = append(items, message.item(q.Redact(message.data)))
+				}
+				continue
+			}
+		}
+
 		// Now we just add the byte array directly as we're storing byte arrays
-		// instead of JSON-serialized data
-		items = append(items, data)
+		// instead of JSON-serialized data, unless a registered type was recorded
+		items = append(items, q.decode(q.Redact(data), payloadType.String))
 	}
 
-	return items
+	return items, rows.Err()
 }
 
 // Purge removes all items from the queue
@@ -311,12 +907,37 @@ func (q *Queue) Purge() {
 		return
 	}
 
-	err = tx.Commit()
+	if err = tx.Commit(); err == nil {
+		q.approxPending.Store(0)
+		q.approxProcessing.Store(0)
+		q.signalBackpressure()
+		q.invalidateTenantUsage()
+	}
 }
 
-// Close closes the queue and its database connection
+// Close stops the queue's background goroutines and marks it closed
+// The database shared with other queues stays open, and Reopen brings the queue back
+// What later operations do depends on the queue's CloseBehavior
+// Items still being stored by EnqueueAsync or buffered by WithMicroBatching are stored first
 func (q *Queue) Close() error {
+	q.asyncEnqueues.Wait()
+	q.FlushMicroBatch()
+
+	q.lifecycleMu.Lock()
+	defer q.lifecycleMu.Unlock()
+
+	q.closeMu.Lock()
 	q.closed.Store(true)
+	if q.reopened == nil {
+		q.reopened = make(chan struct{})
+	}
+	q.closeMu.Unlock()
+
+	if q.stop != nil {
+		close(q.stop)
+		q.stop = nil
+	}
+	q.background.Wait()
 
 	return nil
 }
@@ -1,8 +1,11 @@
 package duckq
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	_ "github.com/marcboeker/go-duckdb/v2"
@@ -119,6 +122,63 @@ func TestDuckQueue(t *testing.T) {
 		}
 	})
 
+	// Test nack
+	t.Run("Nack", func(t *testing.T) {
+		item, success, ackID := q.DequeueWithAckId()
+		if !success {
+			t.Error("DequeueWithAckId failed")
+		}
+
+		if !q.Nack(ackID) {
+			t.Error("Nack failed")
+		}
+
+		// The nacked ack ID is no longer valid
+		if q.Acknowledge(ackID) {
+			t.Error("Acknowledge after Nack should fail")
+		}
+		if q.Nack(ackID) {
+			t.Error("Nack twice should fail")
+		}
+
+		// The item is dequeued again
+		redelivered, success := q.Dequeue()
+		if !success {
+			t.Error("Dequeue failed")
+		}
+		if string(redelivered.([]byte)) != string(item.([]byte)) {
+			t.Errorf("Expected '%s' to be redelivered, got '%s'", item, redelivered)
+		}
+	})
+
+	// Test settling items by ID
+	t.Run("ByID", func(t *testing.T) {
+		q.Purge()
+		q.Enqueue([]byte("by id"))
+
+		item, success, id := q.DequeueWithID()
+		if !success || id == 0 || string(item.([]byte)) != "by id" {
+			t.Fatalf("Expected the item with its ID, got %v and %d", item, id)
+		}
+		if !q.NackByID(id) {
+			t.Error("NackByID failed")
+		}
+		if q.AcknowledgeByID(id) {
+			t.Error("AcknowledgeByID of a pending item should fail")
+		}
+
+		_, _, redelivered := q.DequeueWithID()
+		if redelivered != id {
+			t.Errorf("Expected item %d to be redelivered, got %d", id, redelivered)
+		}
+		if !q.AcknowledgeByID(id) {
+			t.Error("AcknowledgeByID failed")
+		}
+		if q.NackByID(id) {
+			t.Error("NackByID of an acknowledged item should fail")
+		}
+	})
+
 	// Test purge
 	t.Run("Purge", func(t *testing.T) {
 		q.Purge()
@@ -326,3 +386,263 @@ func TestConcurrentOperations(t *testing.T) {
 		t.Errorf("Expected empty queue, got length %d", q.Len())
 	}
 }
+
+// Test enqueueing as part of an application transaction
+func TestEnqueueInTx(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_enqueue_in_tx.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	db := queues.DB()
+	if _, err := db.Exec("CREATE TABLE orders (id INTEGER)"); err != nil {
+		t.Fatalf("Failed to create orders table: %v", err)
+	}
+
+	t.Run("Rollback", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		tx.Exec("INSERT INTO orders VALUES (1)")
+		if err := q.EnqueueInTx(tx, []byte("order 1 created")); err != nil {
+			t.Fatalf("EnqueueInTx failed: %v", err)
+		}
+		tx.Rollback()
+
+		if q.Len() != 0 {
+			t.Errorf("Expected queue length 0 after rollback, got %d", q.Len())
+		}
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		tx.Exec("INSERT INTO orders VALUES (2)")
+		if err := q.EnqueueInTx(tx, []byte("order 2 created")); err != nil {
+			t.Fatalf("EnqueueInTx failed: %v", err)
+		}
+
+		// Not visible to consumers until the transaction commits
+		if q.Len() != 0 {
+			t.Errorf("Expected queue length 0 before commit, got %d", q.Len())
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		var orders int
+		db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&orders)
+		if orders != 1 || q.Len() != 1 {
+			t.Errorf("Expected 1 order and 1 queued item, got %d and %d", orders, q.Len())
+		}
+	})
+
+	t.Run("ClosedQueue", func(t *testing.T) {
+		closed, _ := queues.NewQueue("closed_queue")
+		closed.Close()
+
+		tx, _ := db.Begin()
+		defer tx.Rollback()
+		if err := closed.EnqueueInTx(tx, []byte("item")); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("Expected ErrQueueClosed, got %v", err)
+		}
+	})
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_max_payload_size.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue", WithMaxPayloadSize(8))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	if !q.Enqueue([]byte("small")) {
+		t.Error("Enqueue of a payload within the limit failed")
+	}
+	if q.Enqueue("far too large") {
+		t.Error("Enqueue of a payload over the limit should fail")
+	}
+
+	tx, err := queues.DB().Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if err := q.EnqueueInTx(tx, []byte("far too large")); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+
+	if q.Len() != 1 {
+		t.Errorf("Expected queue length 1, got %d", q.Len())
+	}
+}
+
+func TestConcurrentDequeue(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_concurrent_dequeue.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+
+	queues := New(dbPath)
+	defer queues.Close()
+
+	const workers, perWorker = 8, 25
+
+	run := func(t *testing.T, dequeue func() (any, bool, string), ack func(string) bool, length func() int) {
+		var failed atomic.Int32
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					_, success, ackID := dequeue()
+					if !success || !ack(ackID) {
+						failed.Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if failed.Load() != 0 {
+			t.Errorf("Expected every dequeue to succeed, %d failed", failed.Load())
+		}
+		if length() != 0 {
+			t.Errorf("Expected an empty queue, got length %d", length())
+		}
+	}
+
+	t.Run("Queue", func(t *testing.T) {
+		// Dequeue conflicts are retried with the queue's retry policy
+		q, err := queues.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		for i := 0; i < workers*perWorker; i++ {
+			q.Enqueue([]byte(fmt.Sprintf("item %d", i)))
+		}
+
+		run(t, q.DequeueWithAckId, q.Acknowledge, q.Len)
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority_queue")
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		for i := 0; i < workers*perWorker; i++ {
+			pq.Enqueue([]byte(fmt.Sprintf("item %d", i)), i%3)
+		}
+
+		run(t, pq.DequeueWithAckId, pq.Acknowledge, pq.Len)
+	})
+}
+
+func TestEnqueueID(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_enqueue_id.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	pq, err := queues.NewPriorityQueue("test_priority_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	first, err := q.EnqueueID([]byte("first"))
+	if err != nil {
+		t.Fatalf("EnqueueID failed: %v", err)
+	}
+	second, err := q.EnqueueID([]byte("second"))
+	if err != nil {
+		t.Fatalf("EnqueueID failed: %v", err)
+	}
+	if second <= first {
+		t.Errorf("Expected increasing IDs, got %d then %d", first, second)
+	}
+
+	m, found := q.Get(second)
+	if !found || string(m.Data) != "second" {
+		t.Errorf("Expected the returned ID to identify 'second', got %+v", m)
+	}
+
+	id, err := pq.EnqueueID([]byte("urgent"), 1)
+	if err != nil {
+		t.Fatalf("EnqueueID failed: %v", err)
+	}
+	if m, found := pq.Get(id); !found || m.Priority != 1 {
+		t.Errorf("Expected the returned ID to identify the prioritized item, got %+v", m)
+	}
+
+	q.Close()
+	if _, err := q.EnqueueID([]byte("late")); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_messages.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	pq, err := queues.NewPriorityQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	pq.Enqueue([]byte("low"), 5)
+	pq.Enqueue([]byte("high"), 1)
+	pq.Enqueue([]byte("normal"), 3)
+	pq.DequeueWithAckId()
+
+	messages, err := pq.Messages()
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(messages))
+	}
+	if messages[0].Status != "processing" || messages[0].Priority != 1 || messages[0].Attempts != 1 {
+		t.Errorf("Expected the leased high priority message first, got %+v", messages[0])
+	}
+
+	pending, err := pq.Messages(Filter{Statuses: []string{"pending"}})
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	if len(pending) != 2 || string(pending[0].Data) != "normal" || string(pending[1].Data) != "low" {
+		t.Errorf("Expected the pending messages in dequeue order, got %+v", pending)
+	}
+}
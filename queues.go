@@ -1,44 +1,309 @@
 package duckq
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb/v2"
 )
 
+// motherDuckPrefix is the connection string prefix for databases hosted on MotherDuck
+const motherDuckPrefix = "md:"
+
 type queues struct {
-	client *sql.DB
+	client          *sql.DB
+	motherDuckToken string
+	duckDBSettings  DuckDBSettings
+	openRetry       RetryPolicy
+	openTimeout     time.Duration
+	storeConfigs    bool
+	mu              sync.Mutex
+	opened          map[string]*Queue
+	templates       map[string][]Option
+
+	tenantQuotas       map[string]TenantQuota
+	defaultTenantQuota TenantQuota
+	tenantMeters       map[string]*tenantMeter
+
+	maintenanceIntervals map[MaintenanceTask]time.Duration
+	maintenanceJitter    float64
+	maintenanceObserver  func(task MaintenanceTask, duration time.Duration, affected int64, err error)
+	maintenanceMu        sync.Mutex
+	maintenanceStats     map[MaintenanceTask]MaintenanceStats
+	maintenanceLifecycle sync.Mutex
+	maintenanceStop      chan struct{}
+	maintenanceWake      chan struct{}
+	maintenanceDone      sync.WaitGroup
 }
 
 type Queues interface {
 	NewQueue(queueKey string, opts ...Option) (*Queue, error)
+	NewQueues(keys []string, opts ...Option) ([]*Queue, error)
 	NewPriorityQueue(queueKey string, opts ...Option) (*PriorityQueue, error)
+	NewDelayedQueue(queueKey string, opts ...Option) (*DelayedQueue, error)
+	NewShardedQueue(name string, shards int, opts ...Option) (*ShardedQueue, error)
+	NewQueueFromConfig(cfg QueueConfig) (*Queue, error)
+	DefineTemplate(name string, opts ...Option) error
+	NewQueueFromTemplate(queueKey, template string, opts ...Option) (*Queue, error)
+	ConvertToPriority(name string, opts ...Option) (*PriorityQueue, error)
+	NewTopic(name string) (*Topic, error)
+	NewStream(name string, opts ...StreamOption) (*Stream, error)
+	CloneQueue(src, dst string, filters ...Filter) (int, error)
+	Reopen(queueKey string) (*Queue, error)
+	StoredConfig(queueKey string) (QueueConfig, error)
+	TenantUsage(tenantID string) (TenantUsage, error)
+	EnqueueAll(ctx context.Context, items map[string][]byte) error
+	Mirror(src, dst string) error
+	WithTx(ctx context.Context, fn func(tx QueueTx) error) error
+	StartMaintenance()
+	StopMaintenance()
+	RunMaintenance(task MaintenanceTask) error
+	MaintenanceStats() map[MaintenanceTask]MaintenanceStats
+	DB() *sql.DB
 	Close() error
 }
 
-func New(dbPath string) Queues {
-	db, err := sql.Open("duckdb", dbPath)
+// New opens the DuckDB database at dbPath and returns a manager for the queues stored in it
+// dbPath can be a local file, an empty string for an in-memory database,
+// or an "md:<database>" connection string to host the queues on MotherDuck
+// New panics when the database cannot be opened, use Open to handle the error instead
+func New(dbPath string, opts ...QueuesOption) Queues {
+	q, err := Open(dbPath, opts...)
 	if err != nil {
-		panic(fmt.Sprintf("failed to open database: %v", err))
+		panic(err.Error())
+	}
+
+	return q
+}
+
+// Open opens the database like New, but returns an error instead of panicking
+// Returns ErrDatabaseBusy when another process holds the database file's lock,
+// after retrying as configured with WithOpenRetry and WithOpenTimeout
+func Open(dbPath string, opts ...QueuesOption) (Queues, error) {
+	q := &queues{
+		opened:            make(map[string]*Queue),
+		openRetry:         NoRetry,
+		maintenanceJitter: defaultMaintenanceJitter,
+		maintenanceWake:   make(chan struct{}, 1),
+	}
+
+	// Apply any provided options
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	db, err := q.open(dbPath)
+	if err != nil {
+		return nil, err
 	}
 
 	// DuckDB auto-configures optimization settings
 	// No need for WAL mode configuration as in SQLite
 
-	return &queues{
-		client: db,
+	q.client = db
+	if q.storeConfigs {
+		if err := createQueueConfigsTable(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create stored configurations table: %w", err)
+		}
 	}
+	q.StartMaintenance()
+
+	return q, nil
+}
+
+// dsn builds the connection string passed to the DuckDB driver
+func (q *queues) dsn(dbPath string) string {
+	params := url.Values{}
+	q.duckDBSettings.addTo(params)
+	if q.motherDuckToken != "" && strings.HasPrefix(dbPath, motherDuckPrefix) {
+		params.Set("motherduck_token", q.motherDuckToken)
+	}
+	if len(params) == 0 {
+		return dbPath
+	}
+
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+
+	return dbPath + separator + params.Encode()
 }
 
 func (q *queues) NewQueue(queueKey string, opts ...Option) (*Queue, error) {
-	return newQueue(q.client, queueKey, opts...)
+	opts, err := q.withStoredConfig(queueKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := newQueue(q.client, queueKey, q.withManager(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.storeConfig(queue); err != nil {
+		return nil, err
+	}
+
+	q.track(queueKey, queue)
+	return queue, nil
 }
 
 func (q *queues) NewPriorityQueue(queueKey string, opts ...Option) (*PriorityQueue, error) {
-	return newPriorityQueue(q.client, queueKey, opts...)
+	opts, err := q.withStoredConfig(queueKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	pq, err := newPriorityQueue(q.client, queueKey, q.withManager(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.storeConfig(pq.Queue); err != nil {
+		return nil, err
+	}
+
+	q.track(queueKey, pq.Queue)
+	return pq, nil
+}
+
+func (q *queues) NewDelayedQueue(queueKey string, opts ...Option) (*DelayedQueue, error) {
+	opts, err := q.withStoredConfig(queueKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	dq, err := newDelayedQueue(q.client, queueKey, q.withManager(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.storeConfig(dq.Queue); err != nil {
+		return nil, err
+	}
+
+	q.track(queueKey, dq.Queue)
+	return dq, nil
+}
+
+// withManager prepends the options letting a queue look up its tenant's quota and wake the maintenance scheduler
+func (q *queues) withManager(opts []Option) []Option {
+	return append([]Option{withTenantQuotas(q.quotaFor, q.meterFor), withMaintenanceWake(q.wakeMaintenance)}, opts...)
 }
 
+// NewQueueFromConfig validates cfg and creates the regular queue it describes
+// Every problem with cfg is reported at once, wrapping ErrInvalidOption
+func (q *queues) NewQueueFromConfig(cfg QueueConfig) (*Queue, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return q.NewQueue(cfg.Name, cfg.Options()...)
+}
+
+// track remembers the most recently opened queue for each key, so it can be reopened
+func (q *queues) track(queueKey string, queue *Queue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.opened[queueKey] = queue
+	q.wakeMaintenance()
+}
+
+// retryPolicyOf returns the RetryPolicy of the queue opened through the manager under name,
+// or DefaultRetryPolicy when it wasn't opened
+func (q *queues) retryPolicyOf(name string) RetryPolicy {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if queue, ok := q.opened[name]; ok {
+		return queue.retryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+// Reopen reopens the queue most recently opened with queueKey after it was closed
+// Priority and delayed queues share the returned Queue, so existing handles work again too
+// Returns ErrQueueNotFound if no queue was opened with queueKey
+func (q *queues) Reopen(queueKey string) (*Queue, error) {
+	q.mu.Lock()
+	queue, ok := q.opened[queueKey]
+	q.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQueueNotFound, queueKey)
+	}
+
+	queue.Reopen()
+	return queue, nil
+}
+
+// DB returns the database connection shared by every queue
+// Applications can use it to run their own statements in the same transaction as EnqueueInTx
+func (q *queues) DB() *sql.DB {
+	return q.client
+}
+
+// EnqueueAll enqueues each item into the queue named by its key in a single transaction, so
+// fan-out producers get all-or-nothing semantics across the queues of the database
+// Every queue must have been opened through the manager, otherwise ErrQueueNotFound is returned
+// and nothing is enqueued; the queues' own checks such as payload limits and quotas still apply
+// The transaction is retried with the retry policy of the queue making the most attempts, and the
+// rate limits of the queues only count the items once every queue accepted them
+func (q *queues) EnqueueAll(ctx context.Context, items map[string][]byte) error {
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	targets := make([]*Queue, 0, len(names))
+	q.mu.Lock()
+	for _, name := range names {
+		queue, ok := q.opened[name]
+		if !ok {
+			q.mu.Unlock()
+			return fmt.Errorf("%w: %s", ErrQueueNotFound, name)
+		}
+		targets = append(targets, queue)
+	}
+	q.mu.Unlock()
+
+	if i, err := checkEnqueueRates(targets); err != nil {
+		return fmt.Errorf("failed to enqueue into %s: %w", names[i], err)
+	}
+
+	err := retryPolicyFor(targets).do(func() error {
+		return inTrackedTx(ctx, q.client, func(tx *trackedTx) error {
+			for i, queue := range targets {
+				tx.track(queue)
+				if _, err := queue.enqueueInTx(tx.tx, items[names[i]], sql.NullTime{}); err != nil {
+					return fmt.Errorf("failed to enqueue into %s: %w", names[i], err)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		refundEnqueueRates(targets)
+		return fmt.Errorf("failed to enqueue items: %w", err)
+	}
+
+	return nil
+}
+
+// Close stops the maintenance scheduler, closes every queue opened through the manager,
+// then the shared database
 func (q *queues) Close() error {
+	q.StopMaintenance()
+
+	q.mu.Lock()
+	for _, queue := range q.opened {
+		queue.Close()
+	}
+	q.mu.Unlock()
+
 	return q.client.Close()
 }
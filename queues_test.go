@@ -0,0 +1,133 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMotherDuckDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		dbPath   string
+		token    string
+		expected string
+	}{
+		{"LocalFileIgnoresToken", "queue.db", "secret", "queue.db"},
+		{"MotherDuckWithoutToken", "md:queues", "", "md:queues"},
+		{"MotherDuckWithToken", "md:queues", "secret", "md:queues?motherduck_token=secret"},
+		{"MotherDuckWithParameters", "md:queues?saas_mode=true", "a+b", "md:queues?saas_mode=true&motherduck_token=a%2Bb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &queues{}
+			WithMotherDuckToken(tt.token)(q)
+
+			if dsn := q.dsn(tt.dbPath); dsn != tt.expected {
+				t.Errorf("Expected DSN '%s', got '%s'", tt.expected, dsn)
+			}
+		})
+	}
+}
+
+func TestEnqueueAll(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_enqueue_all.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	billing, err := queues.NewQueue("test_billing")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	shipping, err := queues.NewQueue("test_shipping", WithMaxPayloadSize(8))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("AllOrNothing", func(t *testing.T) {
+		err := queues.EnqueueAll(context.Background(), map[string][]byte{
+			"test_billing":  []byte("invoice"),
+			"test_shipping": []byte("parcel"),
+		})
+		if err != nil {
+			t.Fatalf("EnqueueAll failed: %v", err)
+		}
+		if billing.Len() != 1 || shipping.Len() != 1 {
+			t.Errorf("Expected one item in each queue, got %d and %d", billing.Len(), shipping.Len())
+		}
+
+		err = queues.EnqueueAll(context.Background(), map[string][]byte{
+			"test_billing":  []byte("invoice"),
+			"test_shipping": []byte("oversized parcel"),
+		})
+		if !errors.Is(err, ErrPayloadTooLarge) {
+			t.Fatalf("Expected ErrPayloadTooLarge, got %v", err)
+		}
+		if billing.Len() != 1 {
+			t.Errorf("Expected the failed batch to enqueue nothing, got %d items", billing.Len())
+		}
+		if billing.ApproxLen() != 1 || shipping.ApproxLen() != 1 {
+			t.Errorf("Expected the counters to follow the committed batch only, got %d and %d", billing.ApproxLen(), shipping.ApproxLen())
+		}
+	})
+
+	t.Run("UnknownQueue", func(t *testing.T) {
+		err := queues.EnqueueAll(context.Background(), map[string][]byte{"test_missing": []byte("lost")})
+		if !errors.Is(err, ErrQueueNotFound) {
+			t.Errorf("Expected ErrQueueNotFound, got %v", err)
+		}
+	})
+}
+
+func TestDuckDBSettings(t *testing.T) {
+	t.Run("DSN", func(t *testing.T) {
+		q := &queues{}
+		WithDuckDBSettings(DuckDBSettings{MemoryLimit: "512MB", Threads: 2})(q)
+		WithMotherDuckToken("secret")(q)
+
+		if dsn := q.dsn("md:queues"); dsn != "md:queues?memory_limit=512MB&motherduck_token=secret&threads=2" {
+			t.Errorf("Unexpected DSN '%s'", dsn)
+		}
+		if dsn := q.dsn("queue.db"); dsn != "queue.db?memory_limit=512MB&threads=2" {
+			t.Errorf("Unexpected DSN '%s'", dsn)
+		}
+	})
+
+	t.Run("Applied", func(t *testing.T) {
+		// Create a temporary database file
+		dbPath := "test_duckdb_settings.db"
+
+		// Cleanup after test
+		defer os.Remove(dbPath)
+		queues := New(dbPath, WithDuckDBSettings(DuckDBSettings{
+			MemoryLimit:          "256MB",
+			Threads:              2,
+			TempDirectory:        os.TempDir(),
+			MaxTempDirectorySize: "1GB",
+		}))
+		defer queues.Close()
+
+		if _, err := queues.NewQueue("test_queue"); err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		var threads int64
+		var memoryLimit string
+		err := queues.DB().QueryRow("SELECT current_setting('threads'), current_setting('memory_limit')").Scan(&threads, &memoryLimit)
+		if err != nil {
+			t.Fatalf("Failed to read settings: %v", err)
+		}
+		if threads != 2 {
+			t.Errorf("Expected 2 threads, got %d", threads)
+		}
+		if memoryLimit != "244.1 MiB" {
+			t.Errorf("Expected a 256MB memory limit, got %s", memoryLimit)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package duckq
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitBehavior controls what producers exceeding a queue's enqueue rate limit experience
+type RateLimitBehavior int
+
+const (
+	// RateLimitReject fails enqueues over the limit with ErrRateLimited (the default)
+	RateLimitReject RateLimitBehavior = iota
+	// RateLimitThrottle makes enqueues over the limit wait until the rate allows them
+	RateLimitThrottle
+)
+
+// rateLimiter is a token bucket holding up to limit tokens, refilled at limit tokens per period
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	per    time.Duration
+	tokens float64
+	last   time.Time
+}
+
+// take takes n tokens at now and returns 0, or returns how long until they are available
+// With reserve the tokens are taken anyway, leaving the bucket in debt for the returned duration,
+// so the caller can wait it out once instead of competing for the tokens again
+func (l *rateLimiter) take(now time.Time, n int, reserve bool) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.last.IsZero() {
+		l.tokens = float64(l.limit)
+	} else if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(float64(l.limit), l.tokens+elapsed.Seconds()*float64(l.limit)/l.per.Seconds())
+	}
+	l.last = now
+
+	if l.tokens >= float64(n) {
+		l.tokens -= float64(n)
+		return 0
+	}
+
+	wait := time.Duration((float64(n) - l.tokens) * float64(l.per) / float64(l.limit))
+	if reserve {
+		l.tokens -= float64(n)
+	}
+	return wait
+}
+
+// refund returns n tokens taken by take, without exceeding the bucket's size
+func (l *rateLimiter) refund(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(float64(l.limit), l.tokens+float64(n))
+}
+
+// checkEnqueueRate takes n tokens from the queue's enqueue rate limiter
+// With RateLimitThrottle it waits for them on the queue's clock, otherwise it returns ErrRateLimited,
+// or ErrBatchTooLarge when n is more than the bucket ever holds
+// It runs before an enqueue's transaction starts, so the wait doesn't hold a transaction open
+func (q *Queue) checkEnqueueRate(n int) error {
+	if q.enqueueLimiter == nil || n == 0 {
+		return nil
+	}
+
+	throttle := q.rateLimitBehavior == RateLimitThrottle
+	if !throttle && n > q.enqueueLimiter.limit {
+		// The bucket never holds more tokens than the limit, so retrying can't help
+		return fmt.Errorf("%w: %d items, at most %d per %v", ErrBatchTooLarge, n, q.enqueueLimiter.limit, q.enqueueLimiter.per)
+	}
+	wait := q.enqueueLimiter.take(q.now(), n, throttle)
+	if wait == 0 {
+		return nil
+	}
+	if !throttle {
+		return fmt.Errorf("%w: more than %d enqueues per %v, retry in %v", ErrRateLimited, q.enqueueLimiter.limit, q.enqueueLimiter.per, wait)
+	}
+
+	q.sleep(wait)
+	return nil
+}
+
+// retryEnqueue takes n tokens from the enqueue rate limiter, then runs fn with the queue's retry
+// policy, so the attempts retrying a conflict don't take tokens again
+func (q *Queue) retryEnqueue(n int, fn func() error) error {
+	if err := q.checkEnqueueRate(n); err != nil {
+		return err
+	}
+
+	return q.retry(fn)
+}
+
+// checkEnqueueRates takes a token from the enqueue rate limiter of every queue an enqueue spans
+// When a queue is over its limit, the tokens taken from the queues before it are returned along
+// with the index of the queue and its error, so a rejected enqueue doesn't use up any rate
+func checkEnqueueRates(queues []*Queue) (int, error) {
+	for i, queue := range queues {
+		if err := queue.checkEnqueueRate(1); err != nil {
+			refundEnqueueRates(queues[:i])
+			return i, err
+		}
+	}
+
+	return 0, nil
+}
+
+// refundEnqueueRates returns the tokens checkEnqueueRates took, e.g. when the enqueue failed
+func refundEnqueueRates(queues []*Queue) {
+	for _, queue := range queues {
+		if queue.enqueueLimiter != nil {
+			queue.enqueueLimiter.refund(1)
+		}
+	}
+}
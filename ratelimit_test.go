@@ -0,0 +1,169 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestEnqueueRateLimit(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_enqueue_rate_limit.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Reject", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		q, err := queues.NewQueue("test_reject_queue", WithEnqueueRateLimit(2, time.Second), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		if !q.Enqueue([]byte("a")) || !q.Enqueue([]byte("b")) {
+			t.Fatal("Expected a burst up to the limit to succeed")
+		}
+		if _, err := q.EnqueueID([]byte("c")); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+
+		clock.Advance(500 * time.Millisecond)
+		if _, err := q.EnqueueID([]byte("c")); err != nil {
+			t.Errorf("Expected a token after half the period, got %v", err)
+		}
+		if q.Enqueue([]byte("d")) {
+			t.Error("Expected the bucket to be empty again")
+		}
+		if q.Len() != 3 {
+			t.Errorf("Expected 3 items, got %d", q.Len())
+		}
+	})
+
+	t.Run("BatchTooLarge", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_batch_queue", WithEnqueueRateLimit(2, time.Second))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		items := []PrioritizedItem{{[]byte("a"), 1}, {[]byte("b"), 1}, {[]byte("c"), 1}}
+		if err := pq.EnqueueBatch(items); !errors.Is(err, ErrBatchTooLarge) || errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrBatchTooLarge, got %v", err)
+		}
+		if err := pq.EnqueueBatch(items[:2]); err != nil {
+			t.Errorf("Expected a batch up to the limit to succeed, got %v", err)
+		}
+	})
+
+	t.Run("Throttle", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_throttle_queue", WithEnqueueRateLimit(1, 50*time.Millisecond), WithRateLimitBehavior(RateLimitThrottle))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if !pq.Enqueue([]byte("item"), 1) {
+				t.Fatal("Throttled enqueue failed")
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("Expected enqueues to be spread over the period, took %v", elapsed)
+		}
+	})
+
+	t.Run("ThrottleOnClock", func(t *testing.T) {
+		clock := &sleepingClock{fakeClock: fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}
+		q, err := queues.NewQueue("test_throttle_clock_queue", WithEnqueueRateLimit(1, time.Hour), WithRateLimitBehavior(RateLimitThrottle), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if !q.Enqueue([]byte("item")) {
+				t.Fatal("Throttled enqueue failed")
+			}
+		}
+		if clock.slept != 2*time.Hour {
+			t.Errorf("Expected the enqueues to wait 2h on the clock, waited %v", clock.slept)
+		}
+	})
+
+	t.Run("ThrottleOnFrozenClock", func(t *testing.T) {
+		// A clock that never advances must not keep a throttled enqueue waiting forever
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		q, err := queues.NewQueue("test_throttle_frozen_queue", WithEnqueueRateLimit(1, time.Millisecond), WithRateLimitBehavior(RateLimitThrottle), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if !q.Enqueue([]byte("item")) {
+				t.Fatal("Throttled enqueue failed")
+			}
+		}
+	})
+
+	t.Run("MultiQueue", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		first, err := queues.NewQueue("test_multi_a", WithEnqueueRateLimit(3, time.Hour), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		if _, err := queues.NewQueue("test_multi_b", WithEnqueueRateLimit(1, time.Hour), WithMaxPayloadSize(8), WithClock(clock)); err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		topic, err := queues.NewTopic("test_multi_topic")
+		if err != nil {
+			t.Fatalf("Failed to create topic: %v", err)
+		}
+		topic.Subscribe("test_multi_a")
+		topic.Subscribe("test_multi_b")
+
+		// The transaction fails on the oversized payload, so neither queue keeps the tokens
+		if err := queues.EnqueueAll(t.Context(), map[string][]byte{"test_multi_a": []byte("a"), "test_multi_b": []byte("oversized payload")}); err == nil {
+			t.Fatal("Expected the oversized payload to fail")
+		}
+		if _, err := topic.Publish([]byte("oversized payload")); err == nil {
+			t.Fatal("Expected the oversized payload to fail")
+		}
+		if err := queues.EnqueueAll(t.Context(), map[string][]byte{"test_multi_a": []byte("a"), "test_multi_b": []byte("b")}); err != nil {
+			t.Fatalf("Expected the failed enqueues not to use up the rate, got %v", err)
+		}
+
+		// The second queue is over its limit, so the first one gets its token back
+		if err := queues.EnqueueAll(t.Context(), map[string][]byte{"test_multi_a": []byte("a"), "test_multi_b": []byte("b")}); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+		if _, err := topic.Publish([]byte("c")); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+		if !first.Enqueue([]byte("a")) || !first.Enqueue([]byte("a")) {
+			t.Error("Expected the rejected enqueues not to use up the first queue's rate")
+		}
+		if first.Enqueue([]byte("a")) {
+			t.Error("Expected the first queue's bucket to be empty")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_invalid_queue", WithEnqueueRateLimit(0, time.Second)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
+
+// sleepingClock is a fakeClock that advances instead of blocking when a queue waits on it
+type sleepingClock struct {
+	fakeClock
+	slept time.Duration
+}
+
+func (c *sleepingClock) Sleep(d time.Duration) {
+	c.slept += d
+	c.Advance(d)
+}
@@ -0,0 +1,36 @@
+package duckq
+
+import "time"
+
+// RecoveryReport describes the items that RequeueNoAckRows returned to the queue
+type RecoveryReport struct {
+	// Messages holds the recovered items as they were before being requeued
+	Messages []Message
+	// RecoveredAt is when the items were requeued
+	RecoveredAt time.Time
+}
+
+// Recovered returns the number of recovered items
+func (r RecoveryReport) Recovered() int {
+	return len(r.Messages)
+}
+
+// OldestAge returns how long the oldest recovered item had been in the queue
+func (r RecoveryReport) OldestAge() time.Duration {
+	var oldest time.Duration
+	for _, m := range r.Messages {
+		if age := r.RecoveredAt.Sub(m.CreatedAt); age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
+
+// MaxAttempts returns the highest attempt count among the recovered items
+func (r RecoveryReport) MaxAttempts() int {
+	var attempts int
+	for _, m := range r.Messages {
+		attempts = max(attempts, m.Attempts)
+	}
+	return attempts
+}
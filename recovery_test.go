@@ -0,0 +1,81 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestRecoveryReport(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_recovery.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue([]byte("first"))
+	q.Enqueue([]byte("second"))
+	q.Enqueue([]byte("untouched"))
+
+	// Lease two items and never acknowledge them, as a crashed consumer would
+	q.DequeueWithAckId()
+	_, _, ackID := q.DequeueWithAckId()
+	q.Nack(ackID)
+	q.DequeueWithAckId()
+	time.Sleep(10 * time.Millisecond)
+
+	t.Run("ReportsRecoveredItems", func(t *testing.T) {
+		var report RecoveryReport
+		calls := 0
+		reopened, err := queues.NewQueue("test_queue", WithRecoveryHandler(func(r RecoveryReport) {
+			calls++
+			report = r
+		}))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+
+		if calls != 1 {
+			t.Fatalf("Expected the recovery handler to be called once, got %d", calls)
+		}
+		if report.Recovered() != 2 {
+			t.Errorf("Expected 2 recovered items, got %d", report.Recovered())
+		}
+		if report.MaxAttempts() != 2 {
+			t.Errorf("Expected max attempts 2, got %d", report.MaxAttempts())
+		}
+		if report.OldestAge() < 10*time.Millisecond {
+			t.Errorf("Expected the oldest item to be at least 10ms old, got %v", report.OldestAge())
+		}
+		if report.Messages[0].Status != "processing" {
+			t.Errorf("Expected the report to describe items before requeue, got status '%s'", report.Messages[0].Status)
+		}
+		if reopened.Len() != 3 {
+			t.Errorf("Expected 3 pending items after recovery, got %d", reopened.Len())
+		}
+	})
+
+	t.Run("NothingToRecover", func(t *testing.T) {
+		calls := 0
+		_, err := queues.NewQueue("test_queue", WithRecoveryHandler(func(RecoveryReport) { calls++ }))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("Expected no recovery handler call, got %d", calls)
+		}
+
+		if report := q.RequeueNoAckRows(); report.Recovered() != 0 {
+			t.Errorf("Expected nothing to recover, got %d items", report.Recovered())
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package duckq
+
+// Redactor rewrites a payload before it is shown outside the queue's consumers, e.g. masking
+// personal data in logs and dashboards. It must not modify its argument
+type Redactor func(payload []byte) []byte
+
+// Redact returns data as the queue's inspection APIs show it, passed through the redactor set
+// with WithRedactor, or unchanged without one
+func (q *Queue) Redact(data []byte) []byte {
+	if q.redactor == nil || data == nil {
+		return data
+	}
+
+	return q.redactor(data)
+}
+
+// redactMessages redacts the payload of every message
+func (q *Queue) redactMessages(messages []Message) []Message {
+	for i := range messages {
+		messages[i].Data = q.Redact(messages[i].Data)
+	}
+
+	return messages
+}
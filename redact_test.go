@@ -0,0 +1,65 @@
+package duckq
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestRedactor(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_redactor.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	mask := func(payload []byte) []byte {
+		return bytes.ReplaceAll(payload, []byte("alice@example.com"), []byte("[redacted]"))
+	}
+	q, err := queues.NewQueue("test_queue", WithRedactor(mask), WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	id, err := q.EnqueueID([]byte(`{"email": "alice@example.com"}`))
+	if err != nil {
+		t.Fatalf("EnqueueID failed: %v", err)
+	}
+	const redacted = `{"email": "[redacted]"}`
+
+	t.Run("Previews", func(t *testing.T) {
+		if values := q.Values(); len(values) != 1 || string(values[0].([]byte)) != redacted {
+			t.Errorf("Expected Values to be redacted, got %s", values)
+		}
+		if m, ok := q.Get(id); !ok || string(m.Data) != redacted {
+			t.Errorf("Expected Get to be redacted, got %s", m.Data)
+		}
+		if messages, _ := q.Messages(); len(messages) != 1 || string(messages[0].Data) != redacted {
+			t.Errorf("Expected Messages to be redacted, got %+v", messages)
+		}
+	})
+
+	t.Run("DequeueIntact", func(t *testing.T) {
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != `{"email": "alice@example.com"}` {
+			t.Errorf("Expected the full payload, got %s", item)
+		}
+	})
+
+	t.Run("RecoveryReport", func(t *testing.T) {
+		q.Enqueue([]byte("alice@example.com"))
+		q.DequeueWithAckId()
+
+		var report RecoveryReport
+		_, err := queues.NewQueue("test_queue", WithRedactor(mask), WithRecoveryHandler(func(r RecoveryReport) { report = r }))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+		if report.Recovered() != 1 || string(report.Messages[0].Data) != "[redacted]" {
+			t.Errorf("Expected a redacted recovery report, got %+v", report.Messages)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package duckq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TypeRegistry maps Go types to the names recorded in the payload_type column
+// Queues created with WithTypeRegistry gob-encode items of registered types on Enqueue
+// and decode them back into their original concrete type on Dequeue and Values,
+// so heterogeneous task types can share one queue
+type TypeRegistry struct {
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+// NewTypeRegistry creates an empty TypeRegistry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		byName: make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// Register records the types of the given values under their package-qualified type names
+func (r *TypeRegistry) Register(values ...any) {
+	for _, value := range values {
+		t := reflect.TypeOf(value)
+		r.RegisterName(typeName(t), value)
+	}
+}
+
+// RegisterName records the type of value under the given name
+// Use it to keep stored names stable when a type is renamed or moved to another package
+func (r *TypeRegistry) RegisterName(name string, value any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := reflect.TypeOf(value)
+	r.byName[name] = t
+	r.byType[t] = name
+}
+
+// nameOf returns the registered name of the value's type
+func (r *TypeRegistry) nameOf(value any) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.byType[reflect.TypeOf(value)]
+	return name, ok
+}
+
+// typeOf returns the type registered under name
+func (r *TypeRegistry) typeOf(name string) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.byName[name]
+	return t, ok
+}
+
+// typeName returns a package-qualified name for a type, e.g. "*github.com/acme/tasks.Email"
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		return "*" + typeName(t.Elem())
+	}
+
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+
+	return t.String()
+}
+
+// encode prepares an item for storage
+// Items of a type registered with the queue's TypeRegistry are gob-encoded and
+// returned with their type name, other items go through the queue's Codec unless
+// they are []byte or string, strings are stored as their raw bytes and
+// anything else is stored as is
+// Queues created with WithJSONPayload store every item as JSON text instead, see encodeJSON,
+// and items of the type of a StructSchema are always stored as JSON, see encodeStruct
+func (q *Queue) encode(item any) (any, *string, error) {
+	if batch, ok := item.(microBatch); ok {
+		payloadType := microBatchPayloadType
+		return []byte(batch), &payloadType, nil
+	}
+	if data, payloadType, ok, err := q.encodeStruct(item); ok {
+		return data, payloadType, err
+	}
+	if q.jsonPayload {
+		return q.encodeJSON(item)
+	}
+	if q.registry == nil {
+		return q.encodeCodec(item)
+	}
+
+	name, ok := q.registry.nameOf(item)
+	if !ok {
+		return q.encodeCodec(item)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(item); err != nil {
+		return nil, nil, fmt.Errorf("failed to gob-encode %s: %w", name, err)
+	}
+
+	return buf.Bytes(), &name, nil
+}
+
+// Encode returns the bytes the queue stores for item, the inverse of what Dequeue returns
+// Bridges use it to forward dequeued items that the queue's Codec, TypeRegistry or
+// StructSchema decoded back into values. Returns an ErrInvalidPayload error when the
+// item doesn't encode to bytes, e.g. an int on a queue without a Codec
+func (q *Queue) Encode(item any) ([]byte, error) {
+	data, _, err := q.encode(item)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	switch data := data.(type) {
+	case []byte:
+		return data, nil
+	case string:
+		return []byte(data), nil
+	}
+
+	return nil, fmt.Errorf("%w: %T doesn't encode to bytes", ErrInvalidPayload, item)
+}
+
+// encodeCodec marshals items with the queue's Codec, leaving []byte and string payloads as raw bytes
+func (q *Queue) encodeCodec(item any) (any, *string, error) {
+	switch item.(type) {
+	case []byte, string:
+		return binaryPayload(item), nil, nil
+	}
+	if q.codec == nil {
+		return item, nil, nil
+	}
+
+	data, err := q.codec.Marshal(item)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode item: %w", err)
+	}
+
+	payloadType := codecPayloadType
+	return data, &payloadType, nil
+}
+
+// encodeJSON prepares an item for a JSON payload column
+// []byte and string payloads must already hold valid JSON, other items are marshaled
+// with encoding/json and registered types record their name so decode can rebuild them
+func (q *Queue) encodeJSON(item any) (any, *string, error) {
+	var payloadType *string
+	if q.registry != nil {
+		if name, ok := q.registry.nameOf(item); ok {
+			payloadType = &name
+		}
+	}
+
+	switch item := item.(type) {
+	case []byte:
+		data, err := q.rawPayload(item)
+		return data, nil, err
+	case string:
+		data, err := q.rawPayload([]byte(item))
+		return data, nil, err
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode item as JSON: %w", err)
+	}
+
+	return string(data), payloadType, nil
+}
+
+// rawPayload prepares raw payload bytes for the queue's data column
+// A JSON payload column only accepts valid JSON, which is bound as text
+func (q *Queue) rawPayload(data []byte) (any, error) {
+	if !q.jsonPayload {
+		return data, nil
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%w: payload is not valid JSON", ErrInvalidPayload)
+	}
+
+	return string(data), nil
+}
+
+// decode rebuilds an item stored by encode
+// Payloads without a registered type name, or that fail to decode, are returned as raw bytes
+func (q *Queue) decode(data []byte, payloadType string) any {
+	if payloadType == textPayloadType {
+		return string(data)
+	}
+	if payloadType == structPayloadType && q.structSchema != nil {
+		return q.decodeStruct(data)
+	}
+	if payloadType == codecPayloadType && q.codec != nil {
+		item, err := q.codec.Unmarshal(data)
+		if err != nil {
+			return data
+		}
+		return item
+	}
+
+	if q.registry == nil || payloadType == "" {
+		return data
+	}
+
+	t, ok := q.registry.typeOf(payloadType)
+	if !ok {
+		return data
+	}
+	if q.jsonPayload {
+		value := reflect.New(t)
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			return data
+		}
+		return value.Elem().Interface()
+	}
+
+	// Gob encodes the value behind a pointer, so decode into the pointed-to type
+	target := t
+	if t.Kind() == reflect.Pointer {
+		target = t.Elem()
+	}
+
+	value := reflect.New(target)
+	if err := gob.NewDecoder(bytes.NewReader(data)).DecodeValue(value); err != nil {
+		return data
+	}
+
+	if t.Kind() == reflect.Pointer {
+		return value.Interface()
+	}
+
+	return value.Elem().Interface()
+}
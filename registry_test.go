@@ -0,0 +1,171 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+type emailTask struct {
+	To      string
+	Subject string
+}
+
+type resizeTask struct {
+	ImageID int
+	Width   int
+}
+
+func TestTypeRegistry(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_registry.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	registry := NewTypeRegistry()
+	registry.Register(emailTask{}, &resizeTask{})
+
+	q, err := queues.NewQueue("test_queue", WithTypeRegistry(registry))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.Enqueue(emailTask{To: "a@example.com", Subject: "hi"})
+	q.Enqueue(&resizeTask{ImageID: 7, Width: 640})
+	q.Enqueue([]byte("raw bytes"))
+
+	t.Run("RecordsTypeNames", func(t *testing.T) {
+		var payloadType string
+		row := q.client.QueryRow("SELECT payload_type FROM test_queue ORDER BY id LIMIT 1")
+		if err := row.Scan(&payloadType); err != nil {
+			t.Fatalf("Error reading payload type: %v", err)
+		}
+		if payloadType != "github.com/goptics/duckq.emailTask" {
+			t.Errorf("Unexpected payload type '%s'", payloadType)
+		}
+	})
+
+	t.Run("Values", func(t *testing.T) {
+		values := q.Values()
+		if len(values) != 3 {
+			t.Fatalf("Expected 3 values, got %d", len(values))
+		}
+		if _, ok := values[0].(emailTask); !ok {
+			t.Errorf("Expected emailTask, got %T", values[0])
+		}
+	})
+
+	t.Run("Dequeue", func(t *testing.T) {
+		item, success := q.Dequeue()
+		if !success {
+			t.Fatal("Dequeue failed")
+		}
+		email, ok := item.(emailTask)
+		if !ok || email.To != "a@example.com" || email.Subject != "hi" {
+			t.Errorf("Expected the original emailTask, got %#v", item)
+		}
+
+		item, success, _ = q.DequeueWithAckId()
+		if !success {
+			t.Fatal("DequeueWithAckId failed")
+		}
+		resize, ok := item.(*resizeTask)
+		if !ok || resize.ImageID != 7 || resize.Width != 640 {
+			t.Errorf("Expected the original *resizeTask, got %#v", item)
+		}
+
+		// Unregistered payloads come back as raw bytes
+		item, success = q.Dequeue()
+		if !success {
+			t.Fatal("Dequeue failed")
+		}
+		if raw, ok := item.([]byte); !ok || string(raw) != "raw bytes" {
+			t.Errorf("Expected raw bytes, got %#v", item)
+		}
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority_queue", WithTypeRegistry(registry))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+
+		pq.Enqueue(emailTask{To: "low@example.com"}, 10)
+		pq.Enqueue(&resizeTask{ImageID: 1}, 1)
+
+		item, success := pq.Dequeue()
+		if !success {
+			t.Fatal("Dequeue failed")
+		}
+		if _, ok := item.(*resizeTask); !ok {
+			t.Errorf("Expected *resizeTask, got %T", item)
+		}
+	})
+
+	t.Run("DeadLetterAndQuarantine", func(t *testing.T) {
+		policy := QuarantinePolicy{MaxFailures: 1, Window: time.Minute}
+		typed, err := queues.NewQueue("test_typed_failures", WithTypeRegistry(registry), WithQuarantine(policy))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		typed.Enqueue(emailTask{To: "dead@example.com"})
+		_, _, ackID := typed.DequeueWithAckId()
+		if !typed.DeadLetter(ackID, "rejected") {
+			t.Fatal("DeadLetter failed")
+		}
+		if replayed, err := typed.ReplayDeadLetters(0, true); err != nil || replayed != 1 {
+			t.Fatalf("Expected 1 replayed item, got %d, %v", replayed, err)
+		}
+
+		item, _, ackID := typed.DequeueWithAckId()
+		if email, ok := item.(emailTask); !ok || email.To != "dead@example.com" {
+			t.Fatalf("Expected the replayed emailTask, got %#v", item)
+		}
+
+		if _, err := typed.RecordFailure(ackID, HandlerFailure{Err: errors.New("crash"), Panicked: true}); err != nil {
+			t.Fatalf("RecordFailure failed: %v", err)
+		}
+		quarantined, err := typed.Quarantined(Page{})
+		if err != nil || len(quarantined) != 1 {
+			t.Fatalf("Expected 1 quarantined message, got %d, %v", len(quarantined), err)
+		}
+		if err := typed.ReleaseQuarantined(quarantined[0].ID); err != nil {
+			t.Fatalf("ReleaseQuarantined failed: %v", err)
+		}
+
+		item, _ = typed.Dequeue()
+		if email, ok := item.(emailTask); !ok || email.To != "dead@example.com" {
+			t.Errorf("Expected the released emailTask, got %#v", item)
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		data, err := q.Encode(emailTask{To: "b@example.com"})
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		q.Enqueue(emailTask{To: "b@example.com"})
+
+		var stored []byte
+		if err := q.client.QueryRow("SELECT data FROM test_queue ORDER BY id DESC LIMIT 1").Scan(&stored); err != nil {
+			t.Fatalf("Error reading payload: %v", err)
+		}
+		if string(data) != string(stored) {
+			t.Errorf("Expected Encode to return the stored payload")
+		}
+
+		if data, err := q.Encode("text"); err != nil || string(data) != "text" {
+			t.Errorf("Expected strings to encode to their bytes, got %q, %v", data, err)
+		}
+		if _, err := q.Encode(42); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Expected ErrInvalidPayload for an int, got %v", err)
+		}
+	})
+}
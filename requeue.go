@@ -0,0 +1,100 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// RequeueCause is why an item went back to pending after being delivered
+type RequeueCause string
+
+const (
+	// RequeueNack counts items nacked by their consumer, including failures reported with RecordFailure
+	RequeueNack RequeueCause = "nack"
+	// RequeueLeaseExpiry counts items requeued by WithProcessingTimeout after their lease expired
+	RequeueLeaseExpiry RequeueCause = "lease_expiry"
+	// RequeueCrashRecovery counts items left processing by a crashed consumer and requeued by RequeueNoAckRows
+	RequeueCrashRecovery RequeueCause = "crash_recovery"
+)
+
+// RequeueStats counts the requeues of a queue by cause, since the queue was created
+// Many nacks point at flaky consumers, many lease expiries at jobs that outgrow their lease
+type RequeueStats struct {
+	Nack          int64
+	LeaseExpiry   int64
+	CrashRecovery int64
+}
+
+// Total returns the number of requeues of every cause
+func (s RequeueStats) Total() int64 {
+	return s.Nack + s.LeaseExpiry + s.CrashRecovery
+}
+
+// requeuesTableName returns the name of the table counting a queue's requeues by cause
+func requeuesTableName(tableName string) string {
+	return fmt.Sprintf("%s_requeues", tableName)
+}
+
+// createRequeuesTable creates the requeue counters of a queue if they don't exist
+func createRequeuesTable(db execer, tableName string) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		cause TEXT PRIMARY KEY,
+		requeues BIGINT NOT NULL
+	);
+	`, requeuesTableName(tableName)))
+	return err
+}
+
+// countRequeues adds n requeues with the given cause as part of tx
+func (q *Queue) countRequeues(tx *sql.Tx, cause RequeueCause, n int64) error {
+	if n == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (cause, requeues) VALUES (?, ?) ON CONFLICT (cause) DO UPDATE SET requeues = requeues + excluded.requeues", requeuesTableName(q.tableName)),
+		string(cause), n,
+	)
+	return err
+}
+
+// RequeueStats returns how many times items of the queue were requeued, by cause
+// The counters live in the database, so they cover every process consuming the queue
+func (q *Queue) RequeueStats() (RequeueStats, error) {
+	stats, err := q.requeueStats(q.client)
+	if err != nil {
+		return RequeueStats{}, fmt.Errorf("failed to read requeue stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// requeueStats reads the requeue counters from db, which may be a snapshot transaction
+func (q *Queue) requeueStats(db rowsQuerier) (RequeueStats, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT cause, requeues FROM %s", requeuesTableName(q.tableName)))
+	if err != nil {
+		return RequeueStats{}, err
+	}
+	defer rows.Close()
+
+	var stats RequeueStats
+	for rows.Next() {
+		var cause string
+		var n int64
+		if err := rows.Scan(&cause, &n); err != nil {
+			return RequeueStats{}, err
+		}
+
+		switch RequeueCause(cause) {
+		case RequeueNack:
+			stats.Nack = n
+		case RequeueLeaseExpiry:
+			stats.LeaseExpiry = n
+		case RequeueCrashRecovery:
+			stats.CrashRecovery = n
+		}
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,81 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RequeueFront returns the processing item with ackID to the queue like Nack, but ahead of every
+// pending item, for "try again immediately" flows; on priority queues it goes ahead of the
+// pending items of its own priority
+// The item is moved forward by giving it a timestamp just before the oldest pending item's,
+// so its created_at, or its visible_at on delayed queues, no longer tells when it was enqueued
+// Items that used up WithMaxAttempts are dead-lettered instead
+// Returns ErrAckNotFound if no processing item has ackID
+func (q *Queue) RequeueFront(ackID string) error {
+	err := q.retry(func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			return q.requeueFrontInTx(tx, ackID)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue item: %w", err)
+	}
+
+	return nil
+}
+
+// requeueFrontInTx returns the processing item with ackID ahead of the pending items as part of tx
+func (q *Queue) requeueFrontInTx(tx *sql.Tx, ackID string) error {
+	exhausted, err := q.deadLetterExhausted(tx, "ack_id = ? AND status = 'processing'", ackID)
+	if err != nil {
+		return err
+	}
+	if exhausted > 0 {
+		return nil
+	}
+
+	orderColumn := "created_at"
+	if q.delayed {
+		orderColumn = "COALESCE(visible_at, created_at)"
+	}
+	query := fmt.Sprintf("SELECT MIN(%s) FROM %s WHERE status = 'pending'", orderColumn, q.tableName)
+	args := []any{}
+	if q.hasPriority {
+		query += fmt.Sprintf(" AND priority = (SELECT priority FROM %s WHERE ack_id = ?)", q.tableName)
+		args = append(args, ackID)
+	}
+
+	var oldest sql.NullTime
+	if err := tx.QueryRow(query, args...).Scan(&oldest); err != nil {
+		return err
+	}
+
+	now := q.now()
+	front := now
+	if oldest.Valid && oldest.Time.Before(now) {
+		front = oldest.Time.Add(-time.Microsecond)
+	}
+
+	// Regular and priority queues order by created_at, delayed queues by visibility
+	set := "created_at = ?, visible_at = ?"
+	setArgs := []any{front, now}
+	if q.delayed {
+		set = "visible_at = ?"
+		setArgs = []any{front}
+	}
+	result, err := tx.Exec(
+		fmt.Sprintf("UPDATE %s SET status = 'pending', ack_id = NULL, consumer_id = NULL, lease_expires_at = NULL, %s, updated_at = ? WHERE ack_id = ? AND status = 'processing'", q.tableName, set),
+		append(setArgs, now, ackID)...,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireRows(result); err != nil {
+		return err
+	}
+	q.count(tx, 1, -1)
+
+	return q.countRequeues(tx, RequeueNack, 1)
+}
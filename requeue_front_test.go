@@ -0,0 +1,92 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestRequeueFront(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_requeue_front.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	q, err := queues.NewQueue("test_queue", WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	for _, item := range []string{"first", "second", "third"} {
+		q.Enqueue([]byte(item))
+		clock.Advance(time.Second)
+	}
+
+	t.Run("AheadOfPending", func(t *testing.T) {
+		_, _, first := q.DequeueWithAckId()
+		_, _, second := q.DequeueWithAckId()
+		q.Nack(first)
+		if err := q.RequeueFront(second); err != nil {
+			t.Fatalf("RequeueFront failed: %v", err)
+		}
+
+		// A nacked item keeps its place, the requeued one goes ahead of it
+		for _, want := range []string{"second", "first"} {
+			if item, _ := q.Dequeue(); string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+		stats, _ := q.RequeueStats()
+		if stats.Nack != 2 {
+			t.Errorf("Expected both requeues to be counted, got %+v", stats)
+		}
+	})
+
+	t.Run("EmptyQueue", func(t *testing.T) {
+		_, _, ackID := q.DequeueWithAckId()
+		if err := q.RequeueFront(ackID); err != nil {
+			t.Fatalf("RequeueFront failed: %v", err)
+		}
+		if item, _ := q.Dequeue(); string(item.([]byte)) != "third" {
+			t.Errorf("Expected the requeued item, got %v", item)
+		}
+	})
+
+	t.Run("UnknownAckID", func(t *testing.T) {
+		if err := q.RequeueFront("unknown"); !errors.Is(err, ErrAckNotFound) {
+			t.Errorf("Expected ErrAckNotFound, got %v", err)
+		}
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority_queue", WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		pq.Enqueue([]byte("low"), 5)
+		clock.Advance(time.Second)
+		pq.Enqueue([]byte("urgent 1"), 1)
+		clock.Advance(time.Second)
+		pq.Enqueue([]byte("urgent 2"), 1)
+
+		_, _, first := pq.DequeueWithAckId()
+		_, _, second := pq.DequeueWithAckId()
+		pq.Nack(first)
+		if err := pq.RequeueFront(second); err != nil {
+			t.Fatalf("RequeueFront failed: %v", err)
+		}
+
+		// The item goes ahead of its own priority, lower priorities still come after it
+		for _, want := range []string{"urgent 2", "urgent 1", "low"} {
+			if item, _ := pq.Dequeue(); string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestRequeueStats(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_requeue_stats.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath, WithMaintenanceInterval(MaintenanceLeaseExpiry, 0))
+	defer queues.Close()
+
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q, err := queues.NewQueue("test_queue", WithProcessingTimeout(time.Minute, TimeoutRequeue), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		stats, err := q.RequeueStats()
+		if err != nil {
+			t.Fatalf("RequeueStats failed: %v", err)
+		}
+		if stats != (RequeueStats{}) {
+			t.Errorf("Expected no requeues, got %+v", stats)
+		}
+	})
+
+	t.Run("ByCause", func(t *testing.T) {
+		q.Enqueue([]byte("a"))
+		q.Enqueue([]byte("b"))
+
+		// Nacked twice
+		for i := 0; i < 2; i++ {
+			_, _, ackID := q.DequeueWithAckId()
+			q.Nack(ackID)
+		}
+
+		// Both leases expire
+		q.DequeueWithAckId()
+		q.DequeueWithAckId()
+		clock.Advance(2 * time.Minute)
+		if _, err := q.ApplyTimeouts(); err != nil {
+			t.Fatalf("ApplyTimeouts failed: %v", err)
+		}
+
+		// A consumer crashes holding a lease, and the queue is opened again
+		q.DequeueWithAckId()
+		reopened, err := queues.NewQueue("test_queue", WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to reopen queue: %v", err)
+		}
+
+		stats, err := reopened.RequeueStats()
+		if err != nil {
+			t.Fatalf("RequeueStats failed: %v", err)
+		}
+		want := RequeueStats{Nack: 2, LeaseExpiry: 2, CrashRecovery: 1}
+		if stats != want {
+			t.Errorf("Expected %+v, got %+v", want, stats)
+		}
+		if stats.Total() != 5 {
+			t.Errorf("Expected 5 requeues in total, got %d", stats.Total())
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package duckq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	duckdb "github.com/marcboeker/go-duckdb/v2"
+)
+
+// RetryPolicy controls how queue operations are retried after transient DuckDB errors
+// such as write-write conflicts, lock contention and interrupted checkpoints
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one
+	// Values below 2 disable retries
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+	// InitialBackoff is the delay before the first retry, doubled for every retry after it
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff"`
+}
+
+// DefaultRetryPolicy is used by queues created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 5 * time.Millisecond,
+	MaxBackoff:     250 * time.Millisecond,
+}
+
+// NoRetry surfaces every error on the first attempt
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// backoff returns the jittered delay before the given retry, starting at 1
+// Half of the delay is fixed and the other half random, so competing consumers spread out
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// do runs fn until it succeeds, fails with a non-transient error or runs out of attempts
+func (p RetryPolicy) do(fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt < p.MaxAttempts && isTransient(err); attempt++ {
+		time.Sleep(p.backoff(attempt))
+		err = fn()
+	}
+	return err
+}
+
+// isTransient reports whether err is a DuckDB error that may succeed when retried
+func isTransient(err error) bool {
+	var duckErr *duckdb.Error
+	if !errors.As(err, &duckErr) {
+		return false
+	}
+
+	switch duckErr.Type {
+	case duckdb.ErrorTypeTransaction, duckdb.ErrorTypeInterrupt:
+		return true
+	case duckdb.ErrorTypeIO:
+		return strings.Contains(duckErr.Msg, "lock")
+	}
+
+	return false
+}
+
+// isDuplicateKey reports whether err is a DuckDB primary key or unique constraint violation
+func isDuplicateKey(err error) bool {
+	var duckErr *duckdb.Error
+	return errors.As(err, &duckErr) && duckErr.Type == duckdb.ErrorTypeConstraint &&
+		strings.Contains(duckErr.Msg, "Duplicate key")
+}
+
+// retryPolicyFor returns the retry policy of the queue making the most attempts, so a transaction
+// spanning the queues is retried at least as often as each of them retries its own
+// Returns DefaultRetryPolicy when there are no queues
+func retryPolicyFor(queues []*Queue) RetryPolicy {
+	if len(queues) == 0 {
+		return DefaultRetryPolicy
+	}
+
+	policy := queues[0].retryPolicy
+	for _, queue := range queues[1:] {
+		if queue.retryPolicy.MaxAttempts > policy.MaxAttempts {
+			policy = queue.retryPolicy
+		}
+	}
+	return policy
+}
+
+// retry runs fn with the queue's retry policy
+func (q *Queue) retry(fn func() error) error {
+	return q.retryPolicy.do(fn)
+}
+
+// retryReinsert runs fn, which inserts rows back into the queue under the IDs they had before they
+// were moved out, with the queue's retry policy
+// DuckDB keeps reporting a deleted ID as a duplicate key while transactions that started before
+// the delete are open, so those errors are retried as well
+func (q *Queue) retryReinsert(fn func() error) error {
+	err := q.retry(fn)
+	for attempt := 1; attempt < q.retryPolicy.MaxAttempts && isDuplicateKey(err); attempt++ {
+		time.Sleep(q.retryPolicy.backoff(attempt))
+		err = q.retry(fn)
+	}
+	return err
+}
+
+// requireRows returns ErrAckNotFound when a statement keyed by ack ID affected no rows
+func requireRows(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAckNotFound
+	}
+	return nil
+}
+
+// inTx runs fn in a new transaction, committing if it succeeds and rolling back otherwise
+func (q *Queue) inTx(fn func(tx *sql.Tx) error) error {
+	return inTrackedTx(context.Background(), q.client, func(t *trackedTx) error {
+		t.track(q)
+		return fn(t.tx)
+	})
+}
+
+// inTrackedTx runs fn in a new transaction of db, committing if it succeeds and rolling back otherwise
+// The count changes and commit hooks recorded against the queues fn tracks are applied once it commits,
+// so operations spanning several queues update their counters and wake their consumers like inTx
+func inTrackedTx(ctx context.Context, db *sql.DB, fn func(t *trackedTx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	t := &trackedTx{tx: tx}
+	defer t.release()
+
+	if err := fn(t); err != nil {
+		tx.Rollback()
+		t.discard()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.discard()
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	t.apply()
+	return nil
+}
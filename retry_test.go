@@ -0,0 +1,95 @@
+package duckq
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	duckdb "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	conflict := &duckdb.Error{Type: duckdb.ErrorTypeTransaction, Msg: "TransactionContext Error: Conflict on tuple deletion!"}
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("IsTransient", func(t *testing.T) {
+		tests := []struct {
+			name string
+			err  error
+			want bool
+		}{
+			{"Conflict", conflict, true},
+			{"WrappedConflict", fmt.Errorf("failed to dequeue item: %w", conflict), true},
+			{"Interrupted", &duckdb.Error{Type: duckdb.ErrorTypeInterrupt, Msg: "INTERRUPT Error: Interrupted!"}, true},
+			{"FileLock", &duckdb.Error{Type: duckdb.ErrorTypeIO, Msg: "IO Error: Could not set lock on file"}, true},
+			{"OtherIO", &duckdb.Error{Type: duckdb.ErrorTypeIO, Msg: "IO Error: No space left on device"}, false},
+			{"Constraint", &duckdb.Error{Type: duckdb.ErrorTypeConstraint, Msg: "Constraint Error: duplicate key"}, false},
+			{"Sentinel", ErrEmptyQueue, false},
+			{"Nil", nil, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := isTransient(tt.err); got != tt.want {
+					t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("RetriesTransientErrors", func(t *testing.T) {
+		attempts := 0
+		err := policy.do(func() error {
+			attempts++
+			if attempts < 3 {
+				return conflict
+			}
+			return nil
+		})
+		if err != nil || attempts != 3 {
+			t.Errorf("Expected success on attempt 3, got %v after %d attempts", err, attempts)
+		}
+	})
+
+	t.Run("SurfacesPersistentErrors", func(t *testing.T) {
+		attempts := 0
+		err := policy.do(func() error {
+			attempts++
+			return conflict
+		})
+		if !errors.Is(err, conflict) || attempts != 3 {
+			t.Errorf("Expected the conflict after 3 attempts, got %v after %d attempts", err, attempts)
+		}
+	})
+
+	t.Run("DoesNotRetryOtherErrors", func(t *testing.T) {
+		attempts := 0
+		err := policy.do(func() error {
+			attempts++
+			return ErrAckNotFound
+		})
+		if !errors.Is(err, ErrAckNotFound) || attempts != 1 {
+			t.Errorf("Expected ErrAckNotFound after 1 attempt, got %v after %d attempts", err, attempts)
+		}
+
+		attempts = 0
+		NoRetry.do(func() error {
+			attempts++
+			return conflict
+		})
+		if attempts != 1 {
+			t.Errorf("Expected NoRetry to make 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+		for retry, want := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 10: 40 * time.Millisecond} {
+			got := p.backoff(retry)
+			if got < want/2 || got > want {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", retry, got, want/2, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package duckq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Handler processes a dequeued item
+// Returning nil acknowledges the item, returning an error nacks it so it is delivered again
+// The context is cancelled when Run gives up waiting for the handler during shutdown
+type Handler func(ctx context.Context, item any) error
+
+// Dequeuer is the part of a queue Run consumes from, satisfied by *Queue, *PriorityQueue, *DelayedQueue
+// and the in-memory queue in the duckqtest package
+type Dequeuer interface {
+	DequeueWithAckId() (any, bool, string)
+	Acknowledge(ackID string) bool
+	Nack(ackID string) bool
+}
+
+// RunOption is a function type that can be used to configure Run
+type RunOption func(*runner)
+
+// runner holds the settings of a Run call
+type runner struct {
+	concurrency     int
+	pollInterval    time.Duration
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+	middleware      []Middleware
+}
+
+// WithConcurrency sets how many items Run handles at the same time, defaults to 1
+func WithConcurrency(n int) RunOption {
+	return func(r *runner) {
+		r.concurrency = n
+	}
+}
+
+// WithPollInterval sets how long Run waits before polling an empty queue again, defaults to 100ms
+func WithPollInterval(interval time.Duration) RunOption {
+	return func(r *runner) {
+		r.pollInterval = interval
+	}
+}
+
+// WithShutdownTimeout sets how long Run waits for in-flight handlers after it is asked to stop
+// Defaults to 30 seconds
+func WithShutdownTimeout(timeout time.Duration) RunOption {
+	return func(r *runner) {
+		r.shutdownTimeout = timeout
+	}
+}
+
+// WithSignals sets the signals that stop Run, defaults to SIGINT and SIGTERM
+// Passing no signals makes Run stop only when its context is done
+func WithSignals(signals ...os.Signal) RunOption {
+	return func(r *runner) {
+		r.signals = signals
+	}
+}
+
+// wakeupSource is implemented by queues that signal new items, see WithWakeupSignals
+type wakeupSource interface {
+	Wakeup() <-chan struct{}
+}
+
+// failureRecorder is implemented by queues that track handler failures, see RecordFailure
+type failureRecorder interface {
+	RecordFailure(ackID string, failure HandlerFailure) (bool, error)
+}
+
+// Run consumes queue with handler until ctx is done or the process receives SIGINT or SIGTERM
+// Shutting down stops dequeuing and waits for in-flight handlers. Items whose handlers don't return
+// within the shutdown timeout are nacked, and Run returns ErrShutdownTimeout
+// Failed items of queues created with WithQuarantine are reported with RecordFailure instead of nacked
+// Empty queues created with WithWakeupSignals are polled again as soon as items arrive
+// Returns nil after a graceful shutdown
+func Run(ctx context.Context, queue Dequeuer, handler Handler, opts ...RunOption) error {
+	r := runner{
+		concurrency:     1,
+		pollInterval:    100 * time.Millisecond,
+		shutdownTimeout: 30 * time.Second,
+		signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	if r.concurrency < 1 {
+		return fmt.Errorf("%w: concurrency %d is below 1", ErrInvalidOption, r.concurrency)
+	}
+	handler = Chain(handler, r.middleware...)
+
+	stopCtx, stop := ctx, func() {}
+	if len(r.signals) > 0 {
+		stopCtx, stop = signal.NotifyContext(ctx, r.signals...)
+	}
+	defer stop()
+
+	// Handlers keep running after the stop request until the shutdown timeout
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	var inFlight sync.Map
+	var handlers sync.WaitGroup
+	slots := make(chan struct{}, r.concurrency)
+
+	for stopCtx.Err() == nil {
+		select {
+		case <-stopCtx.Done():
+			continue
+		case slots <- struct{}{}:
+		}
+
+		// Take the wake-up channel before dequeuing, so an enqueue right after an empty poll isn't missed
+		var wake <-chan struct{}
+		if source, ok := queue.(wakeupSource); ok {
+			wake = source.Wakeup()
+		}
+
+		item, success, ackID := queue.DequeueWithAckId()
+		if !success {
+			<-slots
+			select {
+			case <-stopCtx.Done():
+			case <-wake:
+			case <-time.After(r.pollInterval):
+			}
+			continue
+		}
+
+		inFlight.Store(ackID, struct{}{})
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			err := handler(handlerCtx, item)
+
+			// The item belongs to whoever removes it from inFlight first, this handler or the shutdown
+			if _, owned := inFlight.LoadAndDelete(ackID); !owned {
+				return
+			}
+			if recorder, ok := queue.(failureRecorder); ok && err != nil {
+				recorder.RecordFailure(ackID, failureFromError(err, time.Since(start)))
+			} else if err != nil {
+				queue.Nack(ackID)
+			} else {
+				queue.Acknowledge(ackID)
+			}
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		handlers.Wait()
+		close(finished)
+	}()
+
+	timer := time.NewTimer(r.shutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-finished:
+		return nil
+	case <-timer.C:
+	}
+
+	cancelHandlers()
+
+	var unfinished int
+	inFlight.Range(func(ackID, _ any) bool {
+		if _, owned := inFlight.LoadAndDelete(ackID); owned {
+			queue.Nack(ackID.(string))
+			unfinished++
+		}
+		return true
+	})
+	if unfinished == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: nacked %d unfinished items", ErrShutdownTimeout, unfinished)
+}
@@ -0,0 +1,170 @@
+package duckq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestRun(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_run.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("AckAndNack", func(t *testing.T) {
+		q, err := queues.NewQueue("test_run_queue", WithRemoveOnComplete(false))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("ok"))
+		q.Enqueue([]byte("fail"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var handled atomic.Int32
+		handler := func(ctx context.Context, item any) error {
+			defer handled.Add(1)
+			if string(item.([]byte)) == "fail" {
+				cancel()
+				return errors.New("rejected")
+			}
+			return nil
+		}
+
+		if err := Run(ctx, q, handler, WithPollInterval(time.Millisecond), WithSignals()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if handled.Load() != 2 {
+			t.Errorf("Expected 2 handled items, got %d", handled.Load())
+		}
+		completed, _ := q.Messages(Filter{Statuses: []string{"completed"}})
+		if len(completed) != 1 || string(completed[0].Data) != "ok" {
+			t.Errorf("Expected 'ok' to be acknowledged, got %+v", completed)
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the failed item to be nacked, got length %d", q.Len())
+		}
+	})
+
+	t.Run("ShutdownTimeout", func(t *testing.T) {
+		q, err := queues.NewQueue("test_run_timeout_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("slow"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		handler := func(handlerCtx context.Context, item any) error {
+			cancel()
+			<-handlerCtx.Done()
+			return nil
+		}
+
+		err = Run(ctx, q, handler, WithShutdownTimeout(20*time.Millisecond), WithSignals())
+		if !errors.Is(err, ErrShutdownTimeout) {
+			t.Fatalf("Expected ErrShutdownTimeout, got %v", err)
+		}
+
+		// Give the abandoned handler time to return, its acknowledgement must not win
+		time.Sleep(10 * time.Millisecond)
+		if q.Len() != 1 {
+			t.Errorf("Expected the unfinished item to be nacked, got length %d", q.Len())
+		}
+	})
+
+	t.Run("InvalidConcurrency", func(t *testing.T) {
+		err := Run(context.Background(), nil, nil, WithConcurrency(0))
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_middleware.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("Chain", func(t *testing.T) {
+		var order []string
+		trace := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return func(ctx context.Context, item any) error {
+					order = append(order, name)
+					return next(ctx, item)
+				}
+			}
+		}
+
+		handler := Chain(func(ctx context.Context, item any) error {
+			order = append(order, "handler")
+			return nil
+		}, trace("outer"), trace("inner"))
+		handler(context.Background(), nil)
+
+		if len(order) != 3 || order[0] != "outer" || order[1] != "inner" || order[2] != "handler" {
+			t.Errorf("Unexpected middleware order %v", order)
+		}
+	})
+
+	t.Run("RecoverNacks", func(t *testing.T) {
+		q, err := queues.NewQueue("test_recover_queue")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("boom"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var observed error
+		handler := func(ctx context.Context, item any) error {
+			cancel()
+			panic("unexpected payload")
+		}
+
+		err = Run(ctx, q, handler,
+			WithSignals(),
+			WithMiddleware(Metrics(func(d time.Duration, err error) { observed = err }), Recover()),
+		)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if !errors.Is(observed, ErrHandlerPanic) {
+			t.Errorf("Expected the panic to surface as ErrHandlerPanic, got %v", observed)
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the panicking item to be nacked, got length %d", q.Len())
+		}
+	})
+
+	t.Run("LoggingWithPayloads", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+		mask := func(payload []byte) []byte { return bytes.ReplaceAll(payload, []byte("4242"), []byte("****")) }
+
+		handler := Chain(func(ctx context.Context, item any) error {
+			return errors.New("declined")
+		}, LoggingWithPayloads(logger, mask))
+		handler(context.Background(), []byte("card 4242"))
+
+		if !strings.Contains(logs.String(), "payload=\"card ****\"") || strings.Contains(logs.String(), "4242") {
+			t.Errorf("Expected the redacted payload to be logged, got %q", logs.String())
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx so schema helpers can run inside a transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// rowsQuerier is implemented by both *sql.DB and *sql.Tx
+type rowsQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// execQuerier is implemented by both *sql.DB and *sql.Tx so schema helpers can inspect tables before changing them
+type execQuerier interface {
+	execer
+	querier
+	rowsQuerier
+}
+
+// tableExists reports whether a table with the given name exists in the current database
+func tableExists(db querier, tableName string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM duckdb_tables() WHERE table_name = ? AND schema_name = current_schema() AND database_name = current_database()",
+		tableName,
+	).Scan(&count)
+
+	return count > 0, err
+}
+
+// tableHasColumn reports whether the given table has a column with the given name
+func tableHasColumn(db querier, tableName, columnName string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM duckdb_columns() WHERE table_name = ? AND column_name = ? AND schema_name = current_schema() AND database_name = current_database()",
+		tableName, columnName,
+	).Scan(&count)
+
+	return count > 0, err
+}
+
+// tableColumnType returns the DuckDB type of a column, e.g. "BLOB" or "JSON"
+func tableColumnType(db querier, tableName, columnName string) (string, error) {
+	var dataType string
+	err := db.QueryRow(
+		"SELECT data_type FROM duckdb_columns() WHERE table_name = ? AND column_name = ? AND schema_name = current_schema() AND database_name = current_database()",
+		tableName, columnName,
+	).Scan(&dataType)
+
+	return dataType, err
+}
+
+// tableColumn is the name and DuckDB type of a column, see tableColumns
+type tableColumn struct {
+	name     string
+	dataType string
+}
+
+// tableColumns returns the columns of the given table in the order they were defined
+func tableColumns(db rowsQuerier, tableName string) ([]tableColumn, error) {
+	rows, err := db.Query(
+		"SELECT column_name, data_type FROM duckdb_columns() WHERE table_name = ? AND schema_name = current_schema() AND database_name = current_database() ORDER BY column_index",
+		tableName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var column tableColumn
+		if err := rows.Scan(&column.name, &column.dataType); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// columnNames returns the comma separated names of the columns defined in columns, see upgradeTable
+func columnNames(columns string) string {
+	var names []string
+	for _, definition := range strings.Split(columns, ",") {
+		names = append(names, strings.Fields(definition)[0])
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// upgradeTable adds the columns defined in columns that an existing table created by an older
+// version of the library lacks, so upgrading doesn't require dropping queues
+// columns is a list of column definitions like queueColumnsSQL; constraints can't be added to
+// existing tables, so only the type and default of each column are kept
+// Tables that don't exist yet are left alone
+func upgradeTable(db execQuerier, tableName, columns string) error {
+	rows, err := db.Query(
+		"SELECT column_name FROM duckdb_columns() WHERE table_name = ? AND schema_name = current_schema() AND database_name = current_database()",
+		tableName,
+	)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(existing) == 0 {
+		return err
+	}
+
+	for _, definition := range strings.Split(columns, ",") {
+		fields := strings.Fields(definition)
+		if existing[fields[0]] {
+			continue
+		}
+
+		column := fields[0] + " " + fields[1]
+		for i, field := range fields {
+			if field == "DEFAULT" && i+1 < len(fields) {
+				column += " DEFAULT " + fields[i+1]
+			}
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, column)); err != nil {
+			return fmt.Errorf("failed to add column %s to %s: %w", fields[0], tableName, err)
+		}
+	}
+
+	return nil
+}
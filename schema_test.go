@@ -0,0 +1,108 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestLegacySchemaUpgrade(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_legacy_schema.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	// Tables as the first releases created them
+	_, err := queues.DB().Exec(`
+	CREATE SEQUENCE test_queue_id_seq START 1;
+	CREATE TABLE test_queue (
+		id INTEGER PRIMARY KEY DEFAULT nextval('test_queue_id_seq'),
+		data BLOB NOT NULL,
+		status TEXT NOT NULL,
+		ack_id TEXT UNIQUE,
+		ack BOOLEAN DEFAULT 0,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP
+	);
+	CREATE TABLE test_queue_dead_letters (
+		id INTEGER PRIMARY KEY,
+		data BLOB NOT NULL,
+		reason TEXT,
+		dead_lettered_at TIMESTAMP
+	);
+	CREATE SEQUENCE test_priority_id_seq START 1;
+	CREATE TABLE test_priority (
+		id INTEGER PRIMARY KEY DEFAULT nextval('test_priority_id_seq'),
+		data BLOB NOT NULL,
+		status TEXT NOT NULL,
+		ack_id TEXT UNIQUE,
+		ack BOOLEAN DEFAULT 0,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP
+	);
+	INSERT INTO test_queue (data, status, created_at) VALUES ('legacy'::BLOB, 'pending', now());
+	INSERT INTO test_priority (data, status, created_at) VALUES ('legacy'::BLOB, 'pending', now());
+	INSERT INTO test_queue_dead_letters VALUES (7, 'dead'::BLOB, 'legacy', now());
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy tables: %v", err)
+	}
+
+	t.Run("Queue", func(t *testing.T) {
+		q, err := queues.NewQueue("test_queue", WithMaxAttempts(1))
+		if err != nil {
+			t.Fatalf("Failed to open legacy queue: %v", err)
+		}
+
+		for _, column := range []string{"attempts", "visible_at", "deadline", "message_key", "signature"} {
+			if ok, _ := tableHasColumn(queues.DB(), "test_queue", column); !ok {
+				t.Errorf("Expected column %s to be added", column)
+			}
+		}
+
+		item, success, ackID := q.DequeueWithAckId()
+		if !success || string(item.([]byte)) != "legacy" {
+			t.Fatalf("Expected the legacy item, got %v", item)
+		}
+
+		// Dead-lettering needs the columns added to both tables
+		if !q.Nack(ackID) {
+			t.Fatal("Expected the exhausted item to be dead-lettered")
+		}
+		var attempts int
+		if err := queues.DB().QueryRow("SELECT attempts FROM test_queue_dead_letters WHERE id <> 7").Scan(&attempts); err != nil || attempts != 1 {
+			t.Errorf("Expected a dead letter with 1 attempt, got %d (%v)", attempts, err)
+		}
+		// Rows of the table keyed by id belong to the queue after the upgrade
+		if replayed, err := q.ReplayDeadLetters(0, false); err != nil || replayed != 2 {
+			t.Errorf("Expected both dead letters to be replayed, got %d (%v)", replayed, err)
+		}
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		pq, err := queues.NewPriorityQueue("test_priority")
+		if err != nil {
+			t.Fatalf("Failed to open legacy priority queue: %v", err)
+		}
+		pq.Enqueue([]byte("urgent"), -1)
+
+		item, _ := pq.Dequeue()
+		if string(item.([]byte)) != "urgent" {
+			t.Errorf("Expected the urgent item first, got %v", item)
+		}
+		item, _ = pq.Dequeue()
+		if string(item.([]byte)) != "legacy" {
+			t.Errorf("Expected the legacy item at the default priority, got %v", item)
+		}
+	})
+
+	t.Run("Idempotent", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_queue"); err != nil {
+			t.Errorf("Expected reopening the upgraded queue to succeed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package duckq
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// utf8HexPattern matches the hex encoding of a valid UTF-8 byte sequence
+// DuckDB's decode() raises an error on invalid UTF-8, so payloads are checked
+// against this pattern before they are handed to the JSON functions
+const utf8HexPattern = `^(?:[0-7][0-9A-F]|(?:C[2-9A-F]|D[0-9A-F])[89AB][0-9A-F]|E0[AB][0-9A-F][89AB][0-9A-F]|E[1-9A-CEF](?:[89AB][0-9A-F]){2}|ED[89][0-9A-F][89AB][0-9A-F]|F0[9AB][0-9A-F](?:[89AB][0-9A-F]){2}|F[1-3](?:[89AB][0-9A-F]){3}|F48[0-9A-F](?:[89AB][0-9A-F]){2})*$`
+
+// jsonPayloadExpr is a SQL expression that yields the payload as JSON text,
+// or NULL when the payload is not valid UTF-8 encoded JSON
+const jsonPayloadExpr = `CASE WHEN regexp_full_match(hex(data), '` + utf8HexPattern + `') THEN CASE WHEN json_valid(decode(data)) THEN decode(data) END END`
+
+// Search returns the messages whose JSON payload has value at jsonPath
+// jsonPath uses DuckDB's JSON path syntax, e.g. "$.customer_id"
+// The value is compared as JSON, so the number 42 and the string "42" are distinct
+// Payloads that are not valid JSON never match
+// Queues created with WithJSONPayload query the JSON column directly
+// Messages of every status are searched, oldest first
+func (q *Queue) Search(jsonPath string, value any, page Page) ([]Message, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search value: %w", err)
+	}
+
+	messages, err := q.queryMessages(
+		fmt.Sprintf(
+			"SELECT %s FROM %s WHERE json_extract(%s, ?) = json(?) ORDER BY created_at ASC, id ASC%s",
+			q.messageColumns(), q.tableName, q.jsonPayloadSQL(), page.sql(),
+		),
+		jsonPath, string(encoded),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search queue: %w", err)
+	}
+
+	return messages, nil
+}
+
+// jsonPayloadSQL returns a SQL expression that yields the payload as JSON
+func (q *Queue) jsonPayloadSQL() string {
+	if q.jsonPayload {
+		return "data"
+	}
+
+	return jsonPayloadExpr
+}
@@ -0,0 +1,78 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestSearch(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_search.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queues.Close()
+
+	q.Enqueue([]byte(`{"customer_id": 42, "order": 1}`))
+	q.Enqueue([]byte(`{"customer_id": 7, "order": 2}`))
+	q.Enqueue([]byte(`{"customer_id": 42, "order": 3}`))
+	q.Enqueue([]byte(`{"customer_id": "42", "order": 4}`))
+	q.Enqueue([]byte("not json"))
+	q.Enqueue([]byte{0xff, 0x00, 0xfe})
+
+	t.Run("MatchesJSONValue", func(t *testing.T) {
+		messages, err := q.Search("$.customer_id", 42, Page{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(messages) != 2 {
+			t.Fatalf("Expected 2 messages, got %d", len(messages))
+		}
+		if string(messages[0].Data) != `{"customer_id": 42, "order": 1}` {
+			t.Errorf("Unexpected first message: %s", string(messages[0].Data))
+		}
+		if messages[0].Status != "pending" {
+			t.Errorf("Expected status 'pending', got '%s'", messages[0].Status)
+		}
+	})
+
+	t.Run("DistinguishesStringFromNumber", func(t *testing.T) {
+		messages, err := q.Search("$.customer_id", "42", Page{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(messages) != 1 {
+			t.Errorf("Expected 1 message, got %d", len(messages))
+		}
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		messages, err := q.Search("$.customer_id", 42, Page{Limit: 1, Offset: 1})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(messages))
+		}
+		if string(messages[0].Data) != `{"customer_id": 42, "order": 3}` {
+			t.Errorf("Unexpected message: %s", string(messages[0].Data))
+		}
+	})
+
+	t.Run("NoMatches", func(t *testing.T) {
+		messages, err := q.Search("$.customer_id", 1000, Page{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(messages) != 0 {
+			t.Errorf("Expected 0 messages, got %d", len(messages))
+		}
+	})
+}
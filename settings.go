@@ -0,0 +1,43 @@
+package duckq
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// DuckDBSettings limits the resources DuckDB uses for the database opened by New, so queues
+// embedded in a bigger application don't compete with it for all of its memory and cores
+// Zero values keep DuckDB's defaults, sizes use DuckDB's syntax such as "512MB" or "2GB"
+type DuckDBSettings struct {
+	// MemoryLimit caps the memory DuckDB uses before spilling to the temp directory
+	MemoryLimit string `yaml:"memory_limit" json:"memory_limit" env:"MEMORY_LIMIT"`
+	// Threads is how many threads DuckDB runs queries on
+	Threads int `yaml:"threads" json:"threads" env:"THREADS"`
+	// TempDirectory is where DuckDB spills data that doesn't fit in memory
+	TempDirectory string `yaml:"temp_directory" json:"temp_directory" env:"TEMP_DIRECTORY"`
+	// MaxTempDirectorySize caps the disk space used in the temp directory
+	MaxTempDirectorySize string `yaml:"max_temp_directory_size" json:"max_temp_directory_size" env:"MAX_TEMP_DIRECTORY_SIZE"`
+}
+
+// WithDuckDBSettings applies the resource limits to every connection of the manager's database
+func WithDuckDBSettings(settings DuckDBSettings) QueuesOption {
+	return func(q *queues) {
+		q.duckDBSettings = settings
+	}
+}
+
+// addTo adds the configured settings to the parameters of the connection string
+func (s DuckDBSettings) addTo(params url.Values) {
+	if s.MemoryLimit != "" {
+		params.Set("memory_limit", s.MemoryLimit)
+	}
+	if s.Threads != 0 {
+		params.Set("threads", strconv.Itoa(s.Threads))
+	}
+	if s.TempDirectory != "" {
+		params.Set("temp_directory", s.TempDirectory)
+	}
+	if s.MaxTempDirectorySize != "" {
+		params.Set("max_temp_directory_size", s.MaxTempDirectorySize)
+	}
+}
@@ -0,0 +1,173 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedQueue spreads its items across several underlying queue tables, so parallel writers and
+// bulk loads don't contend for a single table
+// Items are enqueued round-robin and dequeued by visiting the shards in turn, so order is only
+// kept within a shard, not across the whole queue
+type ShardedQueue struct {
+	name   string
+	shards []*Queue
+	next   atomic.Uint64
+	turn   atomic.Uint64
+}
+
+// shardTableName returns the name of the table holding the given shard of a sharded queue
+func shardTableName(name string, shard int) string {
+	return fmt.Sprintf("%s_shard_%d", name, shard)
+}
+
+// NewShardedQueue creates the sharded queue with the given name and number of shards, or opens it
+// Each shard is a regular queue named "<name>_shard_<i>" created with opts
+// Reopening a sharded queue with fewer shards leaves the items of the extra shards undelivered
+func (q *queues) NewShardedQueue(name string, shards int, opts ...Option) (*ShardedQueue, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("%w: sharded queue %s needs at least one shard, got %d", ErrInvalidOption, name, shards)
+	}
+
+	sq := &ShardedQueue{name: name, shards: make([]*Queue, shards)}
+	for i := range sq.shards {
+		shard, err := q.NewQueue(shardTableName(name, i), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open shard %d: %w", i, err)
+		}
+		sq.shards[i] = shard
+	}
+
+	return sq, nil
+}
+
+// Name returns the sharded queue's name
+func (sq *ShardedQueue) Name() string {
+	return sq.name
+}
+
+// Shards returns the queues holding the shards, e.g. to inspect or consume a single shard
+func (sq *ShardedQueue) Shards() []*Queue {
+	return sq.shards
+}
+
+// Enqueue adds an item to the next shard in turn
+// Returns true if the operation was successful
+func (sq *ShardedQueue) Enqueue(item any) bool {
+	return sq.shards[sq.next.Add(1)%uint64(len(sq.shards))].Enqueue(item)
+}
+
+// EnqueueBatch spreads items across the shards and enqueues each shard's part in its own
+// transaction, with the shards written in parallel
+// Every shard's part is stored or not on its own, the returned error joins the failed ones
+func (sq *ShardedQueue) EnqueueBatch(items []any) error {
+	parts := make([][]any, len(sq.shards))
+	for _, item := range items {
+		shard := sq.next.Add(1) % uint64(len(sq.shards))
+		parts[shard] = append(parts[shard], item)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(sq.shards))
+	for i, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			shard := sq.shards[i]
+			errs[i] = shard.retryEnqueue(len(part), func() error {
+				return shard.inTx(func(tx *sql.Tx) error {
+					for _, item := range part {
+						if _, err := shard.enqueueInTx(tx, item, sql.NullTime{}); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+			})
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("failed to enqueue into shard %d: %w", i, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Dequeue removes and returns the next item of the first shard in turn that has one
+func (sq *ShardedQueue) Dequeue() (any, bool) {
+	item, success, _ := sq.dequeue(false)
+	return item, success
+}
+
+// DequeueWithAckId leases the next item of the first shard in turn that has one
+// The returned ack ID names the shard, so it must be settled through the sharded queue
+func (sq *ShardedQueue) DequeueWithAckId() (any, bool, string) {
+	return sq.dequeue(true)
+}
+
+// dequeue visits every shard once, starting after the one visited first by the previous call
+func (sq *ShardedQueue) dequeue(withAckId bool) (any, bool, string) {
+	start := sq.turn.Add(1)
+	for i := range uint64(len(sq.shards)) {
+		shard := int((start + i) % uint64(len(sq.shards)))
+		item, success, ackID := sq.shards[shard].dequeueInternal(withAckId)
+		if success {
+			if withAckId {
+				ackID = strconv.Itoa(shard) + ":" + ackID
+			}
+			return item, true, ackID
+		}
+	}
+
+	return nil, false, ""
+}
+
+// Acknowledge marks an item leased by DequeueWithAckId as completed
+// Returns true if the item was successfully acknowledged, false otherwise
+func (sq *ShardedQueue) Acknowledge(ackID string) bool {
+	shard, ackID, ok := sq.shardOf(ackID)
+	return ok && shard.Acknowledge(ackID)
+}
+
+// Nack returns an item leased by DequeueWithAckId to its shard so it can be dequeued again
+// Returns true if the item was returned to the queue or dead-lettered, false otherwise
+func (sq *ShardedQueue) Nack(ackID string) bool {
+	shard, ackID, ok := sq.shardOf(ackID)
+	return ok && shard.Nack(ackID)
+}
+
+// shardOf splits an ack ID returned by DequeueWithAckId into its shard and the shard's ack ID
+func (sq *ShardedQueue) shardOf(ackID string) (*Queue, string, bool) {
+	prefix, shardAckID, found := strings.Cut(ackID, ":")
+	if !found {
+		return nil, "", false
+	}
+
+	shard, err := strconv.Atoi(prefix)
+	if err != nil || shard < 0 || shard >= len(sq.shards) {
+		return nil, "", false
+	}
+
+	return sq.shards[shard], shardAckID, true
+}
+
+// Len returns the number of pending items across every shard
+func (sq *ShardedQueue) Len() int {
+	var total int
+	for _, shard := range sq.shards {
+		total += shard.Len()
+	}
+
+	return total
+}
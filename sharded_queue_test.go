@@ -0,0 +1,109 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestShardedQueue(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_sharded_queue.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	sq, err := queues.NewShardedQueue("test_queue", 4)
+	if err != nil {
+		t.Fatalf("Failed to create sharded queue: %v", err)
+	}
+
+	t.Run("Spread", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			if !sq.Enqueue([]byte(fmt.Sprintf("item-%d", i))) {
+				t.Fatal("Enqueue failed")
+			}
+		}
+		if sq.Len() != 8 {
+			t.Errorf("Expected 8 pending items, got %d", sq.Len())
+		}
+		for i, shard := range sq.Shards() {
+			if shard.Len() != 2 {
+				t.Errorf("Expected 2 items in shard %d, got %d", i, shard.Len())
+			}
+		}
+
+		seen := make(map[string]bool)
+		for i := 0; i < 8; i++ {
+			item, success := sq.Dequeue()
+			if !success {
+				t.Fatal("Dequeue failed")
+			}
+			seen[string(item.([]byte))] = true
+		}
+		if len(seen) != 8 {
+			t.Errorf("Expected every item once, got %v", seen)
+		}
+		if _, success := sq.Dequeue(); success {
+			t.Error("Expected the sharded queue to be empty")
+		}
+	})
+
+	t.Run("BatchAndRun", func(t *testing.T) {
+		items := make([]any, 100)
+		for i := range items {
+			items[i] = []byte(fmt.Sprintf("bulk-%d", i))
+		}
+		if err := sq.EnqueueBatch(items); err != nil {
+			t.Fatalf("EnqueueBatch failed: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var handled atomic.Int32
+		go func() {
+			for sq.Len() > 0 {
+				time.Sleep(5 * time.Millisecond)
+			}
+			cancel()
+		}()
+		err := Run(ctx, sq, func(ctx context.Context, item any) error {
+			handled.Add(1)
+			return nil
+		}, WithConcurrency(4), WithPollInterval(time.Millisecond))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if handled.Load() != 100 {
+			t.Errorf("Expected 100 handled items, got %d", handled.Load())
+		}
+	})
+
+	t.Run("AckIDs", func(t *testing.T) {
+		sq.Enqueue([]byte("leased"))
+		_, _, ackID := sq.DequeueWithAckId()
+		if !sq.Nack(ackID) {
+			t.Error("Nack failed")
+		}
+		_, _, ackID = sq.DequeueWithAckId()
+		if !sq.Acknowledge(ackID) {
+			t.Error("Acknowledge failed")
+		}
+		if sq.Acknowledge("9:unknown") || sq.Acknowledge("unknown") {
+			t.Error("Expected malformed ack IDs to fail")
+		}
+	})
+
+	t.Run("InvalidShards", func(t *testing.T) {
+		if _, err := queues.NewShardedQueue("test_none", 0); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package duckq
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// payloadSignature returns the HMAC-SHA256 signature stored alongside a payload
+// It is NULL unless the queue is created with WithHMAC
+func (q *Queue) payloadSignature(data any) any {
+	if q.hmacKey == nil {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, q.hmacKey)
+	switch data := data.(type) {
+	case []byte:
+		mac.Write(data)
+	case string:
+		mac.Write([]byte(data))
+	}
+
+	return mac.Sum(nil)
+}
+
+// verifySignature returns ErrSignatureMismatch when a queue created with WithHMAC reads a payload
+// that doesn't match its signature, including a payload stored without one
+func (q *Queue) verifySignature(data, signature []byte) error {
+	if q.hmacKey == nil {
+		return nil
+	}
+
+	if !hmac.Equal(q.payloadSignature(data).([]byte), signature) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestHMAC(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_hmac.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	key := []byte("secret")
+	q, err := queues.NewQueue("test_queue", WithHMAC(key))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	deadLetterReasons := func() []string {
+		rows, err := queues.DB().Query("SELECT reason FROM test_queue_dead_letters ORDER BY id")
+		if err != nil {
+			t.Fatalf("Failed to read dead letters: %v", err)
+		}
+		defer rows.Close()
+
+		var reasons []string
+		for rows.Next() {
+			var reason string
+			rows.Scan(&reason)
+			reasons = append(reasons, reason)
+		}
+		return reasons
+	}
+
+	t.Run("Signed", func(t *testing.T) {
+		q.Enqueue([]byte("intact"))
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "intact" {
+			t.Errorf("Expected the signed payload, got %v", item)
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		id, err := q.EnqueueID([]byte("pay alice 10"))
+		if err != nil {
+			t.Fatalf("EnqueueID failed: %v", err)
+		}
+		q.Enqueue([]byte("next"))
+		if _, err := queues.DB().Exec("UPDATE test_queue SET data = 'pay mallory 1000'::BLOB WHERE id = ?", id); err != nil {
+			t.Fatalf("Failed to tamper with the payload: %v", err)
+		}
+
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "next" {
+			t.Errorf("Expected the tampered item to be skipped, got %v", item)
+		}
+		if reasons := deadLetterReasons(); len(reasons) != 1 || reasons[0] != ErrSignatureMismatch.Error() {
+			t.Errorf("Expected the tampered item to be dead-lettered, got %v", reasons)
+		}
+	})
+
+	t.Run("UnsignedOrOtherKey", func(t *testing.T) {
+		unsigned, err := queues.NewQueue("test_queue")
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		unsigned.Enqueue([]byte("unsigned"))
+		other, err := queues.NewQueue("test_queue", WithHMAC([]byte("other")))
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		other.Enqueue([]byte("other key"))
+
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected no item to pass verification")
+		}
+		if reasons := deadLetterReasons(); len(reasons) != 3 {
+			t.Errorf("Expected 3 dead letters, got %v", reasons)
+		}
+	})
+
+	t.Run("VerifyChecksums", func(t *testing.T) {
+		id, _ := q.EnqueueID([]byte("signed"))
+		if _, err := queues.DB().Exec("UPDATE test_queue SET data = 'forged'::BLOB WHERE id = ?", id); err != nil {
+			t.Fatalf("Failed to tamper with the payload: %v", err)
+		}
+		corrupted, err := q.VerifyChecksums()
+		if err != nil {
+			t.Fatalf("VerifyChecksums failed: %v", err)
+		}
+		if len(corrupted) != 1 || corrupted[0] != id {
+			t.Errorf("Expected message %d to be reported, got %v", id, corrupted)
+		}
+		q.Delete(id)
+	})
+
+	t.Run("ReplayKeepsSignature", func(t *testing.T) {
+		q.Enqueue([]byte("replayed"))
+		_, _, ackID := q.DequeueWithAckId()
+		q.DeadLetter(ackID, "manual")
+		if _, err := queues.DB().Exec("DELETE FROM test_queue_dead_letters WHERE reason <> 'manual'"); err != nil {
+			t.Fatalf("Failed to clear dead letters: %v", err)
+		}
+
+		if _, err := q.ReplayDeadLetters(0, true); err != nil {
+			t.Fatalf("ReplayDeadLetters failed: %v", err)
+		}
+		item, success := q.Dequeue()
+		if !success || string(item.([]byte)) != "replayed" {
+			t.Errorf("Expected the replayed item to keep its signature, got %v", item)
+		}
+	})
+
+	t.Run("EmptyKey", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_empty_key", WithHMAC(nil)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
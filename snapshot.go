@@ -0,0 +1,224 @@
+package duckq
+
+import (
+	"bytes"
+	"cmp"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// QueueStats counts a queue's items by status
+type QueueStats struct {
+	Pending      int64
+	Processing   int64
+	Completed    int64
+	Expired      int64
+	DeadLettered int64
+	// Quarantined is only counted on queues created with WithQuarantine
+	Quarantined int64
+}
+
+// Snapshot is a consistent view of a queue, every field read at the same point in time
+type Snapshot struct {
+	TakenAt  time.Time
+	Stats    QueueStats
+	Values   []any
+	Requeues RequeueStats
+	// Messages holds every message of the queue table, of every status, see Messages and DiffSnapshots
+	Messages []Message
+}
+
+// Len returns the number of pending items in the snapshot
+func (s Snapshot) Len() int {
+	return len(s.Values)
+}
+
+// Stats counts the queue's items by status in a single transaction, so the counts add up
+// even while other consumers move items between statuses
+func (q *Queue) Stats() (QueueStats, error) {
+	var stats QueueStats
+	err := q.readSnapshot(func(tx *sql.Tx) (err error) {
+		stats, err = q.stats(tx)
+		return err
+	})
+	if err != nil {
+		return QueueStats{}, fmt.Errorf("failed to read stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Snapshot reads the queue's stats, pending values, requeue counters and messages in a single transaction,
+// so they reflect one point in time instead of racing with concurrent writers
+// Calling Values, Len and Stats one after another can see different states of the queue
+func (q *Queue) Snapshot() (Snapshot, error) {
+	snapshot := Snapshot{TakenAt: q.now()}
+	err := q.readSnapshot(func(tx *sql.Tx) (err error) {
+		if snapshot.Stats, err = q.stats(tx); err != nil {
+			return err
+		}
+		if snapshot.Values, err = q.values(tx); err != nil {
+			return err
+		}
+		if snapshot.Requeues, err = q.requeueStats(tx); err != nil {
+			return err
+		}
+		snapshot.Messages, err = q.snapshotMessages(tx)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to take snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// readSnapshot runs fn in a transaction that is rolled back afterwards
+// DuckDB transactions read from a snapshot taken when they start
+func (q *Queue) readSnapshot(fn func(tx *sql.Tx) error) error {
+	tx, err := q.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(tx)
+}
+
+// stats counts the queue's items by status as part of tx
+func (q *Queue) stats(tx *sql.Tx) (QueueStats, error) {
+	var stats QueueStats
+	err := tx.QueryRow(fmt.Sprintf(`
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'processing'),
+			COUNT(*) FILTER (WHERE status = 'completed'),
+			COUNT(*) FILTER (WHERE status = 'expired'),
+			(SELECT COUNT(*) FROM %s WHERE source_queue = ?)
+		FROM %s`, q.deadLetterTable(), q.tableName),
+		q.tableName,
+	).Scan(&stats.Pending, &stats.Processing, &stats.Completed, &stats.Expired, &stats.DeadLettered)
+	if err != nil {
+		return QueueStats{}, err
+	}
+
+	if q.quarantine != nil {
+		err = tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", quarantineTableName(q.tableName))).Scan(&stats.Quarantined)
+	}
+
+	return stats, err
+}
+
+// snapshotMessages reads every message of the queue as part of tx, in ID order
+func (q *Queue) snapshotMessages(tx *sql.Tx) ([]Message, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY id ASC", q.messageColumns(), q.tableName))
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := scanMessages(rows)
+	return q.redactMessages(messages), err
+}
+
+// SnapshotDiff is what changed between two snapshots of a queue, see DiffSnapshots
+type SnapshotDiff struct {
+	// Added holds the messages only found in the later snapshot
+	Added []Message
+	// Removed holds the messages only found in the earlier snapshot, e.g. acknowledged or purged
+	Removed []Message
+	// Changed holds the messages found in both snapshots that differ
+	Changed []MessageChange
+}
+
+// MessageChange is a message that differs between two snapshots
+type MessageChange struct {
+	Before Message
+	After  Message
+	// Fields names the Message fields that differ, e.g. "Status" and "Attempts"
+	Fields []string
+}
+
+// DiffSnapshots compares two snapshots of the same queue by message ID, e.g. to see what a test
+// flow or a migration did to the queue
+// Every list of the result is in ID order
+func DiffSnapshots(a, b Snapshot) SnapshotDiff {
+	before := make(map[int64]Message, len(a.Messages))
+	for _, m := range a.Messages {
+		before[m.ID] = m
+	}
+	after := make(map[int64]Message, len(b.Messages))
+	for _, m := range b.Messages {
+		after[m.ID] = m
+	}
+
+	var diff SnapshotDiff
+	for _, m := range b.Messages {
+		old, ok := before[m.ID]
+		if !ok {
+			diff.Added = append(diff.Added, m)
+		} else if fields := changedFields(old, m); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, MessageChange{Before: old, After: m, Fields: fields})
+		}
+	}
+	for _, m := range a.Messages {
+		if _, ok := after[m.ID]; !ok {
+			diff.Removed = append(diff.Removed, m)
+		}
+	}
+
+	sortByID := func(messages []Message) {
+		slices.SortFunc(messages, func(x, y Message) int { return cmp.Compare(x.ID, y.ID) })
+	}
+	sortByID(diff.Added)
+	sortByID(diff.Removed)
+	slices.SortFunc(diff.Changed, func(x, y MessageChange) int { return cmp.Compare(x.After.ID, y.After.ID) })
+
+	return diff
+}
+
+// changedFields names the fields that differ between two versions of a message
+func changedFields(a, b Message) []string {
+	var fields []string
+	check := func(name string, equal bool) {
+		if !equal {
+			fields = append(fields, name)
+		}
+	}
+
+	check("Data", bytes.Equal(a.Data, b.Data))
+	check("Status", a.Status == b.Status)
+	check("AckID", a.AckID == b.AckID)
+	check("Attempts", a.Attempts == b.Attempts)
+	check("Priority", a.Priority == b.Priority)
+	check("ConsumerID", a.ConsumerID == b.ConsumerID)
+	check("LeaseExpiresAt", a.LeaseExpiresAt.Equal(b.LeaseExpiresAt))
+	check("VisibleAt", a.VisibleAt.Equal(b.VisibleAt))
+	check("CreatedAt", a.CreatedAt.Equal(b.CreatedAt))
+	check("UpdatedAt", a.UpdatedAt.Equal(b.UpdatedAt))
+
+	return fields
+}
+
+// Empty reports whether the snapshots were identical
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String summarizes the diff with a line per message, e.g. for test logs
+func (d SnapshotDiff) String() string {
+	var b strings.Builder
+	for _, m := range d.Added {
+		fmt.Fprintf(&b, "+ %d %s\n", m.ID, m.Status)
+	}
+	for _, m := range d.Removed {
+		fmt.Fprintf(&b, "- %d %s\n", m.ID, m.Status)
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&b, "~ %d %s\n", change.After.ID, strings.Join(change.Fields, ", "))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package duckq
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestSnapshot(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_snapshot.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue", WithRemoveOnComplete(false))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		stats, err := q.Stats()
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		if stats != (QueueStats{}) {
+			t.Errorf("Expected no items, got %+v", stats)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		for _, item := range []string{"a", "b", "c", "d"} {
+			q.Enqueue([]byte(item))
+		}
+		_, _, ackID := q.DequeueWithAckId()
+		q.Acknowledge(ackID)
+		q.DequeueWithAckId()
+		_, _, ackID = q.DequeueWithAckId()
+		q.DeadLetter(ackID, "manual")
+
+		stats, err := q.Stats()
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		want := QueueStats{Pending: 1, Processing: 1, Completed: 1, DeadLettered: 1}
+		if stats != want {
+			t.Errorf("Expected %+v, got %+v", want, stats)
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		snapshot, err := q.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		if snapshot.Len() != 1 || string(snapshot.Values[0].([]byte)) != "d" {
+			t.Errorf("Expected the pending item d, got %v", snapshot.Values)
+		}
+		if int64(snapshot.Len()) != snapshot.Stats.Pending {
+			t.Errorf("Expected Len to match the pending count, got %d and %d", snapshot.Len(), snapshot.Stats.Pending)
+		}
+		if snapshot.TakenAt.IsZero() {
+			t.Error("Expected the snapshot to be timestamped")
+		}
+	})
+
+	t.Run("ConcurrentWriters", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				q.Enqueue([]byte("item"))
+				_, _, ackID := q.DequeueWithAckId()
+				q.Acknowledge(ackID)
+			}
+		}()
+
+		for i := 0; i < 20; i++ {
+			snapshot, err := q.Snapshot()
+			if err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+			if int64(snapshot.Len()) != snapshot.Stats.Pending {
+				t.Fatalf("Expected Len to match the pending count, got %d and %d", snapshot.Len(), snapshot.Stats.Pending)
+			}
+		}
+		wg.Wait()
+	})
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_diff_snapshots.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("delivered"))
+	q.Enqueue([]byte("leased"))
+	q.Enqueue([]byte("untouched"))
+
+	before, err := q.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if len(before.Messages) != 3 {
+		t.Fatalf("Expected 3 messages in the snapshot, got %d", len(before.Messages))
+	}
+
+	t.Run("Identical", func(t *testing.T) {
+		again, _ := q.Snapshot()
+		if diff := DiffSnapshots(before, again); !diff.Empty() {
+			t.Errorf("Expected no difference, got\n%s", diff)
+		}
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		q.Dequeue()
+		q.DequeueWithAckId()
+		id, _ := q.EnqueueID([]byte("added"))
+
+		after, _ := q.Snapshot()
+		diff := DiffSnapshots(before, after)
+		if len(diff.Added) != 1 || diff.Added[0].ID != id {
+			t.Errorf("Expected message %d to be added, got %v", id, diff.Added)
+		}
+		if len(diff.Removed) != 1 || string(diff.Removed[0].Data) != "delivered" {
+			t.Errorf("Expected the delivered message to be removed, got %v", diff.Removed)
+		}
+		if len(diff.Changed) != 1 || diff.Changed[0].After.Status != "processing" {
+			t.Fatalf("Expected the leased message to change, got %v", diff.Changed)
+		}
+		if fields := diff.Changed[0].Fields; fields[0] != "Status" || fields[1] != "AckID" {
+			t.Errorf("Expected the status and ack ID to change, got %v", fields)
+		}
+		if lines := strings.Count(diff.String(), "\n"); lines != 3 {
+			t.Errorf("Expected a line per message, got\n%s", diff)
+		}
+	})
+}
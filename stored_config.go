@@ -0,0 +1,111 @@
+package duckq
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// queueConfigsTable holds the configurations stored by managers opened with WithStoredConfigs
+const queueConfigsTable = "duckq_queue_configs"
+
+// WithStoredConfigs stores the configuration of every queue the manager opens in the database,
+// and applies a queue's stored configuration whenever it is opened again, so processes sharing
+// the database don't silently diverge on settings such as WithRemoveOnComplete, WithDeadLetter
+// or the timeouts
+// Options passed when opening a queue override its stored configuration, which is then updated,
+// and only settings QueueConfig can describe are stored: codecs, hooks, clocks and the like must
+// still be passed by every process
+func WithStoredConfigs() QueuesOption {
+	return func(q *queues) {
+		q.storeConfigs = true
+	}
+}
+
+// createQueueConfigsTable creates the table of stored queue configurations if it doesn't exist
+func createQueueConfigsTable(db execer) error {
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		queue_name TEXT PRIMARY KEY,
+		config JSON NOT NULL
+	);
+	`, queueConfigsTable))
+	return err
+}
+
+// loadConfig returns the stored configuration of a queue, and false when none is stored
+func (q *queues) loadConfig(queueKey string) (QueueConfig, bool, error) {
+	var data string
+	err := q.client.QueryRow(fmt.Sprintf("SELECT CAST(config AS VARCHAR) FROM %s WHERE queue_name = ?", queueConfigsTable), queueKey).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return QueueConfig{}, false, nil
+	}
+	if err != nil {
+		return QueueConfig{}, false, fmt.Errorf("failed to load stored configuration of %s: %w", queueKey, err)
+	}
+
+	var cfg QueueConfig
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return QueueConfig{}, false, fmt.Errorf("failed to load stored configuration of %s: %w", queueKey, err)
+	}
+
+	return cfg, true, nil
+}
+
+// withStoredConfig prepends the options of the queue's stored configuration to opts
+func (q *queues) withStoredConfig(queueKey string, opts []Option) ([]Option, error) {
+	if !q.storeConfigs {
+		return opts, nil
+	}
+
+	cfg, ok, err := q.loadConfig(queueKey)
+	if err != nil || !ok {
+		return opts, err
+	}
+
+	return append(cfg.Options(), opts...), nil
+}
+
+// storeConfig records the configuration a queue was opened with
+// The queue is closed again when it can't be recorded
+func (q *queues) storeConfig(queue *Queue) error {
+	if !q.storeConfigs {
+		return nil
+	}
+
+	data, err := json.Marshal(queue.config())
+	if err == nil {
+		_, err = q.client.Exec(
+			fmt.Sprintf("INSERT OR REPLACE INTO %s (queue_name, config) VALUES (?, ?)", queueConfigsTable),
+			queue.tableName, string(data),
+		)
+	}
+	if err != nil {
+		queue.Close()
+		return fmt.Errorf("failed to store configuration of %s: %w", queue.tableName, err)
+	}
+
+	return nil
+}
+
+// StoredConfig returns the configuration stored for a queue by a manager opened with WithStoredConfigs
+// Returns ErrQueueNotFound when no configuration is stored for the queue
+func (q *queues) StoredConfig(queueKey string) (QueueConfig, error) {
+	exists, err := tableExists(q.client, queueConfigsTable)
+	if err != nil {
+		return QueueConfig{}, fmt.Errorf("failed to load stored configuration of %s: %w", queueKey, err)
+	}
+
+	cfg, ok := QueueConfig{}, false
+	if exists {
+		if cfg, ok, err = q.loadConfig(queueKey); err != nil {
+			return QueueConfig{}, err
+		}
+	}
+	if !ok {
+		return QueueConfig{}, fmt.Errorf("%w: no stored configuration for %s", ErrQueueNotFound, queueKey)
+	}
+
+	return cfg, nil
+}
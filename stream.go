@@ -0,0 +1,309 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stream is an append-only log of records that are never deleted by consuming them
+// Each record gets an increasing offset, and every named Consumer reads the log independently,
+// tracking its own committed offset, so several readers see every record and any of them can
+// replay from an earlier offset, like a Kafka partition stored in DuckDB
+type Stream struct {
+	name    string
+	manager *queues
+	clock   Clock
+
+	// appendMu serializes the appends of this handle, so they don't conflict on the stream's head
+	appendMu sync.Mutex
+}
+
+// StreamOption configures a Stream
+type StreamOption func(*Stream)
+
+// WithStreamClock sets the clock the stream reads the time of appends and commits from, see WithClock
+func WithStreamClock(clock Clock) StreamOption {
+	return func(s *Stream) {
+		s.clock = clock
+	}
+}
+
+// Record is a record read from a stream
+type Record struct {
+	Offset    int64
+	Data      []byte
+	CreatedAt time.Time
+}
+
+// streamOffsetsTable returns the name of the table holding the committed offsets of a stream's consumers
+func streamOffsetsTable(name string) string {
+	return fmt.Sprintf("%s_offsets", name)
+}
+
+// streamHeadTable returns the name of the table holding the last offset allocated by a stream
+func streamHeadTable(name string) string {
+	return fmt.Sprintf("%s_head", name)
+}
+
+// NewStream creates the stream with the given name, or opens it with its records and committed offsets
+// Any number of handles can append to the same stream: every append takes the next offset from
+// the stream's head row, so an append conflicts with another one until it commits, and records
+// become visible in offset order
+func (q *queues) NewStream(name string, opts ...StreamOption) (*Stream, error) {
+	seqName := fmt.Sprintf("%s_offset_seq", name)
+	_, err := q.client.Exec(fmt.Sprintf(`
+	CREATE SEQUENCE IF NOT EXISTS %s START 1;
+	CREATE TABLE IF NOT EXISTS %s (
+		offset_id BIGINT PRIMARY KEY DEFAULT nextval('%s'),
+		data BLOB NOT NULL,
+		created_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS %s (
+		consumer TEXT PRIMARY KEY,
+		committed BIGINT NOT NULL,
+		committed_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS %s (
+		head_id INTEGER PRIMARY KEY,
+		last_offset BIGINT NOT NULL
+	);
+	INSERT INTO %s SELECT 1, COALESCE(MAX(offset_id), 0) FROM %s ON CONFLICT DO NOTHING;
+	`, seqName, name, seqName, streamOffsetsTable(name), streamHeadTable(name), streamHeadTable(name), name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	s := &Stream{name: name, manager: q, clock: systemClock{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// now returns the current time of the stream's clock in UTC
+func (s *Stream) now() time.Time {
+	return s.clock.Now().UTC()
+}
+
+// Name returns the stream's name
+func (s *Stream) Name() string {
+	return s.name
+}
+
+// Append adds data to the end of the stream and returns its offset
+func (s *Stream) Append(data []byte) (int64, error) {
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
+
+	var offset int64
+	err := DefaultRetryPolicy.do(func() error {
+		tx, err := s.manager.client.Begin()
+		if err != nil {
+			return err
+		}
+		if offset, err = s.appendInTx(tx, data); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to append to stream %s: %w", s.name, err)
+	}
+
+	return offset, nil
+}
+
+// AppendInTx adds data to the end of the stream as part of the caller's transaction, see EnqueueInTx
+// The offset is only taken by tx once it commits, so appends to the stream from other transactions
+// conflict with it until then; keep such transactions short, and retry tx when the append conflicts
+func (s *Stream) AppendInTx(tx *sql.Tx, data []byte) (int64, error) {
+	offset, err := s.appendInTx(tx, data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to append to stream %s: %w", s.name, err)
+	}
+
+	return offset, nil
+}
+
+// appendInTx moves the stream's head to the next offset and adds data at it as part of tx
+func (s *Stream) appendInTx(tx *sql.Tx, data []byte) (int64, error) {
+	var offset int64
+	err := tx.QueryRow(fmt.Sprintf("UPDATE %s SET last_offset = last_offset + 1 WHERE head_id = 1 RETURNING last_offset", streamHeadTable(s.name))).Scan(&offset)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (offset_id, data, created_at) VALUES (?, ?, ?)", s.name), offset, data, s.now())
+	return offset, err
+}
+
+// HighWatermark returns the offset of the last record appended to the stream, or 0 when it is empty
+func (s *Stream) HighWatermark() (int64, error) {
+	var offset int64
+	err := s.manager.client.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(offset_id), 0) FROM %s", s.name)).Scan(&offset)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read high watermark: %w", err)
+	}
+
+	return offset, nil
+}
+
+// Read returns up to limit records starting at offset, without affecting any consumer
+func (s *Stream) Read(offset int64, limit int) ([]Record, error) {
+	rows, err := s.manager.client.Query(
+		fmt.Sprintf("SELECT offset_id, data, created_at FROM %s WHERE offset_id >= ? ORDER BY offset_id ASC LIMIT ?", s.name),
+		offset, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream %s: %w", s.name, err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		var createdAt sql.NullTime
+		if err := rows.Scan(&record.Offset, &record.Data, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read stream %s: %w", s.name, err)
+		}
+		record.CreatedAt = createdAt.Time
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Offsets returns the committed offset of every consumer of the stream, by consumer name
+func (s *Stream) Offsets() (map[string]int64, error) {
+	rows, err := s.manager.client.Query(fmt.Sprintf("SELECT consumer, committed FROM %s", streamOffsetsTable(s.name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+	defer rows.Close()
+
+	offsets := make(map[string]int64)
+	for rows.Next() {
+		var consumer string
+		var committed int64
+		if err := rows.Scan(&consumer, &committed); err != nil {
+			return nil, fmt.Errorf("failed to list offsets: %w", err)
+		}
+		offsets[consumer] = committed
+	}
+
+	return offsets, rows.Err()
+}
+
+// Consumer reads a stream on behalf of a named reader
+// Its position lives in memory and starts after the offset the name last committed, so a
+// restarted consumer resumes where it left off, and records read but not committed are read again
+type Consumer struct {
+	name   string
+	stream *Stream
+
+	mu   sync.Mutex
+	next int64
+}
+
+// Consumer returns a consumer of the stream with the given name, positioned after its committed offset
+// A name that never committed starts at the beginning of the stream
+func (s *Stream) Consumer(name string) (*Consumer, error) {
+	c := &Consumer{name: name, stream: s}
+	committed, err := c.Committed()
+	if err != nil {
+		return nil, err
+	}
+	c.next = committed + 1
+
+	return c, nil
+}
+
+// Name returns the consumer's name
+func (c *Consumer) Name() string {
+	return c.name
+}
+
+// Poll returns up to limit records from the consumer's position and moves the position past them
+// Returns no records once the consumer caught up with the stream
+func (c *Consumer) Poll(limit int) ([]Record, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records, err := c.stream.Read(c.next, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		c.next = records[len(records)-1].Offset + 1
+	}
+
+	return records, nil
+}
+
+// Commit records that the consumer processed every record up to and including offset
+// The next consumer opened with the same name starts right after it
+// Committing an earlier offset than before rewinds the consumer for its next opening
+func (c *Consumer) Commit(offset int64) error {
+	_, err := c.stream.manager.client.Exec(
+		fmt.Sprintf("INSERT INTO %s (consumer, committed, committed_at) VALUES (?, ?, ?) ON CONFLICT (consumer) DO UPDATE SET committed = excluded.committed, committed_at = excluded.committed_at", streamOffsetsTable(c.stream.name)),
+		c.name, offset, c.stream.now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to commit offset: %w", err)
+	}
+
+	return nil
+}
+
+// Committed returns the offset the consumer last committed, or 0 when it never committed
+func (c *Consumer) Committed() (int64, error) {
+	var committed int64
+	err := c.stream.manager.client.QueryRow(
+		fmt.Sprintf("SELECT committed FROM %s WHERE consumer = ?", streamOffsetsTable(c.stream.name)),
+		c.name,
+	).Scan(&committed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read committed offset: %w", err)
+	}
+
+	return committed, nil
+}
+
+// SeekTo moves the consumer so the next Poll starts at offset, e.g. to replay the stream from an
+// earlier record, without changing its committed offset
+func (c *Consumer) SeekTo(offset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next = offset
+}
+
+// Position returns the offset the next Poll starts at
+func (c *Consumer) Position() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.next
+}
+
+// Lag returns how many records of the stream the consumer hasn't committed yet
+func (c *Consumer) Lag() (int64, error) {
+	high, err := c.stream.HighWatermark()
+	if err != nil {
+		return 0, err
+	}
+	committed, err := c.Committed()
+	if err != nil {
+		return 0, err
+	}
+
+	return max(high-committed, 0), nil
+}
@@ -0,0 +1,145 @@
+package duckq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestStream(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_stream.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	stream, err := queues.NewStream("test_stream")
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+	for _, data := range []string{"a", "b", "c"} {
+		if _, err := stream.Append([]byte(data)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	t.Run("IndependentConsumers", func(t *testing.T) {
+		first, err := stream.Consumer("first")
+		if err != nil {
+			t.Fatalf("Failed to create consumer: %v", err)
+		}
+		second, err := stream.Consumer("second")
+		if err != nil {
+			t.Fatalf("Failed to create consumer: %v", err)
+		}
+
+		records, err := first.Poll(2)
+		if err != nil || len(records) != 2 || string(records[0].Data) != "a" || string(records[1].Data) != "b" {
+			t.Fatalf("Expected a and b, got %v (%v)", records, err)
+		}
+		if err := first.Commit(records[1].Offset); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+
+		records, _ = second.Poll(10)
+		if len(records) != 3 {
+			t.Errorf("Expected the second consumer to read every record, got %d", len(records))
+		}
+		if lag, _ := second.Lag(); lag != 3 {
+			t.Errorf("Expected a lag of 3 before committing, got %d", lag)
+		}
+	})
+
+	t.Run("ResumeFromCommit", func(t *testing.T) {
+		resumed, err := stream.Consumer("first")
+		if err != nil {
+			t.Fatalf("Failed to create consumer: %v", err)
+		}
+		records, _ := resumed.Poll(10)
+		if len(records) != 1 || string(records[0].Data) != "c" {
+			t.Errorf("Expected to resume at c, got %v", records)
+		}
+		if records, _ := resumed.Poll(10); len(records) != 0 {
+			t.Errorf("Expected the consumer to have caught up, got %v", records)
+		}
+		if lag, _ := resumed.Lag(); lag != 1 {
+			t.Errorf("Expected a lag of 1, got %d", lag)
+		}
+
+		offsets, err := stream.Offsets()
+		if err != nil || offsets["first"] != 2 {
+			t.Errorf("Expected first to have committed 2, got %v (%v)", offsets, err)
+		}
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		consumer, _ := stream.Consumer("first")
+		consumer.SeekTo(1)
+		records, _ := consumer.Poll(10)
+		if len(records) != 3 {
+			t.Errorf("Expected to replay every record, got %d", len(records))
+		}
+		if committed, _ := consumer.Committed(); committed != 2 {
+			t.Errorf("Expected SeekTo to leave the committed offset, got %d", committed)
+		}
+		if consumer.Position() != 4 {
+			t.Errorf("Expected position 4, got %d", consumer.Position())
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		reopened, err := queues.NewStream("test_stream")
+		if err != nil {
+			t.Fatalf("Failed to reopen stream: %v", err)
+		}
+		offset, _ := reopened.Append([]byte("d"))
+		if high, _ := reopened.HighWatermark(); offset != 4 || high != 4 {
+			t.Errorf("Expected offset 4, got %d and high watermark %d", offset, high)
+		}
+	})
+	t.Run("ConcurrentHandles", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		other, err := queues.NewStream("test_stream", WithStreamClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to open stream: %v", err)
+		}
+		consumer, _ := stream.Consumer("concurrent")
+		consumer.SeekTo(5)
+
+		tx, err := queues.DB().Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		offset, err := stream.AppendInTx(tx, []byte("e"))
+		if err != nil || offset != 5 {
+			t.Fatalf("Expected offset 5, got %d (%v)", offset, err)
+		}
+
+		// Another handle can't commit a later offset while the transaction holds offset 5
+		if _, err := other.Append([]byte("f")); err == nil {
+			t.Error("Expected the append to conflict with the open transaction")
+		}
+		if records, _ := consumer.Poll(10); len(records) != 0 {
+			t.Fatalf("Expected no record before the transaction commits, got %v", records)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if offset, err := other.Append([]byte("f")); err != nil || offset != 6 {
+			t.Fatalf("Expected offset 6, got %d (%v)", offset, err)
+		}
+
+		records, _ := consumer.Poll(10)
+		if len(records) != 2 || string(records[0].Data) != "e" || string(records[1].Data) != "f" {
+			t.Fatalf("Expected e and f in order, got %v", records)
+		}
+		if !records[1].CreatedAt.Equal(clock.Now()) {
+			t.Errorf("Expected the record to be stamped by the stream's clock, got %v", records[1].CreatedAt)
+		}
+	})
+}
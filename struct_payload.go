@@ -0,0 +1,284 @@
+package duckq
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// structPayloadType marks payloads stored by a queue's StructSchema in the payload_type column
+const structPayloadType = "duckq/struct"
+
+// StructColumn maps a struct field to a native column of the queue table
+type StructColumn struct {
+	// Field is the name of the Go struct field
+	Field string
+	// Name is the column name, the snake_case field name when empty
+	Name string
+	// Type is the DuckDB column type, inferred from the field type when empty
+	Type string
+}
+
+// StructSchema maps the fields of a Go struct type to native columns of the queue table,
+// so queue contents can be queried and joined with plain SQL instead of decoding payloads
+type StructSchema struct {
+	typ     reflect.Type
+	columns []StructColumn
+	fields  [][]int
+}
+
+// NewStructSchema creates a StructSchema for the struct type of prototype, which may be a pointer
+// Without columns every exported field is mapped, named by its `duckq:"name"` tag or its
+// snake_case name, and fields tagged `duckq:"-"` are skipped
+// With columns only the listed fields are mapped, which defines the table schema explicitly
+func NewStructSchema(prototype any, columns ...StructColumn) (*StructSchema, error) {
+	t := reflect.TypeOf(prototype)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: struct schema needs a struct, got %T", ErrInvalidOption, prototype)
+	}
+
+	if len(columns) == 0 {
+		for _, field := range reflect.VisibleFields(t) {
+			tag := field.Tag.Get("duckq")
+			if !field.IsExported() || field.Anonymous || tag == "-" {
+				continue
+			}
+			columns = append(columns, StructColumn{Field: field.Name, Name: tag})
+		}
+	}
+
+	s := &StructSchema{typ: t}
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		field, ok := t.FieldByName(column.Field)
+		if !ok || !field.IsExported() {
+			return nil, fmt.Errorf("%w: %s has no exported field %s", ErrInvalidOption, t, column.Field)
+		}
+		if column.Name == "" {
+			column.Name = snakeCase(field.Name)
+		}
+		if column.Type == "" {
+			column.Type = columnTypeOf(field.Type)
+		}
+		if seen[column.Name] {
+			return nil, fmt.Errorf("%w: column %s is mapped twice", ErrInvalidOption, column.Name)
+		}
+		seen[column.Name] = true
+
+		s.columns = append(s.columns, column)
+		s.fields = append(s.fields, field.Index)
+	}
+
+	return s, nil
+}
+
+// Columns returns the columns the schema adds to the queue table
+func (s *StructSchema) Columns() []StructColumn {
+	return append([]StructColumn(nil), s.columns...)
+}
+
+// matches reports whether item is a value of, or pointer to, the schema's struct type
+func (s *StructSchema) matches(item any) (reflect.Value, bool) {
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	return v, v.IsValid() && v.Type() == s.typ
+}
+
+// values returns the column values of a struct, nil for nil pointer fields
+// Fields without a native DuckDB type are bound as JSON text
+func (s *StructSchema) values(v reflect.Value) ([]any, error) {
+	values := make([]any, len(s.columns))
+	for i, index := range s.fields {
+		field, err := v.FieldByIndexErr(index)
+		if err != nil {
+			continue
+		}
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+
+		value := field.Interface()
+		if columnTypeOf(field.Type()) == jsonPayloadColumn {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode field %s: %w", s.columns[i].Field, err)
+			}
+			value = string(encoded)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// columnTypeOf infers the DuckDB column type of a Go type
+// Types without a native equivalent are stored as JSON
+func columnTypeOf(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "TIMESTAMP"
+	}
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return "BIGINT"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int8:
+		return "TINYINT"
+	case reflect.Int16:
+		return "SMALLINT"
+	case reflect.Int32:
+		return "INTEGER"
+	case reflect.Int, reflect.Int64:
+		return "BIGINT"
+	case reflect.Uint8:
+		return "UTINYINT"
+	case reflect.Uint16:
+		return "USMALLINT"
+	case reflect.Uint32:
+		return "UINTEGER"
+	case reflect.Uint, reflect.Uint64:
+		return "UBIGINT"
+	case reflect.Float32:
+		return "FLOAT"
+	case reflect.Float64:
+		return "DOUBLE"
+	case reflect.String:
+		return "VARCHAR"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return blobPayloadColumn
+		}
+	}
+
+	return jsonPayloadColumn
+}
+
+// snakeCase converts a Go field name like CustomerID to customer_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word before an upper-case letter that follows a lower-case one,
+			// or that ends an acronym, e.g. the "P" of "HTTPPort"
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// builtinColumns returns the names of the columns every queue table has
+func builtinColumns() map[string]bool {
+	columns := map[string]bool{"id": true, "data": true, "priority": true}
+	for _, line := range strings.Split(queueColumnsSQL, ",") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			columns[fields[0]] = true
+		}
+	}
+
+	return columns
+}
+
+// addStructColumns adds the columns of the queue's StructSchema to its table
+func (q *Queue) addStructColumns(db execer) error {
+	if q.structSchema == nil {
+		return nil
+	}
+
+	for _, column := range q.structSchema.columns {
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", q.tableName, column.Name, column.Type))
+		if err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// encodeStruct stores items of the StructSchema's type as JSON, so decode can rebuild
+// them from the payload alone while their fields are also written to native columns
+func (q *Queue) encodeStruct(item any) (any, *string, bool, error) {
+	if q.structSchema == nil {
+		return nil, nil, false, nil
+	}
+	if _, ok := q.structSchema.matches(item); !ok {
+		return nil, nil, false, nil
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, nil, true, fmt.Errorf("failed to encode %s: %w", q.structSchema.typ, err)
+	}
+
+	payloadType := structPayloadType
+	if q.jsonPayload {
+		return string(data), &payloadType, true, nil
+	}
+
+	return data, &payloadType, true, nil
+}
+
+// decodeStruct rebuilds an item stored by encodeStruct
+func (q *Queue) decodeStruct(data []byte) any {
+	value := reflect.New(q.structSchema.typ)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return data
+	}
+
+	return value.Elem().Interface()
+}
+
+// writeStructColumns sets the struct columns of the rows matching condition from item
+// Items that are not of the StructSchema's type leave the columns NULL
+func (q *Queue) writeStructColumns(tx *sql.Tx, item any, condition string, args ...any) error {
+	if q.structSchema == nil {
+		return nil
+	}
+	v, ok := q.structSchema.matches(item)
+	if !ok {
+		return nil
+	}
+
+	values, err := q.structSchema.values(v)
+	if err != nil {
+		return err
+	}
+
+	assignments := make([]string, len(q.structSchema.columns))
+	for i, column := range q.structSchema.columns {
+		assignments[i] = column.Name + " = ?"
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("UPDATE %s SET %s WHERE %s", q.tableName, strings.Join(assignments, ", "), condition),
+		append(values, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write struct columns: %w", err)
+	}
+
+	return nil
+}
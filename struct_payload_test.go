@@ -0,0 +1,161 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+type orderTask struct {
+	CustomerID int64
+	Total      float64
+	Note       *string
+	PlacedAt   time.Time
+	Tags       []string
+	Internal   string `duckq:"-"`
+	SKU        string `duckq:"product_sku"`
+}
+
+func TestStructPayload(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_struct_payload.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	schema, err := NewStructSchema(orderTask{})
+	if err != nil {
+		t.Fatalf("NewStructSchema failed: %v", err)
+	}
+
+	t.Run("Columns", func(t *testing.T) {
+		expected := []StructColumn{
+			{"CustomerID", "customer_id", "BIGINT"},
+			{"Total", "total", "DOUBLE"},
+			{"Note", "note", "VARCHAR"},
+			{"PlacedAt", "placed_at", "TIMESTAMP"},
+			{"Tags", "tags", "JSON"},
+			{"SKU", "product_sku", "VARCHAR"},
+		}
+		columns := schema.Columns()
+		if len(columns) != len(expected) {
+			t.Fatalf("Expected %d columns, got %v", len(expected), columns)
+		}
+		for i, column := range columns {
+			if column != expected[i] {
+				t.Errorf("Expected column %v, got %v", expected[i], column)
+			}
+		}
+	})
+
+	q, err := queues.NewQueue("test_queue", WithStructPayload(schema))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	placedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	note := "gift"
+	q.Enqueue(orderTask{CustomerID: 42, Total: 19.5, Note: &note, PlacedAt: placedAt, Tags: []string{"a"}, SKU: "x-1"})
+	q.Enqueue(&orderTask{CustomerID: 7, Total: 4})
+	q.Enqueue([]byte("raw"))
+
+	t.Run("QueryColumns", func(t *testing.T) {
+		rows, err := q.Query(t.Context(), "SELECT customer_id, SUM(total) FROM "+q.TableName()+" WHERE customer_id IS NOT NULL GROUP BY customer_id ORDER BY customer_id")
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		defer rows.Close()
+
+		var totals []float64
+		for rows.Next() {
+			var customerID int64
+			var total float64
+			if err := rows.Scan(&customerID, &total); err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			totals = append(totals, total)
+		}
+		if len(totals) != 2 || totals[0] != 4 || totals[1] != 19.5 {
+			t.Errorf("Unexpected totals per customer: %v", totals)
+		}
+
+		var storedNote, sku string
+		var storedAt time.Time
+		err = queues.DB().QueryRow("SELECT note, placed_at, product_sku FROM test_queue WHERE customer_id = 42").Scan(&storedNote, &storedAt, &sku)
+		if err != nil {
+			t.Fatalf("Failed to read columns: %v", err)
+		}
+		if storedNote != note || !storedAt.Equal(placedAt) || sku != "x-1" {
+			t.Errorf("Unexpected columns: %q %v %q", storedNote, storedAt, sku)
+		}
+	})
+
+	t.Run("Dequeue", func(t *testing.T) {
+		item, _ := q.Dequeue()
+		order, ok := item.(orderTask)
+		if !ok || order.CustomerID != 42 || *order.Note != note || order.Tags[0] != "a" {
+			t.Errorf("Expected the struct back, got %#v", item)
+		}
+		item, _ = q.Dequeue()
+		if order, ok := item.(orderTask); !ok || order.CustomerID != 7 {
+			t.Errorf("Expected the struct enqueued by pointer back, got %#v", item)
+		}
+		item, _ = q.Dequeue()
+		if string(item.([]byte)) != "raw" {
+			t.Errorf("Expected the raw payload back, got %v", item)
+		}
+	})
+
+	t.Run("ScheduleUnique", func(t *testing.T) {
+		dq, err := queues.NewDelayedQueue("test_delayed_queue", WithStructPayload(schema))
+		if err != nil {
+			t.Fatalf("Failed to create delayed queue: %v", err)
+		}
+		at := time.Now().Add(time.Hour)
+		dq.ScheduleUnique("daily", at, orderTask{CustomerID: 1})
+		dq.ScheduleUnique("daily", at, orderTask{CustomerID: 2})
+
+		var customerID int64
+		if err := queues.DB().QueryRow("SELECT customer_id FROM test_delayed_queue").Scan(&customerID); err != nil {
+			t.Fatalf("Failed to read column: %v", err)
+		}
+		if customerID != 2 {
+			t.Errorf("Expected the rescheduled item's column, got %d", customerID)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := NewStructSchema(42); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for a non-struct, got %v", err)
+		}
+		if _, err := NewStructSchema(orderTask{}, StructColumn{Field: "Missing"}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for a missing field, got %v", err)
+		}
+
+		collides, err := NewStructSchema(orderTask{}, StructColumn{Field: "SKU", Name: "status"})
+		if err != nil {
+			t.Fatalf("NewStructSchema failed: %v", err)
+		}
+		if _, err := queues.NewQueue("test_collision_queue", WithStructPayload(collides)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for a colliding column, got %v", err)
+		}
+	})
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"CustomerID": "customer_id",
+		"HTTPPort":   "http_port",
+		"Total":      "total",
+		"placedAt":   "placed_at",
+	} {
+		if got := snakeCase(name); got != expected {
+			t.Errorf("snakeCase(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
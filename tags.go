@@ -0,0 +1,50 @@
+package duckq
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// EnqueueTagged adds an item with tags, so workers sharing the queue can take only the items
+// they can handle with DequeueWithTag or the HasTag filter
+// Returns true if the operation was successful
+func (q *Queue) EnqueueTagged(item any, tags ...string) bool {
+	if err := q.checkOpen(); err != nil {
+		return err == errDropped
+	}
+
+	err := q.retryEnqueue(1, func() error {
+		return q.inTx(func(tx *sql.Tx) error {
+			id, err := q.enqueueInTx(tx, item, sql.NullTime{})
+			if err != nil || id == 0 || len(tags) == 0 {
+				return err
+			}
+
+			// database/sql can't bind lists, so the tags are passed as a JSON array
+			encoded, err := json.Marshal(tags)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET message_tags = CAST(CAST(? AS JSON) AS VARCHAR[]) WHERE id = ?", q.tableName), string(encoded), id)
+			return err
+		})
+	})
+
+	return err == nil
+}
+
+// HasTag matches items enqueued with EnqueueTagged and the given tag
+func HasTag(tag string) DequeueFilter {
+	return DequeueFilter{
+		condition: func(*Queue) string { return "list_contains(message_tags, ?)" },
+		args:      []any{tag},
+	}
+}
+
+// DequeueWithTag removes and returns the next pending item with the given tag, in dequeue order,
+// with an acknowledgment ID; items without the tag are left for other workers
+// Returns ErrEmptyQueue when no pending item has the tag
+func (q *Queue) DequeueWithTag(tag string) (any, string, error) {
+	return q.DequeueMatching(HasTag(tag))
+}
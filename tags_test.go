@@ -0,0 +1,63 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestTags(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_tags.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	q, err := queues.NewQueue("test_queue")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	q.EnqueueTagged([]byte("render"), "gpu", "large")
+	q.EnqueueTagged([]byte("thumbnail"), "cpu")
+	q.Enqueue([]byte("untagged"))
+	q.EnqueueTagged([]byte("train"), "gpu")
+
+	t.Run("DequeueWithTag", func(t *testing.T) {
+		for _, want := range []string{"render", "train"} {
+			item, ackID, err := q.DequeueWithTag("gpu")
+			if err != nil {
+				t.Fatalf("DequeueWithTag failed: %v", err)
+			}
+			if string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+			q.Ack(ackID)
+		}
+
+		if _, _, err := q.DequeueWithTag("gpu"); !errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected ErrEmptyQueue, got %v", err)
+		}
+	})
+
+	t.Run("OthersLeft", func(t *testing.T) {
+		for _, want := range []string{"thumbnail", "untagged"} {
+			if item, _ := q.Dequeue(); string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+	})
+
+	t.Run("CombinedFilters", func(t *testing.T) {
+		q.EnqueueTagged([]byte("small"), "gpu")
+		q.EnqueueTagged([]byte("big"), "gpu", "large")
+		item, _, err := q.DequeueMatching(HasTag("gpu"), HasTag("large"))
+		if err != nil || string(item.([]byte)) != "big" {
+			t.Errorf("Expected the item with both tags, got %v, %v", item, err)
+		}
+	})
+}
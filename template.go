@@ -0,0 +1,40 @@
+package duckq
+
+import "fmt"
+
+// DefineTemplate registers a named set of options, so many queues such as one per customer can
+// be created with the same retry, dead-letter and TTL settings by NewQueueFromTemplate
+// The options are validated for a regular queue right away, reporting every problem wrapping
+// ErrInvalidOption; redefining a template replaces it for queues created afterwards
+func (q *queues) DefineTemplate(name string, opts ...Option) error {
+	if name == "" {
+		return fmt.Errorf("%w: template name is empty", ErrInvalidOption)
+	}
+	if err := defaultQueue(q.client, name).apply(opts); err != nil {
+		return fmt.Errorf("invalid template %s: %w", name, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.templates == nil {
+		q.templates = make(map[string][]Option)
+	}
+	q.templates[name] = append([]Option(nil), opts...)
+	return nil
+}
+
+// NewQueueFromTemplate creates or opens the regular queue queueKey with the options of the given
+// template, followed by opts, which override the template's settings for this queue only
+// Returns ErrTemplateNotFound if no template was defined with that name
+func (q *queues) NewQueueFromTemplate(queueKey, template string, opts ...Option) (*Queue, error) {
+	q.mu.Lock()
+	templateOpts, ok := q.templates[template]
+	q.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, template)
+	}
+
+	return q.NewQueue(queueKey, append(append([]Option(nil), templateOpts...), opts...)...)
+}
@@ -0,0 +1,65 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestQueueTemplates(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_template.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	err := queues.DefineTemplate("customer", WithMaxAttempts(3), WithDeadLetter("customer_dlq"), WithPendingTTL(time.Hour, TimeoutDeadLetter))
+	if err != nil {
+		t.Fatalf("DefineTemplate failed: %v", err)
+	}
+
+	t.Run("SharedSettings", func(t *testing.T) {
+		for _, key := range []string{"test_customer_a", "test_customer_b"} {
+			q, err := queues.NewQueueFromTemplate(key, "customer")
+			if err != nil {
+				t.Fatalf("NewQueueFromTemplate failed: %v", err)
+			}
+			if q.maxAttempts != 3 || q.deadLetterName != "customer_dlq" || q.pendingTTL != time.Hour {
+				t.Errorf("Expected %s to have the template's settings, got %d %q %v", key, q.maxAttempts, q.deadLetterName, q.pendingTTL)
+			}
+		}
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		q, err := queues.NewQueueFromTemplate("test_customer_vip", "customer", WithMaxAttempts(10))
+		if err != nil {
+			t.Fatalf("NewQueueFromTemplate failed: %v", err)
+		}
+		if q.maxAttempts != 10 || q.deadLetterName != "customer_dlq" {
+			t.Errorf("Expected the override on top of the template, got %d %q", q.maxAttempts, q.deadLetterName)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, err := queues.NewQueueFromTemplate("test_customer_c", "missing"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("Expected ErrTemplateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if err := queues.DefineTemplate("broken", WithMaxAttempts(-1)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+		if err := queues.DefineTemplate("", WithMaxAttempts(3)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for an empty name, got %v", err)
+		}
+		if _, err := queues.NewQueueFromTemplate("test_customer_d", "broken"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("Expected the invalid template not to be defined, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,295 @@
+package duckq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tenantQueuesTable records which tenant owns each queue created with WithTenant
+const tenantQueuesTable = "duckq_tenant_queues"
+
+// tenantUsageTTL is how long enqueues check a tenant's quota against its cached usage
+// before the usage is read from the tables again
+const tenantUsageTTL = time.Second
+
+// TenantQuota limits the resources of a tenant across all of its queues
+// Zero-valued fields don't limit anything
+type TenantQuota struct {
+	// MaxQueues limits how many queues the tenant can create
+	MaxQueues int `yaml:"max_queues" json:"max_queues"`
+	// MaxPending limits the pending messages across the tenant's queues
+	MaxPending int64 `yaml:"max_pending" json:"max_pending"`
+	// MaxBytes limits the payload bytes stored across the tenant's queues, of every status
+	MaxBytes int64 `yaml:"max_bytes" json:"max_bytes"`
+}
+
+// TenantUsage is what a tenant currently uses of its quota
+type TenantUsage struct {
+	Queues  int
+	Pending int64
+	Bytes   int64
+}
+
+// tenantMeter caches the usage of a tenant's queues, so enqueues don't scan every table of the tenant
+// Enqueues through the manager reserve their messages and bytes as they are checked, and the
+// pending changes of committed transactions are added as they commit; everything else, such as
+// deleted payloads or other processes' writes, is only seen when the usage is read again
+type tenantMeter struct {
+	mu     sync.Mutex
+	usage  TenantUsage
+	readAt time.Time
+}
+
+// add adjusts the cached usage by the given pending messages and bytes
+func (m *tenantMeter) add(messages, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage.Pending += messages
+	m.usage.Bytes += size
+}
+
+// WithTenantQuota sets the quota of the tenant with the given ID, enforced for queues created with WithTenant
+// Enqueues check the pending and byte quotas against usage the manager re-reads from the tenant's tables
+// at most once a second, so the quota can be overshot by what other managers or processes enqueue
+// within that second, and by enqueues still uncommitted when it is re-read; deleted payloads and
+// items leased elsewhere free up quota once it is re-read
+func WithTenantQuota(tenantID string, quota TenantQuota) QueuesOption {
+	return func(q *queues) {
+		if q.tenantQuotas == nil {
+			q.tenantQuotas = make(map[string]TenantQuota)
+		}
+		q.tenantQuotas[tenantID] = quota
+	}
+}
+
+// WithDefaultTenantQuota sets the quota of tenants without one set by WithTenantQuota
+func WithDefaultTenantQuota(quota TenantQuota) QueuesOption {
+	return func(q *queues) {
+		q.defaultTenantQuota = quota
+	}
+}
+
+// quotaFor returns the quota of a tenant
+func (q *queues) quotaFor(tenantID string) TenantQuota {
+	if quota, ok := q.tenantQuotas[tenantID]; ok {
+		return quota
+	}
+
+	return q.defaultTenantQuota
+}
+
+// meterFor returns the cached usage of a tenant, shared by the tenant's queues opened through the manager
+func (q *queues) meterFor(tenantID string) *tenantMeter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tenantMeters == nil {
+		q.tenantMeters = make(map[string]*tenantMeter)
+	}
+	meter, ok := q.tenantMeters[tenantID]
+	if !ok {
+		meter = &tenantMeter{}
+		q.tenantMeters[tenantID] = meter
+	}
+
+	return meter
+}
+
+// withTenantQuotas lets queues created by the manager look up their tenant's quota and cached usage
+func withTenantQuotas(quotaFor func(tenantID string) TenantQuota, meterFor func(tenantID string) *tenantMeter) Option {
+	return func(q *Queue) {
+		q.quotaFor = quotaFor
+		q.meterFor = meterFor
+	}
+}
+
+// tenantQuota returns the quota of the queue's tenant, which is unlimited without a tenant
+func (q *Queue) tenantQuota() TenantQuota {
+	if q.tenant == "" || q.quotaFor == nil {
+		return TenantQuota{}
+	}
+
+	return q.quotaFor(q.tenant)
+}
+
+// tenantMeter returns the cached usage of the queue's tenant, or nil without a tenant
+func (q *Queue) tenantMeter() *tenantMeter {
+	if q.tenant == "" || q.meterFor == nil {
+		return nil
+	}
+
+	return q.meterFor(q.tenant)
+}
+
+// registerTenant records the queue as owned by its tenant as part of tx
+// Returns ErrQuotaExceeded when the tenant already has as many queues as its quota allows
+func (q *Queue) registerTenant(tx *sql.Tx) error {
+	if q.tenant == "" {
+		return nil
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		queue_name TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL
+	);
+	`, tenantQueuesTable))
+	if err != nil {
+		return err
+	}
+
+	var owner string
+	err = tx.QueryRow(fmt.Sprintf("SELECT tenant_id FROM %s WHERE queue_name = ?", tenantQueuesTable), q.tableName).Scan(&owner)
+	if err == nil {
+		if owner != q.tenant {
+			return fmt.Errorf("%w: queue %s belongs to tenant %s", ErrInvalidOption, q.tableName, owner)
+		}
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	quota := q.tenantQuota()
+	if quota.MaxQueues > 0 {
+		var count int
+		if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE tenant_id = ?", tenantQueuesTable), q.tenant).Scan(&count); err != nil {
+			return err
+		}
+		if count >= quota.MaxQueues {
+			return fmt.Errorf("%w: tenant %s already has %d queues", ErrQuotaExceeded, q.tenant, count)
+		}
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (queue_name, tenant_id) VALUES (?, ?)", tenantQueuesTable), q.tableName, q.tenant)
+	return err
+}
+
+// invalidateTenantUsage makes the next quota check of the queue's tenant read its usage from the tables again
+func (q *Queue) invalidateTenantUsage() {
+	meter := q.tenantMeter()
+	if meter == nil {
+		return
+	}
+
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+
+	meter.readAt = time.Time{}
+}
+
+// checkTenantQuota returns ErrQuotaExceeded when adding an item of the given size would take
+// the queue's tenant over its pending message or byte quota, and reserves the item otherwise
+func (q *Queue) checkTenantQuota(tx *sql.Tx, data any) error {
+	var size int64
+	switch v := data.(type) {
+	case []byte:
+		size = int64(len(v))
+	case string:
+		size = int64(len(v))
+	}
+
+	return q.checkTenantQuotaFor(tx, 1, size)
+}
+
+// checkTenantQuotaFor returns ErrQuotaExceeded when adding messages totalling size bytes would
+// take the queue's tenant over its quota, see checkTenantQuota
+// The usage is cached for tenantUsageTTL; with a tx the messages are reserved as part of it, see reserveTenant
+func (q *Queue) checkTenantQuotaFor(tx *sql.Tx, messages, size int64) error {
+	quota := q.tenantQuota()
+	meter := q.tenantMeter()
+	if meter == nil || (quota.MaxPending <= 0 && quota.MaxBytes <= 0) {
+		return nil
+	}
+
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+
+	if now := q.now(); meter.readAt.IsZero() || now.Sub(meter.readAt) >= tenantUsageTTL {
+		usage, err := tenantUsage(q.client, q.tenant)
+		if err != nil {
+			return fmt.Errorf("failed to check tenant quota: %w", err)
+		}
+		meter.usage, meter.readAt = usage, now
+	}
+	usage := meter.usage
+
+	if quota.MaxPending > 0 && usage.Pending+messages > quota.MaxPending {
+		return fmt.Errorf("%w: tenant %s has %d pending messages", ErrQuotaExceeded, q.tenant, usage.Pending)
+	}
+	if quota.MaxBytes > 0 && usage.Bytes+size > quota.MaxBytes {
+		return fmt.Errorf("%w: tenant %s stores %d bytes", ErrQuotaExceeded, q.tenant, usage.Bytes)
+	}
+
+	if tx != nil {
+		meter.usage.Pending += messages
+		meter.usage.Bytes += size
+		q.reserveTenant(tx, messages, size)
+	}
+	return nil
+}
+
+// reserveTenant records the messages reserved by checkTenantQuota in tx, so they aren't counted
+// again when tx commits and are given back when it rolls back
+// Reservations in transactions not started by inTx or inTrackedTx stay until the usage is read again
+func (q *Queue) reserveTenant(tx *sql.Tx, messages, size int64) {
+	if v, ok := q.txCounts.Load(tx); ok {
+		delta := v.(*countDelta)
+		delta.tenantPending += messages
+		delta.tenantBytes += size
+	}
+}
+
+// TenantUsage returns how many queues, pending messages and payload bytes the tenant currently uses
+func (q *queues) TenantUsage(tenantID string) (TenantUsage, error) {
+	usage, err := tenantUsage(q.client, tenantID)
+	if err != nil {
+		return TenantUsage{}, fmt.Errorf("failed to read tenant usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// tenantUsage sums the usage of a tenant's queues, scanning every table of the tenant
+func tenantUsage(client *sql.DB, tenantID string) (TenantUsage, error) {
+	exists, err := tableExists(client, tenantQueuesTable)
+	if err != nil || !exists {
+		return TenantUsage{}, err
+	}
+
+	// Skip queues whose table was never created or was dropped
+	rows, err := client.Query(fmt.Sprintf(
+		"SELECT queue_name FROM %s WHERE tenant_id = ? AND queue_name IN (SELECT table_name FROM duckdb_tables() WHERE schema_name = current_schema() AND database_name = current_database()) ORDER BY queue_name",
+		tenantQueuesTable,
+	), tenantID)
+	if err != nil {
+		return TenantUsage{}, err
+	}
+	defer rows.Close()
+
+	var pending, bytes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return TenantUsage{}, err
+		}
+		pending = append(pending, fmt.Sprintf("(SELECT COUNT(*) FROM %s WHERE status = 'pending')", name))
+		bytes = append(bytes, fmt.Sprintf("(SELECT COALESCE(SUM(octet_length(CAST(data AS BLOB))), 0) FROM %s)", name))
+	}
+	if err := rows.Err(); err != nil {
+		return TenantUsage{}, err
+	}
+
+	usage := TenantUsage{Queues: len(pending)}
+	if usage.Queues == 0 {
+		return usage, nil
+	}
+
+	err = client.QueryRow(fmt.Sprintf("SELECT %s, %s", strings.Join(pending, " + "), strings.Join(bytes, " + "))).Scan(&usage.Pending, &usage.Bytes)
+	return usage, err
+}
@@ -0,0 +1,128 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestTenantQuota(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_tenant_quota.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath,
+		WithTenantQuota("acme", TenantQuota{MaxQueues: 2, MaxPending: 3, MaxBytes: 20}),
+		WithTenantQuota("initech", TenantQuota{MaxPending: 2}),
+		WithDefaultTenantQuota(TenantQuota{MaxQueues: 1}),
+	)
+	defer queues.Close()
+
+	emails, err := queues.NewQueue("acme_emails", WithTenant("acme"))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	reports, err := queues.NewPriorityQueue("acme_reports", WithTenant("acme"))
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+
+	t.Run("MaxQueues", func(t *testing.T) {
+		if _, err := queues.NewQueue("acme_extra", WithTenant("acme")); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+		}
+		if _, err := queues.NewQueue("acme_emails", WithTenant("acme")); err != nil {
+			t.Errorf("Reopening an existing queue should not count against the quota: %v", err)
+		}
+		if _, err := queues.NewQueue("acme_emails", WithTenant("globex")); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for a queue of another tenant, got %v", err)
+		}
+
+		if _, err := queues.NewQueue("other_first", WithTenant("other")); err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		if _, err := queues.NewQueue("other_second", WithTenant("other")); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected the default quota to apply, got %v", err)
+		}
+	})
+
+	t.Run("MaxPending", func(t *testing.T) {
+		emails.Enqueue([]byte("a"))
+		reports.Enqueue([]byte("b"), 1)
+		emails.Enqueue([]byte("c"))
+
+		if _, err := emails.EnqueueID([]byte("d")); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected ErrQuotaExceeded across the tenant's queues, got %v", err)
+		}
+
+		// Leased items are no longer pending
+		emails.DequeueWithAckId()
+		if _, err := reports.EnqueueID([]byte("e"), 1); err != nil {
+			t.Errorf("Expected room for another pending item, got %v", err)
+		}
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		emails.Purge()
+		reports.Purge()
+
+		if _, err := emails.EnqueueID([]byte("0123456789")); err != nil {
+			t.Fatalf("EnqueueID failed: %v", err)
+		}
+		if _, err := reports.EnqueueID([]byte("0123456789a"), 1); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected ErrQuotaExceeded over the byte quota, got %v", err)
+		}
+
+		usage, err := queues.TenantUsage("acme")
+		if err != nil {
+			t.Fatalf("TenantUsage failed: %v", err)
+		}
+		if usage != (TenantUsage{Queues: 2, Pending: 1, Bytes: 10}) {
+			t.Errorf("Unexpected usage: %+v", usage)
+		}
+	})
+	t.Run("CachedUsage", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		jobs, err := queues.NewQueue("initech_jobs", WithTenant("initech"), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		// A rolled back enqueue gives its reservation back
+		rollback := errors.New("rollback")
+		err = queues.WithTx(context.Background(), func(tx QueueTx) error {
+			if err := tx.Enqueue(jobs, []byte("a")); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if !errors.Is(err, rollback) {
+			t.Fatalf("Expected the transaction to roll back, got %v", err)
+		}
+		if _, err := jobs.EnqueueID([]byte("b")); err != nil {
+			t.Fatalf("EnqueueID failed: %v", err)
+		}
+
+		// Writes of another process are only seen once the cached usage expires
+		if _, err := queues.DB().Exec("INSERT INTO initech_jobs (data, status, ack, created_at, updated_at) VALUES ('x', 'pending', 0, now(), now())"); err != nil {
+			t.Fatalf("Failed to insert item: %v", err)
+		}
+		if _, err := jobs.EnqueueID([]byte("c")); err != nil {
+			t.Errorf("Expected the cached usage to allow the enqueue, got %v", err)
+		}
+		if _, err := jobs.EnqueueID([]byte("d")); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+		}
+
+		jobs.Dequeue()
+		clock.Advance(tenantUsageTTL)
+		if _, err := jobs.EnqueueID([]byte("d")); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected the re-read usage to include the other process's item, got %v", err)
+		}
+	})
+}
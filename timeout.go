@@ -0,0 +1,148 @@
+package duckq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TimeoutAction is what happens to an item that outlives WithProcessingTimeout or WithPendingTTL
+type TimeoutAction int
+
+const (
+	// TimeoutRequeue returns a timed-out processing item to pending, so it is delivered again
+	TimeoutRequeue TimeoutAction = iota
+	// TimeoutExpire marks the item expired, it stays in the table but is never delivered
+	TimeoutExpire
+	// TimeoutDeadLetter moves the item to the dead-letter table
+	TimeoutDeadLetter
+)
+
+// Reasons recorded on items dead-lettered by a timeout
+const (
+	processingTimeoutReason = "processing timeout"
+	pendingTTLReason        = "pending ttl expired"
+	deadlineReason          = "deadline exceeded"
+)
+
+// TimeoutReport counts the items ApplyTimeouts acted on
+type TimeoutReport struct {
+	Requeued     int64
+	Expired      int64
+	DeadLettered int64
+}
+
+// ApplyTimeouts applies the queue's processing timeout to processing items whose lease expired,
+// and its pending TTL to pending items that waited longer than the TTL since becoming visible
+// Items enqueued with EnqueueWithDeadline and not acknowledged by their deadline are dead-lettered
+// The maintenance scheduler of the Queues that opened the queue applies them in the background,
+// see WithMaintenanceInterval, and ApplyTimeouts applies them right away
+func (q *Queue) ApplyTimeouts() (TimeoutReport, error) {
+	return q.applyTimeoutReport(q.processingTimeout, q.pendingTTL > 0, true)
+}
+
+// applyTimeouts applies the processing timeout and pending TTL when asked to and configured
+// Deadlines are applied along with the pending TTL, on queues that have items with a deadline
+// Returns the number of items acted on
+func (q *Queue) applyTimeouts(processing, pending bool) (int64, error) {
+	report, err := q.applyTimeoutReport(processing && q.processingTimeout, pending && q.pendingTTL > 0, pending && q.deadlines.Load())
+	return report.Requeued + report.Expired + report.DeadLettered, err
+}
+
+// applyTimeoutReport applies the processing timeout, pending TTL and deadlines as asked in a single transaction
+func (q *Queue) applyTimeoutReport(processing, pending, deadlines bool) (TimeoutReport, error) {
+	if !processing && !pending && !deadlines {
+		return TimeoutReport{}, nil
+	}
+	if err := q.checkOpen(); err != nil {
+		if err == errDropped {
+			return TimeoutReport{}, nil
+		}
+		return TimeoutReport{}, err
+	}
+
+	var report TimeoutReport
+
+	err := q.retry(func() error {
+		report = TimeoutReport{}
+		return q.inTx(func(tx *sql.Tx) error {
+			now := q.now()
+
+			if deadlines {
+				// Deadlines go first, so an item past its deadline isn't requeued or expired instead
+				moved, err := q.deadLetterInTx(tx, deadlineReason, "status IN ('pending', 'processing') AND deadline < ?", now)
+				report.DeadLettered += moved
+				if err != nil {
+					return err
+				}
+			}
+
+			if processing {
+				err := q.applyTimeout(tx, &report, q.processingAction, processingTimeoutReason,
+					"status = 'processing' AND lease_expires_at < ?", now)
+				if err != nil {
+					return err
+				}
+			}
+
+			if pending {
+				return q.applyTimeout(tx, &report, q.pendingAction, pendingTTLReason,
+					"status = 'pending' AND COALESCE(visible_at, created_at) < ?", now.Add(-q.pendingTTL))
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return TimeoutReport{}, fmt.Errorf("failed to apply timeouts: %w", err)
+	}
+	if report != (TimeoutReport{}) {
+		q.reconcileCounts()
+	}
+
+	return report, nil
+}
+
+// applyTimeout takes action on the items matching condition as part of tx
+func (q *Queue) applyTimeout(tx *sql.Tx, report *TimeoutReport, action TimeoutAction, reason, condition string, args ...any) error {
+	switch action {
+	case TimeoutDeadLetter:
+		moved, err := q.deadLetterInTx(tx, reason, condition, args...)
+		report.DeadLettered += moved
+		return err
+
+	case TimeoutExpire:
+		result, err := tx.Exec(
+			fmt.Sprintf("UPDATE %s SET status = 'expired', lease_expires_at = NULL, updated_at = ? WHERE %s", q.tableName, condition),
+			append([]any{q.now()}, args...)...,
+		)
+		if err != nil {
+			return err
+		}
+		expired, err := result.RowsAffected()
+		report.Expired += expired
+		return err
+
+	default:
+		// Requeueing respects WithMaxAttempts like Nack
+		exhausted, err := q.deadLetterExhausted(tx, condition, args...)
+		if err != nil {
+			return err
+		}
+		report.DeadLettered += exhausted
+
+		result, err := tx.Exec(
+			fmt.Sprintf("UPDATE %s SET status = 'pending', ack_id = NULL, consumer_id = NULL, lease_expires_at = NULL, updated_at = ?%s WHERE %s", q.tableName, q.retryPrioritySQL(), condition),
+			append([]any{q.now()}, args...)...,
+		)
+		if err != nil {
+			return err
+		}
+		requeued, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		report.Requeued += requeued
+
+		return q.countRequeues(tx, RequeueLeaseExpiry, requeued)
+	}
+}
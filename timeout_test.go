@@ -0,0 +1,152 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestTimeouts(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_timeouts.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	t.Run("ProcessingTimeout", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			action TimeoutAction
+			report TimeoutReport
+		}{
+			{"Requeue", TimeoutRequeue, TimeoutReport{Requeued: 1}},
+			{"Expire", TimeoutExpire, TimeoutReport{Expired: 1}},
+			{"DeadLetter", TimeoutDeadLetter, TimeoutReport{DeadLettered: 1}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+				q, err := queues.NewQueue("test_processing_"+tt.name, WithProcessingTimeout(time.Hour, tt.action), WithClock(clock))
+				if err != nil {
+					t.Fatalf("Failed to create queue: %v", err)
+				}
+
+				q.Enqueue([]byte("slow"))
+				q.Enqueue([]byte("waiting"))
+				q.DequeueWithAckId()
+
+				clock.Advance(30 * time.Minute)
+				if report, _ := q.ApplyTimeouts(); report != (TimeoutReport{}) {
+					t.Errorf("Expected nothing to time out yet, got %+v", report)
+				}
+
+				clock.Advance(time.Hour)
+				report, err := q.ApplyTimeouts()
+				if err != nil {
+					t.Fatalf("ApplyTimeouts failed: %v", err)
+				}
+				if report != tt.report {
+					t.Errorf("Expected %+v, got %+v", tt.report, report)
+				}
+
+				// The pending item has no TTL and is left alone
+				pending := 1
+				if tt.action == TimeoutRequeue {
+					pending = 2
+				}
+				if q.Len() != pending {
+					t.Errorf("Expected %d pending items, got %d", pending, q.Len())
+				}
+			})
+		}
+	})
+
+	t.Run("PendingTTL", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+		q, err := queues.NewQueue("test_pending_ttl", WithPendingTTL(time.Hour, TimeoutExpire), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		q.Enqueue([]byte("stale"))
+		clock.Advance(45 * time.Minute)
+		q.Enqueue([]byte("fresh"))
+		clock.Advance(30 * time.Minute)
+
+		report, err := q.ApplyTimeouts()
+		if err != nil {
+			t.Fatalf("ApplyTimeouts failed: %v", err)
+		}
+		if report.Expired != 1 {
+			t.Errorf("Expected 1 expired item, got %+v", report)
+		}
+
+		messages, err := q.Messages(Filter{Statuses: []string{"expired"}})
+		if err != nil {
+			t.Fatalf("Messages failed: %v", err)
+		}
+		if len(messages) != 1 || string(messages[0].Data) != "stale" {
+			t.Errorf("Expected the stale item to be expired, got %v", messages)
+		}
+		if item, _ := q.Dequeue(); string(item.([]byte)) != "fresh" {
+			t.Errorf("Expected only the fresh item to be delivered, got %v", item)
+		}
+	})
+
+	t.Run("Background", func(t *testing.T) {
+		q, err := queues.NewQueue("test_background_timeouts", WithPendingTTL(40*time.Millisecond, TimeoutDeadLetter))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		q.Enqueue([]byte("item"))
+
+		time.Sleep(100 * time.Millisecond)
+		if q.Len() != 0 {
+			t.Error("Expected the stale item to be dead-lettered in the background")
+		}
+	})
+
+	t.Run("RequeueKeepsPlace", func(t *testing.T) {
+		// An expired lease goes back ahead of items of its priority that arrived later
+		clock := &fakeClock{now: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+		pq, err := queues.NewPriorityQueue("test_requeue_place", WithProcessingTimeout(time.Minute, TimeoutRequeue), WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create priority queue: %v", err)
+		}
+		id, _ := pq.EnqueueID([]byte("urgent"), 1)
+		pq.DequeueWithAckId()
+		clock.Advance(time.Second)
+		pq.Enqueue([]byte("urgent later"), 1)
+		pq.Enqueue([]byte("bulk"), 5)
+
+		clock.Advance(time.Minute)
+		if report, err := pq.ApplyTimeouts(); err != nil || report.Requeued != 1 {
+			t.Fatalf("Expected the lease to be requeued, got %+v, %v", report, err)
+		}
+
+		m, _ := pq.Get(id)
+		if m.Attempts != 1 || !m.CreatedAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected the attempts and created_at to be preserved, got %+v", m)
+		}
+		for _, want := range []string{"urgent", "urgent later", "bulk"} {
+			if item, _ := pq.Dequeue(); string(item.([]byte)) != want {
+				t.Errorf("Expected %s, got %v", want, item)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_invalid", WithPendingTTL(time.Hour, TimeoutRequeue)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption requeueing pending items, got %v", err)
+		}
+		if _, err := queues.NewQueue("test_invalid", WithProcessingTimeout(0, TimeoutExpire)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for a zero processing timeout, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package duckq
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Topic fans published items out to every queue subscribed to it
+// Queues are bound with routing-key patterns, and each published item is delivered to the
+// queues with a pattern matching its routing key, like an AMQP topic exchange
+// Subscriptions are stored in the database, so they outlive the process that made them
+type Topic struct {
+	name    string
+	manager *queues
+}
+
+// topicSubscriptionsTable returns the name of the table holding a topic's subscriptions
+func topicSubscriptionsTable(name string) string {
+	return fmt.Sprintf("%s_subscriptions", name)
+}
+
+// NewTopic creates the topic with the given name, or opens it with its existing subscriptions
+func (q *queues) NewTopic(name string) (*Topic, error) {
+	_, err := q.client.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		queue_name TEXT NOT NULL,
+		subscribed_at TIMESTAMP,
+		pattern TEXT
+	);
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS pattern TEXT;
+	`, topicSubscriptionsTable(name), topicSubscriptionsTable(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create topic: %w", err)
+	}
+
+	return &Topic{name: name, manager: q}, nil
+}
+
+// Name returns the topic's name
+func (t *Topic) Name() string {
+	return t.name
+}
+
+// Subscribe binds the queue with the given name to the topic with the "#" pattern, so it
+// receives a copy of every item published from now on, and returns it
+// A queue already opened through the manager is reused, otherwise it is created with opts
+func (t *Topic) Subscribe(queueName string, opts ...Option) (*Queue, error) {
+	return t.Bind(queueName, matchAllPattern, opts...)
+}
+
+// Bind binds the queue with the given name to the topic, so it receives a copy of every item
+// published from now on with a routing key matching pattern, and returns it
+// Routing keys and patterns are dot-separated words, in patterns "*" matches exactly one word
+// and "#" matches zero or more, e.g. "orders.*" matches "orders.created" but not "orders"
+// A queue can be bound with several patterns, and still receives a single copy of each item
+// Binding the same pattern twice keeps a single binding
+func (t *Topic) Bind(queueName, pattern string, opts ...Option) (*Queue, error) {
+	queue, err := t.queue(queueName, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.manager.client.Exec(
+		fmt.Sprintf("INSERT INTO %s (queue_name, subscribed_at, pattern) SELECT CAST(? AS TEXT), CAST(? AS TIMESTAMP), CAST(? AS TEXT) WHERE NOT EXISTS (SELECT 1 FROM %s WHERE queue_name = ? AND %s = ?)", topicSubscriptionsTable(t.name), topicSubscriptionsTable(t.name), patternColumnSQL),
+		queueName, queue.now(), pattern, queueName, pattern,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind %s: %w", queueName, err)
+	}
+
+	return queue, nil
+}
+
+// Unbind removes a single binding of the queue with the given name
+// The queue keeps receiving items matching its other patterns
+func (t *Topic) Unbind(queueName, pattern string) error {
+	_, err := t.manager.client.Exec(fmt.Sprintf("DELETE FROM %s WHERE queue_name = ? AND %s = ?", topicSubscriptionsTable(t.name), patternColumnSQL), queueName, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to unbind %s: %w", queueName, err)
+	}
+
+	return nil
+}
+
+// Unsubscribe removes every binding of the queue with the given name from the topic
+// Items already delivered to the queue stay in it
+func (t *Topic) Unsubscribe(queueName string) error {
+	_, err := t.manager.client.Exec(fmt.Sprintf("DELETE FROM %s WHERE queue_name = ?", topicSubscriptionsTable(t.name)), queueName)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe %s: %w", queueName, err)
+	}
+
+	return nil
+}
+
+// Subscribers returns the names of the queues subscribed to the topic, oldest subscription first
+func (t *Topic) Subscribers() ([]string, error) {
+	bindings, err := t.Bindings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribers: %w", err)
+	}
+
+	var names []string
+	seen := make(map[string]bool, len(bindings))
+	for _, binding := range bindings {
+		if !seen[binding.Queue] {
+			seen[binding.Queue] = true
+			names = append(names, binding.Queue)
+		}
+	}
+
+	return names, nil
+}
+
+// Binding is a queue bound to a topic with a routing-key pattern
+type Binding struct {
+	Queue   string
+	Pattern string
+}
+
+// Bindings returns the topic's bindings, oldest first
+func (t *Topic) Bindings() ([]Binding, error) {
+	rows, err := t.manager.client.Query(fmt.Sprintf("SELECT queue_name, %s FROM %s ORDER BY subscribed_at ASC, queue_name ASC", patternColumnSQL, topicSubscriptionsTable(t.name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bindings: %w", err)
+	}
+	defer rows.Close()
+
+	var bindings []Binding
+	for rows.Next() {
+		var binding Binding
+		if err := rows.Scan(&binding.Queue, &binding.Pattern); err != nil {
+			return nil, fmt.Errorf("failed to list bindings: %w", err)
+		}
+		bindings = append(bindings, binding)
+	}
+
+	return bindings, rows.Err()
+}
+
+// matchAllPattern is the routing-key pattern matching every routing key
+const matchAllPattern = "#"
+
+// patternColumnSQL reads a binding's pattern, subscriptions made before patterns existed match everything
+const patternColumnSQL = "COALESCE(pattern, '" + matchAllPattern + "')"
+
+// matchRoutingKey reports whether a routing key matches a binding pattern
+func matchRoutingKey(pattern, routingKey string) bool {
+	var keyWords []string
+	if routingKey != "" {
+		keyWords = strings.Split(routingKey, ".")
+	}
+
+	return matchWords(strings.Split(pattern, "."), keyWords)
+}
+
+// matchWords matches the words of a routing key against the words of a pattern
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		// Either "#" matches no more words, or it swallows the next one
+		return matchWords(pattern[1:], key) || len(key) > 0 && matchWords(pattern, key[1:])
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+	}
+}
+
+// Publish enqueues a copy of item into every queue subscribed to the topic with "#" in a single
+// transaction, so either every subscriber receives it or none does
+// It publishes with an empty routing key, which patterns of one or more words don't match
+// Returns the number of queues the item was delivered to
+func (t *Topic) Publish(item any) (int, error) {
+	return t.PublishWithKey("", item)
+}
+
+// PublishWithKey enqueues a copy of item into every queue bound with a pattern matching
+// routingKey in a single transaction, so either every matching queue receives it or none does
+// The transaction is retried with the retry policy of the queue making the most attempts, and the
+// rate limits of the queues only count the item once every queue accepted it
+// Returns the number of queues the item was delivered to, which is 0 when no binding matches
+func (t *Topic) PublishWithKey(routingKey string, item any) (int, error) {
+	subscribers, err := t.subscribers(routingKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := checkEnqueueRates(subscribers); err != nil {
+		return 0, fmt.Errorf("failed to publish to topic %s: %w", t.name, err)
+	}
+
+	err = retryPolicyFor(subscribers).do(func() error {
+		return inTrackedTx(context.Background(), t.manager.client, func(tx *trackedTx) error {
+			for _, queue := range subscribers {
+				tx.track(queue)
+			}
+			return publishInTx(tx.tx, subscribers, item)
+		})
+	})
+	if err != nil {
+		refundEnqueueRates(subscribers)
+		return 0, fmt.Errorf("failed to publish to topic %s: %w", t.name, err)
+	}
+
+	return len(subscribers), nil
+}
+
+// PublishInTx enqueues a copy of item into every queue bound with "#" as part of the caller's
+// transaction, see EnqueueInTx
+// Returns the number of queues the item was delivered to
+func (t *Topic) PublishInTx(tx *sql.Tx, item any) (int, error) {
+	return t.PublishWithKeyInTx(tx, "", item)
+}
+
+// PublishWithKeyInTx is PublishWithKey as part of the caller's transaction
+func (t *Topic) PublishWithKeyInTx(tx *sql.Tx, routingKey string, item any) (int, error) {
+	subscribers, err := t.subscribers(routingKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := checkEnqueueRates(subscribers); err != nil {
+		return 0, fmt.Errorf("failed to publish to topic %s: %w", t.name, err)
+	}
+	if err := publishInTx(tx, subscribers, item); err != nil {
+		refundEnqueueRates(subscribers)
+		return 0, fmt.Errorf("failed to publish to topic %s: %w", t.name, err)
+	}
+
+	return len(subscribers), nil
+}
+
+// publishInTx enqueues item into every queue as part of tx
+func publishInTx(tx *sql.Tx, subscribers []*Queue, item any) error {
+	for _, queue := range subscribers {
+		if _, err := queue.enqueueInTx(tx, item, sql.NullTime{}); err != nil {
+			return fmt.Errorf("failed to enqueue into %s: %w", queue.tableName, err)
+		}
+	}
+
+	return nil
+}
+
+// subscribers resolves the queues with a binding matching routingKey, opening those that
+// are bound in the database but weren't opened by this process yet
+func (t *Topic) subscribers(routingKey string) ([]*Queue, error) {
+	bindings, err := t.Bindings()
+	if err != nil {
+		return nil, err
+	}
+
+	var subscribers []*Queue
+	seen := make(map[string]bool, len(bindings))
+	for _, binding := range bindings {
+		if seen[binding.Queue] || !matchRoutingKey(binding.Pattern, routingKey) {
+			continue
+		}
+		seen[binding.Queue] = true
+
+		queue, err := t.queue(binding.Queue)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, queue)
+	}
+
+	return subscribers, nil
+}
+
+// queue returns the queue opened through the manager with the given name, or opens it with opts
+func (t *Topic) queue(name string, opts ...Option) (*Queue, error) {
+	t.manager.mu.Lock()
+	queue, ok := t.manager.opened[name]
+	t.manager.mu.Unlock()
+	if ok {
+		return queue, nil
+	}
+
+	return t.manager.NewQueue(name, opts...)
+}
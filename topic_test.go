@@ -0,0 +1,230 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestTopic(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_topic.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	topic, err := queues.NewTopic("orders")
+	if err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+
+	billing, err := topic.Subscribe("billing")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	shipping, err := queues.NewPriorityQueue("shipping")
+	if err != nil {
+		t.Fatalf("Failed to create priority queue: %v", err)
+	}
+	if _, err := topic.Subscribe("shipping"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, err := topic.Subscribe("billing"); err != nil {
+		t.Fatalf("Subscribing twice failed: %v", err)
+	}
+
+	t.Run("Subscribers", func(t *testing.T) {
+		names, err := topic.Subscribers()
+		if err != nil {
+			t.Fatalf("Subscribers failed: %v", err)
+		}
+		if len(names) != 2 || names[0] != "billing" || names[1] != "shipping" {
+			t.Errorf("Unexpected subscribers: %v", names)
+		}
+	})
+
+	t.Run("FanOut", func(t *testing.T) {
+		delivered, err := topic.Publish([]byte("order-1"))
+		if err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if delivered != 2 {
+			t.Errorf("Expected 2 deliveries, got %d", delivered)
+		}
+		if billing.ApproxLen() != 1 || shipping.ApproxLen() != 1 {
+			t.Errorf("Expected the subscribers' counters to follow the publish, got %d and %d", billing.ApproxLen(), shipping.ApproxLen())
+		}
+
+		if item, _ := billing.Dequeue(); string(item.([]byte)) != "order-1" {
+			t.Errorf("Unexpected billing item: %v", item)
+		}
+		if item, _ := shipping.Dequeue(); string(item.([]byte)) != "order-1" {
+			t.Errorf("Unexpected shipping item: %v", item)
+		}
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		billing.Close()
+		defer billing.Reopen()
+
+		if _, err := topic.Publish([]byte("order-2")); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("Expected ErrQueueClosed, got %v", err)
+		}
+		if shipping.Len() != 0 {
+			t.Errorf("Expected no delivery to any subscriber, got %d shipping items", shipping.Len())
+		}
+	})
+
+	t.Run("PublishInTx", func(t *testing.T) {
+		tx, err := queues.DB().Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		if _, err := topic.PublishInTx(tx, []byte("order-3")); err != nil {
+			t.Fatalf("PublishInTx failed: %v", err)
+		}
+		tx.Rollback()
+
+		if billing.Len() != 0 || shipping.Len() != 0 {
+			t.Error("Expected a rolled back publish to deliver nothing")
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		if err := topic.Unsubscribe("shipping"); err != nil {
+			t.Fatalf("Unsubscribe failed: %v", err)
+		}
+		if delivered, _ := topic.Publish([]byte("order-4")); delivered != 1 {
+			t.Errorf("Expected 1 delivery, got %d", delivered)
+		}
+		if shipping.Len() != 0 {
+			t.Error("Expected no delivery to an unsubscribed queue")
+		}
+	})
+
+	t.Run("PersistedSubscriptions", func(t *testing.T) {
+		queues.Close()
+		other := New(dbPath)
+		defer other.Close()
+
+		reopened, err := other.NewTopic("orders")
+		if err != nil {
+			t.Fatalf("Failed to reopen topic: %v", err)
+		}
+		if delivered, err := reopened.Publish([]byte("order-5")); err != nil || delivered != 1 {
+			t.Fatalf("Expected 1 delivery to the stored subscription, got %d: %v", delivered, err)
+		}
+
+		q, err := other.NewQueue("billing")
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		if q.Len() != 2 {
+			t.Errorf("Expected 2 billing items, got %d", q.Len())
+		}
+	})
+}
+
+func TestTopicRouting(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_topic_routing.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	topic, err := queues.NewTopic("events")
+	if err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+
+	orders, err := topic.Bind("orders", "orders.*")
+	if err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	audit, err := topic.Subscribe("audit")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	eu, err := topic.Bind("eu", "*.*.eu")
+	if err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if _, err := topic.Bind("eu", "orders.#"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	tests := []struct {
+		routingKey string
+		delivered  int
+		orders     int
+		eu         int
+	}{
+		{"orders.created", 3, 1, 1},
+		{"orders.created.eu", 2, 1, 2},
+		{"users.created.eu", 2, 1, 3},
+		{"users.deleted", 1, 1, 3},
+		{"", 1, 1, 3},
+	}
+
+	for _, tt := range tests {
+		delivered, err := topic.PublishWithKey(tt.routingKey, []byte(tt.routingKey))
+		if err != nil {
+			t.Fatalf("PublishWithKey(%q) failed: %v", tt.routingKey, err)
+		}
+		if delivered != tt.delivered {
+			t.Errorf("Expected %q to reach %d queues, got %d", tt.routingKey, tt.delivered, delivered)
+		}
+		if orders.Len() != tt.orders || eu.Len() != tt.eu {
+			t.Errorf("After %q expected orders=%d eu=%d, got orders=%d eu=%d", tt.routingKey, tt.orders, tt.eu, orders.Len(), eu.Len())
+		}
+	}
+	if audit.Len() != len(tests) {
+		t.Errorf("Expected the catch-all subscriber to receive every item, got %d", audit.Len())
+	}
+
+	t.Run("Unbind", func(t *testing.T) {
+		if err := topic.Unbind("eu", "orders.#"); err != nil {
+			t.Fatalf("Unbind failed: %v", err)
+		}
+		bindings, err := topic.Bindings()
+		if err != nil {
+			t.Fatalf("Bindings failed: %v", err)
+		}
+		if len(bindings) != 3 {
+			t.Errorf("Expected 3 bindings, got %v", bindings)
+		}
+		if delivered, _ := topic.PublishWithKey("orders.paid", []byte("paid")); delivered != 2 {
+			t.Errorf("Expected 2 deliveries after Unbind, got %d", delivered)
+		}
+	})
+}
+
+func TestMatchRoutingKey(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		match        bool
+	}{
+		{"#", "", true},
+		{"#", "a.b.c", true},
+		{"a.*", "a.b", true},
+		{"a.*", "a", false},
+		{"a.*", "a.b.c", false},
+		{"a.#", "a", true},
+		{"a.#.c", "a.b.b.c", true},
+		{"a.#.c", "a.b.d", false},
+		{"*", "", false},
+		{"a.b", "a.b", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchRoutingKey(tt.pattern, tt.key); got != tt.match {
+			t.Errorf("matchRoutingKey(%q, %q) = %v, expected %v", tt.pattern, tt.key, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package duckq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// QueueTx runs queue operations as part of a single transaction, see Queues.WithTx
+// Every queue passed in must have been opened on the manager's database
+type QueueTx interface {
+	// Enqueue adds an item to queue, see Queue.EnqueueInTx
+	Enqueue(queue *Queue, item any) error
+	// Dequeue leases the next pending item of queue and returns it with its ack ID
+	// Items failing their checksum or signature are dead-lettered as part of the transaction and skipped, like Queue.Dequeue does
+	// Returns ErrEmptyQueue when no pending item is available
+	Dequeue(queue *Queue) (item any, ackID string, err error)
+	// Ack acknowledges a processing item of queue, see Queue.Ack
+	Ack(queue *Queue, ackID string) error
+	// Nack returns a processing item of queue to pending, see Queue.Nack
+	Nack(queue *Queue, ackID string) error
+	// Tx returns the underlying transaction, to run the application's own SQL in it
+	Tx() *sql.Tx
+}
+
+// queueTx implements QueueTx on top of a tracked transaction of the manager's database
+type queueTx struct {
+	tracked *trackedTx
+	client  *sql.DB
+}
+
+// WithTx runs fn in a transaction of the queues' database and commits it if fn returns nil,
+// so queue operations and the application's own SQL are applied atomically
+// Leases taken by Dequeue and items added by Enqueue are undone when fn fails or the commit does;
+// once it commits, the queues' counters follow and their consumers are woken like after Enqueue
+// fn may run again when the transaction conflicts with a concurrent writer, so it must not have
+// side effects outside the transaction
+func (q *queues) WithTx(ctx context.Context, fn func(tx QueueTx) error) error {
+	return DefaultRetryPolicy.do(func() error {
+		return inTrackedTx(ctx, q.client, func(tx *trackedTx) error {
+			return fn(&queueTx{tracked: tx, client: q.client})
+		})
+	})
+}
+
+// use returns ErrInvalidOption when queue belongs to another database than the transaction,
+// and otherwise records the transaction's changes to queue
+func (t *queueTx) use(queue *Queue) error {
+	if queue.client != t.client {
+		return fmt.Errorf("%w: queue %s is not opened on the transaction's database", ErrInvalidOption, queue.tableName)
+	}
+
+	t.tracked.track(queue)
+	return nil
+}
+
+func (t *queueTx) Enqueue(queue *Queue, item any) error {
+	if err := t.use(queue); err != nil {
+		return err
+	}
+
+	return queue.EnqueueInTx(t.tracked.tx, item)
+}
+
+func (t *queueTx) Dequeue(queue *Queue) (any, string, error) {
+	if err := t.use(queue); err != nil {
+		return nil, "", err
+	}
+	if err := queue.checkOpen(); err != nil {
+		if err == errDropped {
+			return nil, "", ErrEmptyQueue
+		}
+		return nil, "", err
+	}
+
+	row, err := queue.dequeueVerifiedInTx(t.tracked.tx, true, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return queue.decode(row.data, row.payloadType), row.ackID, nil
+}
+
+func (t *queueTx) Ack(queue *Queue, ackID string) error {
+	if err := t.use(queue); err != nil {
+		return err
+	}
+
+	err := queue.ackInTx(t.tracked.tx, ackID)
+	if err != nil && !errors.Is(err, ErrDuplicateAck) && !errors.Is(err, ErrAckNotFound) {
+		return fmt.Errorf("failed to acknowledge item: %w", err)
+	}
+
+	return err
+}
+
+func (t *queueTx) Nack(queue *Queue, ackID string) error {
+	if err := t.use(queue); err != nil {
+		return err
+	}
+
+	if err := queue.nackInTx(t.tracked.tx, ackID); err != nil {
+		if errors.Is(err, ErrAckNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to nack item: %w", err)
+	}
+
+	return nil
+}
+
+func (t *queueTx) Tx() *sql.Tx {
+	return t.tracked.tx
+}
@@ -0,0 +1,177 @@
+package duckq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestWithTx(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_with_tx.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	orders, err := queues.NewQueue("test_orders")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	invoices, err := queues.NewQueue("test_invoices")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	if _, err := queues.DB().Exec("CREATE TABLE test_ledger (entry TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	defer queues.DB().Exec("DROP TABLE test_ledger")
+
+	ledgerEntries := func() int {
+		var count int
+		queues.DB().QueryRow("SELECT COUNT(*) FROM test_ledger").Scan(&count)
+		return count
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		orders.Enqueue([]byte("order"))
+
+		err := queues.WithTx(context.Background(), func(tx QueueTx) error {
+			item, ackID, err := tx.Dequeue(orders)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Tx().Exec("INSERT INTO test_ledger VALUES (?)", string(item.([]byte))); err != nil {
+				return err
+			}
+			if err := tx.Enqueue(invoices, []byte("invoice")); err != nil {
+				return err
+			}
+			return tx.Ack(orders, ackID)
+		})
+		if err != nil {
+			t.Fatalf("WithTx failed: %v", err)
+		}
+
+		if orders.Len() != 0 || invoices.Len() != 1 || ledgerEntries() != 1 {
+			t.Errorf("Expected every operation to be committed, got %d orders, %d invoices and %d entries", orders.Len(), invoices.Len(), ledgerEntries())
+		}
+		if orders.ApproxLen() != 0 || invoices.ApproxLen() != 1 {
+			t.Errorf("Expected the counters to follow the commit, got %d orders and %d invoices", orders.ApproxLen(), invoices.ApproxLen())
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		orders.Enqueue([]byte("order"))
+		errFailed := errors.New("failed")
+
+		err := queues.WithTx(context.Background(), func(tx QueueTx) error {
+			_, ackID, err := tx.Dequeue(orders)
+			if err != nil {
+				return err
+			}
+			tx.Tx().Exec("INSERT INTO test_ledger VALUES ('rolled back')")
+			tx.Enqueue(invoices, []byte("invoice"))
+			tx.Ack(orders, ackID)
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Fatalf("Expected the callback error, got %v", err)
+		}
+
+		if orders.Len() != 1 || invoices.Len() != 1 || ledgerEntries() != 1 {
+			t.Errorf("Expected every operation to be rolled back, got %d orders, %d invoices and %d entries", orders.Len(), invoices.Len(), ledgerEntries())
+		}
+	})
+
+	t.Run("Nack", func(t *testing.T) {
+		err := queues.WithTx(context.Background(), func(tx QueueTx) error {
+			_, ackID, err := tx.Dequeue(orders)
+			if err != nil {
+				return err
+			}
+			return tx.Nack(orders, ackID)
+		})
+		if err != nil {
+			t.Fatalf("WithTx failed: %v", err)
+		}
+		if orders.Len() != 1 {
+			t.Errorf("Expected the nacked item to be pending, got %d", orders.Len())
+		}
+	})
+
+	t.Run("OffloadedAck", func(t *testing.T) {
+		dir := t.TempDir()
+		offloaded, err := queues.NewQueue("test_offloaded", WithPayloadOffload(NewFileBlobStore(dir), 16), WithRemoveOnComplete(true))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		offloaded.Enqueue(bytes.Repeat([]byte("x"), 1024))
+
+		err = queues.WithTx(context.Background(), func(tx QueueTx) error {
+			_, ackID, err := tx.Dequeue(offloaded)
+			if err != nil {
+				return err
+			}
+			return tx.Ack(offloaded, ackID)
+		})
+		if err != nil {
+			t.Fatalf("WithTx failed: %v", err)
+		}
+
+		if matches, _ := filepath.Glob(filepath.Join(dir, "test_offloaded", "*")); len(matches) != 0 {
+			t.Errorf("Expected the acknowledged payload to be deleted, got %d", len(matches))
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		var corrupted []int64
+		checked, err := queues.NewQueue("test_checked", WithChecksum(true), WithCorruptionHandler(func(id int64, err error) {
+			corrupted = append(corrupted, id)
+		}))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		id, _ := checked.EnqueueID([]byte("intact"))
+		checked.Enqueue([]byte("next"))
+		if _, err := queues.DB().Exec("UPDATE test_checked SET data = ? WHERE id = ?", []byte("tampered"), id); err != nil {
+			t.Fatalf("Failed to tamper with the item: %v", err)
+		}
+
+		var item any
+		err = queues.WithTx(context.Background(), func(tx QueueTx) (err error) {
+			item, _, err = tx.Dequeue(checked)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("WithTx failed: %v", err)
+		}
+
+		if string(item.([]byte)) != "next" {
+			t.Errorf("Expected the corrupted item to be skipped, got %v", item)
+		}
+		if len(corrupted) != 1 || corrupted[0] != id {
+			t.Errorf("Expected the corruption handler to report item %d, got %v", id, corrupted)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		err := queues.WithTx(context.Background(), func(tx QueueTx) error {
+			// The only invoice is leased by the first call
+			if _, _, err := tx.Dequeue(invoices); err != nil {
+				return err
+			}
+			_, _, err := tx.Dequeue(invoices)
+			return err
+		})
+		if !errors.Is(err, ErrEmptyQueue) {
+			t.Errorf("Expected ErrEmptyQueue, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package duckq
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// signalsTable holds a sequence number per queue, bumped whenever items become pending, see WithWakeupSignals
+const signalsTable = "duckq_signals"
+
+// wakeup broadcasts changes of a queue's signal sequence to the channels handed out by Wakeup
+type wakeup struct {
+	interval time.Duration
+
+	mu  sync.Mutex
+	seq int64
+	ch  chan struct{}
+}
+
+// WithWakeupSignals bumps a sequence number in the shared duckq_signals table whenever a
+// transaction of the queue makes items pending, and watches it every interval, so consumers in
+// other processes sharing the database file wake within milliseconds of an enqueue instead of
+// waiting out long poll intervals
+// Producers and consumers must both open the queue with the option; Run and Wakeup pick it up
+// Topic.Publish, Queues.EnqueueAll and Queues.WithTx signal once they commit, as do Nack,
+// ReplayDeadLetters and ReleaseQuarantined when they make items pending again, while items enqueued
+// in the caller's transaction with EnqueueInTx or PublishInTx, or made visible by a delay passing,
+// don't signal
+func WithWakeupSignals(interval time.Duration) Option {
+	return func(q *Queue) {
+		q.wakeup = &wakeup{interval: interval}
+	}
+}
+
+// initWakeupSignals creates the signals table of a queue created with WithWakeupSignals
+func (q *Queue) initWakeupSignals(db execer) error {
+	if q.wakeup == nil {
+		return nil
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		queue_name TEXT PRIMARY KEY,
+		seq BIGINT NOT NULL
+	);
+	`, signalsTable))
+	return err
+}
+
+// loadWakeupSignals reads the signal sequence of a queue created with WithWakeupSignals,
+// so the watcher only wakes consumers for later signals
+func (q *Queue) loadWakeupSignals() error {
+	if q.wakeup == nil {
+		return nil
+	}
+
+	seq, err := q.signalSeq()
+	if err != nil {
+		return err
+	}
+	q.wakeup.seq = seq
+	return nil
+}
+
+// signalSeq returns the queue's signal sequence, or 0 when it was never bumped
+func (q *Queue) signalSeq() (int64, error) {
+	var seq int64
+	err := q.client.QueryRow(
+		fmt.Sprintf("SELECT COALESCE(MAX(seq), 0) FROM %s WHERE queue_name = ?", signalsTable),
+		q.tableName,
+	).Scan(&seq)
+	return seq, err
+}
+
+// startWakeupWatcher starts watching the signal sequence of a queue created with WithWakeupSignals
+func (q *Queue) startWakeupWatcher() {
+	if q.wakeup == nil {
+		return
+	}
+
+	q.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(q.wakeup.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			if seq, err := q.signalSeq(); err == nil {
+				q.wakeup.observe(seq)
+			}
+		}
+	})
+}
+
+// signalWakeup bumps the queue's signal sequence after a transaction made items pending
+// Failing to bump only delays other processes until they poll again, so errors are dropped
+func (q *Queue) signalWakeup() {
+	if q.wakeup == nil {
+		return
+	}
+
+	var seq int64
+	err := q.retry(func() error {
+		return q.client.QueryRow(
+			fmt.Sprintf("INSERT INTO %s (queue_name, seq) VALUES (?, 1) ON CONFLICT (queue_name) DO UPDATE SET seq = seq + 1 RETURNING seq", signalsTable),
+			q.tableName,
+		).Scan(&seq)
+	})
+	if err != nil {
+		return
+	}
+	q.wakeup.observe(seq)
+}
+
+// observe wakes the waiting channels when seq differs from the last sequence seen
+func (w *wakeup) observe(seq int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if seq == w.seq {
+		return
+	}
+	w.seq = seq
+	if w.ch != nil {
+		close(w.ch)
+		w.ch = nil
+	}
+}
+
+// Wakeup returns a channel that is closed the next time items of the queue become pending in
+// any process, see WithWakeupSignals
+// Take the channel before dequeuing and wait on it after finding the queue empty, so an enqueue
+// in between isn't missed; wake-ups can be spurious, e.g. when another consumer took the item
+// Returns nil, which never fires, for queues created without WithWakeupSignals
+func (q *Queue) Wakeup() <-chan struct{} {
+	if q.wakeup == nil {
+		return nil
+	}
+
+	q.wakeup.mu.Lock()
+	defer q.wakeup.mu.Unlock()
+
+	if q.wakeup.ch == nil {
+		q.wakeup.ch = make(chan struct{})
+	}
+	return q.wakeup.ch
+}
@@ -0,0 +1,209 @@
+package duckq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestWakeupSignals(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_wakeup.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	// Separate handles on the same table stand in for processes sharing the database file
+	producer, err := queues.NewQueue("test_wakeup", WithWakeupSignals(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	consumer, err := queues.NewQueue("test_wakeup", WithWakeupSignals(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	t.Run("Enqueue", func(t *testing.T) {
+		wake := consumer.Wakeup()
+		select {
+		case <-wake:
+			t.Fatal("Expected no wake-up before an enqueue")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		producer.Enqueue("job")
+		select {
+		case <-wake:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the consumer to wake up")
+		}
+		consumer.Dequeue()
+	})
+
+	t.Run("MultiQueueTransactions", func(t *testing.T) {
+		topic, err := queues.NewTopic("test_wakeup_topic")
+		if err != nil {
+			t.Fatalf("Failed to create topic: %v", err)
+		}
+		if _, err := topic.Subscribe("test_wakeup"); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+
+		operations := map[string]func() error{
+			"Publish": func() error {
+				_, err := topic.Publish([]byte("job"))
+				return err
+			},
+			"EnqueueAll": func() error {
+				return queues.EnqueueAll(context.Background(), map[string][]byte{"test_wakeup": []byte("job")})
+			},
+			"WithTx": func() error {
+				return queues.WithTx(context.Background(), func(tx QueueTx) error {
+					return tx.Enqueue(producer, []byte("job"))
+				})
+			},
+		}
+		for name, operation := range operations {
+			before, _ := consumer.signalSeq()
+			if err := operation(); err != nil {
+				t.Fatalf("%s failed: %v", name, err)
+			}
+			if after, _ := consumer.signalSeq(); after != before+1 {
+				t.Errorf("Expected %s to bump the signal, got %d after %d", name, after, before)
+			}
+			consumer.Dequeue()
+		}
+	})
+
+	t.Run("MovedItems", func(t *testing.T) {
+		quarantined, err := queues.NewQueue("test_wakeup_moved",
+			WithWakeupSignals(5*time.Millisecond),
+			WithQuarantine(QuarantinePolicy{MaxFailures: 1, Window: time.Hour}),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		var id int64
+		operations := map[string]func() error{
+			"ReplayDeadLetters": func() error {
+				_, _, ackID := quarantined.DequeueWithAckId()
+				quarantined.DeadLetter(ackID, "broken")
+				_, err := quarantined.ReplayDeadLetters(0, false)
+				return err
+			},
+			"ReleaseQuarantined": func() error {
+				_, _, ackID := quarantined.DequeueWithAckId()
+				if _, err := quarantined.RecordFailure(ackID, HandlerFailure{Err: errors.New("boom"), Panicked: true}); err != nil {
+					return err
+				}
+				return quarantined.ReleaseQuarantined(id)
+			},
+		}
+		for name, operation := range operations {
+			id, _ = quarantined.EnqueueID([]byte("job"))
+			wake := quarantined.Wakeup()
+			before, _ := quarantined.signalSeq()
+			if err := operation(); err != nil {
+				t.Fatalf("%s failed: %v", name, err)
+			}
+			if after, _ := quarantined.signalSeq(); after != before+1 {
+				t.Errorf("Expected %s to bump the signal, got %d after %d", name, after, before)
+			}
+			select {
+			case <-wake:
+			case <-time.After(time.Second):
+				t.Errorf("Expected %s to wake the consumer", name)
+			}
+			quarantined.Dequeue()
+		}
+	})
+
+	t.Run("ExternalBump", func(t *testing.T) {
+		wake := consumer.Wakeup()
+		if _, err := queues.DB().Exec("UPDATE duckq_signals SET seq = seq + 1 WHERE queue_name = 'test_wakeup'"); err != nil {
+			t.Fatalf("Failed to bump the signal: %v", err)
+		}
+		select {
+		case <-wake:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the consumer to wake up")
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		handled := make(chan time.Time, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- Run(ctx, consumer, func(ctx context.Context, item any) error {
+				handled <- time.Now()
+				return nil
+			}, WithPollInterval(time.Minute), WithSignals())
+		}()
+
+		// Let Run find the queue empty and start waiting
+		time.Sleep(50 * time.Millisecond)
+		enqueued := time.Now()
+		producer.Enqueue("job")
+		select {
+		case at := <-handled:
+			if at.Sub(enqueued) > time.Second {
+				t.Errorf("Expected the item to be handled promptly, took %v", at.Sub(enqueued))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Run to wake up before its poll interval")
+		}
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	})
+
+	t.Run("PriorityQueue", func(t *testing.T) {
+		producer, err := queues.NewPriorityQueue("test_wakeup_priority", WithWakeupSignals(5*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		consumer, err := queues.NewPriorityQueue("test_wakeup_priority", WithWakeupSignals(5*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+
+		wake := consumer.Wakeup()
+		producer.Enqueue("job", 1)
+		select {
+		case <-wake:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the priority queue consumer to wake up")
+		}
+
+		var seq int64
+		if err := queues.DB().QueryRow("SELECT seq FROM duckq_signals WHERE queue_name = 'test_wakeup_priority'").Scan(&seq); err != nil || seq != 1 {
+			t.Errorf("Expected the enqueue to bump the signal once, got %d (%v)", seq, err)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		plain, err := queues.NewQueue("test_wakeup_plain")
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		if plain.Wakeup() != nil {
+			t.Error("Expected no wake-up channel without WithWakeupSignals")
+		}
+	})
+
+	t.Run("InvalidInterval", func(t *testing.T) {
+		if _, err := queues.NewQueue("test_wakeup_invalid", WithWakeupSignals(0)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package duckq
+
+import (
+	"slices"
+	"time"
+)
+
+// DeliveryWindow is a daily time range during which a queue created with WithDeliveryWindow delivers items
+type DeliveryWindow struct {
+	// Start is the time of day the window opens, as "15:04"
+	Start string `yaml:"start" json:"start"`
+	// End is the time of day the window closes, as "15:04"
+	// An End before Start closes the window on the next day, e.g. "22:00" to "06:00"
+	End string `yaml:"end" json:"end"`
+	// Days limits the window to the weekdays it opens on, every day when empty
+	Days []time.Weekday `yaml:"days" json:"days"`
+	// Location is the time zone of Start and End, time.Local when nil
+	Location *time.Location `yaml:"-" json:"-"`
+}
+
+// WithDeliveryWindow only lets items be dequeued during the given windows, e.g. to run batch
+// work at night; outside of them every dequeue finds the queue empty while enqueues go on
+// Items keep their order, and leases taken inside a window run to completion after it closes
+func WithDeliveryWindow(windows ...DeliveryWindow) Option {
+	return func(q *Queue) {
+		q.deliveryWindows = append(q.deliveryWindows, windows...)
+	}
+}
+
+// parseTimeOfDay returns the offset from midnight of a "15:04" time of day
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// valid reports whether the window's times of day can be parsed
+func (w DeliveryWindow) valid() bool {
+	_, startErr := parseTimeOfDay(w.Start)
+	_, endErr := parseTimeOfDay(w.End)
+	return startErr == nil && endErr == nil && w.Start != w.End
+}
+
+// contains reports whether the window is open at now
+func (w DeliveryWindow) contains(now time.Time) bool {
+	location := w.Location
+	if location == nil {
+		location = time.Local
+	}
+	now = now.In(location)
+
+	start, _ := parseTimeOfDay(w.Start)
+	end, _ := parseTimeOfDay(w.End)
+	// Read the wall clock, so days with a daylight saving change keep their windows
+	sinceMidnight := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+
+	opensOn := func(day time.Weekday) bool {
+		return len(w.Days) == 0 || slices.Contains(w.Days, day)
+	}
+
+	if start < end {
+		return opensOn(now.Weekday()) && sinceMidnight >= start && sinceMidnight < end
+	}
+
+	// The window spans midnight, so the early hours belong to the window opened the day before
+	if sinceMidnight >= start {
+		return opensOn(now.Weekday())
+	}
+	return sinceMidnight < end && opensOn((now.Weekday()+6)%7)
+}
+
+// inDeliveryWindow reports whether the queue may deliver items at now
+func (q *Queue) inDeliveryWindow(now time.Time) bool {
+	if len(q.deliveryWindows) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(q.deliveryWindows, func(w DeliveryWindow) bool {
+		return w.contains(now)
+	})
+}
@@ -0,0 +1,78 @@
+package duckq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+func TestDeliveryWindow(t *testing.T) {
+	// Create a temporary database file
+	dbPath := "test_delivery_window.db"
+
+	// Cleanup after test
+	defer os.Remove(dbPath)
+	queues := New(dbPath)
+	defer queues.Close()
+
+	// Saturday noon
+	clock := &fakeClock{now: time.Date(2030, 1, 5, 12, 0, 0, 0, time.UTC)}
+	night := DeliveryWindow{Start: "22:00", End: "06:00", Days: []time.Weekday{time.Saturday}, Location: time.UTC}
+	q, err := queues.NewQueue("test_queue", WithDeliveryWindow(night), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	q.Enqueue([]byte("batch"))
+
+	t.Run("Closed", func(t *testing.T) {
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected no delivery outside the window")
+		}
+		if q.Len() != 1 {
+			t.Errorf("Expected the item to stay pending, got %d", q.Len())
+		}
+	})
+
+	t.Run("OpenAcrossMidnight", func(t *testing.T) {
+		// Sunday 01:00 belongs to the window opened on Saturday night
+		clock.Advance(13 * time.Hour)
+		item, success, ackID := q.DequeueWithAckId()
+		if !success || string(item.([]byte)) != "batch" {
+			t.Fatalf("Expected the item inside the window, got %v", item)
+		}
+		q.Acknowledge(ackID)
+	})
+
+	t.Run("ClosedAgain", func(t *testing.T) {
+		// Sunday 23:00, the window only opens on Saturdays
+		clock.Advance(22 * time.Hour)
+		q.Enqueue([]byte("later"))
+		if _, success := q.Dequeue(); success {
+			t.Error("Expected no delivery on Sunday night")
+		}
+	})
+
+	t.Run("Contains", func(t *testing.T) {
+		office := DeliveryWindow{Start: "09:00", End: "17:30"}
+		at := func(hour, minute int) time.Time {
+			return time.Date(2030, 1, 7, hour, minute, 0, 0, time.Local)
+		}
+		if !office.contains(at(9, 0)) || !office.contains(at(17, 29)) {
+			t.Error("Expected the window to include its start and last minute")
+		}
+		if office.contains(at(17, 30)) || office.contains(at(8, 59)) {
+			t.Error("Expected the window to exclude its end")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, window := range []DeliveryWindow{{Start: "25:00", End: "06:00"}, {Start: "08:00", End: "08:00"}, {Start: "night"}} {
+			if _, err := queues.NewQueue("test_invalid", WithDeliveryWindow(window)); !errors.Is(err, ErrInvalidOption) {
+				t.Errorf("Expected ErrInvalidOption for %+v, got %v", window, err)
+			}
+		}
+	})
+}